@@ -5,6 +5,7 @@ go 1.22.0
 toolchain go1.23.4
 
 require (
+	github.com/boombuler/barcode v1.0.2
 	github.com/dchest/uniuri v1.2.0
 	github.com/go-git/go-git/v5 v5.13.1
 	github.com/google/uuid v1.6.0
@@ -25,7 +26,6 @@ require (
 	github.com/Microsoft/go-winio v0.6.2 // indirect
 	github.com/ProtonMail/go-crypto v1.1.5 // indirect
 	github.com/acomagu/bufpipe v1.0.4 // indirect
-	github.com/boombuler/barcode v1.0.2 // indirect
 	github.com/cloudflare/circl v1.5.0 // indirect
 	github.com/cyphar/filepath-securejoin v0.4.0 // indirect
 	github.com/emirpasic/gods v1.18.1 // indirect
@@ -0,0 +1,341 @@
+package httphandlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/MeetPlan/MeetPlanBackend/helpers"
+	"github.com/MeetPlan/MeetPlanBackend/proton"
+	"github.com/MeetPlan/MeetPlanBackend/sql"
+	"github.com/gorilla/mux"
+	"net/http"
+	"time"
+)
+
+// Opozorilo o prenizki prisotnosti pri predmetu se sproži šele, ko je bilo izvedenih vsaj toliko ur predmeta.
+const ABSENCE_ALERT_MIN_MEETINGS = 10
+
+type SubjectAbsenceStats struct {
+	sql.SubjectAbsenceStats
+	SubjectName       string
+	Held              int
+	AttendancePercent float64
+}
+
+type AbsenceTotals struct {
+	Excused        int
+	Unexcused      int
+	Late           int
+	SchoolActivity int
+}
+
+type StudentAbsenceStats struct {
+	UserID   string
+	Name     string
+	Subjects []SubjectAbsenceStats
+	Totals   AbsenceTotals
+}
+
+type ClassAbsenceStats struct {
+	ClassID  string
+	Name     string
+	Students []StudentAbsenceStats
+	Totals   AbsenceTotals
+}
+
+func (totals *AbsenceTotals) add(excused int, unexcused int, late int, schoolActivity int) {
+	totals.Excused += excused
+	totals.Unexcused += unexcused
+	totals.Late += late
+	totals.SchoolActivity += schoolActivity
+}
+
+// getAbsenceStatsPeriod vrne obdobje statistike. Privzeto je to tekoče šolsko leto do danes.
+// Konec obdobja ni nikoli v prihodnosti, saj bodoča srečanja še niso bila izvedena.
+func getAbsenceStatsPeriod(r *http.Request) (string, string, bool) {
+	now := time.Now()
+	start := proton.FirstMondayOfSchoolYear(now)
+	end := now
+	var err error
+	if r.URL.Query().Get("start") != "" {
+		start, err = time.Parse("02-01-2006", r.URL.Query().Get("start"))
+		if err != nil {
+			return "", "", false
+		}
+	}
+	if r.URL.Query().Get("end") != "" {
+		end, err = time.Parse("02-01-2006", r.URL.Query().Get("end"))
+		if err != nil {
+			return "", "", false
+		}
+	}
+	if end.After(now) {
+		end = now
+	}
+	return start.Format("02-01-2006"), end.Format("02-01-2006"), true
+}
+
+func (server *httpImpl) getHeldMeetingCounts(start string, end string) (map[string]int, error) {
+	counts, err := server.db.GetHeldMeetingCounts(start, end)
+	if err != nil {
+		return nil, err
+	}
+	held := make(map[string]int)
+	for i := 0; i < len(counts); i++ {
+		held[counts[i].SubjectID] = counts[i].Held
+	}
+	return held, nil
+}
+
+// getStudentAbsenceStats sešteje odsotnosti učenca po predmetih, ki jih obiskuje.
+func (server *httpImpl) getStudentAbsenceStats(student sql.User, start string, end string, held map[string]int) (StudentAbsenceStats, error) {
+	stats := StudentAbsenceStats{UserID: student.ID, Name: student.Name, Subjects: make([]SubjectAbsenceStats, 0)}
+	subjects, err := server.db.GetAllSubjectsForUser(student.ID)
+	if err != nil {
+		return stats, err
+	}
+	absences, err := server.db.GetAbsenceStatsForUser(student.ID, start, end)
+	if err != nil {
+		return stats, err
+	}
+	for i := 0; i < len(subjects); i++ {
+		subject := subjects[i]
+		subjectStats := SubjectAbsenceStats{
+			SubjectAbsenceStats: sql.SubjectAbsenceStats{SubjectID: subject.ID},
+			SubjectName:         subject.LongName,
+			Held:                held[subject.ID],
+			AttendancePercent:   100,
+		}
+		for n := 0; n < len(absences); n++ {
+			if absences[n].SubjectID == subject.ID {
+				subjectStats.SubjectAbsenceStats = absences[n]
+				break
+			}
+		}
+		if subjectStats.Held != 0 {
+			present := subjectStats.Held - subjectStats.Excused - subjectStats.Unexcused
+			subjectStats.AttendancePercent = 100 * float64(present) / float64(subjectStats.Held)
+		}
+		stats.Subjects = append(stats.Subjects, subjectStats)
+		stats.Totals.add(subjectStats.Excused, subjectStats.Unexcused, subjectStats.Late, subjectStats.SchoolActivity)
+	}
+	return stats, nil
+}
+
+// canViewAbsencesOf preveri dostop do odsotnosti učenca po enakih pravilih kot GetAbsencesUser.
+func (server *httpImpl) canViewAbsencesOf(user sql.User, studentId string) (bool, error) {
+	if user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT || user.Role == SCHOOL_PSYCHOLOGIST {
+		return true, nil
+	}
+	if user.Role == PARENT && !server.config.ParentViewAbsences {
+		return false, nil
+	}
+	return server.canViewExcuses(user, studentId)
+}
+
+func (server *httpImpl) GetAbsenceStatsForStudent(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	studentId := mux.Vars(r)["student_id"]
+	ok, err := server.canViewAbsencesOf(user, studentId)
+	if err != nil {
+		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		WriteForbiddenJWT(w)
+		return
+	}
+	start, end, ok := getAbsenceStatsPeriod(r)
+	if !ok {
+		WriteBadRequest(w)
+		return
+	}
+	student, err := server.db.GetUser(studentId)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Could not fetch user", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	held, err := server.getHeldMeetingCounts(start, end)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while counting meetings", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	stats, err := server.getStudentAbsenceStats(student, start, end, held)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while computing absence statistics", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: stats, Success: true}, http.StatusOK)
+}
+
+func (server *httpImpl) GetAbsenceStatsForClass(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == TEACHER || user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT || user.Role == SCHOOL_PSYCHOLOGIST) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	class, err := server.db.GetClass(mux.Vars(r)["class_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Could not fetch class", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	if user.Role == TEACHER && class.Teacher != user.ID {
+		WriteForbiddenJWT(w)
+		return
+	}
+	start, end, ok := getAbsenceStatsPeriod(r)
+	if !ok {
+		WriteBadRequest(w)
+		return
+	}
+	var students []string
+	err = json.Unmarshal([]byte(class.Students), &students)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Could not unmarshal students", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	held, err := server.getHeldMeetingCounts(start, end)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while counting meetings", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	classStats := ClassAbsenceStats{ClassID: class.ID, Name: class.Name, Students: make([]StudentAbsenceStats, 0)}
+	for i := 0; i < len(students); i++ {
+		student, err := server.db.GetUser(students[i])
+		if err != nil {
+			WriteJSON(w, Response{Data: "Could not fetch user", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		stats, err := server.getStudentAbsenceStats(student, start, end, held)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while computing absence statistics", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		classStats.Students = append(classStats.Students, stats)
+		classStats.Totals.add(stats.Totals.Excused, stats.Totals.Unexcused, stats.Totals.Late, stats.Totals.SchoolActivity)
+	}
+	WriteJSON(w, Response{Data: classStats, Success: true}, http.StatusOK)
+}
+
+func (server *httpImpl) GetAbsenceAlertsForStudent(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	studentId := mux.Vars(r)["student_id"]
+	ok, err := server.canViewAbsencesOf(user, studentId)
+	if err != nil {
+		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		WriteForbiddenJWT(w)
+		return
+	}
+	alerts, err := server.db.GetAbsenceAlertsForUser(studentId)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving absence alerts", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: alerts, Success: true}, http.StatusOK)
+}
+
+// checkAbsenceAlerts preveri, ali je učenec v tekočem šolskem letu presegel katerega od pragov odsotnosti.
+// Ob prvem preseganju vsakega praga obvesti razrednika in starše.
+func (server *httpImpl) checkAbsenceAlerts(from string, studentId string) error {
+	if server.config.AbsenceAlertUnexcusedHours == 0 && server.config.AbsenceAlertTotalHours == 0 && server.config.MinAttendancePercent == 0 {
+		return nil
+	}
+	now := time.Now()
+	start := proton.FirstMondayOfSchoolYear(now).Format("02-01-2006")
+	end := now.Format("02-01-2006")
+
+	student, err := server.db.GetUser(studentId)
+	if err != nil {
+		return err
+	}
+	held, err := server.getHeldMeetingCounts(start, end)
+	if err != nil {
+		return err
+	}
+	stats, err := server.getStudentAbsenceStats(student, start, end, held)
+	if err != nil {
+		return err
+	}
+
+	messages := make([]string, 0)
+	alert := func(alertType int, subjectId *string, message string) error {
+		inserted, err := server.db.InsertAbsenceAlert(sql.AbsenceAlert{
+			UserID:      studentId,
+			AlertType:   alertType,
+			SubjectID:   subjectId,
+			PeriodStart: start,
+		})
+		if inserted {
+			messages = append(messages, message)
+		}
+		return err
+	}
+
+	if server.config.AbsenceAlertUnexcusedHours != 0 && stats.Totals.Unexcused >= server.config.AbsenceAlertUnexcusedHours {
+		err = alert(sql.ABSENCE_ALERT_UNEXCUSED, nil, fmt.Sprintf("Število neopravičenih ur je %d.", stats.Totals.Unexcused))
+		if err != nil {
+			return err
+		}
+	}
+	total := stats.Totals.Excused + stats.Totals.Unexcused
+	if server.config.AbsenceAlertTotalHours != 0 && total >= server.config.AbsenceAlertTotalHours {
+		err = alert(sql.ABSENCE_ALERT_TOTAL, nil, fmt.Sprintf("Skupno število ur odsotnosti je %d.", total))
+		if err != nil {
+			return err
+		}
+	}
+	if server.config.MinAttendancePercent != 0 {
+		for i := 0; i < len(stats.Subjects); i++ {
+			subject := stats.Subjects[i]
+			if subject.Held < ABSENCE_ALERT_MIN_MEETINGS || subject.AttendancePercent >= float64(server.config.MinAttendancePercent) {
+				continue
+			}
+			subjectId := subject.SubjectID
+			err = alert(sql.ABSENCE_ALERT_ATTENDANCE, &subjectId, fmt.Sprintf("Prisotnost pri predmetu %s je %.1f %%.", subject.SubjectName, subject.AttendancePercent))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+
+	recipients, err := server.getClassTeachersOf(studentId)
+	if err != nil {
+		return err
+	}
+	parents, err := server.db.GetParents()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(parents); i++ {
+		var children []string
+		err := json.Unmarshal([]byte(parents[i].Users), &children)
+		if err != nil {
+			return err
+		}
+		if helpers.Contains(children, studentId) {
+			recipients = append(recipients, parents[i].ID)
+		}
+	}
+	body := fmt.Sprintf("Učenec %s je presegel prag odsotnosti.", student.Name)
+	for i := 0; i < len(messages); i++ {
+		body = fmt.Sprintf("%s\n%s", body, messages[i])
+	}
+	return server.notifyUsers(from, recipients, "Opozorilo o odsotnosti", body)
+}
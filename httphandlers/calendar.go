@@ -0,0 +1,207 @@
+package httphandlers
+
+import (
+	"github.com/MeetPlan/MeetPlanBackend/proton"
+	"github.com/MeetPlan/MeetPlanBackend/sql"
+	"github.com/gorilla/mux"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (server *httpImpl) GetCalendar(w http.ResponseWriter, r *http.Request) {
+	_, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	events, err := server.db.GetCalendarEvents()
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the school calendar", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: events, Success: true}, http.StatusOK)
+}
+
+// optionalIntFormValue vrne nil, če vrednost v obrazcu ni podana.
+func optionalIntFormValue(r *http.Request, key string) (*int, error) {
+	value := r.FormValue(key)
+	if value == "" {
+		return nil, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+func (server *httpImpl) NewCalendarEvent(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	eventType, err := strconv.Atoi(r.FormValue("event_type"))
+	if err != nil || eventType < sql.CALENDAR_HOLIDAY || eventType > sql.CALENDAR_CLASS_EXCEPTION {
+		WriteBadRequest(w)
+		return
+	}
+	start, err := time.Parse("02-01-2006", r.FormValue("start_date"))
+	if err != nil {
+		WriteBadRequest(w)
+		return
+	}
+	endDate := r.FormValue("end_date")
+	if endDate == "" {
+		endDate = r.FormValue("start_date")
+	}
+	end, err := time.Parse("02-01-2006", endDate)
+	if err != nil || end.Before(start) {
+		WriteBadRequest(w)
+		return
+	}
+	runsAs, err := optionalIntFormValue(r, "runs_as")
+	if err != nil {
+		WriteBadRequest(w)
+		return
+	}
+	week, err := optionalIntFormValue(r, "week")
+	if err != nil {
+		WriteBadRequest(w)
+		return
+	}
+	term, err := optionalIntFormValue(r, "term")
+	if err != nil {
+		WriteBadRequest(w)
+		return
+	}
+
+	event := sql.CalendarEvent{
+		Name:      r.FormValue("name"),
+		EventType: eventType,
+		StartDate: start.Format("02-01-2006"),
+		EndDate:   end.Format("02-01-2006"),
+		RunsAs:    runsAs,
+		Week:      week,
+		Term:      term,
+	}
+
+	// Vsaka vrsta dogodka potrebuje svoje podatke.
+	if eventType == sql.CALENDAR_MAKEUP_DAY && (runsAs == nil || *runsAs < 0 || *runsAs > 4) {
+		WriteJSON(w, Response{Data: "Make-up days require runs_as between 0 (Monday) and 4 (Friday)", Success: false}, http.StatusBadRequest)
+		return
+	}
+	if eventType == sql.CALENDAR_WEEK && (week == nil || *week < 0 || *week > 1) {
+		WriteJSON(w, Response{Data: "Week designation requires week 0 (A) or 1 (B)", Success: false}, http.StatusBadRequest)
+		return
+	}
+	if eventType == sql.CALENDAR_TERM && (term == nil || *term < 1) {
+		WriteJSON(w, Response{Data: "Terms require a term number", Success: false}, http.StatusBadRequest)
+		return
+	}
+	if eventType == sql.CALENDAR_TERM && r.FormValue("closing_date") != "" {
+		closingDate, err := time.Parse("02-01-2006", r.FormValue("closing_date"))
+		if err != nil || closingDate.Before(end) {
+			WriteJSON(w, Response{Data: "The closing date must not be before the end of the term", Success: false}, http.StatusBadRequest)
+			return
+		}
+		c := closingDate.Format("02-01-2006")
+		event.ClosingDate = &c
+	}
+	if eventType == sql.CALENDAR_CLASS_EXCEPTION {
+		classId := r.FormValue("class_id")
+		_, err := server.db.GetClass(classId)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Class exceptions require an existing class", Error: err.Error(), Success: false}, http.StatusBadRequest)
+			return
+		}
+		event.ClassID = &classId
+	}
+
+	err = server.db.InsertCalendarEvent(event)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while inserting the calendar event", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusCreated)
+}
+
+func (server *httpImpl) DeleteCalendarEvent(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	err = server.db.DeleteCalendarEvent(mux.Vars(r)["event_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while deleting the calendar event", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusOK)
+}
+
+// ImportCalendar uvozi dogodke iz iCalendar datoteke (npr. koledar praznikov in počitnic ministrstva).
+// Vsi uvoženi dogodki dobijo vrsto event_type (privzeto praznik) in po želji razred class_id.
+func (server *httpImpl) ImportCalendar(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	eventType := sql.CALENDAR_HOLIDAY
+	if r.FormValue("event_type") != "" {
+		eventType, err = strconv.Atoi(r.FormValue("event_type"))
+		if err != nil || !(eventType == sql.CALENDAR_HOLIDAY || eventType == sql.CALENDAR_BREAK || eventType == sql.CALENDAR_CLASS_EXCEPTION) {
+			WriteJSON(w, Response{Data: "Only holidays, breaks and class exceptions can be imported", Success: false}, http.StatusBadRequest)
+			return
+		}
+	}
+	var classId *string
+	if eventType == sql.CALENDAR_CLASS_EXCEPTION {
+		id := r.FormValue("class_id")
+		_, err := server.db.GetClass(id)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Class exceptions require an existing class", Error: err.Error(), Success: false}, http.StatusBadRequest)
+			return
+		}
+		classId = &id
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while reading the uploaded file", Error: err.Error(), Success: false}, http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while reading the uploaded file", Error: err.Error(), Success: false}, http.StatusBadRequest)
+		return
+	}
+
+	events, err := proton.ParseCalendarICS(data, eventType, classId)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while parsing the calendar", Error: err.Error(), Success: false}, http.StatusBadRequest)
+		return
+	}
+	err = server.db.InsertCalendarEvents(events)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while inserting calendar events", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: events, Success: true}, http.StatusCreated)
+}
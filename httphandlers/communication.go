@@ -158,6 +158,31 @@ func (server *httpImpl) NewMessage(w http.ResponseWriter, r *http.Request) {
 	WriteJSON(w, Response{Success: true, Data: "OK"}, http.StatusCreated)
 }
 
+// notifyUsers ustvari nov pogovor med pošiljateljem in prejemniki ter vanj zapiše sporočilo.
+func (server *httpImpl) notifyUsers(from string, to []string, title string, body string) error {
+	people := make([]string, 0)
+	people = append(people, to...)
+	if !helpers.Contains(people, from) {
+		people = append(people, from)
+	}
+	users, err := json.Marshal(people)
+	if err != nil {
+		return err
+	}
+	comm := sql.Communication{
+		People:      string(users),
+		DateCreated: time.Now().String(),
+		Title:       title,
+	}
+	message := sql.Message{
+		UserID:      from,
+		Body:        body,
+		Seen:        fmt.Sprintf(`["%s"]`, fmt.Sprint(from)),
+		DateCreated: time.Now().String(),
+	}
+	return server.db.InsertCommunicationWithMessage(comm, message)
+}
+
 func (server *httpImpl) NewCommunication(w http.ResponseWriter, r *http.Request) {
 	user, err := server.db.CheckToken(GetAuthorizationToken(r))
 	if err != nil {
@@ -3,8 +3,10 @@ package httphandlers
 import (
 	"encoding/json"
 	"github.com/MeetPlan/MeetPlanBackend/sql"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 type ParentConfig struct {
@@ -73,11 +75,110 @@ func (server *httpImpl) UpdateConfiguration(w http.ResponseWriter, r *http.Reque
 		return
 	}
 	// admins, pls no shady business when patching dates, otherwise, system will not work anymore
-	err = json.Unmarshal([]byte(r.FormValue("school_free_days")), &server.config.SchoolFreeDays)
+	var schoolFreeDays []string
+	err = json.Unmarshal([]byte(r.FormValue("school_free_days")), &schoolFreeDays)
 	if err != nil {
 		WriteBadRequest(w)
 		return
 	}
+	// Nastavitve nadomeščanj in opozoril o odsotnosti niso obvezne, saj jih starejši odjemalci ne pošiljajo.
+	// Vse vrednosti najprej preverimo in jih v konfiguracijo zapišemo šele na koncu, da neveljavna zahteva ne
+	// pusti delno spremenjene konfiguracije.
+	fairnessWeight := server.config.SubstitutionFairnessWeight
+	if r.FormValue("substitution_fairness_weight") != "" {
+		weight, err := strconv.ParseFloat(r.FormValue("substitution_fairness_weight"), 32)
+		if err != nil || weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+			WriteBadRequest(w)
+			return
+		}
+		fairnessWeight = float32(weight)
+	}
+	maxSubstitutionsPerWeek := server.config.MaxSubstitutionsPerWeek
+	if r.FormValue("max_substitutions_per_week") != "" {
+		maxSubstitutions, err := strconv.Atoi(r.FormValue("max_substitutions_per_week"))
+		if err != nil || maxSubstitutions < 0 {
+			WriteBadRequest(w)
+			return
+		}
+		maxSubstitutionsPerWeek = maxSubstitutions
+	}
+	unexcusedHours := server.config.AbsenceAlertUnexcusedHours
+	if r.FormValue("absence_alert_unexcused_hours") != "" {
+		hours, err := strconv.Atoi(r.FormValue("absence_alert_unexcused_hours"))
+		if err != nil || hours < 0 {
+			WriteBadRequest(w)
+			return
+		}
+		unexcusedHours = hours
+	}
+	totalHours := server.config.AbsenceAlertTotalHours
+	if r.FormValue("absence_alert_total_hours") != "" {
+		hours, err := strconv.Atoi(r.FormValue("absence_alert_total_hours"))
+		if err != nil || hours < 0 {
+			WriteBadRequest(w)
+			return
+		}
+		totalHours = hours
+	}
+	minAttendancePercent := server.config.MinAttendancePercent
+	if r.FormValue("min_attendance_percent") != "" {
+		percent, err := strconv.ParseFloat(r.FormValue("min_attendance_percent"), 32)
+		// NaN ne izpolni nobene primerjave, zato mora biti pogoj zapisan kot obseg
+		if err != nil || !(percent >= 0 && percent <= 100) {
+			WriteBadRequest(w)
+			return
+		}
+		minAttendancePercent = float32(percent)
+	}
+	writtenAssessmentLimits := map[string]int{
+		"max_written_assessments_per_day":  server.config.MaxWrittenAssessmentsPerDay,
+		"max_written_assessments_per_week": server.config.MaxWrittenAssessmentsPerWeek,
+		"written_assessment_notice_days":   server.config.WrittenAssessmentNoticeDays,
+	}
+	for key := range writtenAssessmentLimits {
+		if r.FormValue(key) == "" {
+			continue
+		}
+		value, err := strconv.Atoi(r.FormValue(key))
+		if err != nil || value < 0 {
+			WriteBadRequest(w)
+			return
+		}
+		writtenAssessmentLimits[key] = value
+	}
+	verificationURL := server.config.VerificationURL
+	if _, ok := r.Form["verification_url"]; ok {
+		verificationURL = strings.TrimSpace(r.FormValue("verification_url"))
+	}
+	retention := server.config.DocumentRetentionDays
+	if _, ok := r.Form["document_retention_days"]; ok {
+		retention = nil
+		err = json.Unmarshal([]byte(r.FormValue("document_retention_days")), &retention)
+		if err != nil {
+			WriteBadRequest(w)
+			return
+		}
+		for _, days := range retention {
+			if days < 0 {
+				WriteBadRequest(w)
+				return
+			}
+		}
+	}
+
+	server.config.SchoolFreeDays = schoolFreeDays
+	server.config.SubstitutionFairnessWeight = fairnessWeight
+	server.config.MaxSubstitutionsPerWeek = maxSubstitutionsPerWeek
+	server.config.AbsenceAlertUnexcusedHours = unexcusedHours
+	server.config.AbsenceAlertTotalHours = totalHours
+	server.config.MinAttendancePercent = minAttendancePercent
+	server.config.MaxWrittenAssessmentsPerDay = writtenAssessmentLimits["max_written_assessments_per_day"]
+	server.config.MaxWrittenAssessmentsPerWeek = writtenAssessmentLimits["max_written_assessments_per_week"]
+	server.config.WrittenAssessmentNoticeDays = writtenAssessmentLimits["written_assessment_notice_days"]
+	server.config.VerificationURL = verificationURL
+	server.retentionMutex.Lock()
+	server.config.DocumentRetentionDays = retention
+	server.retentionMutex.Unlock()
 	server.config.SchoolPostCode = schoolPostCode
 	server.config.SchoolCountry = r.FormValue("school_country")
 	server.config.SchoolAddress = r.FormValue("school_address")
@@ -0,0 +1,370 @@
+package httphandlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"github.com/MeetPlan/MeetPlanBackend/helpers"
+	"github.com/MeetPlan/MeetPlanBackend/sql"
+	"github.com/gorilla/mux"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const DOCUMENT_BATCH_DIRECTORY = "documents/batches"
+
+// Vrste dokumentov, ki jih je mogoče izdati za celoten razred, in njihove predloge
+var batchDocumentTemplates = map[int]string{
+	SPRICEVALO:         TEMPLATE_SPRICEVALO,
+	POTRDILO_O_SOLANJU: TEMPLATE_POTRDILO_O_SOLANJU,
+}
+
+type DocumentBatchResponse struct {
+	sql.DocumentBatch
+	Documents []sql.Document
+}
+
+type batchDocument struct {
+	ID          string
+	StudentName string
+	Data        TemplateData
+	Err         error
+}
+
+// canIssueDocumentBatch preveri, ali lahko uporabnik izda dokumente vrste documentType za razred. Pravice so enake
+// kot pri izdaji posameznega dokumenta.
+func canIssueDocumentBatch(user sql.User, class sql.Class, documentType int) bool {
+	if user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT {
+		return true
+	}
+	if documentType == SPRICEVALO {
+		return user.Role == TEACHER && class.Teacher == user.ID
+	}
+	return documentType == POTRDILO_O_SOLANJU && user.Role == SCHOOL_PSYCHOLOGIST
+}
+
+func batchFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// renderBatchDocument izriše, podpiše in zabeleži dokument enega učenca v paketni izdaji.
+func (server *httpImpl) renderBatchDocument(batch sql.DocumentBatch, definition DocumentTemplateDefinition, class sql.Class, studentId string, withBackground bool) batchDocument {
+	student, err := server.db.GetUser(studentId)
+	if err != nil {
+		return batchDocument{Err: err}
+	}
+	document := batchDocument{ID: newDocumentID(), StudentName: student.Name}
+	if batch.DocumentType == SPRICEVALO {
+		document.Data, document.Err = server.certificateTemplateData(student, class, document.ID)
+	} else {
+		document.Data, document.Err = server.schoolingCertificateTemplateData(student, class, document.ID)
+	}
+	if document.Err != nil {
+		return document
+	}
+
+	pdf, err := newTemplatePdf(definition)
+	if err != nil {
+		document.Err = err
+		return document
+	}
+	document.Err = renderDocumentTemplate(pdf, definition, document.Data, withBackground)
+	if document.Err != nil {
+		return document
+	}
+	document.Err = helpers.Sign(pdf.GetBytesPdf(), fmt.Sprintf("documents/%s.pdf", document.ID), "cacerts/key-pair.p12", "")
+	if document.Err != nil {
+		return document
+	}
+	document.Err = server.insertSignedDocument(sql.Document{
+		ID:           document.ID,
+		ExportedBy:   batch.CreatedBy,
+		DocumentType: batch.DocumentType,
+		IsSigned:     true,
+		BatchID:      &batch.ID,
+		IssuedFor:    &student.ID,
+	})
+	return document
+}
+
+func (server *httpImpl) writeDocumentBatchZip(batch sql.DocumentBatch, documents []batchDocument) error {
+	file, err := os.Create(fmt.Sprintf("%s/%s.zip", DOCUMENT_BATCH_DIRECTORY, batch.ID))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	archive := zip.NewWriter(file)
+	for i := 0; i < len(documents); i++ {
+		signed, err := os.ReadFile(fmt.Sprintf("documents/%s.pdf", documents[i].ID))
+		if err != nil {
+			return err
+		}
+		entry, err := archive.Create(fmt.Sprintf("%02d_%s_%s.pdf", i+1, batchFileName(documents[i].StudentName), documents[i].ID))
+		if err != nil {
+			return err
+		}
+		_, err = entry.Write(signed)
+		if err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// revokeDocumentBatch prekliče že izdane dokumente neuspešne paketne izdaje in izbriše datoteke paketa. Neuspešna
+// izdaja ni bila predana naprej, zato njeni dokumenti ne smejo ostati veljavni.
+func (server *httpImpl) revokeDocumentBatch(batch sql.DocumentBatch) {
+	documents, err := server.db.GetDocumentsForBatch(batch.ID)
+	if err != nil {
+		server.logger.Errorw("failed to retrieve documents of a failed batch", "batch", batch.ID, "error", err.Error())
+		return
+	}
+	for _, document := range documents {
+		if document.IsRevoked {
+			continue
+		}
+		err = server.revokeDocument(document, batch.CreatedBy, fmt.Sprintf("Paketna izdaja %s ni uspela", batch.ID))
+		if err != nil {
+			server.logger.Errorw("failed to revoke a document of a failed batch", "batch", batch.ID, "document", document.ID, "error", err.Error())
+		}
+	}
+	for _, format := range []string{"pdf", "zip"} {
+		err = os.Remove(fmt.Sprintf("%s/%s.%s", DOCUMENT_BATCH_DIRECTORY, batch.ID, format))
+		if err != nil && !os.IsNotExist(err) {
+			server.logger.Errorw("failed to remove a file of a failed batch", "batch", batch.ID, "error", err.Error())
+		}
+	}
+}
+
+// runDocumentBatch v ozadju vzporedno izriše in podpiše dokumente vseh učencev, nato pa jih združi v en podpisan PDF
+// za tiskanje in ZIP arhiv posamično podpisanih dokumentov. Če izdaja ne uspe, se že izdani dokumenti prekličejo.
+func (server *httpImpl) runDocumentBatch(batch sql.DocumentBatch, class sql.Class, students []string, templateName string, withBackground bool) {
+	var documents []batchDocument
+	fail := func(err error) {
+		server.logger.Errorw("document batch failed", "batch", batch.ID, "error", err.Error())
+		// podpisani PDF-ji, ki niso bili zabeleženi v bazi, se izbrišejo, zabeleženi dokumenti pa prekličejo
+		for i := 0; i < len(documents); i++ {
+			if documents[i].Err == nil || documents[i].ID == "" {
+				continue
+			}
+			err := os.Remove(fmt.Sprintf("documents/%s.pdf", documents[i].ID))
+			if err != nil && !os.IsNotExist(err) {
+				server.logger.Errorw("failed to remove an unrecorded batch document", "batch", batch.ID, "document", documents[i].ID, "error", err.Error())
+			}
+		}
+		server.revokeDocumentBatch(batch)
+		batch.Status = sql.DOCUMENT_BATCH_FAILED
+		batch.Error = err.Error()
+		err = server.db.UpdateDocumentBatch(batch)
+		if err != nil {
+			server.logger.Errorw("failed to update the document batch", "batch", batch.ID, "error", err.Error())
+		}
+	}
+
+	definition, _, err := server.loadDocumentTemplate(templateName)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	documents = make([]batchDocument, len(students))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range jobs {
+				documents[n] = server.renderBatchDocument(batch, definition, class, students[n], withBackground)
+				if documents[n].Err == nil {
+					err := server.db.IncrementDocumentBatchProgress(batch.ID)
+					if err != nil {
+						server.logger.Errorw("failed to update the document batch progress", "batch", batch.ID, "error", err.Error())
+					}
+				}
+			}
+		}()
+	}
+	for n := 0; n < len(students); n++ {
+		jobs <- n
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i := 0; i < len(documents); i++ {
+		if documents[i].Err != nil {
+			fail(fmt.Errorf("%s: %s", students[i], documents[i].Err.Error()))
+			return
+		}
+	}
+
+	merged, err := newTemplatePdf(definition)
+	if err != nil {
+		fail(err)
+		return
+	}
+	for i := 0; i < len(documents); i++ {
+		err = renderDocumentTemplate(merged, definition, documents[i].Data, withBackground)
+		if err != nil {
+			fail(err)
+			return
+		}
+	}
+	err = helpers.Sign(merged.GetBytesPdf(), fmt.Sprintf("%s/%s.pdf", DOCUMENT_BATCH_DIRECTORY, batch.ID), "cacerts/key-pair.p12", "")
+	if err != nil {
+		fail(err)
+		return
+	}
+	err = server.writeDocumentBatchZip(batch, documents)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	batch.Status = sql.DOCUMENT_BATCH_DONE
+	batch.Completed = len(documents)
+	err = server.db.UpdateDocumentBatch(batch)
+	if err != nil {
+		server.logger.Errorw("failed to update the document batch", "batch", batch.ID, "error", err.Error())
+	}
+}
+
+// NewDocumentBatch začne paketno izdajo dokumentov (document_type) za vse učence razreda. Izdaja teče v ozadju,
+// njeno stanje pa je dostopno prek GetDocumentBatch.
+func (server *httpImpl) NewDocumentBatch(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	class, err := server.db.GetClass(mux.Vars(r)["class_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the class", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	documentType, err := strconv.Atoi(r.FormValue("document_type"))
+	if err != nil {
+		WriteBadRequest(w)
+		return
+	}
+	templateName, ok := batchDocumentTemplates[documentType]
+	if !ok {
+		WriteJSON(w, Response{Data: "This document type can't be issued for a whole class", Success: false}, http.StatusBadRequest)
+		return
+	}
+	if !canIssueDocumentBatch(user, class, documentType) {
+		WriteForbiddenJWT(w)
+		return
+	}
+
+	var students []string
+	err = json.Unmarshal([]byte(class.Students), &students)
+	if err != nil {
+		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	if len(students) == 0 {
+		WriteJSON(w, Response{Data: "Class has no students", Success: false}, http.StatusBadRequest)
+		return
+	}
+
+	batch := sql.DocumentBatch{
+		ClassID:      class.ID,
+		DocumentType: documentType,
+		Status:       sql.DOCUMENT_BATCH_RUNNING,
+		Total:        len(students),
+		CreatedBy:    user.ID,
+	}
+	batch.ID, err = server.db.InsertDocumentBatch(batch)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while inserting the batch", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	go server.runDocumentBatch(batch, class, students, templateName, server.config.Debug || r.FormValue("useDocument") == "true")
+
+	WriteJSON(w, Response{Data: batch, Success: true}, http.StatusAccepted)
+}
+
+func (server *httpImpl) getDocumentBatchForUser(w http.ResponseWriter, r *http.Request) (sql.DocumentBatch, bool) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return sql.DocumentBatch{}, false
+	}
+	batch, err := server.db.GetDocumentBatch(mux.Vars(r)["batch_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Batch not found", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return batch, false
+	}
+	if !(batch.CreatedBy == user.ID || user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return batch, false
+	}
+	return batch, true
+}
+
+func (server *httpImpl) GetDocumentBatch(w http.ResponseWriter, r *http.Request) {
+	batch, ok := server.getDocumentBatchForUser(w, r)
+	if !ok {
+		return
+	}
+	documents, err := server.db.GetDocumentsForBatch(batch.ID)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving documents", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: DocumentBatchResponse{DocumentBatch: batch, Documents: documents}, Success: true}, http.StatusOK)
+}
+
+// FailInterruptedDocumentBatches označi paketne izdaje, ki jih je prekinil ponovni zagon strežnika, kot neuspešne
+// in prekliče njihove že izdane dokumente.
+func (server *httpImpl) FailInterruptedDocumentBatches() {
+	batches, err := server.db.FailInterruptedDocumentBatches()
+	if err != nil {
+		server.logger.Errorw("failed to mark interrupted document batches", "error", err.Error())
+		return
+	}
+	for _, batch := range batches {
+		server.revokeDocumentBatch(batch)
+	}
+}
+
+// GetDocumentBatchFile vrne združen podpisan PDF (format=pdf) ali ZIP arhiv posamično podpisanih dokumentov (format=zip).
+func (server *httpImpl) GetDocumentBatchFile(w http.ResponseWriter, r *http.Request) {
+	batch, ok := server.getDocumentBatchForUser(w, r)
+	if !ok {
+		return
+	}
+	format := mux.Vars(r)["format"]
+	if !(format == "pdf" || format == "zip") {
+		WriteBadRequest(w)
+		return
+	}
+	if batch.Status != sql.DOCUMENT_BATCH_DONE {
+		WriteJSON(w, Response{Data: "Batch is not finished", Error: batch.Error, Success: false}, http.StatusConflict)
+		return
+	}
+	file, err := os.ReadFile(fmt.Sprintf("%s/%s.%s", DOCUMENT_BATCH_DIRECTORY, batch.ID, format))
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while reading the batch file", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	if format == "pdf" {
+		w.Header().Set("Content-Type", "application/pdf")
+	} else {
+		w.Header().Set("Content-Type", "application/zip")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"batch-%s.%s\"", batch.ID, format))
+	w.Write(file)
+}
@@ -0,0 +1,626 @@
+package httphandlers
+
+import (
+	sql2 "database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/MeetPlan/MeetPlanBackend/helpers"
+	"github.com/MeetPlan/MeetPlanBackend/sql"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/signintech/gopdf"
+	"image"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const TEMPLATE_SPRICEVALO = "spricevalo"
+const TEMPLATE_POTRDILO_O_SOLANJU = "potrdilo_o_solanju"
+const TEMPLATE_RESETIRANJE_GESLA = "resetiranje_gesla"
+const TEMPLATE_POTRDILO_O_SAMOTESTIRANJU = "potrdilo_o_samotestiranju"
+
+var documentTemplateNames = []string{TEMPLATE_SPRICEVALO, TEMPLATE_POTRDILO_O_SOLANJU, TEMPLATE_RESETIRANJE_GESLA, TEMPLATE_POTRDILO_O_SAMOTESTIRANJU}
+
+const TEMPLATE_FIELD_TEXT = "text"
+const TEMPLATE_FIELD_PARAGRAPH = "paragraph"
+const TEMPLATE_FIELD_LINE = "line"
+const TEMPLATE_FIELD_IMAGE = "image"
+const TEMPLATE_FIELD_SUBJECT = "subject"
+const TEMPLATE_FIELD_QR = "qr"
+
+var templateFieldTypes = []string{TEMPLATE_FIELD_TEXT, TEMPLATE_FIELD_PARAGRAPH, TEMPLATE_FIELD_LINE, TEMPLATE_FIELD_IMAGE, TEMPLATE_FIELD_SUBJECT, TEMPLATE_FIELD_QR}
+
+// Vgrajene predloge so shranjene kot officialdocs/templates/<ime>.json, naložene podlage pa v mapi templates.
+const BUILTIN_TEMPLATES_DIRECTORY = "officialdocs/templates"
+const UPLOADED_TEMPLATES_DIRECTORY = "templates"
+const DOCUMENT_TEMPLATE_MAX_BACKGROUND_SIZE = 10 << 20
+
+// Poti v predlogah (podlaga, pisave, slike) morajo biti relativne in znotraj teh map.
+var documentTemplateDirectories = []string{"officialdocs/", UPLOADED_TEMPLATES_DIRECTORY + "/", "fonts/", "icons/"}
+
+var templatePlaceholder = regexp.MustCompile(`\{\{\s*([a-z0-9_.]+)\s*\}\}`)
+
+type TemplateFont struct {
+	Name string
+	Path string
+}
+
+// TemplateField je eno polje predloge. Koordinate so v točkah od zgornjega levega kota strani A4 (595 x 842).
+//
+// Text lahko vsebuje podatkovne poti v obliki {{student.name}}. Polje vrste "subject" izpiše zaključno oceno
+// predmeta: predmet se izbere po dolgem imenu (Subjects, prvi ujemajoči se predmet) ali pa, če je Dynamic, se vzame
+// naslednji še neizpisan predmet. Če je podan NameX, se izpiše tudi ime predmeta. Polje vrste "qr" izriše QR kodo
+// z vsebino Text, običajno {{document.verification_url}}.
+type TemplateField struct {
+	Type string
+
+	X  float64
+	Y  float64
+	X2 float64
+	Y2 float64
+	// Širina odstavka oziroma velikost slike in QR kode
+	Width  float64
+	Height float64
+
+	Text       string
+	Font       string
+	FontSize   float64
+	Align      string
+	LineHeight float64
+	LineWidth  float64
+	Path       string
+
+	// Polje se izriše samo, če je vrednost podatkovne poti If enaka Equals (privzeto "true")
+	If     string
+	Equals string
+
+	Subjects []string
+	Dynamic  bool
+	NameX    *float64
+	Empty    string
+}
+
+type TemplatePage struct {
+	// Stran podlage, ki se izriše pod polja (0 - brez podlage)
+	BackgroundPage int
+	Fields         []TemplateField
+}
+
+// DocumentTemplateDefinition je definicija predloge uradnega dokumenta.
+type DocumentTemplateDefinition struct {
+	Name       string
+	Background string
+	// Podlaga se izriše samo na zahtevo (useDocument=true) ali v razvojnem načinu, saj se npr. spričevala tiskajo
+	// na že natisnjene uradne obrazce.
+	BackgroundOnRequest bool
+	Fonts               []TemplateFont
+	Font                string
+	FontSize            float64
+	Pages               []TemplatePage
+}
+
+type TemplateSubject struct {
+	LongName string
+	Grade    string
+}
+
+type TemplateData struct {
+	Values   map[string]string
+	Subjects []TemplateSubject
+}
+
+type DocumentTemplateInfo struct {
+	Name          string
+	ActiveVersion int
+	Versions      []sql.DocumentTemplate
+}
+
+type DocumentTemplateResponse struct {
+	Name       string
+	Version    int
+	Definition DocumentTemplateDefinition
+}
+
+func isDocumentTemplatePath(path string) bool {
+	if filepath.IsAbs(path) {
+		return false
+	}
+	clean := filepath.ToSlash(filepath.Clean(path))
+	for i := 0; i < len(documentTemplateDirectories); i++ {
+		if strings.HasPrefix(clean, documentTemplateDirectories[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func fillTemplateText(text string, values map[string]string) string {
+	return templatePlaceholder.ReplaceAllStringFunc(text, func(s string) string {
+		return values[templatePlaceholder.FindStringSubmatch(s)[1]]
+	})
+}
+
+// validateDocumentTemplate preveri definicijo predloge in jo poskusno izriše s praznimi podatki.
+func validateDocumentTemplate(definition DocumentTemplateDefinition) error {
+	if len(definition.Pages) == 0 {
+		return errors.New("template has no pages")
+	}
+	if definition.Background != "" && !isDocumentTemplatePath(definition.Background) {
+		return fmt.Errorf("invalid background path %s", definition.Background)
+	}
+	var fonts = make([]string, 0)
+	for i := 0; i < len(definition.Fonts); i++ {
+		if !isDocumentTemplatePath(definition.Fonts[i].Path) {
+			return fmt.Errorf("invalid font path %s", definition.Fonts[i].Path)
+		}
+		fonts = append(fonts, definition.Fonts[i].Name)
+	}
+	if !helpers.Contains(fonts, definition.Font) {
+		return fmt.Errorf("unknown font %s", definition.Font)
+	}
+	for p := 0; p < len(definition.Pages); p++ {
+		for f := 0; f < len(definition.Pages[p].Fields); f++ {
+			field := definition.Pages[p].Fields[f]
+			if !helpers.Contains(templateFieldTypes, field.Type) {
+				return fmt.Errorf("unknown field type %s on page %d", field.Type, p+1)
+			}
+			if field.Font != "" && !helpers.Contains(fonts, field.Font) {
+				return fmt.Errorf("unknown font %s on page %d", field.Font, p+1)
+			}
+			if field.Type == TEMPLATE_FIELD_IMAGE && !isDocumentTemplatePath(field.Path) {
+				return fmt.Errorf("invalid image path %s on page %d", field.Path, p+1)
+			}
+		}
+	}
+	pdf, err := newTemplatePdf(definition)
+	if err != nil {
+		return err
+	}
+	return renderDocumentTemplate(pdf, definition, TemplateData{Values: make(map[string]string)}, true)
+}
+
+func newTemplatePdf(definition DocumentTemplateDefinition) (*gopdf.GoPdf, error) {
+	pdf := &gopdf.GoPdf{}
+	pdf.Start(gopdf.Config{PageSize: *gopdf.PageSizeA4})
+	for i := 0; i < len(definition.Fonts); i++ {
+		err := pdf.AddTTFFont(definition.Fonts[i].Name, definition.Fonts[i].Path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pdf, nil
+}
+
+// assignTemplateSubjects razporedi predmete po poljih vrste "subject". Najprej se zapolnijo polja z navedenimi
+// predmeti, nato pa še dinamična polja s preostalimi predmeti v vrstnem redu.
+func assignTemplateSubjects(definition DocumentTemplateDefinition, subjects []TemplateSubject) map[[2]int]int {
+	assigned := make(map[[2]int]int)
+	used := make(map[int]bool)
+	for _, dynamic := range []bool{false, true} {
+		for p := 0; p < len(definition.Pages); p++ {
+			for f := 0; f < len(definition.Pages[p].Fields); f++ {
+				field := definition.Pages[p].Fields[f]
+				if field.Type != TEMPLATE_FIELD_SUBJECT || field.Dynamic != dynamic {
+					continue
+				}
+				assigned[[2]int{p, f}] = -1
+				for s := 0; s < len(subjects); s++ {
+					if used[s] || !(dynamic || helpers.Contains(field.Subjects, subjects[s].LongName)) {
+						continue
+					}
+					assigned[[2]int{p, f}] = s
+					used[s] = true
+					break
+				}
+			}
+		}
+	}
+	return assigned
+}
+
+func drawTemplateText(pdf *gopdf.GoPdf, x float64, y float64, align string, text string) error {
+	if align == "center" || align == "right" {
+		width, err := pdf.MeasureTextWidth(text)
+		if err != nil {
+			return err
+		}
+		if align == "center" {
+			x -= width / 2
+		} else {
+			x -= width
+		}
+	}
+	pdf.SetXY(x, y)
+	return pdf.Cell(nil, text)
+}
+
+// renderDocumentTemplate izriše vse strani predloge s podanimi podatki v pdf.
+func renderDocumentTemplate(pdf *gopdf.GoPdf, definition DocumentTemplateDefinition, data TemplateData, withBackground bool) (err error) {
+	// uvoz podlage ob neveljavni datoteki ali strani sproži panic
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed while rendering the template: %v", r)
+		}
+	}()
+
+	assigned := assignTemplateSubjects(definition, data.Subjects)
+	for p := 0; p < len(definition.Pages); p++ {
+		page := definition.Pages[p]
+		pdf.AddPage()
+		if definition.Background != "" && page.BackgroundPage > 0 && (withBackground || !definition.BackgroundOnRequest) {
+			tpl := pdf.ImportPage(definition.Background, page.BackgroundPage, "/MediaBox")
+			pdf.UseImportedTemplate(tpl, 0, 0, 595, 0)
+		}
+		for f := 0; f < len(page.Fields); f++ {
+			field := page.Fields[f]
+			if field.If != "" {
+				equals := field.Equals
+				if equals == "" {
+					equals = "true"
+				}
+				if data.Values[field.If] != equals {
+					continue
+				}
+			}
+
+			font := field.Font
+			if font == "" {
+				font = definition.Font
+			}
+			size := field.FontSize
+			if size == 0 {
+				size = definition.FontSize
+			}
+			err = pdf.SetFont(font, "", size)
+			if err != nil {
+				return err
+			}
+
+			switch field.Type {
+			case TEMPLATE_FIELD_TEXT:
+				err = drawTemplateText(pdf, field.X, field.Y, field.Align, fillTemplateText(field.Text, data.Values))
+			case TEMPLATE_FIELD_PARAGRAPH:
+				var lines []string
+				lines, err = pdf.SplitText(fillTemplateText(field.Text, data.Values), field.Width)
+				if err != nil {
+					return err
+				}
+				lineHeight := field.LineHeight
+				if lineHeight == 0 {
+					lineHeight = size * 1.3
+				}
+				for i := 0; i < len(lines); i++ {
+					err = drawTemplateText(pdf, field.X, field.Y+float64(i)*lineHeight, field.Align, lines[i])
+					if err != nil {
+						return err
+					}
+				}
+			case TEMPLATE_FIELD_LINE:
+				lineWidth := field.LineWidth
+				if lineWidth == 0 {
+					lineWidth = 1
+				}
+				pdf.SetLineWidth(lineWidth)
+				pdf.SetLineType("full")
+				pdf.Line(field.X, field.Y, field.X2, field.Y2)
+			case TEMPLATE_FIELD_IMAGE:
+				err = pdf.Image(field.Path, field.X, field.Y, &gopdf.Rect{W: field.Width, H: field.Height})
+			case TEMPLATE_FIELD_QR:
+				text := fillTemplateText(field.Text, data.Values)
+				if text == "" {
+					continue
+				}
+				var code image.Image
+				code, err = verificationQRCode(text, 256)
+				if err != nil {
+					return err
+				}
+				err = pdf.ImageFrom(code, field.X, field.Y, &gopdf.Rect{W: field.Width, H: field.Height})
+			case TEMPLATE_FIELD_SUBJECT:
+				grade := field.Empty
+				if grade == "" {
+					grade = "/"
+				}
+				if s := assigned[[2]int{p, f}]; s != -1 {
+					grade = data.Subjects[s].Grade
+					if field.NameX != nil {
+						err = drawTemplateText(pdf, *field.NameX, field.Y, "", data.Subjects[s].LongName)
+						if err != nil {
+							return err
+						}
+					}
+				}
+				err = drawTemplateText(pdf, field.X, field.Y, field.Align, grade)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadDocumentTemplate vrne aktivno različico predloge oziroma vgrajeno predlogo (različica 0).
+func (server *httpImpl) loadDocumentTemplate(name string) (DocumentTemplateDefinition, int, error) {
+	var definition DocumentTemplateDefinition
+	template, err := server.db.GetActiveDocumentTemplate(name)
+	if err == nil {
+		err = json.Unmarshal([]byte(template.Definition), &definition)
+		return definition, template.Version, err
+	}
+	if !errors.Is(err, sql2.ErrNoRows) {
+		return definition, 0, err
+	}
+	file, err := os.ReadFile(fmt.Sprintf("%s/%s.json", BUILTIN_TEMPLATES_DIRECTORY, name))
+	if err != nil {
+		return definition, 0, err
+	}
+	err = json.Unmarshal(file, &definition)
+	return definition, 0, err
+}
+
+// renderDocument izriše aktivno različico predloge in vrne nepodpisan PDF.
+func (server *httpImpl) renderDocument(name string, data TemplateData, withBackground bool) ([]byte, error) {
+	definition, _, err := server.loadDocumentTemplate(name)
+	if err != nil {
+		return nil, err
+	}
+	pdf, err := newTemplatePdf(definition)
+	if err != nil {
+		return nil, err
+	}
+	err = renderDocumentTemplate(pdf, definition, data, withBackground)
+	if err != nil {
+		return nil, err
+	}
+	return pdf.GetBytesPdf(), nil
+}
+
+func (server *httpImpl) schoolTemplateValues() map[string]string {
+	return map[string]string{
+		"school.name":      server.config.SchoolName,
+		"school.address":   server.config.SchoolAddress,
+		"school.post_code": fmt.Sprint(server.config.SchoolPostCode),
+		"school.city":      server.config.SchoolCity,
+		"school.country":   server.config.SchoolCountry,
+	}
+}
+
+// documentTemplateValues vrne podatke o šoli in dokumentu, ki so na voljo v vseh predlogah.
+func (server *httpImpl) documentTemplateValues(id string) map[string]string {
+	values := server.schoolTemplateValues()
+	values["document.id"] = id
+	values["document.verification_url"] = server.documentVerificationURL(id)
+	return values
+}
+
+func addUserTemplateValues(values map[string]string, prefix string, user sql.User) {
+	values[prefix+".id"] = user.ID
+	values[prefix+".name"] = user.Name
+	values[prefix+".email"] = user.Email
+	values[prefix+".role"] = user.Role
+	values[prefix+".birthday"] = user.Birthday
+	values[prefix+".city_of_birth"] = user.CityOfBirth
+	values[prefix+".country_of_birth"] = user.CountryOfBirth
+	values[prefix+".birth_certificate_number"] = user.BirthCertificateNumber
+	values[prefix+".is_passing"] = fmt.Sprint(user.IsPassing)
+}
+
+func addClassTemplateValues(values map[string]string, class sql.Class) {
+	values["class.name"] = class.Name
+	values["class.year"] = class.ClassYear
+	values["class.sok"] = fmt.Sprint(class.SOK)
+	values["class.eok"] = fmt.Sprint(class.EOK)
+}
+
+func (server *httpImpl) GetDocumentTemplates(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if user.Role != ADMIN {
+		WriteForbiddenJWT(w)
+		return
+	}
+	var templates = make([]DocumentTemplateInfo, 0)
+	for i := 0; i < len(documentTemplateNames); i++ {
+		versions, err := server.db.GetDocumentTemplates(documentTemplateNames[i])
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while retrieving templates", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		info := DocumentTemplateInfo{Name: documentTemplateNames[i], Versions: versions}
+		for n := 0; n < len(versions); n++ {
+			if versions[n].IsActive {
+				info.ActiveVersion = versions[n].Version
+			}
+		}
+		templates = append(templates, info)
+	}
+	WriteJSON(w, Response{Data: templates, Success: true}, http.StatusOK)
+}
+
+// GetDocumentTemplate vrne definicijo predloge. Brez parametra version vrne trenutno aktivno različico,
+// version=0 pa vgrajeno predlogo.
+func (server *httpImpl) GetDocumentTemplate(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if user.Role != ADMIN {
+		WriteForbiddenJWT(w)
+		return
+	}
+	name := mux.Vars(r)["name"]
+	if !helpers.Contains(documentTemplateNames, name) {
+		WriteJSON(w, Response{Data: "Unknown template", Success: false}, http.StatusNotFound)
+		return
+	}
+
+	var definition DocumentTemplateDefinition
+	var version = 0
+	v := r.URL.Query().Get("version")
+	if v == "" {
+		definition, version, err = server.loadDocumentTemplate(name)
+	} else {
+		version, err = strconv.Atoi(v)
+		if err != nil {
+			WriteBadRequest(w)
+			return
+		}
+		if version == 0 {
+			var file []byte
+			file, err = os.ReadFile(fmt.Sprintf("%s/%s.json", BUILTIN_TEMPLATES_DIRECTORY, name))
+			if err == nil {
+				err = json.Unmarshal(file, &definition)
+			}
+		} else {
+			var template sql.DocumentTemplate
+			template, err = server.db.GetDocumentTemplate(name, version)
+			if err != nil {
+				WriteJSON(w, Response{Data: "Template version not found", Error: err.Error(), Success: false}, http.StatusNotFound)
+				return
+			}
+			err = json.Unmarshal([]byte(template.Definition), &definition)
+		}
+	}
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the template", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: DocumentTemplateResponse{Name: name, Version: version, Definition: definition}, Success: true}, http.StatusOK)
+}
+
+// NewDocumentTemplateVersion naloži novo različico predloge. Definicija je v polju definition, neobvezna PDF
+// podlaga pa v datoteki background. Z activate=true postane nova različica takoj aktivna.
+func (server *httpImpl) NewDocumentTemplateVersion(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if user.Role != ADMIN {
+		WriteForbiddenJWT(w)
+		return
+	}
+	name := mux.Vars(r)["name"]
+	if !helpers.Contains(documentTemplateNames, name) {
+		WriteJSON(w, Response{Data: "Unknown template", Success: false}, http.StatusNotFound)
+		return
+	}
+
+	var definition DocumentTemplateDefinition
+	err = json.Unmarshal([]byte(r.FormValue("definition")), &definition)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Invalid template definition", Error: err.Error(), Success: false}, http.StatusBadRequest)
+		return
+	}
+	definition.Name = name
+
+	var background *string
+	file, header, err := r.FormFile("background")
+	if err == nil {
+		defer file.Close()
+		if strings.ToLower(filepath.Ext(header.Filename)) != ".pdf" || header.Size > DOCUMENT_TEMPLATE_MAX_BACKGROUND_SIZE {
+			WriteJSON(w, Response{Data: "Unsupported background", Success: false}, http.StatusBadRequest)
+			return
+		}
+		document, err := io.ReadAll(file)
+		if err != nil {
+			WriteBadRequest(w)
+			return
+		}
+		if !strings.HasPrefix(string(document), "%PDF-") {
+			WriteJSON(w, Response{Data: "Unsupported background", Success: false}, http.StatusBadRequest)
+			return
+		}
+		path := fmt.Sprintf("%s/%s.pdf", UPLOADED_TEMPLATES_DIRECTORY, uuid.New().String())
+		err = os.WriteFile(path, document, 0600)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while saving the background", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		definition.Background = path
+		background = &path
+	} else if err != http.ErrMissingFile {
+		WriteBadRequest(w)
+		return
+	}
+
+	err = validateDocumentTemplate(definition)
+	if err != nil {
+		if background != nil {
+			os.Remove(*background)
+		}
+		WriteJSON(w, Response{Data: "Invalid template definition", Error: err.Error(), Success: false}, http.StatusBadRequest)
+		return
+	}
+
+	marshal, err := json.Marshal(definition)
+	if err != nil {
+		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	version, err := server.db.InsertDocumentTemplate(sql.DocumentTemplate{
+		Name:       name,
+		Definition: string(marshal),
+		Background: background,
+		CreatedBy:  user.ID,
+	})
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while inserting the template", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	if r.FormValue("activate") == "true" {
+		err = server.db.ActivateDocumentTemplate(name, version)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while activating the template", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+	}
+	WriteJSON(w, Response{Data: version, Success: true}, http.StatusCreated)
+}
+
+// ActivateDocumentTemplate nastavi aktivno različico predloge. Različica 0 pomeni vgrajeno predlogo.
+func (server *httpImpl) ActivateDocumentTemplate(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if user.Role != ADMIN {
+		WriteForbiddenJWT(w)
+		return
+	}
+	name := mux.Vars(r)["name"]
+	if !helpers.Contains(documentTemplateNames, name) {
+		WriteJSON(w, Response{Data: "Unknown template", Success: false}, http.StatusNotFound)
+		return
+	}
+	version, err := strconv.Atoi(r.FormValue("version"))
+	if err != nil || version < 0 {
+		WriteBadRequest(w)
+		return
+	}
+	if version != 0 {
+		_, err = server.db.GetDocumentTemplate(name, version)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Template version not found", Error: err.Error(), Success: false}, http.StatusNotFound)
+			return
+		}
+	}
+	err = server.db.ActivateDocumentTemplate(name, version)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while activating the template", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusOK)
+}
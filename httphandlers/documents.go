@@ -1,16 +1,211 @@
 package httphandlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"github.com/MeetPlan/MeetPlanBackend/sql"
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+	"github.com/dchest/uniuri"
+	"github.com/gorilla/mux"
+	"github.com/signintech/gopdf"
+	"image"
+	"image/draw"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const SPRICEVALO = 0
 const POTRDILO_O_SOLANJU = 1
 const RESETIRANJE_GESLA = 2
 const POTRDILO_O_SAMOTESTIRANJU = 3
+const POROCILO_O_NADOMESCANJIH = 4
+const ZAPISNIK_REDOVALNE_KONFERENCE = 5
+
+var documentTypeNames = map[int]string{
+	SPRICEVALO:                    "spričevalo",
+	POTRDILO_O_SOLANJU:            "potrdilo o šolanju",
+	RESETIRANJE_GESLA:             "resetiranje gesla",
+	POTRDILO_O_SAMOTESTIRANJU:     "potrdilo o samotestiranju",
+	POROCILO_O_NADOMESCANJIH:      "poročilo o nadomeščanjih",
+	ZAPISNIK_REDOVALNE_KONFERENCE: "zapisnik redovalne konference",
+}
+
+// Mapa, v katero se premaknejo PDF-ji preklicanih dokumentov
+const DOCUMENT_ARCHIVE_DIRECTORY = "documents/archive"
+
+// Kako pogosto se preverjajo roki hrambe dokumentov
+const DOCUMENT_RETENTION_INTERVAL = 24 * time.Hour
+
+// Identifikatorji dokumentov so kratki, saj se izpišejo na dokument, kjer je malo prostora.
+const DOCUMENT_ID_LENGTH = 10
+
+func newDocumentID() string {
+	return uniuri.NewLen(DOCUMENT_ID_LENGTH)
+}
+
+// documentVerificationURL vrne vsebino QR kode, s katero lahko tretja oseba preveri dokument.
+func (server *httpImpl) documentVerificationURL(id string) string {
+	if server.config.VerificationURL == "" {
+		return id
+	}
+	return fmt.Sprintf("%s/%s", strings.TrimRight(server.config.VerificationURL, "/"), id)
+}
+
+// verificationQRCode vrne QR kodo kot 8-bitno sivinsko sliko, saj gopdf 16-bitnih slik ne podpira.
+func verificationQRCode(content string, size int) (image.Image, error) {
+	code, err := qr.Encode(content, qr.M, qr.Auto)
+	if err != nil {
+		return nil, err
+	}
+	code, err = barcode.Scale(code, size, size)
+	if err != nil {
+		return nil, err
+	}
+	gray := image.NewGray(code.Bounds())
+	draw.Draw(gray, gray.Bounds(), code, code.Bounds().Min, draw.Src)
+	return gray, nil
+}
+
+// drawVerificationCode izriše QR kodo za preverjanje dokumenta in pod njo identifikator dokumenta.
+func (server *httpImpl) drawVerificationCode(pdf *gopdf.GoPdf, id string, x float64, y float64, size float64) error {
+	code, err := verificationQRCode(server.documentVerificationURL(id), 256)
+	if err != nil {
+		return err
+	}
+	err = pdf.ImageFrom(code, x, y, &gopdf.Rect{W: size, H: size})
+	if err != nil {
+		return err
+	}
+	pdf.SetXY(x, y+size)
+	return pdf.Cell(nil, id)
+}
+
+// insertSignedDocument zabeleži že podpisan dokument (documents/<id>.pdf) skupaj z zgoščeno vrednostjo datoteke.
+// Če dokument nadomešča starejšega, se starejši prekliče.
+func (server *httpImpl) insertSignedDocument(document sql.Document) error {
+	file, err := os.ReadFile(fmt.Sprintf("documents/%s.pdf", document.ID))
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(file)
+	document.SHA256 = hex.EncodeToString(hash[:])
+	err = server.db.InsertDocument(document)
+	if err != nil {
+		return err
+	}
+	if document.Supersedes == nil {
+		return nil
+	}
+	superseded, err := server.db.GetDocument(*document.Supersedes)
+	if err != nil {
+		return err
+	}
+	return server.revokeDocument(superseded, document.ExportedBy, fmt.Sprintf("Nadomeščen z dokumentom %s", document.ID))
+}
+
+// supersededDocument vrne identifikator dokumenta, ki ga nadomešča ponovna izdaja (parameter supersedes), ali nil,
+// če ne gre za ponovno izdajo. Ponovna izdaja prekliče nadomeščeni dokument, zato jo lahko opravi le, kdor sme
+// preklicati dokumente. Nadomestiti je mogoče le veljaven dokument iste vrste, izdan istemu uporabniku (issuedFor).
+// Če dokumenta ni mogoče nadomestiti, zapiše odgovor in vrne false.
+func (server *httpImpl) supersededDocument(w http.ResponseWriter, r *http.Request, user sql.User, documentType int, issuedFor string) (*string, bool) {
+	id := r.FormValue("supersedes")
+	if id == "" {
+		return nil, true
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return nil, false
+	}
+	document, err := server.db.GetDocument(id)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Superseded document not found", Error: err.Error(), Success: false}, http.StatusBadRequest)
+		return nil, false
+	}
+	if document.DocumentType != documentType {
+		WriteJSON(w, Response{Data: "Invalid superseded document", Error: "superseded document is of a different type", Success: false}, http.StatusBadRequest)
+		return nil, false
+	}
+	// Dokumenti, izdani pred beleženjem prejemnika, nimajo issued_for in jih je treba preklicati ročno.
+	if document.IssuedFor == nil || *document.IssuedFor != issuedFor {
+		WriteJSON(w, Response{Data: "Invalid superseded document", Error: "superseded document was issued for a different user", Success: false}, http.StatusBadRequest)
+		return nil, false
+	}
+	if document.IsRevoked {
+		WriteJSON(w, Response{Data: "Invalid superseded document", Error: "superseded document is already revoked", Success: false}, http.StatusConflict)
+		return nil, false
+	}
+	return &document.ID, true
+}
+
+func documentFilePath(document sql.Document) string {
+	if document.IsRevoked {
+		return fmt.Sprintf("%s/%s.pdf", DOCUMENT_ARCHIVE_DIRECTORY, document.ID)
+	}
+	return fmt.Sprintf("documents/%s.pdf", document.ID)
+}
+
+// revokeDocument prekliče dokument in njegov PDF premakne v arhiv. Zapis o dokumentu ostane v bazi.
+func (server *httpImpl) revokeDocument(document sql.Document, revokedBy string, reason string) error {
+	err := os.Rename(documentFilePath(document), fmt.Sprintf("%s/%s.pdf", DOCUMENT_ARCHIVE_DIRECTORY, document.ID))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return server.db.RevokeDocument(document.ID, revokedBy, reason)
+}
+
+// documentRetentionDays vrne kopijo rokov hrambe, saj jih lahko UpdateConfiguration med brisanjem spremeni.
+func (server *httpImpl) documentRetentionDays() map[int]int {
+	server.retentionMutex.RLock()
+	defer server.retentionMutex.RUnlock()
+	retention := make(map[int]int, len(server.config.DocumentRetentionDays))
+	for documentType, days := range server.config.DocumentRetentionDays {
+		retention[documentType] = days
+	}
+	return retention
+}
+
+// DeleteExpiredDocuments izbriše dokumente (zapis in PDF), ki jim je potekel rok hrambe. Dokumenti pod pravnim
+// zadržanjem se ne brišejo.
+func (server *httpImpl) DeleteExpiredDocuments() {
+	for documentType, days := range server.documentRetentionDays() {
+		if days <= 0 {
+			continue
+		}
+		documents, err := server.db.GetExpiredDocuments(documentType, days)
+		if err != nil {
+			server.logger.Errorw("failed to retrieve expired documents", "document_type", documentType, "error", err.Error())
+			continue
+		}
+		for _, document := range documents {
+			err = os.Remove(documentFilePath(document))
+			if err != nil && !os.IsNotExist(err) {
+				server.logger.Errorw("failed to delete an expired document", "document", document.ID, "error", err.Error())
+				continue
+			}
+			err = server.db.DeleteDocument(document.ID)
+			if err != nil {
+				server.logger.Errorw("failed to delete an expired document", "document", document.ID, "error", err.Error())
+				continue
+			}
+			server.logger.Infow("deleted an expired document", "document", document.ID, "document_type", documentType)
+		}
+	}
+}
+
+// RunDocumentRetention ob zagonu in nato enkrat dnevno izbriše dokumente s pretečenim rokom hrambe.
+func (server *httpImpl) RunDocumentRetention() {
+	ticker := time.NewTicker(DOCUMENT_RETENTION_INTERVAL)
+	defer ticker.Stop()
+	for {
+		server.DeleteExpiredDocuments()
+		<-ticker.C
+	}
+}
 
 type Document struct {
 	sql.Document
@@ -46,7 +241,9 @@ func (server *httpImpl) FetchAllDocuments(w http.ResponseWriter, r *http.Request
 	WriteJSON(w, Response{Data: documentsJson, Success: true}, http.StatusOK)
 }
 
-func (server *httpImpl) DeleteDocument(w http.ResponseWriter, r *http.Request) {
+// RevokeDocument prekliče dokument z obveznim razlogom. Dokument ostane zabeležen (in ga je mogoče preveriti kot
+// preklicanega), PDF pa se premakne v arhiv.
+func (server *httpImpl) RevokeDocument(w http.ResponseWriter, r *http.Request) {
 	user, err := server.db.CheckToken(GetAuthorizationToken(r))
 	if err != nil {
 		WriteForbiddenJWT(w)
@@ -57,21 +254,58 @@ func (server *httpImpl) DeleteDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	documentId := r.FormValue("documentId")
-
-	document, err := server.db.GetDocument(documentId)
+	document, err := server.db.GetDocument(mux.Vars(r)["document_id"])
 	if err != nil {
 		WriteJSON(w, Response{Data: "Document not found", Error: err.Error(), Success: false}, http.StatusNotFound)
 		return
 	}
+	if document.IsRevoked {
+		WriteJSON(w, Response{Data: "Document is already revoked", Success: false}, http.StatusConflict)
+		return
+	}
+	reason := strings.TrimSpace(r.FormValue("reason"))
+	if reason == "" {
+		WriteJSON(w, Response{Data: "Revocation reason is required", Success: false}, http.StatusBadRequest)
+		return
+	}
+
+	err = server.revokeDocument(document, user.ID, reason)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while revoking the document", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusOK)
+}
 
-	err = os.Remove(fmt.Sprintf("documents/%s.pdf", document.ID))
+// SetDocumentLegalHold nastavi ali odstrani pravno zadržanje, ki prepreči brisanje dokumenta ob poteku roka hrambe.
+func (server *httpImpl) SetDocumentLegalHold(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
 	if err != nil {
-		WriteJSON(w, Response{Data: "Failed while deleting the document", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
 		return
 	}
 
-	server.db.DeleteDocument(documentId)
+	document, err := server.db.GetDocument(mux.Vars(r)["document_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Document not found", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	legalHold, err := strconv.ParseBool(r.FormValue("legal_hold"))
+	if err != nil {
+		WriteBadRequest(w)
+		return
+	}
+
+	err = server.db.SetDocumentLegalHold(document.ID, legalHold)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while updating the document", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
 
 	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusOK)
 }
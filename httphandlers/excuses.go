@@ -0,0 +1,418 @@
+package httphandlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/MeetPlan/MeetPlanBackend/helpers"
+	"github.com/MeetPlan/MeetPlanBackend/sql"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Največja velikost naloženega zdravniškega opravičila
+const EXCUSE_MAX_DOCUMENT_SIZE = 10 << 20
+
+var excuseDocumentExtensions = []string{".pdf", ".png", ".jpg", ".jpeg"}
+
+type Excuse struct {
+	sql.Excuse
+	StudentName     string
+	SubmittedByName string
+	History         []sql.ExcuseHistory
+}
+
+// getClassTeachersOf vrne razrednike razredov, v katere je vpisan učenec.
+func (server *httpImpl) getClassTeachersOf(studentId string) ([]string, error) {
+	classes, err := server.db.GetClasses()
+	if err != nil {
+		return nil, err
+	}
+	teachers := make([]string, 0)
+	for i := 0; i < len(classes); i++ {
+		var students []string
+		err := json.Unmarshal([]byte(classes[i].Students), &students)
+		if err != nil {
+			return nil, err
+		}
+		if helpers.Contains(students, studentId) && !helpers.Contains(teachers, classes[i].Teacher) {
+			teachers = append(teachers, classes[i].Teacher)
+		}
+	}
+	return teachers, nil
+}
+
+// canReviewExcuse preveri, ali lahko uporabnik odobri ali zavrne opravičilo učenca (razrednik ali vodstvo šole).
+func (server *httpImpl) canReviewExcuse(user sql.User, studentId string) (bool, error) {
+	if user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT {
+		return true, nil
+	}
+	if user.Role != TEACHER {
+		return false, nil
+	}
+	teachers, err := server.getClassTeachersOf(studentId)
+	if err != nil {
+		return false, err
+	}
+	return helpers.Contains(teachers, user.ID), nil
+}
+
+func isParentOf(user sql.User, studentId string) (bool, error) {
+	if user.Role != PARENT {
+		return false, nil
+	}
+	var students []string
+	err := json.Unmarshal([]byte(user.Users), &students)
+	if err != nil {
+		return false, err
+	}
+	return helpers.Contains(students, studentId), nil
+}
+
+func (server *httpImpl) canViewExcuses(user sql.User, studentId string) (bool, error) {
+	if user.Role == STUDENT {
+		return user.ID == studentId, nil
+	}
+	parent, err := isParentOf(user, studentId)
+	if err != nil || parent {
+		return parent, err
+	}
+	return server.canReviewExcuse(user, studentId)
+}
+
+func (server *httpImpl) describeExcuse(excuse sql.Excuse) (Excuse, error) {
+	student, err := server.db.GetUser(excuse.StudentID)
+	if err != nil {
+		return Excuse{}, err
+	}
+	submittedBy, err := server.db.GetUser(excuse.SubmittedBy)
+	if err != nil {
+		return Excuse{}, err
+	}
+	history, err := server.db.GetExcuseHistory(excuse.ID)
+	if err != nil {
+		return Excuse{}, err
+	}
+	return Excuse{Excuse: excuse, StudentName: student.Name, SubmittedByName: submittedBy.Name, History: history}, nil
+}
+
+// getExcuseFromRequest pridobi opravičilo iz poti in preveri, ali ga uporabnik lahko vidi.
+// Ob napaki sam zapiše odgovor.
+func (server *httpImpl) getExcuseFromRequest(w http.ResponseWriter, r *http.Request, user sql.User) (sql.Excuse, bool) {
+	excuse, err := server.db.GetExcuse(mux.Vars(r)["excuse_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the excuse", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return excuse, false
+	}
+	ok, err := server.canViewExcuses(user, excuse.StudentID)
+	if err != nil {
+		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return excuse, false
+	}
+	if !ok {
+		WriteForbiddenJWT(w)
+		return excuse, false
+	}
+	return excuse, true
+}
+
+func (server *httpImpl) GetExcusesForStudent(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	studentId := mux.Vars(r)["student_id"]
+	ok, err := server.canViewExcuses(user, studentId)
+	if err != nil {
+		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		WriteForbiddenJWT(w)
+		return
+	}
+	excuses, err := server.db.GetExcusesForStudent(studentId)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving excuses", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	excusesJson := make([]Excuse, 0)
+	for i := 0; i < len(excuses); i++ {
+		excuse, err := server.describeExcuse(excuses[i])
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while retrieving the excuse", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		excusesJson = append(excusesJson, excuse)
+	}
+	WriteJSON(w, Response{Data: excusesJson, Success: true}, http.StatusOK)
+}
+
+// GetPendingExcuses vrne opravičila, ki čakajo na odločitev. Razrednik vidi samo opravičila učencev svojih razredov.
+func (server *httpImpl) GetPendingExcuses(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == TEACHER || user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	excuses, err := server.db.GetExcusesWithStatus(sql.EXCUSE_PENDING)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving excuses", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	excusesJson := make([]Excuse, 0)
+	for i := 0; i < len(excuses); i++ {
+		ok, err := server.canReviewExcuse(user, excuses[i].StudentID)
+		if err != nil {
+			WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			continue
+		}
+		excuse, err := server.describeExcuse(excuses[i])
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while retrieving the excuse", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		excusesJson = append(excusesJson, excuse)
+	}
+	WriteJSON(w, Response{Data: excusesJson, Success: true}, http.StatusOK)
+}
+
+func (server *httpImpl) GetExcuse(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	excuse, ok := server.getExcuseFromRequest(w, r, user)
+	if !ok {
+		return
+	}
+	excuseJson, err := server.describeExcuse(excuse)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the excuse", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: excuseJson, Success: true}, http.StatusOK)
+}
+
+// NewExcuse omogoča staršem, da oddajo opravičilo za obdobje, po želji skupaj z zdravniškim opravičilom (datoteka document).
+func (server *httpImpl) NewExcuse(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, EXCUSE_MAX_DOCUMENT_SIZE+(1<<20))
+	studentId := r.FormValue("student_id")
+	ok, err := isParentOf(user, studentId)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Could not unmarshal students", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		WriteForbiddenJWT(w)
+		return
+	}
+
+	startDate := r.FormValue("start_date")
+	endDate := r.FormValue("end_date")
+	start, err := time.Parse("02-01-2006", startDate)
+	if err != nil {
+		WriteBadRequest(w)
+		return
+	}
+	end, err := time.Parse("02-01-2006", endDate)
+	if err != nil || end.Before(start) {
+		WriteBadRequest(w)
+		return
+	}
+	reason := strings.TrimSpace(r.FormValue("reason"))
+	if reason == "" {
+		WriteBadRequest(w)
+		return
+	}
+
+	excuse := sql.Excuse{
+		StudentID:   studentId,
+		SubmittedBy: user.ID,
+		StartDate:   startDate,
+		EndDate:     endDate,
+		Reason:      reason,
+	}
+
+	file, header, err := r.FormFile("document")
+	if err == nil {
+		defer file.Close()
+		extension := strings.ToLower(filepath.Ext(header.Filename))
+		if !helpers.Contains(excuseDocumentExtensions, extension) || header.Size > EXCUSE_MAX_DOCUMENT_SIZE {
+			WriteJSON(w, Response{Data: "Unsupported document", Success: false}, http.StatusBadRequest)
+			return
+		}
+		document, err := io.ReadAll(file)
+		if err != nil {
+			WriteBadRequest(w)
+			return
+		}
+		excuse.DocumentPath = fmt.Sprintf("excuses/%s%s", uuid.New().String(), extension)
+		excuse.DocumentName = filepath.Base(header.Filename)
+		err = os.WriteFile(excuse.DocumentPath, document, 0600)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while saving the document", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+	} else if err != http.ErrMissingFile {
+		WriteBadRequest(w)
+		return
+	}
+
+	excuse.ID, err = server.db.InsertExcuse(excuse)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while inserting the excuse", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	err = server.notifyClassTeachersAboutExcuse(user.ID, excuse)
+	if err != nil {
+		server.logger.Errorw("failed to notify the class teacher", "excuse", excuse.ID, "error", err.Error())
+	}
+
+	WriteJSON(w, Response{Data: excuse, Success: true}, http.StatusCreated)
+}
+
+func (server *httpImpl) notifyClassTeachersAboutExcuse(from string, excuse sql.Excuse) error {
+	student, err := server.db.GetUser(excuse.StudentID)
+	if err != nil {
+		return err
+	}
+	teachers, err := server.getClassTeachersOf(excuse.StudentID)
+	if err != nil || len(teachers) == 0 {
+		return err
+	}
+	return server.notifyUsers(
+		from,
+		teachers,
+		"Opravičilo",
+		fmt.Sprintf("Oddano je bilo opravičilo za %s (%s - %s):\n%s", student.Name, excuse.StartDate, excuse.EndDate, excuse.Reason),
+	)
+}
+
+func (server *httpImpl) GetExcuseDocument(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	excuse, ok := server.getExcuseFromRequest(w, r, user)
+	if !ok {
+		return
+	}
+	if excuse.DocumentPath == "" {
+		WriteJSON(w, Response{Data: "Excuse has no document", Success: false}, http.StatusNotFound)
+		return
+	}
+	file, err := os.ReadFile(excuse.DocumentPath)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while reading the document", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", excuse.DocumentName))
+	w.Write(file)
+}
+
+func (server *httpImpl) reviewExcuse(w http.ResponseWriter, r *http.Request, status int) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	excuse, err := server.db.GetExcuse(mux.Vars(r)["excuse_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the excuse", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	ok, err := server.canReviewExcuse(user, excuse.StudentID)
+	if err != nil {
+		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		WriteForbiddenJWT(w)
+		return
+	}
+	// Odločitev je mogoče spremeniti, preklicanega opravičila pa ne.
+	if excuse.Status == status || excuse.Status == sql.EXCUSE_WITHDRAWN {
+		WriteJSON(w, Response{Data: "Excuse is already in this state or was withdrawn", Success: false}, http.StatusConflict)
+		return
+	}
+	comment := r.FormValue("comment")
+	err = server.db.SetExcuseStatus(excuse, status, user.ID, comment)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while updating the excuse", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	var decision = "odobreno"
+	if status == sql.EXCUSE_REJECTED {
+		decision = "zavrnjeno"
+	}
+	body := fmt.Sprintf("Opravičilo za obdobje %s - %s je bilo %s.", excuse.StartDate, excuse.EndDate, decision)
+	if comment != "" {
+		body = fmt.Sprintf("%s\n%s", body, comment)
+	}
+	err = server.notifyUsers(user.ID, []string{excuse.SubmittedBy}, "Opravičilo", body)
+	if err != nil {
+		server.logger.Errorw("failed to notify the parent", "excuse", excuse.ID, "error", err.Error())
+	}
+
+	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusOK)
+}
+
+// ApproveExcuse odobri opravičilo in opraviči vse odsotnosti učenca v obdobju opravičila.
+func (server *httpImpl) ApproveExcuse(w http.ResponseWriter, r *http.Request) {
+	server.reviewExcuse(w, r, sql.EXCUSE_APPROVED)
+}
+
+func (server *httpImpl) RejectExcuse(w http.ResponseWriter, r *http.Request) {
+	server.reviewExcuse(w, r, sql.EXCUSE_REJECTED)
+}
+
+// WithdrawExcuse omogoča staršem, da prekličejo svoje opravičilo.
+func (server *httpImpl) WithdrawExcuse(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	excuse, err := server.db.GetExcuse(mux.Vars(r)["excuse_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the excuse", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	if excuse.SubmittedBy != user.ID {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if excuse.Status == sql.EXCUSE_WITHDRAWN {
+		WriteJSON(w, Response{Data: "Excuse was already withdrawn", Success: false}, http.StatusConflict)
+		return
+	}
+	err = server.db.SetExcuseStatus(excuse, sql.EXCUSE_WITHDRAWN, user.ID, r.FormValue("comment"))
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while updating the excuse", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusOK)
+}
@@ -0,0 +1,726 @@
+package httphandlers
+
+import (
+	sql2 "database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/MeetPlan/MeetPlanBackend/helpers"
+	"github.com/MeetPlan/MeetPlanBackend/sql"
+	"github.com/gorilla/mux"
+	"github.com/signintech/gopdf"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Povprečje, ki je od meje zaokroževanja (x,5) oddaljeno manj kot toliko, je mejno in ga razrednik pregleda posebej.
+const FINAL_GRADE_BORDERLINE_MARGIN = 0.1
+
+// FinalGradeProposal je predlog zaključne ocene učenca pri predmetu.
+//
+// AutoGrade je samodejni predlog iz povprečja po pravilu predmeta. Grade je ocena, ki bo potrjena na konferenci:
+// vpisana zaključna ocena, sicer učiteljev predlog, sicer samodejni predlog.
+type FinalGradeProposal struct {
+	UserID        string
+	Name          string
+	Surname       string
+	SubjectID     string
+	SubjectName   string
+	TeacherID     string
+	Average       float64
+	AutoGrade     *int
+	Grade         *int
+	Assessment    string
+	GradeLabel    string
+	Justification string
+	// Učitelj je predlagal drugačno oceno od samodejnega predloga
+	IsOverridden bool
+	// Zaključna ocena je že vpisana
+	IsFinal      bool
+	IsMissing    bool
+	IsBorderline bool
+	IsFailing    bool
+}
+
+type ClassConferenceStudent struct {
+	ID            string
+	Name          string
+	Surname       string
+	Subjects      []FinalGradeProposal
+	HasMissing    bool
+	HasBorderline bool
+	HasFailing    bool
+}
+
+type ClassConferenceReview struct {
+	ClassID     string
+	ClassName   string
+	SchoolYear  string
+	IsConfirmed bool
+	Conference  *sql.ClassConference
+	Students    []ClassConferenceStudent
+}
+
+// getStudentGradesInSubject vrne ocene učenca pri predmetu (z upoštevanjem popravnih rokov) in zaključno oceno, če obstaja.
+func (server *httpImpl) getStudentGradesInSubject(userId string, subjectId string) ([]Grade, *Grade, error) {
+	grades, err := server.db.GetGradesForUserInSubject(userId, subjectId)
+	if err != nil {
+		return nil, nil, err
+	}
+	gm := make(map[string][]sql.Grade)
+	for _, v := range grades {
+		gradingId := ""
+		if v.TermID != nil {
+			term, err := server.db.GetGradingTerm(*v.TermID)
+			if err != nil {
+				return nil, nil, err
+			}
+			gradingId = term.GradingID
+		}
+		gm[gradingId] = append(gm[gradingId], v)
+	}
+	result := make([]Grade, 0)
+	var final *Grade
+	for gradingId, g := range gm {
+		var transformed []Grade
+		if gradingId == "" {
+			transformed = make([]Grade, 0)
+			for _, v := range g {
+				transformed = append(transformed, MakeGradeFromSQLGrade(&v))
+			}
+		} else {
+			transformed = server.TransformGradesCountable(g)
+		}
+		for i := 0; i < len(transformed); i++ {
+			if transformed[i].IsFinal {
+				final = &transformed[i]
+			} else {
+				result = append(result, transformed[i])
+			}
+		}
+	}
+	return result, final, nil
+}
+
+// getFinalGradeProposals pripravi predloge zaključnih ocen predmeta za podane učence.
+func (server *httpImpl) getFinalGradeProposals(subject sql.Subject, students []string, periods []GradingPeriod) ([]FinalGradeProposal, error) {
+	scale, err := server.getGradingScaleForSubject(subject)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := server.getGradingPolicyForSubject(subject.ID)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := server.db.GetFinalGradeProposalsForSubject(subject.ID, helpers.GetCurrentSchoolYear())
+	if err != nil {
+		return nil, err
+	}
+	storedProposals := make(map[string]sql.FinalGradeProposal)
+	for i := 0; i < len(stored); i++ {
+		storedProposals[stored[i].UserID] = stored[i]
+	}
+
+	proposals := make([]FinalGradeProposal, 0)
+	for i := 0; i < len(students); i++ {
+		student, err := server.db.GetUser(students[i])
+		if err != nil {
+			return nil, err
+		}
+		grades, final, err := server.getStudentGradesInSubject(student.ID, subject.ID)
+		if err != nil {
+			return nil, err
+		}
+		_, avg, err := server.aggregateGradingPeriods(grades, periods, scale, policy)
+		if err != nil {
+			return nil, err
+		}
+		proposal := FinalGradeProposal{
+			UserID:      student.ID,
+			Name:        student.Name,
+			Surname:     student.Surname,
+			SubjectID:   subject.ID,
+			SubjectName: subject.Name,
+			TeacherID:   subject.TeacherID,
+			Average:     avg,
+		}
+		if scale.IsAveraged() && avg > 0 {
+			auto := int(math.Round(avg))
+			if auto < scale.MinGrade {
+				auto = scale.MinGrade
+			} else if auto > scale.MaxGrade {
+				auto = scale.MaxGrade
+			}
+			proposal.AutoGrade = &auto
+			proposal.IsBorderline = math.Abs(avg-math.Floor(avg)-0.5) < FINAL_GRADE_BORDERLINE_MARGIN
+		}
+
+		s, hasStored := storedProposals[student.ID]
+		if hasStored {
+			proposal.Justification = s.Justification
+			proposal.IsOverridden = proposal.AutoGrade == nil || *proposal.AutoGrade != s.Grade
+		}
+		if final != nil {
+			grade := final.Grade
+			proposal.Grade = &grade
+			proposal.Assessment = final.Assessment
+			proposal.IsFinal = true
+		} else if hasStored {
+			grade := s.Grade
+			proposal.Grade = &grade
+			proposal.Assessment = s.Assessment
+		} else {
+			proposal.Grade = proposal.AutoGrade
+		}
+
+		proposal.IsMissing = proposal.Grade == nil
+		if !proposal.IsMissing {
+			g := sql.Grade{Grade: *proposal.Grade, Assessment: proposal.Assessment}
+			proposal.GradeLabel = scale.Format(g)
+			proposal.IsFailing = !scale.IsPassing(g)
+		}
+		proposals = append(proposals, proposal)
+	}
+	return proposals, nil
+}
+
+// getClassConferenceReview zbere predloge zaključnih ocen vseh ocenjevanih predmetov za vse učence razreda.
+func (server *httpImpl) getClassConferenceReview(class sql.Class) (ClassConferenceReview, error) {
+	review := ClassConferenceReview{
+		ClassID:    class.ID,
+		ClassName:  class.Name,
+		SchoolYear: helpers.GetCurrentSchoolYear(),
+		Students:   make([]ClassConferenceStudent, 0),
+	}
+	conference, err := server.db.GetClassConference(class.ID, review.SchoolYear)
+	if err == nil {
+		review.IsConfirmed = true
+		review.Conference = &conference
+	} else if !errors.Is(err, sql2.ErrNoRows) {
+		return review, err
+	}
+
+	var students []string
+	err = json.Unmarshal([]byte(class.Students), &students)
+	if err != nil {
+		return review, err
+	}
+	periods, err := server.getGradingPeriods(time.Now())
+	if err != nil {
+		return review, err
+	}
+	subjects, err := server.db.GetAllSubjects()
+	if err != nil {
+		return review, err
+	}
+
+	studentSubjects := make(map[string][]FinalGradeProposal)
+	for i := 0; i < len(subjects); i++ {
+		if !subjects[i].IsGraded {
+			continue
+		}
+		subjectStudents := make([]string, 0)
+		for _, student := range server.db.GetStudentsFromSubject(&subjects[i]) {
+			if helpers.Contains(students, student) {
+				subjectStudents = append(subjectStudents, student)
+			}
+		}
+		if len(subjectStudents) == 0 {
+			continue
+		}
+		proposals, err := server.getFinalGradeProposals(subjects[i], subjectStudents, periods)
+		if err != nil {
+			return review, err
+		}
+		for n := 0; n < len(proposals); n++ {
+			studentSubjects[proposals[n].UserID] = append(studentSubjects[proposals[n].UserID], proposals[n])
+		}
+	}
+
+	for i := 0; i < len(students); i++ {
+		student, err := server.db.GetUser(students[i])
+		if err != nil {
+			return review, err
+		}
+		s := ClassConferenceStudent{
+			ID:       student.ID,
+			Name:     student.Name,
+			Surname:  student.Surname,
+			Subjects: studentSubjects[student.ID],
+		}
+		if s.Subjects == nil {
+			s.Subjects = make([]FinalGradeProposal, 0)
+		}
+		for n := 0; n < len(s.Subjects); n++ {
+			s.HasMissing = s.HasMissing || s.Subjects[n].IsMissing
+			s.HasBorderline = s.HasBorderline || s.Subjects[n].IsBorderline
+			s.HasFailing = s.HasFailing || s.Subjects[n].IsFailing
+		}
+		review.Students = append(review.Students, s)
+	}
+	return review, nil
+}
+
+// getClassForConference vrne razred iz zahteve, če ga uporabnik sme pregledovati (razrednik ali vodstvo šole).
+func (server *httpImpl) getClassForConference(w http.ResponseWriter, r *http.Request) (sql.User, sql.Class, bool) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return user, sql.Class{}, false
+	}
+	class, err := server.db.GetClass(mux.Vars(r)["class_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the class", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return user, class, false
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT || class.Teacher == user.ID) {
+		WriteForbiddenJWT(w)
+		return user, class, false
+	}
+	return user, class, true
+}
+
+func (server *httpImpl) GetFinalGradeProposals(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == TEACHER || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	subject, err := server.db.GetSubject(mux.Vars(r)["subject_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the subject", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	if user.Role == TEACHER && subject.TeacherID != user.ID {
+		WriteForbiddenJWT(w)
+		return
+	}
+	periods, err := server.getGradingPeriods(time.Now())
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving grading periods", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	proposals, err := server.getFinalGradeProposals(subject, server.db.GetStudentsFromSubject(&subject), periods)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while preparing final grade proposals", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: proposals, Success: true}, http.StatusOK)
+}
+
+// PatchFinalGradeProposal shrani učiteljev predlog zaključne ocene. Če se predlog razlikuje od samodejnega
+// predloga iz povprečja, je utemeljitev (justification) obvezna.
+func (server *httpImpl) PatchFinalGradeProposal(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == TEACHER || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	subject, err := server.db.GetSubject(mux.Vars(r)["subject_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the subject", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	if user.Role == TEACHER && subject.TeacherID != user.ID {
+		WriteForbiddenJWT(w)
+		return
+	}
+	studentId := mux.Vars(r)["student_id"]
+	if !helpers.Contains(server.db.GetStudentsFromSubject(&subject), studentId) {
+		WriteBadRequest(w)
+		return
+	}
+	_, err = server.db.CheckIfFinal(studentId, subject.ID)
+	if err == nil {
+		WriteJSON(w, Response{Data: "Final grade has already been confirmed", Success: false}, http.StatusConflict)
+		return
+	} else if !errors.Is(err, sql2.ErrNoRows) {
+		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	scale, err := server.getGradingScaleForSubject(subject)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the grading scale", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	grade, _, assessment, err := gradeFromForm(scale, r.FormValue("grade"), r.FormValue("points"), r.FormValue("assessment"))
+	if err != nil {
+		WriteJSON(w, Response{Data: "Invalid grade for the grading scale", Error: err.Error(), Success: false}, http.StatusBadRequest)
+		return
+	}
+	periods, err := server.getGradingPeriods(time.Now())
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving grading periods", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	proposals, err := server.getFinalGradeProposals(subject, []string{studentId}, periods)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while preparing final grade proposals", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	current := proposals[0]
+
+	justification := strings.TrimSpace(r.FormValue("justification"))
+	if current.AutoGrade != nil && *current.AutoGrade != grade && justification == "" {
+		WriteJSON(w, Response{Data: "Justification is required when the proposed grade differs from the average", Success: false}, http.StatusBadRequest)
+		return
+	}
+
+	err = server.db.SetFinalGradeProposal(sql.FinalGradeProposal{
+		UserID:        studentId,
+		SubjectID:     subject.ID,
+		TeacherID:     user.ID,
+		SchoolYear:    helpers.GetCurrentSchoolYear(),
+		Grade:         grade,
+		Assessment:    assessment,
+		Average:       current.Average,
+		AutoGrade:     current.AutoGrade,
+		Justification: justification,
+	})
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while saving the final grade proposal", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusOK)
+}
+
+// DeleteFinalGradeProposal odstrani učiteljev predlog, s čimer spet velja samodejni predlog iz povprečja.
+func (server *httpImpl) DeleteFinalGradeProposal(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == TEACHER || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	subject, err := server.db.GetSubject(mux.Vars(r)["subject_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the subject", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	if user.Role == TEACHER && subject.TeacherID != user.ID {
+		WriteForbiddenJWT(w)
+		return
+	}
+	studentId := mux.Vars(r)["student_id"]
+	_, err = server.db.CheckIfFinal(studentId, subject.ID)
+	if err == nil {
+		WriteJSON(w, Response{Data: "Final grade has already been confirmed", Success: false}, http.StatusConflict)
+		return
+	} else if !errors.Is(err, sql2.ErrNoRows) {
+		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	err = server.db.DeleteFinalGradeProposal(studentId, subject.ID, helpers.GetCurrentSchoolYear())
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while deleting the final grade proposal", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusOK)
+}
+
+// GetClassConference vrne pregled zaključnih ocen razreda za redovalno konferenco.
+// Z only_flagged=true vrne samo učence z manjkajočimi, mejnimi ali negativnimi ocenami.
+func (server *httpImpl) GetClassConference(w http.ResponseWriter, r *http.Request) {
+	_, class, ok := server.getClassForConference(w, r)
+	if !ok {
+		return
+	}
+	review, err := server.getClassConferenceReview(class)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while preparing the class review", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	if r.URL.Query().Get("only_flagged") == "true" {
+		flagged := make([]ClassConferenceStudent, 0)
+		for i := 0; i < len(review.Students); i++ {
+			s := review.Students[i]
+			if s.HasMissing || s.HasBorderline || s.HasFailing {
+				flagged = append(flagged, s)
+			}
+		}
+		review.Students = flagged
+	}
+	WriteJSON(w, Response{Data: review, Success: true}, http.StatusOK)
+}
+
+// ConfirmClassConference potrdi zaključne ocene razreda. Vpiše manjkajoče zaključne ocene iz predlogov, ki so s tem
+// zaklenjene, in nastavi, ali učenci izdelajo razred. Potrditev ni mogoča, dokler katera od ocen manjka.
+func (server *httpImpl) ConfirmClassConference(w http.ResponseWriter, r *http.Request) {
+	user, class, ok := server.getClassForConference(w, r)
+	if !ok {
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	review, err := server.getClassConferenceReview(class)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while preparing the class review", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	if review.IsConfirmed {
+		WriteJSON(w, Response{Data: "Class conference has already been confirmed", Success: false}, http.StatusConflict)
+		return
+	}
+	missing := make([]ClassConferenceStudent, 0)
+	for i := 0; i < len(review.Students); i++ {
+		if review.Students[i].HasMissing {
+			missing = append(missing, review.Students[i])
+		}
+	}
+	if len(missing) != 0 {
+		WriteJSON(w, Response{Data: missing, Error: "Some final grades are missing", Success: false}, http.StatusConflict)
+		return
+	}
+
+	periods, err := server.getGradingPeriods(time.Now())
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving grading periods", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	grades := make([]sql.Grade, 0)
+	passing := make(map[string]bool)
+	for i := 0; i < len(review.Students); i++ {
+		student := review.Students[i]
+		passing[student.ID] = !student.HasFailing
+		for n := 0; n < len(student.Subjects); n++ {
+			proposal := student.Subjects[n]
+			if proposal.IsFinal {
+				continue
+			}
+			grades = append(grades, sql.Grade{
+				UserID:      student.ID,
+				TeacherID:   proposal.TeacherID,
+				SubjectID:   proposal.SubjectID,
+				Grade:       *proposal.Grade,
+				Assessment:  proposal.Assessment,
+				Date:        time.Now().Format("2006-01-02"),
+				IsFinal:     true,
+				CanPatch:    false,
+				Period:      periods[len(periods)-1].Period,
+				Description: "Zaključna ocena, potrjena na redovalni konferenci",
+				Category:    sql.GRADE_CATEGORY_OTHER,
+			})
+		}
+	}
+
+	conference := sql.ClassConference{
+		ClassID:     class.ID,
+		SchoolYear:  review.SchoolYear,
+		ConfirmedBy: user.ID,
+		Notes:       r.FormValue("notes"),
+	}
+	err = server.db.ConfirmClassConference(conference, grades, passing)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while confirming the class conference", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusCreated)
+}
+
+// GetClassConferenceMinutes vrne podpisan zapisnik potrjene redovalne konference razreda.
+func (server *httpImpl) GetClassConferenceMinutes(w http.ResponseWriter, r *http.Request) {
+	user, class, ok := server.getClassForConference(w, r)
+	if !ok {
+		return
+	}
+	review, err := server.getClassConferenceReview(class)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while preparing the class review", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	if !review.IsConfirmed {
+		WriteJSON(w, Response{Data: "Class conference hasn't been confirmed yet", Success: false}, http.StatusConflict)
+		return
+	}
+	chair, err := server.db.GetUser(review.Conference.ConfirmedBy)
+	if err != nil {
+		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	teacher, err := server.db.GetUser(class.Teacher)
+	if err != nil {
+		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	pdf := gopdf.GoPdf{}
+	pdf.Start(gopdf.Config{PageSize: *gopdf.PageSizeA4})
+	pdf.AddPage()
+
+	err = pdf.AddTTFFont("opensans", "fonts/opensans.ttf")
+	if err != nil {
+		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	err = pdf.Image("icons/school_banner.png", 30, 30, &gopdf.Rect{H: 70, W: 70})
+	if err != nil {
+		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	const borderBase = 30
+	const pageBottom = 780
+
+	_ = pdf.SetFont("opensans", "", 20)
+	pdf.SetX(120)
+	pdf.SetY(60)
+	pdf.Text("Zapisnik redovalne konference")
+	_ = pdf.SetFont("opensans", "", 13)
+	pdf.SetX(120)
+	pdf.SetY(82)
+	pdf.Text(fmt.Sprintf("%s, razred %s, šolsko leto %s", server.config.SchoolName, class.Name, review.SchoolYear))
+
+	pdf.Line(20, 115, 575, 115)
+
+	var y float64 = 140
+	newLine := func(height float64) {
+		y += height
+		if y > pageBottom {
+			pdf.AddPage()
+			y = 50
+		}
+	}
+	writeLine := func(x float64, text string) {
+		lines, err := pdf.SplitText(text, 575-x)
+		if err != nil {
+			lines = []string{text}
+		}
+		for i := 0; i < len(lines); i++ {
+			pdf.SetX(x)
+			pdf.SetY(y)
+			pdf.Text(lines[i])
+			newLine(15)
+		}
+	}
+
+	var passingCount = 0
+	for i := 0; i < len(review.Students); i++ {
+		if !review.Students[i].HasFailing {
+			passingCount++
+		}
+	}
+
+	_ = pdf.SetFont("opensans", "", 11)
+	writeLine(borderBase, fmt.Sprintf("Datum potrditve: %s", review.Conference.CreatedAt))
+	writeLine(borderBase, fmt.Sprintf("Konferenco je vodil(a): %s", chair.Name))
+	writeLine(borderBase, fmt.Sprintf("Razrednik: %s", teacher.Name))
+	writeLine(borderBase, fmt.Sprintf("Število učencev: %d, razred izdelalo: %d, razreda ni izdelalo: %d", len(review.Students), passingCount, len(review.Students)-passingCount))
+	if review.Conference.Notes != "" {
+		writeLine(borderBase, fmt.Sprintf("Opombe: %s", review.Conference.Notes))
+	}
+	newLine(10)
+
+	_ = pdf.SetFont("opensans", "", 15)
+	writeLine(borderBase, "Učenci")
+	_ = pdf.SetFont("opensans", "", 10)
+	for i := 0; i < len(review.Students); i++ {
+		student := review.Students[i]
+		status := "izdelal(a)"
+		if student.HasFailing {
+			failing := make([]string, 0)
+			for n := 0; n < len(student.Subjects); n++ {
+				if student.Subjects[n].IsFailing {
+					failing = append(failing, student.Subjects[n].SubjectName)
+				}
+			}
+			status = fmt.Sprintf("ni izdelal(a), negativno ocenjen(a) pri: %s", strings.Join(failing, ", "))
+		}
+		writeLine(borderBase+10, fmt.Sprintf("%s %s - %s", student.Name, student.Surname, status))
+	}
+	newLine(10)
+
+	_ = pdf.SetFont("opensans", "", 15)
+	writeLine(borderBase, "Ocene, ki odstopajo od povprečja")
+	_ = pdf.SetFont("opensans", "", 10)
+	var overridden = 0
+	for i := 0; i < len(review.Students); i++ {
+		student := review.Students[i]
+		for n := 0; n < len(student.Subjects); n++ {
+			proposal := student.Subjects[n]
+			if !proposal.IsOverridden {
+				continue
+			}
+			overridden++
+			writeLine(borderBase+10, fmt.Sprintf(
+				"%s %s, %s: ocena %s (povprečje %.2f) - %s",
+				student.Name,
+				student.Surname,
+				proposal.SubjectName,
+				proposal.GradeLabel,
+				proposal.Average,
+				proposal.Justification,
+			))
+		}
+	}
+	if overridden == 0 {
+		writeLine(borderBase+10, "Ni odstopanj.")
+	}
+
+	newLine(40)
+	pdf.SetX(50)
+	pdf.SetY(y)
+	pdf.Cell(nil, teacher.Name)
+	pdf.SetX(390)
+	pdf.Cell(nil, chair.Name)
+
+	UUID := newDocumentID()
+
+	newLine(30)
+	if y > pageBottom-80 {
+		pdf.AddPage()
+		y = 50
+	}
+	_ = pdf.SetFont("opensans", "", 8)
+	err = server.drawVerificationCode(&pdf, UUID, borderBase, y, 70)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while drawing the verification code", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("documents/%s.pdf", UUID)
+
+	err = helpers.Sign(pdf.GetBytesPdf(), filename, "cacerts/key-pair.p12", "")
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while signing", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	document := sql.Document{
+		ID:           UUID,
+		ExportedBy:   user.ID,
+		DocumentType: ZAPISNIK_REDOVALNE_KONFERENCE,
+		IsSigned:     true,
+	}
+	err = server.insertSignedDocument(document)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while inserting Document into database", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	file, err := os.ReadFile(filename)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while reading signed document", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Write(file)
+}
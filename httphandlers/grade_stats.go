@@ -0,0 +1,512 @@
+package httphandlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"github.com/MeetPlan/MeetPlanBackend/sql"
+	"github.com/gorilla/mux"
+	"github.com/johnfercher/maroto/pkg/consts"
+	"github.com/johnfercher/maroto/pkg/pdf"
+	"github.com/johnfercher/maroto/pkg/props"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Učenec je ogrožen, če ima pri predmetu povprečje pod to mejo ali vsaj eno negativno oceno, ki šteje.
+// Meja velja za lestvico 1-5, pri drugih številčnih lestvicah se sorazmerno preslika (glej atRiskAverage).
+const STUDENT_AT_RISK_AVERAGE = 2
+
+type GradeStatsSummary struct {
+	sql.GradeStats
+	FailureRate float64
+}
+
+type GradingTermStats struct {
+	GradingTermID string
+	Name          string
+	Stats         GradeStatsSummary
+	Distribution  []sql.GradeCount
+}
+
+type SubjectStatsEntry struct {
+	GradeStatsSummary
+	SubjectID   string
+	SubjectName string
+	ClassName   string
+	TeacherID   string
+	TeacherName string
+}
+
+type TeacherStatsEntry struct {
+	GradeStatsSummary
+	TeacherID   string
+	TeacherName string
+}
+
+type PeriodStatsEntry struct {
+	GradeStatsSummary
+	Period int
+}
+
+type SubjectComparison struct {
+	LongName string
+	Since    string
+	Subjects []SubjectStatsEntry
+	Teachers []TeacherStatsEntry
+	Periods  []PeriodStatsEntry
+}
+
+type StudentAtRisk struct {
+	sql.StudentSubject
+	Average     float64
+	Failed      int
+	Name        string
+	Surname     string
+	SubjectName string
+	ClassName   string
+}
+
+// atRiskAverage preslika STUDENT_AT_RISK_AVERAGE s privzete lestvice 1-5 na podano lestvico.
+func atRiskAverage(scale sql.GradingScale) float64 {
+	def := sql.DefaultGradingScale()
+	if scale.MaxGrade <= scale.MinGrade {
+		return float64(scale.PassingGrade)
+	}
+	ratio := float64(STUDENT_AT_RISK_AVERAGE-def.MinGrade) / float64(def.MaxGrade-def.MinGrade)
+	return float64(scale.MinGrade) + ratio*float64(scale.MaxGrade-scale.MinGrade)
+}
+
+func summarizeGradeStats(stats sql.GradeStats) GradeStatsSummary {
+	summary := GradeStatsSummary{GradeStats: stats}
+	if stats.Count != 0 {
+		summary.FailureRate = float64(stats.Failed) / float64(stats.Count)
+	}
+	return summary
+}
+
+// getGradeStatsSince vrne začetek obdobja statistike (parameter since), privzeto začetek tekočega šolskega leta.
+func (server *httpImpl) getGradeStatsSince(r *http.Request) (string, bool) {
+	since := r.URL.Query().Get("since")
+	if since != "" {
+		_, err := time.Parse("02-01-2006", since)
+		return since, err == nil
+	}
+	periods, err := server.getGradingPeriods(time.Now())
+	if err != nil {
+		return "", false
+	}
+	return periods[0].StartDate, true
+}
+
+// writeStatsExport izvozi tabelo statistike v formatu csv ali pdf. Izvoz ni uraden dokument, zato ni podpisan.
+func (server *httpImpl) writeStatsExport(w http.ResponseWriter, format string, title string, filename string, header []string, rows [][]string) {
+	if format == "csv" {
+		var output bytes.Buffer
+		writer := csv.NewWriter(&output)
+		_ = writer.Write(header)
+		for i := 0; i < len(rows); i++ {
+			_ = writer.Write(rows[i])
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			WriteJSON(w, Response{Data: "Failed while exporting statistics", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.csv\"", filename))
+		w.Write(output.Bytes())
+		return
+	}
+
+	if format != "pdf" {
+		WriteJSON(w, Response{Data: "Unsupported format", Success: false}, http.StatusBadRequest)
+		return
+	}
+
+	m := pdf.NewMaroto(consts.Portrait, consts.A4)
+
+	m.AddUTF8Font("OpenSans", consts.Normal, "fonts/opensans.ttf")
+	m.SetDefaultFontFamily("OpenSans")
+
+	m.Row(40, func() {
+		m.Col(3, func() {
+			_ = m.FileImage("icons/school_logo.png", props.Rect{
+				Center:  true,
+				Percent: 80,
+			})
+		})
+		m.ColSpace(1)
+		m.Col(8, func() {
+			m.Text(title, props.Text{
+				Top:         12,
+				Size:        20,
+				Extrapolate: true,
+			})
+			m.Text(fmt.Sprintf("%s, %s", server.config.SchoolName, time.Now().Format("02-01-2006")), props.Text{
+				Top:         23,
+				Size:        11,
+				Extrapolate: true,
+			})
+		})
+	})
+
+	m.Line(10)
+
+	// maroto razdeli vrstico na 12 delov, ostanek dobi prvi stolpec
+	gridSizes := make([]uint, len(header))
+	for i := 0; i < len(header); i++ {
+		gridSizes[i] = uint(12 / len(header))
+	}
+	gridSizes[0] += uint(12 % len(header))
+
+	m.TableList(header, rows, props.TableList{
+		HeaderProp: props.TableListContent{
+			Family:    "OpenSans",
+			Size:      9,
+			GridSizes: gridSizes,
+		},
+		ContentProp: props.TableListContent{
+			Family:    "OpenSans",
+			Size:      9,
+			GridSizes: gridSizes,
+		},
+		Line: true,
+	})
+
+	output, err := m.Output()
+	if err != nil {
+		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Write(output.Bytes())
+}
+
+func statsRow(name string, stats GradeStatsSummary) []string {
+	return []string{
+		name,
+		fmt.Sprint(stats.Count),
+		fmt.Sprintf("%.2f", stats.Mean),
+		fmt.Sprintf("%.1f", stats.Median),
+		fmt.Sprint(stats.Failed),
+		fmt.Sprintf("%.1f %%", stats.FailureRate*100),
+	}
+}
+
+// GetGradingTermStats vrne porazdelitev ocen, povprečje, mediano in delež negativnih ocen roka ocenjevanja.
+func (server *httpImpl) GetGradingTermStats(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == TEACHER || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	gradingTerm, err := server.db.GetGradingTerm(mux.Vars(r)["grading_term_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the grading term", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	grading, err := server.db.GetGrading(gradingTerm.GradingID)
+	if err != nil {
+		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	subject, err := server.db.GetSubject(grading.SubjectID)
+	if err != nil {
+		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	if user.Role == TEACHER && subject.TeacherID != user.ID && gradingTerm.TeacherID != user.ID {
+		WriteForbiddenJWT(w)
+		return
+	}
+
+	stats, err := server.db.GetGradeStatsForTerm(gradingTerm.ID)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while calculating statistics", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	distribution, err := server.db.GetGradeDistributionForTerm(gradingTerm.ID)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while calculating statistics", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	termStats := GradingTermStats{
+		GradingTermID: gradingTerm.ID,
+		Name:          fmt.Sprintf("%s, %d. rok", grading.Name, gradingTerm.Term),
+		Stats:         summarizeGradeStats(stats),
+		Distribution:  distribution,
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" || format == "json" {
+		WriteJSON(w, Response{Data: termStats, Success: true}, http.StatusOK)
+		return
+	}
+	rows := make([][]string, 0)
+	for i := 0; i < len(distribution); i++ {
+		rows = append(rows, []string{fmt.Sprint(distribution[i].Grade), fmt.Sprint(distribution[i].Count)})
+	}
+	rows = append(rows, []string{"Povprečje", fmt.Sprintf("%.2f", termStats.Stats.Mean)})
+	rows = append(rows, []string{"Mediana", fmt.Sprintf("%.1f", termStats.Stats.Median)})
+	rows = append(rows, []string{"Delež negativnih", fmt.Sprintf("%.1f %%", termStats.Stats.FailureRate*100)})
+	server.writeStatsExport(
+		w,
+		format,
+		fmt.Sprintf("Statistika ocen: %s", termStats.Name),
+		fmt.Sprintf("grading-term-%s", gradingTerm.ID),
+		[]string{"Ocena", "Število"},
+		rows,
+	)
+}
+
+// GetSubjectComparison primerja ocene predmetov z istim dolgim imenom (long_name) med razredi, učitelji in
+// ocenjevalnimi obdobji.
+func (server *httpImpl) GetSubjectComparison(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	longName := r.URL.Query().Get("long_name")
+	if longName == "" {
+		WriteBadRequest(w)
+		return
+	}
+	since, ok := server.getGradeStatsSince(r)
+	if !ok {
+		WriteBadRequest(w)
+		return
+	}
+
+	subjectStats, err := server.db.GetGradeStatsForLongName(longName, since)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while calculating statistics", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	teacherStats, err := server.db.GetTeacherGradeStatsForLongName(longName, since)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while calculating statistics", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	periodStats, err := server.db.GetPeriodGradeStatsForLongName(longName, since)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while calculating statistics", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	comparison := SubjectComparison{
+		LongName: longName,
+		Since:    since,
+		Subjects: make([]SubjectStatsEntry, 0),
+		Teachers: make([]TeacherStatsEntry, 0),
+		Periods:  make([]PeriodStatsEntry, 0),
+	}
+	for i := 0; i < len(subjectStats); i++ {
+		entry := SubjectStatsEntry{
+			GradeStatsSummary: summarizeGradeStats(subjectStats[i].GradeStats),
+			SubjectID:         subjectStats[i].SubjectID,
+			TeacherID:         subjectStats[i].TeacherID,
+		}
+		subject, err := server.db.GetSubject(subjectStats[i].SubjectID)
+		if err != nil {
+			WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		entry.SubjectName = subject.Name
+		if subjectStats[i].ClassID != nil {
+			class, err := server.db.GetClass(*subjectStats[i].ClassID)
+			if err != nil {
+				WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+				return
+			}
+			entry.ClassName = class.Name
+		}
+		teacher, err := server.db.GetUser(subjectStats[i].TeacherID)
+		if err != nil {
+			WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		entry.TeacherName = teacher.Name
+		comparison.Subjects = append(comparison.Subjects, entry)
+	}
+	for i := 0; i < len(teacherStats); i++ {
+		teacher, err := server.db.GetUser(teacherStats[i].TeacherID)
+		if err != nil {
+			WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		comparison.Teachers = append(comparison.Teachers, TeacherStatsEntry{
+			GradeStatsSummary: summarizeGradeStats(teacherStats[i].GradeStats),
+			TeacherID:         teacher.ID,
+			TeacherName:       teacher.Name,
+		})
+	}
+	for i := 0; i < len(periodStats); i++ {
+		comparison.Periods = append(comparison.Periods, PeriodStatsEntry{
+			GradeStatsSummary: summarizeGradeStats(periodStats[i].GradeStats),
+			Period:            periodStats[i].Period,
+		})
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" || format == "json" {
+		WriteJSON(w, Response{Data: comparison, Success: true}, http.StatusOK)
+		return
+	}
+	rows := make([][]string, 0)
+	for i := 0; i < len(comparison.Subjects); i++ {
+		entry := comparison.Subjects[i]
+		rows = append(rows, statsRow(fmt.Sprintf("%s %s (%s)", entry.SubjectName, entry.ClassName, entry.TeacherName), entry.GradeStatsSummary))
+	}
+	for i := 0; i < len(comparison.Teachers); i++ {
+		rows = append(rows, statsRow(fmt.Sprintf("Učitelj: %s", comparison.Teachers[i].TeacherName), comparison.Teachers[i].GradeStatsSummary))
+	}
+	for i := 0; i < len(comparison.Periods); i++ {
+		rows = append(rows, statsRow(fmt.Sprintf("%d. ocenjevalno obdobje", comparison.Periods[i].Period), comparison.Periods[i].GradeStatsSummary))
+	}
+	server.writeStatsExport(
+		w,
+		format,
+		fmt.Sprintf("Primerjava ocen: %s", longName),
+		"subject-comparison",
+		[]string{"Skupina", "Ocene", "Povprečje", "Mediana", "Negativne", "Delež negativnih"},
+		rows,
+	)
+}
+
+// GetStudentsAtRisk vrne učence, ki imajo pri katerem od predmetov povprečje pod STUDENT_AT_RISK_AVERAGE ali negativne ocene.
+// Povprečje se izračuna enako kot v redovalnici (calculateAverage z utežmi in pravilom predmeta), popravljene ocene
+// ne štejejo. Pri lestvici opravil/ni opravil se upoštevajo samo negativne ocene.
+func (server *httpImpl) GetStudentsAtRisk(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT || user.Role == SCHOOL_PSYCHOLOGIST) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	since, ok := server.getGradeStatsSince(r)
+	if !ok {
+		WriteBadRequest(w)
+		return
+	}
+	studentSubjects, err := server.db.GetStudentSubjectsWithGrades(since)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while calculating statistics", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	students := make([]StudentAtRisk, 0)
+	for i := 0; i < len(studentSubjects); i++ {
+		subject, err := server.db.GetSubject(studentSubjects[i].SubjectID)
+		if err != nil {
+			WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		scale, err := server.getGradingScaleForSubject(subject)
+		if err != nil {
+			WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		if scale.ScaleType == sql.GRADING_SCALE_DESCRIPTIVE {
+			continue
+		}
+		sqlGrades, err := server.db.GetGradesForUserInSubjectSince(studentSubjects[i].UserID, subject.ID, since)
+		if err != nil {
+			WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		grades := server.TransformGradesCountable(sqlGrades)
+
+		var failed = 0
+		for n := 0; n < len(grades); n++ {
+			if grades[n].Counts && !scale.IsPassing(sql.Grade{Grade: grades[n].Grade}) {
+				failed++
+			}
+		}
+		var average = 0.0
+		var belowAverage = false
+		if scale.IsAveraged() {
+			policy, err := server.getGradingPolicyForSubject(subject.ID)
+			if err != nil {
+				WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+				return
+			}
+			err = server.setGradeWeights(grades, policy)
+			if err != nil {
+				WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+				return
+			}
+			average = calculateAverage(grades, policy)
+			belowAverage = average > 0 && average < atRiskAverage(scale)
+		}
+		if failed == 0 && !belowAverage {
+			continue
+		}
+
+		student, err := server.db.GetUser(studentSubjects[i].UserID)
+		if err != nil {
+			WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		entry := StudentAtRisk{
+			StudentSubject: studentSubjects[i],
+			Average:        average,
+			Failed:         failed,
+			Name:           student.Name,
+			Surname:        student.Surname,
+			SubjectName:    subject.Name,
+		}
+		if subject.ClassID != nil {
+			class, err := server.db.GetClass(*subject.ClassID)
+			if err != nil {
+				WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+				return
+			}
+			entry.ClassName = class.Name
+		}
+		students = append(students, entry)
+	}
+	sort.SliceStable(students, func(i, j int) bool {
+		if students[i].Failed != students[j].Failed {
+			return students[i].Failed > students[j].Failed
+		}
+		return students[i].Average < students[j].Average
+	})
+
+	format := r.URL.Query().Get("format")
+	if format == "" || format == "json" {
+		WriteJSON(w, Response{Data: students, Success: true}, http.StatusOK)
+		return
+	}
+	rows := make([][]string, 0)
+	for i := 0; i < len(students); i++ {
+		rows = append(rows, []string{
+			fmt.Sprintf("%s %s", students[i].Name, students[i].Surname),
+			students[i].ClassName,
+			students[i].SubjectName,
+			fmt.Sprintf("%.2f", students[i].Average),
+			fmt.Sprint(students[i].Failed),
+		})
+	}
+	server.writeStatsExport(
+		w,
+		format,
+		"Ogroženi učenci",
+		"students-at-risk",
+		[]string{"Učenec", "Razred", "Predmet", "Povprečje", "Negativne"},
+		rows,
+	)
+}
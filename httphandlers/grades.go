@@ -7,9 +7,7 @@ import (
 	"fmt"
 	"github.com/MeetPlan/MeetPlanBackend/helpers"
 	"github.com/MeetPlan/MeetPlanBackend/sql"
-	"github.com/dchest/uniuri"
 	"github.com/gorilla/mux"
-	"github.com/signintech/gopdf"
 	"net/http"
 	"os"
 	"strconv"
@@ -30,8 +28,13 @@ type Grade struct {
 	Period      int
 	Description string
 	CanPatch    bool
-	CreatedAt   string
-	UpdatedAt   string
+	Points      *float32
+	Assessment  string
+	Category    int
+	// Utež pri izračunu povprečja (utež kategorije, pomnožena z utežjo ocenjevanja)
+	Weight    float32
+	CreatedAt string
+	UpdatedAt string
 }
 
 type PeriodGrades struct {
@@ -49,14 +52,11 @@ type UserGradeTable struct {
 	Final    int
 	IsGraded bool
 	Periods  []PeriodGrades
-}
-
-type SubjectPosition struct {
-	X                      float64
-	Y                      float64
-	Name                   string
-	IsThirdLanguage        bool
-	IsDynamicallyAllocated bool
+	// Lestvica ocenjevanja predmeta in zaključna ocena, zapisana po tej lestvici
+	GradingScale sql.GradingScale
+	FinalLabel   string
+	// Pravilo, po katerem je izračunano povprečje
+	GradingPolicy sql.GradingPolicy
 }
 
 type SubjectGradesResponse struct {
@@ -82,6 +82,10 @@ func MakeGradeFromSQLGrade(grade *sql.Grade) Grade {
 		Period:      grade.Period,
 		Description: grade.Description,
 		CanPatch:    grade.CanPatch,
+		Points:      grade.Points,
+		Assessment:  grade.Assessment,
+		Category:    grade.Category,
+		Weight:      1,
 		CreatedAt:   grade.CreatedAt,
 		UpdatedAt:   grade.UpdatedAt,
 		Counts:      true,
@@ -182,6 +186,21 @@ func (server *httpImpl) GetGradesForMeeting(w http.ResponseWriter, r *http.Reque
 		WriteForbiddenJWT(w)
 		return
 	}
+	scale, err := server.getGradingScaleForSubject(subject)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the grading scale", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	policy, err := server.getGradingPolicyForSubject(subject.ID)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the grading policy", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	gradingPeriods, err := server.getGradingPeriods(time.Now())
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving grading periods", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
 	var users []string
 	if subject.InheritsClass {
 		class, err := server.db.GetClass(*subject.ClassID)
@@ -203,9 +222,9 @@ func (server *httpImpl) GetGradesForMeeting(w http.ResponseWriter, r *http.Reque
 	}
 	var usergrades = make([]UserGradeTable, 0)
 	for i := 0; i < len(users); i++ {
-		var period1 = make([]Grade, 0)
-		var period2 = make([]Grade, 0)
+		var subjectGrades = make([]Grade, 0)
 		var final = 0
+		var finalLabel = ""
 		grades, err := server.db.GetGradesForUser(users[i])
 		if err != nil {
 			WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
@@ -247,10 +266,9 @@ func (server *httpImpl) GetGradesForMeeting(w http.ResponseWriter, r *http.Reque
 				if grade.SubjectID == subject.ID {
 					if grade.IsFinal {
 						final = grade.Grade
-					} else if grade.Period == 1 {
-						period1 = append(period1, grade)
-					} else if grade.Period == 2 {
-						period2 = append(period2, grade)
+						finalLabel = scale.Format(sql.Grade{Grade: grade.Grade, Assessment: grade.Assessment})
+					} else {
+						subjectGrades = append(subjectGrades, grade)
 					}
 				}
 			}
@@ -261,54 +279,11 @@ func (server *httpImpl) GetGradesForMeeting(w http.ResponseWriter, r *http.Reque
 			return
 		}
 
-		var firstPeriodTotal = 0
-		var firstPeriodNumber = 0
-		for n := 0; n < len(period1); n++ {
-			if !period1[n].Counts {
-				continue
-			}
-			firstPeriodTotal += period1[n].Grade
-			firstPeriodNumber++
-		}
-
-		var secondPeriodTotal = 0
-		var secondPeriodNumber = 0
-		for n := 0; n < len(period2); n++ {
-			if !period2[n].Counts {
-				continue
-			}
-			secondPeriodTotal += period2[n].Grade
-			secondPeriodNumber++
-		}
-
-		var firstAverage = 0.0
-		if len(period1) != 0 {
-			firstAverage = float64(firstPeriodTotal) / float64(firstPeriodNumber)
-		}
-
-		var secondAverage = 0.0
-		if len(period2) != 0 {
-			secondAverage = float64(secondPeriodTotal) / float64(secondPeriodNumber)
-		}
-
-		var avg = 0.0
-		if !(len(period1) == 0 && len(period2) == 0) {
-			avg = float64(secondPeriodTotal+firstPeriodTotal) / float64(firstPeriodNumber+secondPeriodNumber)
+		periods, avg, err := server.aggregateGradingPeriods(subjectGrades, gradingPeriods, scale, policy)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while calculating averages", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
 		}
-
-		var periods = make([]PeriodGrades, 0)
-		periods = append(periods, PeriodGrades{
-			Period:  1,
-			Grades:  period1,
-			Total:   firstPeriodTotal,
-			Average: firstAverage,
-		})
-		periods = append(periods, PeriodGrades{
-			Period:  2,
-			Grades:  period2,
-			Total:   secondPeriodTotal,
-			Average: secondAverage,
-		})
 		usergrades = append(usergrades, UserGradeTable{
 			ID:       user.ID,
 			Name:     user.Name,
@@ -317,6 +292,10 @@ func (server *httpImpl) GetGradesForMeeting(w http.ResponseWriter, r *http.Reque
 			IsGraded: subject.IsGraded,
 			Average:  avg,
 			Final:    final,
+
+			GradingScale:  scale,
+			FinalLabel:    finalLabel,
+			GradingPolicy: policy,
 		})
 	}
 	WriteJSON(w, Response{
@@ -328,6 +307,24 @@ func (server *httpImpl) GetGradesForMeeting(w http.ResponseWriter, r *http.Reque
 	}, http.StatusOK)
 }
 
+// gradeCategoryFromForm prebere kategorijo ocene (category). Če ni podana, je ocena pisna ali ustna glede na is_written.
+func gradeCategoryFromForm(r *http.Request, isWritten bool) (int, error) {
+	if r.FormValue("category") == "" {
+		if isWritten {
+			return sql.GRADE_CATEGORY_WRITTEN, nil
+		}
+		return sql.GRADE_CATEGORY_ORAL, nil
+	}
+	category, err := strconv.Atoi(r.FormValue("category"))
+	if err != nil {
+		return 0, err
+	}
+	if category < sql.GRADE_CATEGORY_ORAL || category > sql.GRADE_CATEGORY_HOMEWORK {
+		return 0, errors.New("unknown grade category")
+	}
+	return category, nil
+}
+
 func (server *httpImpl) NewGrade(w http.ResponseWriter, r *http.Request) {
 	user, err := server.db.CheckToken(GetAuthorizationToken(r))
 	if err != nil {
@@ -381,9 +378,14 @@ func (server *httpImpl) NewGrade(w http.ResponseWriter, r *http.Request) {
 		WriteForbiddenJWT(w)
 		return
 	}
-	grade, err := strconv.Atoi(r.FormValue("grade"))
+	scale, err := server.getGradingScaleForSubject(subject)
 	if err != nil {
-		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		WriteJSON(w, Response{Data: "Failed while retrieving the grading scale", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	grade, points, assessment, err := gradeFromForm(scale, r.FormValue("grade"), r.FormValue("points"), r.FormValue("assessment"))
+	if err != nil {
+		WriteJSON(w, Response{Data: "Invalid grade for the grading scale", Error: err.Error(), Success: false}, http.StatusBadRequest)
 		return
 	}
 	period, err := strconv.Atoi(r.FormValue("period"))
@@ -396,12 +398,38 @@ func (server *httpImpl) NewGrade(w http.ResponseWriter, r *http.Request) {
 		WriteBadRequest(w)
 		return
 	}
+	category, err := gradeCategoryFromForm(r, isWritten)
+	if err != nil {
+		WriteBadRequest(w)
+		return
+	}
 
 	isFinal, err := strconv.ParseBool(r.FormValue("is_final"))
 	if err != nil {
 		WriteBadRequest(w)
 		return
 	}
+	// Zaključne ocene potrdi redovalna konferenca, učitelj jih lahko samo predlaga.
+	if isFinal && user.Role == TEACHER {
+		WriteJSON(w, Response{Data: "Final grades are confirmed by the class conference", Success: false}, http.StatusForbidden)
+		return
+	}
+
+	periods, err := server.getGradingPeriods(time.Now())
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving grading periods", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	if !isFinal {
+		if _, ok := findGradingPeriod(periods, period); !ok {
+			WriteBadRequest(w)
+			return
+		}
+		if !canEditGradesInPeriod(user, periods, period) {
+			WriteJSON(w, Response{Data: "Grading period is closed", Success: false}, http.StatusConflict)
+			return
+		}
+	}
 
 	canPatch, err := strconv.ParseBool(r.FormValue("can_patch"))
 	if err != nil {
@@ -434,6 +462,9 @@ func (server *httpImpl) NewGrade(w http.ResponseWriter, r *http.Request) {
 		Description: r.FormValue("description"),
 		IsFinal:     isFinal,
 		CanPatch:    canPatch,
+		Points:      points,
+		Assessment:  assessment,
+		Category:    category,
 	}
 
 	err = server.db.InsertGrade(g)
@@ -487,27 +518,58 @@ func (server *httpImpl) PatchGrade(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ngrade, err := strconv.Atoi(r.FormValue("grade"))
+	subject, err := server.db.GetSubject(grade.SubjectID)
 	if err != nil {
 		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
 		return
 	}
+	scale, err := server.getGradingScaleForSubject(subject)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the grading scale", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	ngrade, points, assessment, err := gradeFromForm(scale, r.FormValue("grade"), r.FormValue("points"), r.FormValue("assessment"))
+	if err != nil {
+		WriteJSON(w, Response{Data: "Invalid grade for the grading scale", Error: err.Error(), Success: false}, http.StatusBadRequest)
+		return
+	}
 	period, err := strconv.Atoi(r.FormValue("period"))
 	if err != nil {
 		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
 		return
 	}
+	periods, err := server.getGradingPeriods(time.Now())
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving grading periods", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	if _, ok := findGradingPeriod(periods, period); !ok {
+		WriteBadRequest(w)
+		return
+	}
+	if !canEditGradesInPeriod(user, periods, grade.Period) || !canEditGradesInPeriod(user, periods, period) {
+		WriteJSON(w, Response{Data: "Grading period is closed", Success: false}, http.StatusConflict)
+		return
+	}
 
 	isWritten, err := strconv.ParseBool(r.FormValue("is_written"))
 	if err != nil {
 		WriteBadRequest(w)
 		return
 	}
+	category, err := gradeCategoryFromForm(r, isWritten)
+	if err != nil {
+		WriteBadRequest(w)
+		return
+	}
 
 	grade.Description = r.FormValue("description")
 	grade.Grade = ngrade
+	grade.Points = points
+	grade.Assessment = assessment
 	grade.Period = period
 	grade.IsWritten = isWritten
+	grade.Category = category
 	grade.TeacherID = user.ID
 
 	err = server.db.UpdateGrade(grade)
@@ -556,6 +618,17 @@ func (server *httpImpl) DeleteGrade(w http.ResponseWriter, r *http.Request) {
 		WriteForbiddenJWT(w)
 		return
 	}
+	if !grade.IsFinal {
+		periods, err := server.getGradingPeriods(time.Now())
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while retrieving grading periods", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		if !canEditGradesInPeriod(user, periods, grade.Period) {
+			WriteJSON(w, Response{Data: "Grading period is closed", Success: false}, http.StatusConflict)
+			return
+		}
+	}
 
 	err = server.db.DeleteGrade(gradeId)
 	if err != nil {
@@ -671,46 +744,42 @@ func (server *httpImpl) GetMyGrades(w http.ResponseWriter, r *http.Request) {
 		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
 		return
 	}
+	gradingPeriods, err := server.getGradingPeriods(time.Now())
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving grading periods", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
 	var subjectsResponse = make([]UserGradeTable, 0)
 	for i := 0; i < len(subjects); i++ {
 		subject := subjects[i]
-		var periods = make([]PeriodGrades, 0)
-		var total = 0
-		var gradesCount = 0
+		scale, err := server.getGradingScaleForSubject(subject)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while retrieving the grading scale", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		policy, err := server.getGradingPolicyForSubject(subject.ID)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while retrieving the grading policy", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		var subjectGrades = make([]Grade, 0)
 		var final = 0
-		for n := 1; n <= 2; n++ {
-			var gradesPeriod = make([]Grade, 0)
-			var iGradeCount = 0
-			var iTotal = 0
-			for _, grade := range allGrades {
-				if grade.SubjectID == subject.ID && grade.IsFinal {
-					final = grade.Grade
-				} else if grade.SubjectID == subject.ID && grade.Period == n {
-					gradesPeriod = append(gradesPeriod, grade)
-					if grade.Counts {
-						gradesCount++
-						total += grade.Grade
-						// No, I don't mean you - Apple. i => internal
-						iGradeCount++
-						iTotal += grade.Grade
-					}
-				}
-			}
-			var avg = 0.0
-			if iTotal != 0 && iGradeCount != 0 {
-				avg = float64(iTotal) / float64(iGradeCount)
+		var finalLabel = ""
+		for _, grade := range allGrades {
+			if grade.SubjectID != subject.ID {
+				continue
 			}
-			period := PeriodGrades{
-				Period:  n,
-				Grades:  gradesPeriod,
-				Total:   iTotal,
-				Average: avg,
+			if grade.IsFinal {
+				final = grade.Grade
+				finalLabel = scale.Format(sql.Grade{Grade: grade.Grade, Assessment: grade.Assessment})
+			} else {
+				subjectGrades = append(subjectGrades, grade)
 			}
-			periods = append(periods, period)
 		}
-		var avg = 0.0
-		if total != 0 && gradesCount != 0 {
-			avg = float64(total) / float64(gradesCount)
+		periods, avg, err := server.aggregateGradingPeriods(subjectGrades, gradingPeriods, scale, policy)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while calculating averages", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
 		}
 		grades := UserGradeTable{
 			ID:       subject.ID,
@@ -719,6 +788,10 @@ func (server *httpImpl) GetMyGrades(w http.ResponseWriter, r *http.Request) {
 			Periods:  periods,
 			IsGraded: subject.IsGraded,
 			Final:    final,
+
+			GradingScale:  scale,
+			FinalLabel:    finalLabel,
+			GradingPolicy: policy,
 		}
 		subjectsResponse = append(subjectsResponse, grades)
 	}
@@ -727,163 +800,80 @@ func (server *httpImpl) GetMyGrades(w http.ResponseWriter, r *http.Request) {
 	}, Success: true}, http.StatusOK)
 }
 
-func (server *httpImpl) PrintCertificateOfEndingClass(w http.ResponseWriter, r *http.Request) {
-	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+// certificateTemplateData pripravi podatke za spričevalo učenca z zaključnimi ocenami vseh ocenjenih predmetov.
+func (server *httpImpl) certificateTemplateData(student sql.User, class sql.Class, UUID string) (TemplateData, error) {
+	subjects, err := server.db.GetAllSubjectsForUser(student.ID)
 	if err != nil {
-		WriteForbiddenJWT(w)
-		return
+		return TemplateData{}, err
 	}
-	const x1 = 210
-	const yb = 18
-	const x2 = 485
-	var subjectsPosition = []SubjectPosition{
-		// Pos 1
-		{
-			X:    x1,
-			Y:    yb * 1,
-			Name: "slovenščina",
-		},
-		{
-			X:    x2,
-			Y:    yb * 1,
-			Name: "kemija",
-		},
 
-		// Pos 2
-		{
-			X:    x1,
-			Y:    yb * 2,
-			Name: "matematika",
-		},
-		{
-			X:    x2,
-			Y:    yb * 2,
-			Name: "biologija",
-		},
-
-		// Pos 3
-		{
-			X:                      x1,
-			Y:                      yb * 3,
-			Name:                   "tretji jezik",
-			IsThirdLanguage:        true,
-			IsDynamicallyAllocated: true,
-		},
-		{
-			X:    x2,
-			Y:    yb * 3,
-			Name: "naravoslovje",
-		},
+	values := server.documentTemplateValues(UUID)
+	addUserTemplateValues(values, "student", student)
+	addClassTemplateValues(values, class)
 
-		// Pos 4
-		{
-			X:    x1,
-			Y:    yb * 4,
-			Name: "likovna umetnost",
-		},
-		{
-			X:    x2,
-			Y:    yb * 4,
-			Name: "naravoslovje in tehnika",
-		},
-
-		// Pos 5
-		{
-			X:    x1,
-			Y:    yb * 5,
-			Name: "glasbena umetnost",
-		},
-		{
-			X:    x2,
-			Y:    yb * 5,
-			Name: "tehnika in tehnologija",
-		},
-
-		// Pos 6
-		{
-			X:    x1,
-			Y:    yb * 6,
-			Name: "družba",
-		},
-		{
-			X:    x2,
-			Y:    yb * 6,
-			Name: "gospodinjstvo",
-		},
-
-		// Pos 7
-		{
-			X:    x1,
-			Y:    yb * 7,
-			Name: "geografija",
-		},
-		{
-			X:    x2,
-			Y:    yb * 7,
-			Name: "šport",
-		},
+	var templateSubjects = make([]TemplateSubject, 0)
+	for n := 0; n < len(subjects); n++ {
+		if !subjects[n].IsGraded {
+			continue
+		}
+		grades, err := server.db.GetGradesForUserInSubject(student.ID, subjects[n].ID)
+		if err != nil {
+			return TemplateData{}, err
+		}
+		var final *sql.Grade
+		for x := 0; x < len(grades); x++ {
+			if grades[x].IsFinal {
+				final = &grades[x]
+			}
+		}
+		var grade = "NEOCENJEN"
+		if final != nil {
+			scale, err := server.getGradingScaleForSubject(subjects[n])
+			if err != nil {
+				return TemplateData{}, err
+			}
+			grade = scale.Format(*final)
+		}
+		templateSubjects = append(templateSubjects, TemplateSubject{LongName: subjects[n].LongName, Grade: grade})
+	}
 
-		// Pos 8
-		{
-			X:    x1,
-			Y:    yb * 8,
-			Name: "zgodovina",
-		},
-		{
-			X:                      x2,
-			Y:                      yb * 8,
-			Name:                   "",
-			IsDynamicallyAllocated: true,
-		},
+	lastDate := time.UnixMilli(int64(class.LastSchoolDate * 1000))
+	if class.LastSchoolDate == 0 {
+		// razred nima določenega zadnjega šolskega dne, zato uporabimo konec zadnjega ocenjevalnega obdobja
+		periods, err := server.getGradingPeriods(time.Now())
+		if err != nil {
+			return TemplateData{}, err
+		}
+		lastDate, err = time.Parse("02-01-2006", periods[len(periods)-1].EndDate)
+		if err != nil {
+			return TemplateData{}, err
+		}
+	}
+	year, month, day := lastDate.Date()
+	values["document.date"] = fmt.Sprintf("%s.%s.%s", fmt.Sprint(day), fmt.Sprint(int(month)), fmt.Sprint(year))
+	values["document.number"] = fmt.Sprintf("00/%s/%s", fmt.Sprint(year), UUID)
 
-		// Pos 9
-		{
-			X:    x1,
-			Y:    yb * 9.5,
-			Name: "domovinska in državljanska kultura in etika",
-		},
-		{
-			X:                      x2,
-			Y:                      yb * 9,
-			Name:                   "",
-			IsDynamicallyAllocated: true,
-		},
+	teacher, err := server.db.GetUser(class.Teacher)
+	if err != nil {
+		return TemplateData{}, err
+	}
+	values["class_teacher.name"] = teacher.Name
 
-		// Pos 10
-		{
-			X:    x1,
-			Y:    yb * 11,
-			Name: "spoznavanje okolja",
-		},
-		{
-			X:                      x2,
-			Y:                      yb * 10,
-			Name:                   "",
-			IsDynamicallyAllocated: true,
-		},
+	principal, err := server.db.GetPrincipal()
+	if err != nil {
+		return TemplateData{}, err
+	}
+	values["principal.name"] = principal.Name
 
-		// Pos 11
-		{
-			X:    x1,
-			Y:    yb * 12,
-			Name: "fizika",
-		},
-		{
-			X:                      x2,
-			Y:                      yb * 11,
-			Name:                   "",
-			IsDynamicallyAllocated: true,
-		},
+	return TemplateData{Values: values, Subjects: templateSubjects}, nil
+}
 
-		// Pos 12 (right)
-		{
-			X:                      x2,
-			Y:                      yb * 12,
-			Name:                   "",
-			IsDynamicallyAllocated: true,
-		},
+func (server *httpImpl) PrintCertificateOfEndingClass(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
 	}
-
 	if !(user.Role == ADMIN || user.Role == TEACHER || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
 		WriteForbiddenJWT(w)
 		return
@@ -947,182 +937,29 @@ func (server *httpImpl) PrintCertificateOfEndingClass(w http.ResponseWriter, r *
 		return
 	}
 
-	subjects, err := server.db.GetAllSubjectsForUser(studentId)
-	if err != nil {
-		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
-		return
-	}
-
-	pdf := gopdf.GoPdf{}
-	pdf.Start(gopdf.Config{PageSize: *gopdf.PageSizeA4})
-	pdf.AddPage()
-
-	if server.config.Debug || r.URL.Query().Get("useDocument") == "true" {
-		// Import page 1
-		tpl1 := pdf.ImportPage("officialdocs/spričevalo.pdf", 1, "/MediaBox")
-
-		// Draw pdf onto page
-		pdf.UseImportedTemplate(tpl1, 0, 0, 595, 0)
-	}
-
-	err = pdf.AddTTFFont("opensans", "fonts/opensans.ttf")
-	if err != nil {
-		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
-		return
-	}
-
-	err = pdf.SetFont("opensans", "", 11)
-	if err != nil {
-		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+	supersedes, ok := server.supersededDocument(w, r, user, SPRICEVALO, student.ID)
+	if !ok {
 		return
 	}
 
-	// School info
-	pdf.SetX(50)
-	pdf.SetY(132)
-	pdf.Cell(nil, server.config.SchoolName)
-	pdf.SetX(50)
-	pdf.SetY(157)
-	pdf.Cell(nil, fmt.Sprintf("%s, %s %s, %s", server.config.SchoolAddress, fmt.Sprint(server.config.SchoolPostCode), server.config.SchoolCity, server.config.SchoolCountry))
-
-	// Student info
-	pdf.SetX(50)
-	pdf.SetY(270)
-	pdf.Cell(nil, student.Name)
-
-	pdf.SetY(300)
-	pdf.SetX(50)
-	pdf.Cell(nil, student.Birthday)
-	pdf.SetX(215)
-	pdf.Cell(nil, fmt.Sprintf("%s, %s", student.CityOfBirth, student.CountryOfBirth))
-
-	pdf.SetX(50)
-	pdf.SetY(332)
-	pdf.Cell(nil, student.BirthCertificateNumber)
-	pdf.SetX(215)
-	pdf.Cell(nil, class.Name)
-	pdf.SetX(430)
-	pdf.Cell(nil, class.ClassYear)
-
-	var subjectsAlreadyIn = make([]string, 0)
-
-	for i := 0; i < len(subjectsPosition); i++ {
-		var found = -1
-		var name = ""
-		for n := 0; n < len(subjects); n++ {
-			if !subjects[n].IsGraded {
-				continue
-			}
-			if subjectsPosition[i].IsThirdLanguage {
-				if subjects[n].LongName == "angleščina" || subjects[n].LongName == "madžarščina" || subjects[n].LongName == "italijanščina" {
-					name = subjects[n].LongName
-					found = n
-					break
-				}
-			} else {
-				if subjectsPosition[i].IsDynamicallyAllocated && !helpers.Contains(subjectsAlreadyIn, subjects[n].LongName) {
-					name = subjects[n].LongName
-					found = n
-					break
-				} else if subjectsPosition[i].Name == subjects[n].LongName {
-					name = subjectsPosition[i].Name
-					found = n
-					break
-				}
-			}
-		}
-		var grade = "/"
-		if found != -1 {
-			grades, err := server.db.GetGradesForUserInSubject(studentId, subjects[found].ID)
-			if err != nil {
-				WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
-				return
-			}
-			final := 0
-			for x := 0; x < len(grades); x++ {
-				if grades[x].IsFinal {
-					final = grades[x].Grade
-				}
-			}
-			if final == 0 {
-				grade = "NEOCENJEN"
-			} else {
-				grade = fmt.Sprint(final)
-			}
-		} else {
-			// Student doesn't have this subject
-			grade = "/"
-		}
-		if grade == "5" {
-			grade = fmt.Sprintf("odlično %s", grade)
-		} else if grade == "4" {
-			grade = fmt.Sprintf("prav dobro %s", grade)
-		} else if grade == "3" {
-			grade = fmt.Sprintf("dobro %s", grade)
-		} else if grade == "2" {
-			grade = fmt.Sprintf("zadostno %s", grade)
-		} else if grade == "1" {
-			grade = fmt.Sprintf("nezadostno %s", grade)
-		}
-		server.logger.Debug(name, grade)
-		pdf.SetY(subjectsPosition[i].Y + 372)
-		if subjectsPosition[i].IsDynamicallyAllocated {
-			pdf.SetX(subjectsPosition[i].X - 175)
-			pdf.Cell(nil, name)
-		}
-		pdf.SetX(subjectsPosition[i].X - float64(len(grade)/3)*6)
-		pdf.Cell(nil, grade)
-		subjectsAlreadyIn = append(subjectsAlreadyIn, name)
-	}
-
-	pdf.SetLineWidth(2)
-	pdf.SetLineType("full")
+	UUID := newDocumentID()
 
-	const lineY = 640
-
-	if student.IsPassing {
-		pdf.Line(190, lineY, 335, lineY)
-	} else {
-		pdf.Line(340, lineY, 412, lineY)
-	}
-
-	pdf.SetX(70)
-	pdf.SetY(669)
-	pdf.Cell(nil, fmt.Sprint(class.SOK))
-
-	pdf.SetX(150)
-	pdf.Cell(nil, fmt.Sprint(class.EOK))
-
-	UUID := uniuri.NewLen(10)
-
-	lastDate := time.UnixMilli(int64(class.LastSchoolDate * 1000))
-	year, month, day := lastDate.Date()
-	pdf.SetX(50)
-	pdf.SetY(725)
-	pdf.Cell(nil, fmt.Sprintf("%s.%s.%s", fmt.Sprint(day), fmt.Sprint(int(month)), fmt.Sprint(year)))
-	pdf.SetX(390)
-	pdf.Cell(nil, fmt.Sprintf("00/%s/%s", fmt.Sprint(year), UUID))
-
-	teacher, err := server.db.GetUser(class.Teacher)
+	data, err := server.certificateTemplateData(student, *class, UUID)
 	if err != nil {
-		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		WriteJSON(w, Response{Data: "Failed while preparing the certificate", Error: err.Error(), Success: false}, http.StatusInternalServerError)
 		return
 	}
 
-	pdf.SetX(50)
-	pdf.SetY(770)
-	pdf.Cell(nil, teacher.Name)
-
-	principal, err := server.db.GetPrincipal()
+	output, err := server.renderDocument(
+		TEMPLATE_SPRICEVALO,
+		data,
+		server.config.Debug || r.URL.Query().Get("useDocument") == "true",
+	)
 	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while rendering the document", Error: err.Error(), Success: false}, http.StatusInternalServerError)
 		return
 	}
 
-	pdf.SetX(390)
-	pdf.Cell(nil, principal.Name)
-
-	output := pdf.GetBytesPdf()
-
 	filename := fmt.Sprintf("documents/%s.pdf", UUID)
 
 	err = helpers.Sign(output, filename, "cacerts/key-pair.p12", "")
@@ -1136,8 +973,10 @@ func (server *httpImpl) PrintCertificateOfEndingClass(w http.ResponseWriter, r *
 		ExportedBy:   user.ID,
 		DocumentType: SPRICEVALO,
 		IsSigned:     true,
+		Supersedes:   supersedes,
+		IssuedFor:    &student.ID,
 	}
-	err = server.db.InsertDocument(document)
+	err = server.insertSignedDocument(document)
 	if err != nil {
 		WriteJSON(w, Response{Data: "Failed while inserting Document into database", Error: err.Error(), Success: false}, http.StatusInternalServerError)
 		return
@@ -0,0 +1,175 @@
+package httphandlers
+
+import (
+	"github.com/MeetPlan/MeetPlanBackend/proton"
+	"github.com/MeetPlan/MeetPlanBackend/sql"
+	"net/http"
+	"time"
+)
+
+// GradingPeriod je ocenjevalno obdobje tekočega šolskega leta. Po zaključnem datumu (ClosingDate) so ocene obdobja zaklenjene.
+type GradingPeriod struct {
+	Period      int     `json:"period"`
+	Name        string  `json:"name"`
+	StartDate   string  `json:"start_date"`
+	EndDate     string  `json:"end_date"`
+	ClosingDate *string `json:"closing_date"`
+	IsClosed    bool    `json:"is_closed"`
+}
+
+func (server *httpImpl) getSchoolCalendar(date time.Time) (*proton.SchoolCalendar, error) {
+	events, err := server.db.GetCalendarEvents()
+	if err != nil {
+		return nil, err
+	}
+	return proton.NewSchoolCalendar(events, server.config.SchoolFreeDays, proton.FirstMondayOfSchoolYear(date))
+}
+
+// getGradingPeriods vrne ocenjevalna obdobja šolskega leta, v katerem je podan dan, iz šolskega koledarja.
+// Če v koledarju niso določena, veljata privzeti obdobji: prvo do 15. januarja, drugo od 16. januarja.
+func (server *httpImpl) getGradingPeriods(now time.Time) ([]GradingPeriod, error) {
+	calendar, err := server.getSchoolCalendar(now)
+	if err != nil {
+		return nil, err
+	}
+	periods := make([]GradingPeriod, 0)
+	events := calendar.GradingPeriods(now)
+	for i := 0; i < len(events); i++ {
+		periods = append(periods, GradingPeriod{
+			Period:      *events[i].Term,
+			Name:        events[i].Name,
+			StartDate:   events[i].StartDate,
+			EndDate:     events[i].EndDate,
+			ClosingDate: events[i].ClosingDate,
+		})
+	}
+	if len(periods) == 0 {
+		periods = defaultGradingPeriods(now)
+	}
+	for i := 0; i < len(periods); i++ {
+		if periods[i].ClosingDate == nil {
+			continue
+		}
+		closingDate, err := time.Parse("02-01-2006", *periods[i].ClosingDate)
+		if err != nil {
+			return nil, err
+		}
+		periods[i].IsClosed = now.After(closingDate.AddDate(0, 0, 1))
+	}
+	return periods, nil
+}
+
+// defaultGradingPeriods vrne privzeti ocenjevalni obdobji šolskega leta, v katerem je podan dan.
+func defaultGradingPeriods(now time.Time) []GradingPeriod {
+	year := now.Year()
+	if now.Month() < time.September {
+		year--
+	}
+	return []GradingPeriod{
+		{
+			Period:    1,
+			Name:      "1. ocenjevalno obdobje",
+			StartDate: time.Date(year, time.September, 1, 0, 0, 0, 0, now.Location()).Format("02-01-2006"),
+			EndDate:   time.Date(year+1, time.January, 15, 0, 0, 0, 0, now.Location()).Format("02-01-2006"),
+		},
+		{
+			Period:    2,
+			Name:      "2. ocenjevalno obdobje",
+			StartDate: time.Date(year+1, time.January, 16, 0, 0, 0, 0, now.Location()).Format("02-01-2006"),
+			EndDate:   time.Date(year+1, time.August, 31, 0, 0, 0, 0, now.Location()).Format("02-01-2006"),
+		},
+	}
+}
+
+// gradingPeriodOf vrne ocenjevalno obdobje, v katerem je podan dan. Za dneve med obdobji (npr. počitnice)
+// vrne zadnje obdobje, ki se je že začelo.
+func gradingPeriodOf(periods []GradingPeriod, date time.Time) int {
+	var period = periods[0].Period
+	for i := 0; i < len(periods); i++ {
+		start, err := time.Parse("02-01-2006", periods[i].StartDate)
+		if err != nil || start.After(date) {
+			continue
+		}
+		period = periods[i].Period
+		end, err := time.Parse("02-01-2006", periods[i].EndDate)
+		if err == nil && !end.Before(date) {
+			return period
+		}
+	}
+	return period
+}
+
+// findGradingPeriod vrne ocenjevalno obdobje s podano številko.
+func findGradingPeriod(periods []GradingPeriod, period int) (GradingPeriod, bool) {
+	for i := 0; i < len(periods); i++ {
+		if periods[i].Period == period {
+			return periods[i], true
+		}
+	}
+	return GradingPeriod{}, false
+}
+
+// canEditGradesInPeriod preveri, ali sme uporabnik spreminjati ocene obdobja. Zaklenjena obdobja lahko ureja samo vodstvo.
+func canEditGradesInPeriod(user sql.User, periods []GradingPeriod, period int) bool {
+	p, ok := findGradingPeriod(periods, period)
+	if !ok {
+		return false
+	}
+	return !p.IsClosed || user.Role == ADMIN || user.Role == PRINCIPAL
+}
+
+// aggregateGradingPeriods razdeli ocene predmeta po ocenjevalnih obdobjih in izračuna vsote in povprečja
+// obdobij ter povprečje celega leta.
+func (server *httpImpl) aggregateGradingPeriods(grades []Grade, periods []GradingPeriod, scale sql.GradingScale, policy sql.GradingPolicy) ([]PeriodGrades, float64, error) {
+	result := make([]PeriodGrades, 0)
+	all := make([]Grade, 0)
+	for i := 0; i < len(periods); i++ {
+		periodGrades := make([]Grade, 0)
+		var total = 0
+		for n := 0; n < len(grades); n++ {
+			if grades[n].Period != periods[i].Period {
+				continue
+			}
+			periodGrades = append(periodGrades, grades[n])
+			if grades[n].Counts {
+				total += grades[n].Grade
+			}
+		}
+		err := server.setGradeWeights(periodGrades, policy)
+		if err != nil {
+			return nil, 0, err
+		}
+		all = append(all, periodGrades...)
+
+		// Pri opisnih ocenah in ocenah opravil/ni opravil povprečje nima pomena.
+		var avg = 0.0
+		if scale.IsAveraged() {
+			avg = calculateAverage(periodGrades, policy)
+		}
+		result = append(result, PeriodGrades{
+			Period:  periods[i].Period,
+			Grades:  periodGrades,
+			Total:   total,
+			Average: avg,
+		})
+	}
+	var avg = 0.0
+	if scale.IsAveraged() {
+		avg = calculateAverage(all, policy)
+	}
+	return result, avg, nil
+}
+
+func (server *httpImpl) GetGradingPeriods(w http.ResponseWriter, r *http.Request) {
+	_, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	periods, err := server.getGradingPeriods(time.Now())
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving grading periods", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: periods, Success: true}, http.StatusOK)
+}
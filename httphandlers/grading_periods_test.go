@@ -0,0 +1,85 @@
+package httphandlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDefaultGradingPeriods(t *testing.T) {
+	tests := []struct {
+		name    string
+		now     time.Time
+		periods [][2]string
+	}{
+		{"start of school year", time.Date(2023, time.September, 1, 8, 0, 0, 0, time.UTC), [][2]string{{"01-09-2023", "15-01-2024"}, {"16-01-2024", "31-08-2024"}}},
+		{"before new year", time.Date(2023, time.December, 20, 8, 0, 0, 0, time.UTC), [][2]string{{"01-09-2023", "15-01-2024"}, {"16-01-2024", "31-08-2024"}}},
+		{"after new year", time.Date(2024, time.March, 4, 8, 0, 0, 0, time.UTC), [][2]string{{"01-09-2023", "15-01-2024"}, {"16-01-2024", "31-08-2024"}}},
+		{"summer holidays", time.Date(2024, time.August, 31, 8, 0, 0, 0, time.UTC), [][2]string{{"01-09-2023", "15-01-2024"}, {"16-01-2024", "31-08-2024"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			periods := defaultGradingPeriods(test.now)
+			if len(periods) != len(test.periods) {
+				t.Fatalf("got %d periods, expected %d", len(periods), len(test.periods))
+			}
+			for i := 0; i < len(periods); i++ {
+				if periods[i].Period != i+1 || periods[i].StartDate != test.periods[i][0] || periods[i].EndDate != test.periods[i][1] {
+					t.Fatalf("got period %d from %s to %s, expected period %d from %s to %s", periods[i].Period, periods[i].StartDate, periods[i].EndDate, i+1, test.periods[i][0], test.periods[i][1])
+				}
+				if periods[i].ClosingDate != nil || periods[i].IsClosed {
+					t.Fatalf("default period %d must not be closed", periods[i].Period)
+				}
+			}
+		})
+	}
+}
+
+func TestGradingPeriodOf(t *testing.T) {
+	periods := []GradingPeriod{
+		{Period: 1, StartDate: "01-09-2023", EndDate: "22-12-2023"},
+		{Period: 2, StartDate: "08-01-2024", EndDate: "15-03-2024"},
+		{Period: 3, StartDate: "18-03-2024", EndDate: "24-06-2024"},
+	}
+	tests := []struct {
+		name   string
+		date   time.Time
+		period int
+	}{
+		{"first day of first period", time.Date(2023, time.September, 1, 0, 0, 0, 0, time.UTC), 1},
+		{"inside first period", time.Date(2023, time.October, 10, 0, 0, 0, 0, time.UTC), 1},
+		{"last day of first period", time.Date(2023, time.December, 22, 0, 0, 0, 0, time.UTC), 1},
+		{"during last day of first period", time.Date(2023, time.December, 22, 13, 30, 0, 0, time.UTC), 1},
+		{"between first and second period", time.Date(2024, time.January, 2, 0, 0, 0, 0, time.UTC), 1},
+		{"first day of second period", time.Date(2024, time.January, 8, 0, 0, 0, 0, time.UTC), 2},
+		{"weekend between second and third period", time.Date(2024, time.March, 16, 0, 0, 0, 0, time.UTC), 2},
+		{"inside last period", time.Date(2024, time.May, 6, 0, 0, 0, 0, time.UTC), 3},
+		{"after last period", time.Date(2024, time.July, 15, 0, 0, 0, 0, time.UTC), 3},
+		{"before first period", time.Date(2023, time.August, 28, 0, 0, 0, 0, time.UTC), 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			period := gradingPeriodOf(periods, test.date)
+			if period != test.period {
+				t.Fatalf("got period %d, expected %d", period, test.period)
+			}
+		})
+	}
+}
+
+func TestGradingPeriodOfDefaultPeriods(t *testing.T) {
+	tests := []struct {
+		date   time.Time
+		period int
+	}{
+		{time.Date(2023, time.November, 15, 0, 0, 0, 0, time.UTC), 1},
+		{time.Date(2024, time.January, 15, 0, 0, 0, 0, time.UTC), 1},
+		{time.Date(2024, time.January, 16, 0, 0, 0, 0, time.UTC), 2},
+		{time.Date(2024, time.June, 20, 0, 0, 0, 0, time.UTC), 2},
+	}
+	for _, test := range tests {
+		period := gradingPeriodOf(defaultGradingPeriods(test.date), test.date)
+		if period != test.period {
+			t.Fatalf("%s: got period %d, expected %d", test.date.Format("02-01-2006"), period, test.period)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package httphandlers
+
+import (
+	sql2 "database/sql"
+	"errors"
+	"github.com/MeetPlan/MeetPlanBackend/sql"
+	"github.com/gorilla/mux"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// getGradingPolicyForSubject vrne pravilo za izračun povprečja predmeta oziroma privzeto pravilo, če ni nastavljeno.
+func (server *httpImpl) getGradingPolicyForSubject(subjectId string) (sql.GradingPolicy, error) {
+	policy, err := server.db.GetGradingPolicyForSubject(subjectId)
+	if errors.Is(err, sql2.ErrNoRows) {
+		return sql.DefaultGradingPolicy(subjectId), nil
+	}
+	return policy, err
+}
+
+// setGradeWeights nastavi uteži ocen glede na kategorijo ocene in utež ocenjevanja, iz katerega ocena izhaja.
+func (server *httpImpl) setGradeWeights(grades []Grade, policy sql.GradingPolicy) error {
+	termWeights := make(map[string]float32)
+	for i := 0; i < len(grades); i++ {
+		weight := policy.CategoryWeight(grades[i].Category)
+		if grades[i].TermID != nil {
+			termWeight, exists := termWeights[*grades[i].TermID]
+			if !exists {
+				term, err := server.db.GetGradingTerm(*grades[i].TermID)
+				if err != nil {
+					return err
+				}
+				grading, err := server.db.GetGrading(term.GradingID)
+				if err != nil {
+					return err
+				}
+				termWeight = grading.Weight
+				termWeights[*grades[i].TermID] = termWeight
+			}
+			weight *= termWeight
+		}
+		grades[i].Weight = weight
+	}
+	return nil
+}
+
+// calculateAverage izračuna povprečje ocen, ki štejejo, po pravilu predmeta.
+// Vsi pogledi (učenec, starš, učitelj) uporabljajo ta izračun, da se povprečja ujemajo.
+func calculateAverage(grades []Grade, policy sql.GradingPolicy) float64 {
+	values := make([]float64, 0)
+	var weightedTotal = 0.0
+	var weights = 0.0
+	for i := 0; i < len(grades); i++ {
+		if !grades[i].Counts {
+			continue
+		}
+		values = append(values, float64(grades[i].Grade))
+		weightedTotal += float64(grades[i].Grade) * float64(grades[i].Weight)
+		weights += float64(grades[i].Weight)
+	}
+	if len(values) == 0 {
+		return 0
+	}
+
+	if policy.AverageType == sql.AVERAGE_MEDIAN {
+		sort.Float64s(values)
+		if len(values)%2 == 1 {
+			return values[len(values)/2]
+		}
+		return (values[len(values)/2-1] + values[len(values)/2]) / 2
+	}
+
+	if policy.AverageType == sql.AVERAGE_BEST_N {
+		sort.Sort(sort.Reverse(sort.Float64Slice(values)))
+		if policy.BestN > 0 && policy.BestN < len(values) {
+			values = values[:policy.BestN]
+		}
+		var total = 0.0
+		for i := 0; i < len(values); i++ {
+			total += values[i]
+		}
+		return total / float64(len(values))
+	}
+
+	if weights == 0 {
+		return 0
+	}
+	return weightedTotal / weights
+}
+
+func (server *httpImpl) GetGradingPolicy(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if user.Role == UNVERIFIED || user.Role == FOOD_ORGANIZER {
+		WriteForbiddenJWT(w)
+		return
+	}
+	subject, err := server.db.GetSubject(mux.Vars(r)["subject_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the subject", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	policy, err := server.getGradingPolicyForSubject(subject.ID)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the grading policy", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: policy, Success: true}, http.StatusOK)
+}
+
+// PatchGradingPolicy nastavi način izračuna povprečja in uteži kategorij ocen za predmet.
+// Polja, ki niso podana, ostanejo nespremenjena.
+func (server *httpImpl) PatchGradingPolicy(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == TEACHER || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	subject, err := server.db.GetSubject(mux.Vars(r)["subject_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the subject", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	if user.Role == TEACHER && subject.TeacherID != user.ID {
+		WriteForbiddenJWT(w)
+		return
+	}
+	policy, err := server.getGradingPolicyForSubject(subject.ID)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the grading policy", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	if r.FormValue("average_type") != "" {
+		averageType, err := strconv.Atoi(r.FormValue("average_type"))
+		if err != nil || averageType < sql.AVERAGE_WEIGHTED_MEAN || averageType > sql.AVERAGE_BEST_N {
+			WriteBadRequest(w)
+			return
+		}
+		policy.AverageType = averageType
+	}
+	if r.FormValue("best_n") != "" {
+		bestN, err := strconv.Atoi(r.FormValue("best_n"))
+		if err != nil || bestN < 0 {
+			WriteBadRequest(w)
+			return
+		}
+		policy.BestN = bestN
+	}
+	weights := map[string]*float32{
+		"oral_weight":     &policy.OralWeight,
+		"written_weight":  &policy.WrittenWeight,
+		"other_weight":    &policy.OtherWeight,
+		"project_weight":  &policy.ProjectWeight,
+		"homework_weight": &policy.HomeworkWeight,
+	}
+	for key, weight := range weights {
+		if r.FormValue(key) == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(r.FormValue(key), 32)
+		if err != nil || value < 0 {
+			WriteBadRequest(w)
+			return
+		}
+		*weight = float32(value)
+	}
+
+	err = server.db.SetGradingPolicy(policy)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while saving the grading policy", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: policy, Success: true}, http.StatusOK)
+}
@@ -0,0 +1,40 @@
+package httphandlers
+
+import (
+	"github.com/MeetPlan/MeetPlanBackend/sql"
+	"math"
+	"testing"
+)
+
+func TestCalculateAverage(t *testing.T) {
+	grade := func(value int, weight float32, counts bool) Grade {
+		return Grade{Grade: value, Weight: weight, Counts: counts}
+	}
+	tests := []struct {
+		name    string
+		grades  []Grade
+		policy  sql.GradingPolicy
+		average float64
+	}{
+		{"no grades", []Grade{}, sql.GradingPolicy{AverageType: sql.AVERAGE_WEIGHTED_MEAN}, 0},
+		{"only grades that don't count", []Grade{grade(5, 1, false)}, sql.GradingPolicy{AverageType: sql.AVERAGE_WEIGHTED_MEAN}, 0},
+		{"arithmetic mean", []Grade{grade(5, 1, true), grade(4, 1, true), grade(3, 1, true)}, sql.GradingPolicy{AverageType: sql.AVERAGE_WEIGHTED_MEAN}, 4},
+		{"weighted mean", []Grade{grade(5, 2, true), grade(2, 1, true)}, sql.GradingPolicy{AverageType: sql.AVERAGE_WEIGHTED_MEAN}, 4},
+		{"retaken grade doesn't count", []Grade{grade(1, 1, false), grade(4, 1, true), grade(2, 1, true)}, sql.GradingPolicy{AverageType: sql.AVERAGE_WEIGHTED_MEAN}, 3},
+		{"zero weights", []Grade{grade(5, 0, true)}, sql.GradingPolicy{AverageType: sql.AVERAGE_WEIGHTED_MEAN}, 0},
+		{"median of odd count", []Grade{grade(1, 1, true), grade(5, 1, true), grade(4, 1, true)}, sql.GradingPolicy{AverageType: sql.AVERAGE_MEDIAN}, 4},
+		{"median of even count", []Grade{grade(1, 1, true), grade(5, 1, true), grade(4, 1, true), grade(2, 1, true)}, sql.GradingPolicy{AverageType: sql.AVERAGE_MEDIAN}, 3},
+		{"median ignores weights", []Grade{grade(1, 10, true), grade(5, 1, true), grade(4, 1, true)}, sql.GradingPolicy{AverageType: sql.AVERAGE_MEDIAN}, 4},
+		{"best n", []Grade{grade(2, 1, true), grade(5, 1, true), grade(4, 1, true)}, sql.GradingPolicy{AverageType: sql.AVERAGE_BEST_N, BestN: 2}, 4.5},
+		{"best n larger than count", []Grade{grade(2, 1, true), grade(5, 1, true)}, sql.GradingPolicy{AverageType: sql.AVERAGE_BEST_N, BestN: 5}, 3.5},
+		{"best n not set", []Grade{grade(2, 1, true), grade(5, 1, true)}, sql.GradingPolicy{AverageType: sql.AVERAGE_BEST_N}, 3.5},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			average := calculateAverage(test.grades, test.policy)
+			if math.Abs(average-test.average) > 1e-9 {
+				t.Fatalf("got average %f, expected %f", average, test.average)
+			}
+		})
+	}
+}
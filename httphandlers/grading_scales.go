@@ -0,0 +1,268 @@
+package httphandlers
+
+import (
+	"errors"
+	"github.com/MeetPlan/MeetPlanBackend/sql"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// getGradingScaleForSubject vrne lestvico predmeta, sicer lestvico razreda predmeta, sicer privzeto lestvico 1-5.
+func (server *httpImpl) getGradingScaleForSubject(subject sql.Subject) (sql.GradingScale, error) {
+	if subject.GradingScaleID != nil {
+		return server.db.GetGradingScale(*subject.GradingScaleID)
+	}
+	if subject.ClassID != nil {
+		class, err := server.db.GetClass(*subject.ClassID)
+		if err != nil {
+			return sql.GradingScale{}, err
+		}
+		if class.GradingScaleID != nil {
+			return server.db.GetGradingScale(*class.GradingScaleID)
+		}
+	}
+	return sql.DefaultGradingScale(), nil
+}
+
+// gradeFromForm prebere oceno glede na lestvico: pri točkovni lestvici iz točk (points), pri opisni lestvici
+// iz besedila (assessment), sicer iz številčne ocene (grade). Vrne oceno, točke in besedilo opisne ocene.
+func gradeFromForm(scale sql.GradingScale, grade string, points string, assessment string) (int, *float32, string, error) {
+	if scale.ScaleType == sql.GRADING_SCALE_DESCRIPTIVE {
+		assessment = strings.TrimSpace(assessment)
+		if assessment == "" {
+			return 0, nil, "", errors.New("descriptive grade is empty")
+		}
+		return 0, nil, assessment, nil
+	}
+	if scale.ScaleType == sql.GRADING_SCALE_POINTS {
+		p, err := strconv.ParseFloat(points, 32)
+		if err != nil {
+			return 0, nil, "", err
+		}
+		value := float32(p)
+		g, err := scale.GradeFromPoints(value)
+		if err != nil {
+			return 0, nil, "", err
+		}
+		return g, &value, "", nil
+	}
+	g, err := strconv.Atoi(grade)
+	if err != nil {
+		return 0, nil, "", err
+	}
+	if !scale.IsValidGrade(g) {
+		return 0, nil, "", errors.New("grade is out of range for the grading scale")
+	}
+	return g, nil, "", nil
+}
+
+// gradingScaleFromForm prebere definicijo lestvice iz obrazca. Pri lestvicah opravil/ni opravil in opisnih
+// lestvicah so meje ocen določene vnaprej.
+func gradingScaleFromForm(r *http.Request, scale sql.GradingScale) (sql.GradingScale, error) {
+	var err error
+	scale.Name = strings.TrimSpace(r.FormValue("name"))
+	if scale.Name == "" {
+		return scale, errors.New("name is empty")
+	}
+	scale.ScaleType, err = strconv.Atoi(r.FormValue("scale_type"))
+	if err != nil {
+		return scale, err
+	}
+	scale.Labels = r.FormValue("labels")
+	if scale.Labels == "" {
+		scale.Labels = "[]"
+	}
+	scale.PointsMapping = r.FormValue("points_mapping")
+	if scale.PointsMapping == "" {
+		scale.PointsMapping = "[]"
+	}
+	scale.MaxPoints = 0
+	if scale.ScaleType == sql.GRADING_SCALE_PASS_FAIL {
+		scale.MinGrade = 0
+		scale.MaxGrade = 1
+		scale.PassingGrade = 1
+	} else if scale.ScaleType == sql.GRADING_SCALE_DESCRIPTIVE {
+		scale.MinGrade = 0
+		scale.MaxGrade = 0
+		scale.PassingGrade = 0
+	} else {
+		scale.MinGrade, err = strconv.Atoi(r.FormValue("min_grade"))
+		if err != nil {
+			return scale, err
+		}
+		scale.MaxGrade, err = strconv.Atoi(r.FormValue("max_grade"))
+		if err != nil {
+			return scale, err
+		}
+		scale.PassingGrade, err = strconv.Atoi(r.FormValue("passing_grade"))
+		if err != nil {
+			return scale, err
+		}
+		if scale.ScaleType == sql.GRADING_SCALE_POINTS {
+			maxPoints, err := strconv.ParseFloat(r.FormValue("max_points"), 32)
+			if err != nil {
+				return scale, err
+			}
+			scale.MaxPoints = float32(maxPoints)
+		}
+	}
+	return scale, scale.Validate()
+}
+
+func (server *httpImpl) GetGradingScales(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == TEACHER || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT || user.Role == SCHOOL_PSYCHOLOGIST) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	scales, err := server.db.GetGradingScales()
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving grading scales", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: scales, Success: true}, http.StatusOK)
+}
+
+func (server *httpImpl) NewGradingScale(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	scale, err := gradingScaleFromForm(r, sql.GradingScale{})
+	if err != nil {
+		WriteJSON(w, Response{Data: "Invalid grading scale", Error: err.Error(), Success: false}, http.StatusBadRequest)
+		return
+	}
+	err = server.db.InsertGradingScale(scale)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while inserting the grading scale", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusCreated)
+}
+
+func (server *httpImpl) PatchGradingScale(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	scale, err := server.db.GetGradingScale(mux.Vars(r)["grading_scale_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the grading scale", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	scale, err = gradingScaleFromForm(r, scale)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Invalid grading scale", Error: err.Error(), Success: false}, http.StatusBadRequest)
+		return
+	}
+	err = server.db.UpdateGradingScale(scale)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while updating the grading scale", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusOK)
+}
+
+func (server *httpImpl) DeleteGradingScale(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	err = server.db.DeleteGradingScale(mux.Vars(r)["grading_scale_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while deleting the grading scale", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusOK)
+}
+
+// getGradingScaleIDFromRequest prebere grading_scale_id iz obrazca. Prazna vrednost pomeni, da se lestvica odstrani.
+func (server *httpImpl) getGradingScaleIDFromRequest(r *http.Request) (*string, error) {
+	scaleId := r.FormValue("grading_scale_id")
+	if scaleId == "" {
+		return nil, nil
+	}
+	_, err := server.db.GetGradingScale(scaleId)
+	if err != nil {
+		return nil, err
+	}
+	return &scaleId, nil
+}
+
+func (server *httpImpl) PatchSubjectGradingScale(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	subject, err := server.db.GetSubject(mux.Vars(r)["subject_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the subject", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	scaleId, err := server.getGradingScaleIDFromRequest(r)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the grading scale", Error: err.Error(), Success: false}, http.StatusBadRequest)
+		return
+	}
+	err = server.db.SetSubjectGradingScale(subject.ID, scaleId)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while updating the subject", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusOK)
+}
+
+func (server *httpImpl) PatchClassGradingScale(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	class, err := server.db.GetClass(mux.Vars(r)["class_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the class", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	scaleId, err := server.getGradingScaleIDFromRequest(r)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the grading scale", Error: err.Error(), Success: false}, http.StatusBadRequest)
+		return
+	}
+	err = server.db.SetClassGradingScale(class.ID, scaleId)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while updating the class", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusOK)
+}
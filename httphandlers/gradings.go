@@ -77,25 +77,41 @@ func (server *httpImpl) NewGrading(w http.ResponseWriter, r *http.Request) {
 	// 0 = ustno
 	// 1 = pisno
 	// 2 = drugo
+	// 3 = projekt
+	// 4 = domača naloga
 	gradingType, err := strconv.Atoi(r.FormValue("grading_type"))
 	if err != nil {
 		WriteBadRequest(w)
 		return
 	}
-	if gradingType < 0 || gradingType > 2 {
+	if gradingType < sql.GRADE_CATEGORY_ORAL || gradingType > sql.GRADE_CATEGORY_HOMEWORK {
 		WriteBadRequest(w)
 		return
 	}
 
+	var weight float32 = 1
+	if r.FormValue("weight") != "" {
+		w64, err := strconv.ParseFloat(r.FormValue("weight"), 32)
+		if err != nil || w64 < 0 {
+			WriteBadRequest(w)
+			return
+		}
+		weight = float32(w64)
+	}
+
 	// 0 = celoletno
-	// 1 = prvo ocenjevalno obdobje
-	// 2 = drugo ocenjevalno obdobje
+	// 1..N = ocenjevalno obdobje iz šolskega koledarja
 	period, err := strconv.Atoi(r.FormValue("period"))
 	if err != nil {
 		WriteBadRequest(w)
 		return
 	}
-	if period < 0 || period > 2 {
+	periods, err := server.getGradingPeriods(time.Now())
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving grading periods", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	if _, ok := findGradingPeriod(periods, period); period != 0 && !ok {
 		WriteBadRequest(w)
 		return
 	}
@@ -108,6 +124,7 @@ func (server *httpImpl) NewGrading(w http.ResponseWriter, r *http.Request) {
 		GradingType: gradingType,
 		SchoolYear:  helpers.GetCurrentSchoolYear(),
 		Period:      period,
+		Weight:      weight,
 	}
 
 	err = server.db.InsertGrading(grading)
@@ -288,6 +305,15 @@ func (server *httpImpl) NewGradingTerm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var overrideReason = ""
+	if grading.GradingType == sql.GRADE_CATEGORY_WRITTEN {
+		reason, ok := server.checkWrittenAssessmentLimits(w, r, user, subject, []time.Time{dateParsed}, []string{})
+		if !ok {
+			return
+		}
+		overrideReason = reason
+	}
+
 	gradingTerm := sql.GradingTerm{
 		TeacherID:           subject.TeacherID,
 		GradingID:           grading.ID,
@@ -304,6 +330,13 @@ func (server *httpImpl) NewGradingTerm(w http.ResponseWriter, r *http.Request) {
 		WriteJSON(w, Response{Data: "Error whilst inserting a grading", Error: err.Error(), Success: false}, http.StatusInternalServerError)
 		return
 	}
+	if overrideReason != "" {
+		err = server.recordWrittenAssessmentOverrides(user, subject, []time.Time{dateParsed}, hour, overrideReason)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while recording the override", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+	}
 	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusCreated)
 }
 
@@ -359,16 +392,30 @@ func (server *httpImpl) PatchGrading(w http.ResponseWriter, r *http.Request) {
 
 	gradingType, err := strconv.Atoi(r.FormValue("grading_type"))
 	if err == nil {
-		if gradingType < 0 || gradingType > 3 {
+		if gradingType < sql.GRADE_CATEGORY_ORAL || gradingType > sql.GRADE_CATEGORY_HOMEWORK {
 			WriteBadRequest(w)
 			return
 		}
 		grading.GradingType = gradingType
 	}
 
+	weight, err := strconv.ParseFloat(r.FormValue("weight"), 32)
+	if err == nil {
+		if weight < 0 {
+			WriteBadRequest(w)
+			return
+		}
+		grading.Weight = float32(weight)
+	}
+
 	period, err := strconv.Atoi(r.FormValue("period"))
 	if err == nil {
-		if period < 0 || period > 3 {
+		periods, err := server.getGradingPeriods(time.Now())
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while retrieving grading periods", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		if _, ok := findGradingPeriod(periods, period); period != 0 && !ok {
 			WriteBadRequest(w)
 			return
 		}
@@ -481,6 +528,41 @@ func (server *httpImpl) PatchGradingTerm(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	scale, err := server.getGradingScaleForSubject(subject)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the grading scale", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	// Datum preverimo pred vpisom ocen, da zavrnjena sprememba roka ne pusti delno vpisanih ocen.
+	// Omejitve pisnih ocenjevanj preverimo samo, če se rok pisnega ocenjevanja prestavi na drug dan.
+	var newDate time.Time
+	var overrideReason = ""
+	date := r.FormValue("date")
+	if date != "" {
+		newDate, err = time.Parse("2006-01-02", date)
+		if err != nil {
+			WriteBadRequest(w)
+			return
+		}
+		dateFmt := newDate.Format("2006-01-02")
+		if grading.GradingType == sql.GRADE_CATEGORY_WRITTEN && dateFmt != gradingTerm.Date {
+			reason, ok := server.checkWrittenAssessmentLimits(w, r, user, subject, []time.Time{newDate}, []string{gradingTerm.ID})
+			if !ok {
+				return
+			}
+			overrideReason = reason
+		}
+		gradingTerm.Date = dateFmt
+	}
+
+	now := time.Now()
+	periods, err := server.getGradingPeriods(now)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving grading periods", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
 	students := server.db.GetStudentsFromSubject(&subject)
 	for _, student := range students {
 		studentUser, err := server.db.GetUser(student)
@@ -489,29 +571,31 @@ func (server *httpImpl) PatchGradingTerm(w http.ResponseWriter, r *http.Request)
 		}
 		fv := r.FormValue(fmt.Sprintf("%s.grade", studentUser.ID))
 		if fv == "null" {
-			server.db.DeleteGradeByTermAndUser(gradingTermId, studentUser.ID)
+			gradeDb, err := server.db.GetGradeForTermAndUser(gradingTermId, studentUser.ID)
+			if err == nil && canEditGradesInPeriod(user, periods, gradeDb.Period) {
+				server.db.DeleteGradeByTermAndUser(gradingTermId, studentUser.ID)
+			}
 			continue
 		}
-		grade, err := strconv.Atoi(fv)
+		grade, points, assessment, err := gradeFromForm(
+			scale,
+			fv,
+			r.FormValue(fmt.Sprintf("%s.points", studentUser.ID)),
+			r.FormValue(fmt.Sprintf("%s.assessment", studentUser.ID)),
+		)
 		if err != nil {
 			continue
 		}
-		if grade <= 0 || grade > 5 {
-			continue
-		}
 		gradeDb, err := server.db.GetGradeForTermAndUser(gradingTermId, studentUser.ID)
 		if errors.Is(err, sql2.ErrNoRows) {
-			now := time.Now()
-
 			// če je grading.Period == 0, to pomeni, da je celoletno
 			// v takem primeru prilagodimo obdobje na datum vpisa ocene
 			period := grading.Period
 			if grading.Period == 0 {
-				if (now.Month() == time.January && time.Now().Day() <= 15) || (now.Month() >= 9) {
-					period = 1
-				} else {
-					period = 2
-				}
+				period = gradingPeriodOf(periods, now)
+			}
+			if !canEditGradesInPeriod(user, periods, period) {
+				continue
 			}
 
 			g := sql.Grade{
@@ -526,6 +610,9 @@ func (server *httpImpl) PatchGradingTerm(w http.ResponseWriter, r *http.Request)
 				Period:      period,
 				Description: fmt.Sprintf("%d. rok; %s; %s", gradingTerm.Term, grading.Name, grading.Description),
 				CanPatch:    true,
+				Points:      points,
+				Assessment:  assessment,
+				Category:    grading.GradingType,
 			}
 			server.db.InsertGrade(g)
 			continue
@@ -533,10 +620,12 @@ func (server *httpImpl) PatchGradingTerm(w http.ResponseWriter, r *http.Request)
 			server.logger.Errorw("error while fetching a grade", "err", err)
 			continue
 		}
-		if !gradeDb.CanPatch {
+		if !gradeDb.CanPatch || !canEditGradesInPeriod(user, periods, gradeDb.Period) {
 			continue
 		}
 		gradeDb.Grade = grade
+		gradeDb.Points = points
+		gradeDb.Assessment = assessment
 		server.db.UpdateGrade(gradeDb)
 	}
 
@@ -597,22 +686,18 @@ func (server *httpImpl) PatchGradingTerm(w http.ResponseWriter, r *http.Request)
 		gradingTerm.Term = term
 	}
 
-	date := r.FormValue("date")
-	if date != "" {
-		dateParsed, err := time.Parse("2006-01-02", date)
-		if err != nil {
-			WriteBadRequest(w)
-			return
-		}
-		dateFmt := dateParsed.Format("2006-01-02")
-		gradingTerm.Date = dateFmt
-	}
-
 	err = server.db.UpdateGradingTerm(gradingTerm)
 	if err != nil {
 		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
 		return
 	}
+	if overrideReason != "" {
+		err = server.recordWrittenAssessmentOverrides(user, subject, []time.Time{newDate}, gradingTerm.Hour, overrideReason)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while recording the override", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+	}
 
 	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusOK)
 }
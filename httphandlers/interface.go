@@ -6,6 +6,7 @@ import (
 	"github.com/signintech/gopdf"
 	"go.uber.org/zap"
 	"net/http"
+	"sync"
 )
 
 type Response struct {
@@ -19,6 +20,8 @@ type httpImpl struct {
 	db     sql.SQL
 	config sql.Config
 	proton proton.Proton
+	// Ščiti config.DocumentRetentionDays, ki ga bere brisanje dokumentov v ozadju
+	retentionMutex sync.RWMutex
 }
 
 type HTTP interface {
@@ -33,7 +36,7 @@ type HTTP interface {
 	GetAbsencesUser(w http.ResponseWriter, r *http.Request)
 	CertificateOfSchooling(w http.ResponseWriter, r *http.Request)
 	ResetPassword(w http.ResponseWriter, r *http.Request)
-	GenerateNewUserCert(pdf *gopdf.GoPdf, userId string) (*gopdf.GoPdf, string, error)
+	GenerateNewUserCert(pdf *gopdf.GoPdf, definition DocumentTemplateDefinition, userId string) (*gopdf.GoPdf, string, error)
 	ChangePassword(w http.ResponseWriter, r *http.Request)
 
 	// testing.go
@@ -65,6 +68,7 @@ type HTTP interface {
 	DeleteMeeting(w http.ResponseWriter, r *http.Request)
 	GetMeeting(w http.ResponseWriter, r *http.Request)
 	GetAbsencesTeacher(w http.ResponseWriter, r *http.Request)
+	PutAbsencesForMeeting(w http.ResponseWriter, r *http.Request)
 	PatchAbsence(w http.ResponseWriter, r *http.Request)
 	GetUsersForMeeting(w http.ResponseWriter, r *http.Request)
 	MigrateBetaMeetings(w http.ResponseWriter, r *http.Request)
@@ -147,9 +151,105 @@ type HTTP interface {
 	NewProtonRule(w http.ResponseWriter, r *http.Request)
 	GetProtonRules(w http.ResponseWriter, r *http.Request)
 	AssembleTimetable(w http.ResponseWriter, r *http.Request)
+	AssemblePartialTimetable(w http.ResponseWriter, r *http.Request)
 	AcceptAssembledTimetable(w http.ResponseWriter, r *http.Request)
+	AcceptTimetable(protonMeetings []proton.ProtonMeeting, name string, createdBy string) (sql.TimetableVersion, error)
 	ManualPostProcessRepeat(w http.ResponseWriter, r *http.Request)
 	DeleteProtonRule(w http.ResponseWriter, r *http.Request)
+	GetProtonConfigVersions(w http.ResponseWriter, r *http.Request)
+	RestoreProtonConfigVersion(w http.ResponseWriter, r *http.Request)
+
+	// timetable.go
+	ImportTimetable(w http.ResponseWriter, r *http.Request)
+	ExportAssembledTimetable(w http.ResponseWriter, r *http.Request)
+	ExportCurrentTimetable(w http.ResponseWriter, r *http.Request)
+	GetTimetableVersions(w http.ResponseWriter, r *http.Request)
+	PublishTimetableVersion(w http.ResponseWriter, r *http.Request)
+	RollbackTimetableVersion(w http.ResponseWriter, r *http.Request)
+	DeleteTimetableVersion(w http.ResponseWriter, r *http.Request)
+	DiffTimetableVersions(w http.ResponseWriter, r *http.Request)
+
+	// calendar.go
+	GetCalendar(w http.ResponseWriter, r *http.Request)
+	NewCalendarEvent(w http.ResponseWriter, r *http.Request)
+	DeleteCalendarEvent(w http.ResponseWriter, r *http.Request)
+	ImportCalendar(w http.ResponseWriter, r *http.Request)
+
+	// substitutions.go
+	GetTeacherAbsences(w http.ResponseWriter, r *http.Request)
+	NewTeacherAbsence(w http.ResponseWriter, r *http.Request)
+	DeleteTeacherAbsence(w http.ResponseWriter, r *http.Request)
+	PlanTeacherAbsenceSubstitutions(w http.ResponseWriter, r *http.Request)
+	ApplyTeacherAbsenceSubstitutions(w http.ResponseWriter, r *http.Request)
+	RevertTeacherAbsenceSubstitutions(w http.ResponseWriter, r *http.Request)
+	GetSubstitutionStats(w http.ResponseWriter, r *http.Request)
+	PatchSubstitutionPaid(w http.ResponseWriter, r *http.Request)
+	GetSubstitutionReport(w http.ResponseWriter, r *http.Request)
+
+	// excuses.go
+	GetExcusesForStudent(w http.ResponseWriter, r *http.Request)
+	GetPendingExcuses(w http.ResponseWriter, r *http.Request)
+	GetExcuse(w http.ResponseWriter, r *http.Request)
+	NewExcuse(w http.ResponseWriter, r *http.Request)
+	GetExcuseDocument(w http.ResponseWriter, r *http.Request)
+	ApproveExcuse(w http.ResponseWriter, r *http.Request)
+	RejectExcuse(w http.ResponseWriter, r *http.Request)
+	WithdrawExcuse(w http.ResponseWriter, r *http.Request)
+
+	// absence_stats.go
+	GetAbsenceStatsForStudent(w http.ResponseWriter, r *http.Request)
+	GetAbsenceStatsForClass(w http.ResponseWriter, r *http.Request)
+	GetAbsenceAlertsForStudent(w http.ResponseWriter, r *http.Request)
+
+	// planned_absences.go
+	GetPlannedAbsences(w http.ResponseWriter, r *http.Request)
+	NewPlannedAbsence(w http.ResponseWriter, r *http.Request)
+	DeletePlannedAbsence(w http.ResponseWriter, r *http.Request)
+
+	// grading_scales.go
+	GetGradingScales(w http.ResponseWriter, r *http.Request)
+	NewGradingScale(w http.ResponseWriter, r *http.Request)
+	PatchGradingScale(w http.ResponseWriter, r *http.Request)
+	DeleteGradingScale(w http.ResponseWriter, r *http.Request)
+	PatchSubjectGradingScale(w http.ResponseWriter, r *http.Request)
+	PatchClassGradingScale(w http.ResponseWriter, r *http.Request)
+
+	// grading_policy.go
+	GetGradingPolicy(w http.ResponseWriter, r *http.Request)
+	PatchGradingPolicy(w http.ResponseWriter, r *http.Request)
+
+	// grading_periods.go
+	GetGradingPeriods(w http.ResponseWriter, r *http.Request)
+
+	// final_grades.go
+	GetFinalGradeProposals(w http.ResponseWriter, r *http.Request)
+	PatchFinalGradeProposal(w http.ResponseWriter, r *http.Request)
+	DeleteFinalGradeProposal(w http.ResponseWriter, r *http.Request)
+	GetClassConference(w http.ResponseWriter, r *http.Request)
+	ConfirmClassConference(w http.ResponseWriter, r *http.Request)
+	GetClassConferenceMinutes(w http.ResponseWriter, r *http.Request)
+
+	// grade_stats.go
+	GetGradingTermStats(w http.ResponseWriter, r *http.Request)
+	GetSubjectComparison(w http.ResponseWriter, r *http.Request)
+	GetStudentsAtRisk(w http.ResponseWriter, r *http.Request)
+
+	// written_assessments.go
+	GetTestCalendar(w http.ResponseWriter, r *http.Request)
+
+	// lesson_log.go
+	GetLessonLog(w http.ResponseWriter, r *http.Request)
+	PostLessonLog(w http.ResponseWriter, r *http.Request)
+	SignLessonLog(w http.ResponseWriter, r *http.Request)
+	UnsignLessonLog(w http.ResponseWriter, r *http.Request)
+	GetSubjectRegister(w http.ResponseWriter, r *http.Request)
+	GetMissingLessonLogs(w http.ResponseWriter, r *http.Request)
+
+	// substitution_board.go
+	GetSubstitutionBoard(w http.ResponseWriter, r *http.Request)
+	GetSubstitutionBoardPDF(w http.ResponseWriter, r *http.Request)
+	NewKioskToken(w http.ResponseWriter, r *http.Request)
+	DeleteKioskToken(w http.ResponseWriter, r *http.Request)
 
 	// improvements.go
 	NewImprovement(w http.ResponseWriter, r *http.Request)
@@ -157,7 +257,25 @@ type HTTP interface {
 
 	// documents.go
 	FetchAllDocuments(w http.ResponseWriter, r *http.Request)
-	DeleteDocument(w http.ResponseWriter, r *http.Request)
+	RevokeDocument(w http.ResponseWriter, r *http.Request)
+	SetDocumentLegalHold(w http.ResponseWriter, r *http.Request)
+	DeleteExpiredDocuments()
+	RunDocumentRetention()
+
+	// document_templates.go
+	GetDocumentTemplates(w http.ResponseWriter, r *http.Request)
+	GetDocumentTemplate(w http.ResponseWriter, r *http.Request)
+	NewDocumentTemplateVersion(w http.ResponseWriter, r *http.Request)
+	ActivateDocumentTemplate(w http.ResponseWriter, r *http.Request)
+
+	// document_batches.go
+	NewDocumentBatch(w http.ResponseWriter, r *http.Request)
+	GetDocumentBatch(w http.ResponseWriter, r *http.Request)
+	GetDocumentBatchFile(w http.ResponseWriter, r *http.Request)
+	FailInterruptedDocumentBatches()
+
+	// verification.go
+	VerifyDocument(w http.ResponseWriter, r *http.Request)
 }
 
 func NewHTTPInterface(logger *zap.SugaredLogger, db sql.SQL, config sql.Config, proton proton.Proton) HTTP {
@@ -0,0 +1,312 @@
+package httphandlers
+
+import (
+	"bytes"
+	sql2 "database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/MeetPlan/MeetPlanBackend/sql"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// getMeetingForLessonLog vrne srečanje in predmet, če lahko uporabnik ureja vpis v dnevnik tega srečanja.
+// Vpis lahko ureja učitelj predmeta ali učitelj, ki je uro izvedel (npr. nadomeščanje).
+func (server *httpImpl) getMeetingForLessonLog(user sql.User, meetingId string) (sql.Meeting, sql.Subject, bool, error) {
+	meeting, err := server.db.GetMeeting(meetingId)
+	if err != nil {
+		return meeting, sql.Subject{}, false, err
+	}
+	subject, err := server.db.GetSubject(meeting.SubjectID)
+	if err != nil {
+		return meeting, subject, false, err
+	}
+	if user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT {
+		return meeting, subject, true, nil
+	}
+	return meeting, subject, user.Role == TEACHER && (subject.TeacherID == user.ID || meeting.TeacherID == user.ID), nil
+}
+
+func (server *httpImpl) GetLessonLog(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	_, _, ok, err := server.getMeetingForLessonLog(user, mux.Vars(r)["meeting_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the meeting", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	if !ok {
+		WriteForbiddenJWT(w)
+		return
+	}
+	log, err := server.db.GetLessonLogForMeeting(mux.Vars(r)["meeting_id"])
+	if err != nil {
+		if errors.Is(err, sql2.ErrNoRows) {
+			WriteJSON(w, Response{Data: nil, Success: true}, http.StatusOK)
+			return
+		}
+		WriteJSON(w, Response{Data: "Failed while retrieving the lesson log", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: log, Success: true}, http.StatusOK)
+}
+
+// PostLessonLog ustvari ali posodobi vpis v dnevnik za srečanje. Zaporedna številka ure (lesson_number) ni obvezna;
+// če ni podana, se novemu vpisu dodeli naslednja številka pri predmetu. Podpisanega vpisa ni mogoče spreminjati.
+func (server *httpImpl) PostLessonLog(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	meeting, subject, ok, err := server.getMeetingForLessonLog(user, mux.Vars(r)["meeting_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the meeting", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	if !ok {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if meeting.IsBeta {
+		WriteJSON(w, Response{Data: "Cannot log a beta meeting", Success: false}, http.StatusConflict)
+		return
+	}
+	topic := strings.TrimSpace(r.FormValue("topic"))
+	if topic == "" {
+		WriteBadRequest(w)
+		return
+	}
+	lessonNumber, err := optionalIntFormValue(r, "lesson_number")
+	if err != nil || (lessonNumber != nil && *lessonNumber < 1) {
+		WriteBadRequest(w)
+		return
+	}
+
+	log, err := server.db.GetLessonLogForMeeting(meeting.ID)
+	if err != nil {
+		if !errors.Is(err, sql2.ErrNoRows) {
+			WriteJSON(w, Response{Data: "Failed while retrieving the lesson log", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		log = sql.LessonLog{
+			MeetingID: meeting.ID,
+			SubjectID: subject.ID,
+			TeacherID: user.ID,
+			Topic:     topic,
+			Notes:     r.FormValue("notes"),
+		}
+		if lessonNumber != nil {
+			log.LessonNumber = *lessonNumber
+		}
+		log, err = server.db.InsertLessonLog(log)
+		if errors.Is(err, sql.ErrDuplicateLessonNumber) {
+			WriteJSON(w, Response{Data: "Lesson number is already used", Error: err.Error(), Success: false}, http.StatusConflict)
+			return
+		}
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while inserting the lesson log", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		WriteJSON(w, Response{Data: log, Success: true}, http.StatusCreated)
+		return
+	}
+
+	if log.IsSigned {
+		WriteJSON(w, Response{Data: "The lesson log is already signed", Success: false}, http.StatusConflict)
+		return
+	}
+	log.TeacherID = user.ID
+	log.Topic = topic
+	log.Notes = r.FormValue("notes")
+	if lessonNumber != nil {
+		log.LessonNumber = *lessonNumber
+	}
+	err = server.db.UpdateLessonLog(log)
+	if errors.Is(err, sql.ErrDuplicateLessonNumber) {
+		WriteJSON(w, Response{Data: "Lesson number is already used", Error: err.Error(), Success: false}, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while updating the lesson log", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: log, Success: true}, http.StatusOK)
+}
+
+func (server *httpImpl) SignLessonLog(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	_, _, ok, err := server.getMeetingForLessonLog(user, mux.Vars(r)["meeting_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the meeting", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	if !ok {
+		WriteForbiddenJWT(w)
+		return
+	}
+	log, err := server.db.GetLessonLogForMeeting(mux.Vars(r)["meeting_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the lesson log", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	if log.IsSigned {
+		WriteJSON(w, Response{Data: "The lesson log is already signed", Success: false}, http.StatusConflict)
+		return
+	}
+	err = server.db.SignLessonLog(log.ID, true)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while signing the lesson log", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusOK)
+}
+
+// UnsignLessonLog odstrani podpis z vpisa v dnevnik, da ga lahko učitelj popravi. Dovoljeno je samo vodstvu šole.
+func (server *httpImpl) UnsignLessonLog(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	log, err := server.db.GetLessonLogForMeeting(mux.Vars(r)["meeting_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the lesson log", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	err = server.db.SignLessonLog(log.ID, false)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while unsigning the lesson log", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusOK)
+}
+
+// GetSubjectRegister vrne dnevnik predmeta (vse vpise po zaporednih številkah ur). Format je json (privzeto) ali csv.
+func (server *httpImpl) GetSubjectRegister(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == TEACHER || user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT || user.Role == SCHOOL_PSYCHOLOGIST) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	subject, err := server.db.GetSubject(mux.Vars(r)["subject_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the subject", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	if user.Role == TEACHER && subject.TeacherID != user.ID {
+		WriteForbiddenJWT(w)
+		return
+	}
+	logs, err := server.db.GetLessonLogsForSubject(subject.ID)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the lesson logs", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" || format == "json" {
+		WriteJSON(w, Response{Data: logs, Success: true}, http.StatusOK)
+		return
+	}
+	if format != "csv" {
+		WriteJSON(w, Response{Data: "Unsupported format", Success: false}, http.StatusBadRequest)
+		return
+	}
+
+	teachers := make(map[string]string)
+	var output bytes.Buffer
+	writer := csv.NewWriter(&output)
+	_ = writer.Write([]string{"lesson_number", "date", "hour", "teacher", "topic", "notes", "is_signed", "signed_at"})
+	for i := 0; i < len(logs); i++ {
+		log := logs[i]
+		teacher, exists := teachers[log.TeacherID]
+		if !exists {
+			t, err := server.db.GetUser(log.TeacherID)
+			if err != nil {
+				WriteJSON(w, Response{Data: "Failed while retrieving the teacher", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+				return
+			}
+			teacher = t.Name
+			teachers[log.TeacherID] = teacher
+		}
+		signedAt := ""
+		if log.SignedAt != nil {
+			signedAt = *log.SignedAt
+		}
+		_ = writer.Write([]string{
+			fmt.Sprint(log.LessonNumber),
+			log.Date,
+			fmt.Sprint(log.Hour),
+			teacher,
+			log.Topic,
+			log.Notes,
+			strconv.FormatBool(log.IsSigned),
+			signedAt,
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		WriteJSON(w, Response{Data: "Failed while exporting the register", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"register-%s.csv\"", subject.ID))
+	w.Write(output.Bytes())
+}
+
+// GetMissingLessonLogs vrne izvedena srečanja med start in end (DD-MM-YYYY), ki še nimajo vpisa v dnevnik.
+// Prihodnja srečanja niso upoštevana. Učitelj vidi samo svoja srečanja.
+func (server *httpImpl) GetMissingLessonLogs(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == TEACHER || user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	start, err := time.Parse("02-01-2006", r.URL.Query().Get("start"))
+	if err != nil {
+		WriteBadRequest(w)
+		return
+	}
+	end, err := time.Parse("02-01-2006", r.URL.Query().Get("end"))
+	if err != nil || end.Before(start) {
+		WriteBadRequest(w)
+		return
+	}
+	if today := time.Now(); end.After(today) {
+		end = today
+	}
+	teacherId := ""
+	if user.Role == TEACHER {
+		teacherId = user.ID
+	}
+	meetings, err := server.db.GetMeetingsWithoutLessonLog(start.Format("02-01-2006"), end.Format("02-01-2006"), teacherId)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving meetings", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: meetings, Success: true}, http.StatusOK)
+}
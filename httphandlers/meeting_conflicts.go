@@ -0,0 +1,129 @@
+package httphandlers
+
+import (
+	"encoding/json"
+	"github.com/MeetPlan/MeetPlanBackend/helpers"
+	"github.com/MeetPlan/MeetPlanBackend/sql"
+	"net/http"
+	"strings"
+)
+
+const CONFLICT_TEACHER = "teacher"
+const CONFLICT_STUDENT = "student"
+const CONFLICT_ROOM = "room"
+
+// MeetingConflict opisuje obstoječe srečanje, ki poteka ob istem času kot novo ali spremenjeno srečanje.
+type MeetingConflict struct {
+	Type        string `json:"type"`
+	Date        string `json:"date"`
+	Hour        int    `json:"hour"`
+	MeetingID   string `json:"meeting_id"`
+	MeetingName string `json:"meeting_name"`
+	// Učenci, ki imajo ob istem času drugo srečanje (samo pri CONFLICT_STUDENT)
+	Students []string `json:"students,omitempty"`
+}
+
+// getSubjectStudents vrne učence predmeta, bodisi iz razreda bodisi iz seznama učencev predmeta.
+func (server *httpImpl) getSubjectStudents(subject sql.Subject) ([]string, error) {
+	var students []string
+	if subject.InheritsClass {
+		class, err := server.db.GetClass(*subject.ClassID)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(class.Students), &students)
+		return students, err
+	}
+	err := json.Unmarshal([]byte(subject.Students), &students)
+	return students, err
+}
+
+// findMeetingConflicts poišče srečanja ob istem času, ki jih ima isti učitelj, kateri od učencev ali ki potekajo
+// v istem prostoru. Srečanja z ID-ji iz ignore (npr. srečanja, ki jih ravno spreminjamo) se ne upoštevajo.
+// Beta srečanja so osnutek urnika, zato se upoštevajo samo pri preverjanju beta srečanj.
+func (server *httpImpl) findMeetingConflicts(meeting sql.Meeting, students []string, ignore []string) ([]MeetingConflict, error) {
+	conflicts := make([]MeetingConflict, 0)
+	meetings, err := server.db.GetMeetingsOnSpecificTime(meeting.Date, meeting.Hour)
+	if err != nil {
+		return nil, err
+	}
+	location := strings.ToLower(strings.TrimSpace(meeting.Location))
+	for i := 0; i < len(meetings); i++ {
+		other := meetings[i]
+		if other.ID == meeting.ID || helpers.Contains(ignore, other.ID) || (other.IsBeta && !meeting.IsBeta) {
+			continue
+		}
+		conflict := MeetingConflict{
+			Date:        other.Date,
+			Hour:        other.Hour,
+			MeetingID:   other.ID,
+			MeetingName: other.MeetingName,
+		}
+		if other.TeacherID == meeting.TeacherID {
+			conflict.Type = CONFLICT_TEACHER
+			conflicts = append(conflicts, conflict)
+		}
+		if location != "" && strings.ToLower(strings.TrimSpace(other.Location)) == location {
+			conflict.Type = CONFLICT_ROOM
+			conflicts = append(conflicts, conflict)
+		}
+		if other.SubjectID == meeting.SubjectID {
+			conflict.Type = CONFLICT_STUDENT
+			conflict.Students = students
+			conflicts = append(conflicts, conflict)
+			continue
+		}
+		subject, err := server.db.GetSubject(other.SubjectID)
+		if err != nil {
+			return nil, err
+		}
+		otherStudents, err := server.getSubjectStudents(subject)
+		if err != nil {
+			return nil, err
+		}
+		shared := make([]string, 0)
+		for n := 0; n < len(otherStudents); n++ {
+			if helpers.Contains(students, otherStudents[n]) {
+				shared = append(shared, otherStudents[n])
+			}
+		}
+		if len(shared) != 0 {
+			conflict.Type = CONFLICT_STUDENT
+			conflict.Students = shared
+			conflicts = append(conflicts, conflict)
+		}
+	}
+	return conflicts, nil
+}
+
+// canOverrideConflicts preveri, ali je uporabnik zahteval (override_conflicts=true) in sme shraniti srečanje kljub prekrivanju.
+func canOverrideConflicts(user sql.User, r *http.Request) bool {
+	return r.FormValue("override_conflicts") == "true" && (user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT)
+}
+
+// checkMeetingConflicts preveri prekrivanja za vsa podana srečanja. Če prekrivanja obstajajo in jih uporabnik ne sme
+// ali ne želi prezreti, zapiše odgovor s seznamom prekrivanj in vrne false.
+func (server *httpImpl) checkMeetingConflicts(w http.ResponseWriter, r *http.Request, user sql.User, meetings []sql.Meeting, subject sql.Subject, ignore []string) bool {
+	if canOverrideConflicts(user, r) {
+		return true
+	}
+	students, err := server.getSubjectStudents(subject)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving students of the subject", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return false
+	}
+	conflicts := make([]MeetingConflict, 0)
+	for i := 0; i < len(meetings); i++ {
+		c, err := server.findMeetingConflicts(meetings[i], students, ignore)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while checking meeting conflicts", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return false
+		}
+		conflicts = append(conflicts, c...)
+	}
+	if len(conflicts) != 0 {
+		WriteJSON(w, Response{Data: conflicts, Error: "The meeting conflicts with existing meetings", Success: false}, http.StatusConflict)
+		return false
+	}
+	return true
+}
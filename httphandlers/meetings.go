@@ -4,8 +4,11 @@ import (
 	sql2 "database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/MeetPlan/MeetPlanBackend/helpers"
+	"github.com/MeetPlan/MeetPlanBackend/proton"
 	"github.com/MeetPlan/MeetPlanBackend/sql"
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"net/http"
 	"strconv"
@@ -13,6 +16,11 @@ import (
 	"time"
 )
 
+// Obseg spremembe ali izbrisa srečanja, ki je del serije
+const MEETING_SCOPE_OCCURRENCE = "occurrence"
+const MEETING_SCOPE_FOLLOWING = "following"
+const MEETING_SCOPE_SERIES = "series"
+
 type Meeting struct {
 	sql.Meeting
 	TeacherName string
@@ -23,6 +31,10 @@ type Meeting struct {
 type TimetableDate struct {
 	Meetings [][]Meeting `json:"meetings"`
 	Date     string      `json:"date"`
+	// Dogodki iz šolskega koledarja, teden (0 - A, 1 - B) in dan v tednu, po katerem poteka pouk (nil, če ni pouka)
+	Events       []sql.CalendarEvent `json:"events"`
+	Week         int                 `json:"week"`
+	TimetableDay *int                `json:"timetable_day"`
 }
 
 type Absence struct {
@@ -30,6 +42,8 @@ type Absence struct {
 	TeacherName string
 	UserName    string
 	MeetingName string
+	// Napovedana odsotnost, ki pokriva srečanje (razlog je prikazan na seznamu učencev)
+	PlannedAbsence *sql.PlannedAbsence
 }
 
 func (server *httpImpl) GetTimetable(w http.ResponseWriter, r *http.Request) {
@@ -162,9 +176,31 @@ func (server *httpImpl) GetTimetable(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	firstDate, err := time.Parse("02-01-2006", startDate)
+	if err != nil {
+		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	events, err := server.db.GetCalendarEvents()
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the school calendar", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	calendar, err := proton.NewSchoolCalendar(events, server.config.SchoolFreeDays, proton.FirstMondayOfSchoolYear(firstDate))
+	if err != nil {
+		WriteJSON(w, Response{Data: "Invalid school calendar", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
 	var meetingsJson = make([]TimetableDate, 0)
 	for i := 0; i < len(dates); i++ {
 		date := dates[i]
+		calendarDate, err := time.Parse("02-01-2006", date)
+		if err != nil {
+			WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		timetableDay, hasLessons := calendar.TimetableDay(calendarDate)
 		meetings, err := server.db.GetMeetingsOnSpecificDate(date,
 			user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT || user.Role == TEACHER || user.Role == SCHOOL_PSYCHOLOGIST,
 		)
@@ -179,6 +215,15 @@ func (server *httpImpl) GetTimetable(w http.ResponseWriter, r *http.Request) {
 			if err != nil {
 				return
 			}
+			// Srečanja iz urnika (ne pa ročno ustvarjena) na dneve brez pouka ne prikažemo.
+			if meeting.ProtonConfigVersion != nil {
+				if !hasLessons {
+					continue
+				}
+				if subject.InheritsClass && subject.ClassID != nil && calendar.IsClassFreeDay(calendarDate, *subject.ClassID) {
+					continue
+				}
+			}
 			var u []string
 			if subject.InheritsClass {
 				class, err := server.db.GetClass(*subject.ClassID)
@@ -279,12 +324,31 @@ func (server *httpImpl) GetTimetable(w http.ResponseWriter, r *http.Request) {
 			}
 			dateMeetingsJson = append(dateMeetingsJson, hour)
 		}
-		ttdate := TimetableDate{Date: date, Meetings: dateMeetingsJson}
+		ttdate := TimetableDate{Date: date, Meetings: dateMeetingsJson, Events: calendar.EventsOn(calendarDate), Week: calendar.WeekOf(calendarDate)}
+		if hasLessons {
+			ttdate.TimetableDay = &timetableDay
+		}
 		meetingsJson = append(meetingsJson, ttdate)
 	}
 	WriteJSON(w, Response{Data: meetingsJson, Success: true}, http.StatusOK)
 }
 
+// getMeetingsInScope vrne srečanja serije, na katera vpliva sprememba srečanja meeting:
+// MEETING_SCOPE_FOLLOWING - to in vsa naslednja srečanja, MEETING_SCOPE_SERIES - celotna serija.
+// Že izvedena srečanja (pretekla ali z vezanimi podatki) se ne spreminjajo.
+func (server *httpImpl) getMeetingsInScope(meeting sql.Meeting, scope string) ([]sql.Meeting, error) {
+	if meeting.SeriesID == nil {
+		return nil, errors.New("meeting is not part of a series")
+	}
+	if scope == MEETING_SCOPE_FOLLOWING {
+		return server.db.GetEditableMeetingsInSeries(*meeting.SeriesID, meeting.Date)
+	}
+	if scope == MEETING_SCOPE_SERIES {
+		return server.db.GetEditableMeetingsInSeries(*meeting.SeriesID, "")
+	}
+	return nil, errors.New(fmt.Sprintf("unknown scope %s", scope))
+}
+
 func (server *httpImpl) NewMeeting(w http.ResponseWriter, r *http.Request) {
 	user, err := server.db.CheckToken(GetAuthorizationToken(r))
 	if err != nil {
@@ -345,10 +409,14 @@ func (server *httpImpl) NewMeeting(w http.ResponseWriter, r *http.Request) {
 		isTest = true
 	}
 
+	// Ponavljanje: repeat_cycle je število tednov med srečanji (1 - tedensko, 2 - vsak drugi teden) do last_date.
+	// Dnevi brez pouka (prazniki, počitnice, prosti dnevi iz konfiguracije) se preskočijo, tudi če je to prvi datum.
+	var seriesId *string
 	if r.FormValue("last_date") != "" {
+		dates = make([]string, 0)
 		repeatCycle, err := strconv.Atoi(r.FormValue("repeat_cycle"))
-		if err != nil {
-			WriteJSON(w, Response{Error: err.Error(), Data: "Failed at converting repeat_cycle to int", Success: false}, http.StatusBadRequest)
+		if err != nil || repeatCycle < 1 {
+			WriteJSON(w, Response{Data: "Failed at converting repeat_cycle to a positive int", Success: false}, http.StatusBadRequest)
 			return
 		}
 		lastDate, err := time.Parse("02-01-2006", r.FormValue("last_date"))
@@ -361,16 +429,34 @@ func (server *httpImpl) NewMeeting(w http.ResponseWriter, r *http.Request) {
 			WriteJSON(w, Response{Error: err.Error(), Data: "Failed at converting date to Time", Success: false}, http.StatusBadRequest)
 			return
 		}
-		for {
-			m := 24 * 7 * repeatCycle
-			date = date.Add(time.Hour * time.Duration(m))
-			if date.After(lastDate) {
-				break
+		calendar, err := server.getSchoolCalendar(date)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while retrieving the school calendar", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		for ; !date.After(lastDate); date = date.AddDate(0, 0, 7*repeatCycle) {
+			if calendar.IsSchoolFreeDay(date) {
+				continue
 			}
 			dates = append(dates, date.Format("02-01-2006"))
 		}
+		if len(dates) == 0 {
+			WriteJSON(w, Response{Data: "All dates of the series are school-free days", Success: false}, http.StatusBadRequest)
+			return
+		}
+		if len(dates) > 1 {
+			id := uuid.New().String()
+			seriesId = &id
+		}
 	}
 
+	subject, err := server.db.GetSubject(subjectId)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the subject", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+
+	meetings := make([]sql.Meeting, 0)
 	for i := 0; i < len(dates); i++ {
 		date := dates[i]
 
@@ -390,11 +476,42 @@ func (server *httpImpl) NewMeeting(w http.ResponseWriter, r *http.Request) {
 			IsCorrectionTest:    isCorrectionTest,
 			IsSubstitution:      false,
 			IsBeta:              false,
+			SeriesID:            seriesId,
 		}
+		meetings = append(meetings, meeting)
+	}
+
+	if !server.checkMeetingConflicts(w, r, user, meetings, subject, []string{}) {
+		return
+	}
+
+	var assessmentDates = make([]time.Time, 0)
+	var overrideReason = ""
+	if countsAsWrittenAssessment(isWrittenAssessment, isTest) {
+		for i := 0; i < len(meetings); i++ {
+			d, err := time.Parse("02-01-2006", meetings[i].Date)
+			if err != nil {
+				WriteBadRequest(w)
+				return
+			}
+			assessmentDates = append(assessmentDates, d)
+		}
+		reason, ok := server.checkWrittenAssessmentLimits(w, r, user, subject, assessmentDates, []string{})
+		if !ok {
+			return
+		}
+		overrideReason = reason
+	}
 
-		err = server.db.InsertMeeting(meeting)
+	err = server.db.InsertMeetings(meetings)
+	if err != nil {
+		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	if overrideReason != "" {
+		err = server.recordWrittenAssessmentOverrides(user, subject, assessmentDates, hour, overrideReason)
 		if err != nil {
-			WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			WriteJSON(w, Response{Data: "Failed while recording the override", Error: err.Error(), Success: false}, http.StatusInternalServerError)
 			return
 		}
 	}
@@ -482,6 +599,98 @@ func (server *httpImpl) PatchMeeting(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	scope := r.FormValue("scope")
+	if scope != "" && scope != MEETING_SCOPE_OCCURRENCE {
+		// Nadomeščanje vedno velja za posamezno srečanje.
+		if isSubstitution {
+			WriteBadRequest(w)
+			return
+		}
+		meetings, err := server.getMeetingsInScope(originalmeeting, scope)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while retrieving the meeting series", Error: err.Error(), Success: false}, http.StatusBadRequest)
+			return
+		}
+		if len(meetings) == 0 {
+			WriteJSON(w, Response{Data: "All meetings in this scope have already been held", Success: false}, http.StatusConflict)
+			return
+		}
+		// Sprememba datuma se prenese na vsa srečanja kot zamik za enako število dni.
+		originalDate, err := time.Parse("02-01-2006", originalmeeting.Date)
+		if err != nil {
+			WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		newDate, err := time.Parse("02-01-2006", date)
+		if err != nil {
+			WriteBadRequest(w)
+			return
+		}
+		shift := int(newDate.Sub(originalDate).Hours() / 24)
+		calendar, err := server.getSchoolCalendar(newDate)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while retrieving the school calendar", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		ignore := make([]string, 0)
+		assessmentDates := make([]time.Time, 0)
+		assessmentIds := make([]string, 0)
+		for i := 0; i < len(meetings); i++ {
+			occurrenceDate, err := time.Parse("02-01-2006", meetings[i].Date)
+			if err != nil {
+				WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+				return
+			}
+			occurrenceDate = occurrenceDate.AddDate(0, 0, shift)
+			if shift != 0 && calendar.IsSchoolFreeDay(occurrenceDate) {
+				WriteJSON(w, Response{Data: fmt.Sprintf("%s is a school-free day", occurrenceDate.Format("02-01-2006")), Success: false}, http.StatusConflict)
+				return
+			}
+			// Omejitve pisnih ocenjevanj preverimo za srečanja, ki so se premaknila ali so na novo postala ocenjevanja.
+			if countsAsWrittenAssessment(isWrittenAssessment, isTest) && (shift != 0 || !countsAsWrittenAssessment(meetings[i].IsWrittenAssessment, meetings[i].IsTest)) {
+				assessmentDates = append(assessmentDates, occurrenceDate)
+				assessmentIds = append(assessmentIds, meetings[i].ID)
+			}
+			meetings[i].MeetingName = name
+			meetings[i].Hour = hour
+			meetings[i].Date = occurrenceDate.Format("02-01-2006")
+			meetings[i].IsMandatory = isMandatory
+			meetings[i].URL = url
+			meetings[i].Details = details
+			meetings[i].IsGrading = isGrading
+			meetings[i].IsWrittenAssessment = isWrittenAssessment
+			meetings[i].IsTest = isTest
+			meetings[i].IsCorrectionTest = isCorrectionTest
+			meetings[i].Location = r.FormValue("location")
+			ignore = append(ignore, meetings[i].ID)
+		}
+		if !server.checkMeetingConflicts(w, r, user, meetings, subject, ignore) {
+			return
+		}
+		var overrideReason = ""
+		if len(assessmentDates) != 0 {
+			reason, ok := server.checkWrittenAssessmentLimits(w, r, user, subject, assessmentDates, assessmentIds)
+			if !ok {
+				return
+			}
+			overrideReason = reason
+		}
+		err = server.db.UpdateMeetings(meetings)
+		if err != nil {
+			WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		if overrideReason != "" {
+			err = server.recordWrittenAssessmentOverrides(user, subject, assessmentDates, hour, overrideReason)
+			if err != nil {
+				WriteJSON(w, Response{Data: "Failed while recording the override", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+				return
+			}
+		}
+		WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusOK)
+		return
+	}
+
 	meeting := sql.Meeting{
 		ID:                  id,
 		MeetingName:         name,
@@ -501,11 +710,55 @@ func (server *httpImpl) PatchMeeting(w http.ResponseWriter, r *http.Request) {
 		Location:            r.FormValue("location"),
 	}
 
+	if !server.checkMeetingConflicts(w, r, user, []sql.Meeting{meeting}, subject, []string{}) {
+		return
+	}
+
+	// Omejitve pisnih ocenjevanj preverimo, če se ocenjevanje premakne na drug dan ali srečanje na novo postane ocenjevanje.
+	var assessmentDates = make([]time.Time, 0)
+	var overrideReason = ""
+	if countsAsWrittenAssessment(isWrittenAssessment, isTest) && (date != originalmeeting.Date || !countsAsWrittenAssessment(originalmeeting.IsWrittenAssessment, originalmeeting.IsTest)) {
+		d, err := time.Parse("02-01-2006", date)
+		if err != nil {
+			WriteBadRequest(w)
+			return
+		}
+		assessmentDates = append(assessmentDates, d)
+		reason, ok := server.checkWrittenAssessmentLimits(w, r, user, subject, assessmentDates, []string{id})
+		if !ok {
+			return
+		}
+		overrideReason = reason
+	}
+
 	err = server.db.UpdateMeeting(meeting)
 	if err != nil {
 		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
 		return
 	}
+	if overrideReason != "" {
+		err = server.recordWrittenAssessmentOverrides(user, subject, assessmentDates, hour, overrideReason)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while recording the override", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Ročna nadomeščanja beležimo v zgodovino nadomeščanj, da štejejo v obremenitev učitelja in v poročilo.
+	if isSubstitution {
+		err = server.db.UpsertSubstitutionForMeeting(sql.Substitution{
+			MeetingID:           id,
+			OriginalTeacherID:   subject.TeacherID,
+			SubstituteTeacherID: teacherId,
+			IsPaid:              true,
+		})
+	} else if originalmeeting.IsSubstitution {
+		err = server.db.DeleteSubstitutionForMeeting(id)
+	}
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while updating the substitution history", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
 	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusOK)
 }
 
@@ -531,7 +784,34 @@ func (server *httpImpl) DeleteMeeting(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = server.db.DeleteMeeting(id)
+	scope := r.FormValue("scope")
+	if scope == MEETING_SCOPE_FOLLOWING || scope == MEETING_SCOPE_SERIES {
+		if originalmeeting.SeriesID == nil {
+			WriteBadRequest(w)
+			return
+		}
+		from := ""
+		if scope == MEETING_SCOPE_FOLLOWING {
+			from = originalmeeting.Date
+		}
+		// Izvedena srečanja serije ostanejo, izbrišejo se samo prihodnja.
+		_, err = server.db.DeleteEditableMeetingsInSeries(*originalmeeting.SeriesID, from)
+	} else if scope == "" || scope == MEETING_SCOPE_OCCURRENCE {
+		var hasAttachments bool
+		hasAttachments, err = server.db.MeetingHasAttachments(id)
+		if err != nil {
+			WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		if hasAttachments {
+			WriteJSON(w, Response{Data: "The meeting has attendance, lesson log or other entries and can't be deleted", Success: false}, http.StatusConflict)
+			return
+		}
+		err = server.db.DeleteMeeting(id)
+	} else {
+		WriteBadRequest(w)
+		return
+	}
 	if err != nil {
 		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
 		return
@@ -690,6 +970,16 @@ func (server *httpImpl) GetAbsencesTeacher(w http.ResponseWriter, r *http.Reques
 			return
 		}
 	}
+	plannedAbsences, err := server.getPlannedAbsencesForMeeting(meeting, users)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving planned absences", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	existing, err := server.db.GetAbsencesForMeeting(meetingId)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving absences", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
 	var absences = make([]Absence, 0)
 	for i := 0; i < len(users); i++ {
 		userId := users[i]
@@ -697,38 +987,199 @@ func (server *httpImpl) GetAbsencesTeacher(w http.ResponseWriter, r *http.Reques
 		if err != nil {
 			return
 		}
-		absence, err := server.db.GetAbsenceForUserMeeting(meetingId, userId)
-		if err != nil {
-			if errors.Is(err, sql2.ErrNoRows) {
-				absence := sql.Absence{
-					UserID:      userId,
-					TeacherID:   user.ID,
-					MeetingID:   meetingId,
-					AbsenceType: "UNMANAGED",
-				}
-				err := server.db.InsertAbsence(absence)
-				if err != nil {
-					return
-				}
-				// da pridobimo ID novoustvarjene odsotnosti
-				absence, err = server.db.GetAbsenceForUserMeeting(meetingId, userId)
-				if err != nil {
-					return
-				}
-			} else {
-				return
+		var plannedAbsence *sql.PlannedAbsence
+		if planned, ok := plannedAbsences[userId]; ok {
+			plannedAbsence = &planned
+		}
+		// Učenci brez vpisane odsotnosti so prisotni. Učenci na napovedani odsotnosti so predlagani kot
+		// opravičeno odsotni, vpišejo pa se šele, ko učitelj odda seznam.
+		absence := sql.Absence{
+			UserID:      userId,
+			MeetingID:   meetingId,
+			AbsenceType: sql.ABSENCE_PRESENT,
+		}
+		if plannedAbsence != nil {
+			absence.AbsenceType = plannedAbsence.AbsenceType()
+			absence.IsExcused = true
+		}
+		for n := 0; n < len(existing); n++ {
+			if existing[n].UserID == userId {
+				absence = existing[n]
+				break
 			}
 		}
 
 		absences = append(absences, Absence{
-			Absence:     absence,
-			TeacherName: user.Name,
-			UserName:    currentUser.Name,
+			Absence:        absence,
+			TeacherName:    user.Name,
+			UserName:       currentUser.Name,
+			PlannedAbsence: plannedAbsence,
 		})
 	}
 	WriteJSON(w, Response{Success: true, Data: absences}, http.StatusOK)
 }
 
+// resolveAbsenceExcuse nastavi opravičenost odsotnosti. Opravičenost se ohrani, če je bila odsotnost že vpisana,
+// drugače je odsotnost opravičena, če jo pokriva napovedana odsotnost ali že odobreno opravičilo.
+func (server *httpImpl) resolveAbsenceExcuse(absence *sql.Absence, previous *sql.Absence, date string, planned *sql.PlannedAbsence) error {
+	absence.IsExcused = false
+	absence.ExcuseID = nil
+	if !sql.IsExcusableAbsenceType(absence.AbsenceType) && absence.AbsenceType != sql.ABSENCE_SCHOOL_ACTIVITY {
+		return nil
+	}
+	if previous != nil && (sql.IsExcusableAbsenceType(previous.AbsenceType) || previous.AbsenceType == sql.ABSENCE_SCHOOL_ACTIVITY) {
+		absence.IsExcused = previous.IsExcused
+		absence.ExcuseID = previous.ExcuseID
+	}
+	if planned != nil {
+		absence.IsExcused = true
+	}
+	if absence.IsExcused || !sql.IsExcusableAbsenceType(absence.AbsenceType) {
+		return nil
+	}
+	excuse, err := server.db.GetApprovedExcuseForStudentOnDate(absence.UserID, date)
+	if err == nil {
+		absence.IsExcused = true
+		absence.ExcuseID = &excuse.ID
+	} else if !errors.Is(err, sql2.ErrNoRows) {
+		return err
+	}
+	return nil
+}
+
+type AttendanceEntry struct {
+	UserID      string `json:"user_id"`
+	AbsenceType string `json:"absence_type"`
+}
+
+// PutAbsencesForMeeting v eni transakciji zapiše prisotnost vseh učencev na srečanju.
+// absences je JSON seznam AttendanceEntry. Učenci, ki jih ni na seznamu, so prisotni, razen če imajo napovedano odsotnost.
+func (server *httpImpl) PutAbsencesForMeeting(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == TEACHER || user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT || user.Role == SCHOOL_PSYCHOLOGIST) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	meetingId := mux.Vars(r)["meeting_id"]
+	meeting, err := server.db.GetMeeting(meetingId)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the meeting", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	subject, err := server.db.GetSubject(meeting.SubjectID)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the subject", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	if (user.Role == TEACHER || user.Role == SCHOOL_PSYCHOLOGIST) && !(subject.TeacherID == user.ID || meeting.TeacherID == user.ID) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	var users []string
+	if subject.InheritsClass {
+		class, err := server.db.GetClass(*subject.ClassID)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while retrieving the class", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		err = json.Unmarshal([]byte(class.Students), &users)
+		if err != nil {
+			WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+	} else {
+		err = json.Unmarshal([]byte(subject.Students), &users)
+		if err != nil {
+			WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	var entries []AttendanceEntry
+	err = json.Unmarshal([]byte(r.FormValue("absences")), &entries)
+	if err != nil {
+		WriteBadRequest(w)
+		return
+	}
+	statuses := make(map[string]string)
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if !helpers.Contains(users, entry.UserID) || !helpers.Contains(sql.AbsenceTypes, entry.AbsenceType) {
+			WriteJSON(w, Response{Data: fmt.Sprintf("Invalid attendance entry for %s", entry.UserID), Success: false}, http.StatusBadRequest)
+			return
+		}
+		statuses[entry.UserID] = entry.AbsenceType
+	}
+
+	plannedAbsences, err := server.getPlannedAbsencesForMeeting(meeting, users)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving planned absences", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	existing, err := server.db.GetAbsencesForMeeting(meetingId)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving absences", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	absences := make([]sql.Absence, 0)
+	for i := 0; i < len(users); i++ {
+		userId := users[i]
+		var planned *sql.PlannedAbsence
+		if plannedAbsence, ok := plannedAbsences[userId]; ok {
+			planned = &plannedAbsence
+		}
+		absenceType, ok := statuses[userId]
+		if !ok {
+			absenceType = sql.ABSENCE_PRESENT
+			if planned != nil {
+				absenceType = planned.AbsenceType()
+			}
+		}
+		absence := sql.Absence{
+			UserID:      userId,
+			TeacherID:   user.ID,
+			MeetingID:   meetingId,
+			AbsenceType: absenceType,
+		}
+		var previous *sql.Absence
+		for n := 0; n < len(existing); n++ {
+			if existing[n].UserID == userId {
+				previous = &existing[n]
+				break
+			}
+		}
+		err = server.resolveAbsenceExcuse(&absence, previous, meeting.Date, planned)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while retrieving excuses", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		absences = append(absences, absence)
+	}
+
+	err = server.db.SetAbsencesForMeeting(meetingId, absences)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while saving attendance", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	for i := 0; i < len(absences); i++ {
+		if absences[i].AbsenceType != sql.ABSENCE_ABSENT && absences[i].AbsenceType != sql.ABSENCE_LATE {
+			continue
+		}
+		err = server.checkAbsenceAlerts(user.ID, absences[i].UserID)
+		if err != nil {
+			server.logger.Errorw("failed to check absence alerts", "student", absences[i].UserID, "error", err.Error())
+		}
+	}
+	WriteJSON(w, Response{Success: true, Data: "OK"}, http.StatusOK)
+}
+
+// PatchAbsence spremeni že vpisano odsotnost enega učenca. Za vpis prisotnosti celega razreda je namenjen PutAbsencesForMeeting.
 func (server *httpImpl) PatchAbsence(w http.ResponseWriter, r *http.Request) {
 	user, err := server.db.CheckToken(GetAuthorizationToken(r))
 	if err != nil {
@@ -760,12 +1211,39 @@ func (server *httpImpl) PatchAbsence(w http.ResponseWriter, r *http.Request) {
 		WriteForbiddenJWT(w)
 		return
 	}
+	absenceType := r.FormValue("absence_type")
+	if !helpers.Contains(sql.AbsenceTypes, absenceType) {
+		WriteBadRequest(w)
+		return
+	}
+	// Prisotnost ni shranjena, zato vrstico izbrišemo.
+	if absenceType == sql.ABSENCE_PRESENT {
+		err = server.db.DeleteAbsence(absence.ID)
+		if err != nil {
+			WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		WriteJSON(w, Response{Success: true, Data: "OK"}, http.StatusOK)
+		return
+	}
+	previous := absence
 	absence.TeacherID = user.ID
-	absence.AbsenceType = r.FormValue("absence_type")
+	absence.AbsenceType = absenceType
+	err = server.resolveAbsenceExcuse(&absence, &previous, meeting.Date, nil)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving excuses", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
 	err = server.db.UpdateAbsence(absence)
 	if err != nil {
 		return
 	}
+	if absenceType == sql.ABSENCE_ABSENT || absenceType == sql.ABSENCE_LATE {
+		err = server.checkAbsenceAlerts(user.ID, absence.UserID)
+		if err != nil {
+			server.logger.Errorw("failed to check absence alerts", "student", absence.UserID, "error", err.Error())
+		}
+	}
 	WriteJSON(w, Response{Success: true, Data: "OK"}, http.StatusOK)
 }
 
@@ -837,6 +1315,15 @@ func (server *httpImpl) MigrateBetaMeetings(w http.ResponseWriter, r *http.Reque
 		WriteForbiddenJWT(w)
 		return
 	}
+	// Zadnji osnutek urnika se objavi kot nova različica urnika.
+	draft, err := server.db.GetLatestDraftTimetableVersion()
+	if err == nil {
+		err = server.db.PublishTimetableVersion(draft.Version)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while publishing the timetable version", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+	}
 	err = server.db.MigrateBetaMeetingsToNonBeta()
 	if err != nil {
 		WriteJSON(w, Response{Data: "Failed while migrating beta meetings to non-beta meetings", Error: err.Error(), Success: false}, http.StatusInternalServerError)
@@ -855,6 +1342,14 @@ func (server *httpImpl) DeleteBetaMeetings(w http.ResponseWriter, r *http.Reques
 		WriteForbiddenJWT(w)
 		return
 	}
+	draft, err := server.db.GetLatestDraftTimetableVersion()
+	if err == nil {
+		err = server.db.DeleteDraftTimetableVersion(draft.Version)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while deleting the timetable version", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+	}
 	err = server.db.DeleteBetaMeetings()
 	if err != nil {
 		WriteJSON(w, Response{Data: "Failed while deleting beta meetings", Error: err.Error(), Success: false}, http.StatusInternalServerError)
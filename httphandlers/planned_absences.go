@@ -0,0 +1,225 @@
+package httphandlers
+
+import (
+	"encoding/json"
+	"github.com/MeetPlan/MeetPlanBackend/helpers"
+	"github.com/MeetPlan/MeetPlanBackend/sql"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// plannedAbsenceCovers preveri, ali napovedana odsotnost pokriva uro hour na dan date.
+func plannedAbsenceCovers(absence sql.PlannedAbsence, date time.Time, hour int) (bool, error) {
+	start, err := time.Parse("02-01-2006", absence.StartDate)
+	if err != nil {
+		return false, err
+	}
+	end, err := time.Parse("02-01-2006", absence.EndDate)
+	if err != nil {
+		return false, err
+	}
+	if date.Before(start) || date.After(end) {
+		return false, nil
+	}
+	if date.Equal(start) && absence.StartHour != nil && hour < *absence.StartHour {
+		return false, nil
+	}
+	if date.Equal(end) && absence.EndHour != nil && hour > *absence.EndHour {
+		return false, nil
+	}
+	return true, nil
+}
+
+// getPlannedAbsencesForMeeting vrne napovedane odsotnosti učencev na srečanju, po ID-jih učencev.
+func (server *httpImpl) getPlannedAbsencesForMeeting(meeting sql.Meeting, students []string) (map[string]sql.PlannedAbsence, error) {
+	planned := make(map[string]sql.PlannedAbsence)
+	absences, err := server.db.GetPlannedAbsencesBetween(meeting.Date, meeting.Date)
+	if err != nil || len(absences) == 0 {
+		return planned, err
+	}
+	date, err := time.Parse("02-01-2006", meeting.Date)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(absences); i++ {
+		absence := absences[i]
+		covers, err := plannedAbsenceCovers(absence, date, meeting.Hour)
+		if err != nil {
+			return nil, err
+		}
+		if !covers {
+			continue
+		}
+		affected := make([]string, 0)
+		if absence.UserID != nil {
+			affected = append(affected, *absence.UserID)
+		} else if absence.ClassID != nil {
+			class, err := server.db.GetClass(*absence.ClassID)
+			if err != nil {
+				return nil, err
+			}
+			err = json.Unmarshal([]byte(class.Students), &affected)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for n := 0; n < len(affected); n++ {
+			if helpers.Contains(students, affected[n]) {
+				planned[affected[n]] = absence
+			}
+		}
+	}
+	return planned, nil
+}
+
+func (server *httpImpl) GetPlannedAbsences(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == TEACHER || user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT || user.Role == SCHOOL_PSYCHOLOGIST) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	start := r.URL.Query().Get("start")
+	end := r.URL.Query().Get("end")
+	_, err = time.Parse("02-01-2006", start)
+	if err != nil {
+		WriteBadRequest(w)
+		return
+	}
+	_, err = time.Parse("02-01-2006", end)
+	if err != nil {
+		WriteBadRequest(w)
+		return
+	}
+	absences, err := server.db.GetPlannedAbsencesBetween(start, end)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving planned absences", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: absences, Success: true}, http.StatusOK)
+}
+
+// NewPlannedAbsence napove odsotnost za učence (user_ids, JSON seznam) ali za cel razred (class_id).
+// start_hour in end_hour nista obvezna.
+func (server *httpImpl) NewPlannedAbsence(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == TEACHER || user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT || user.Role == SCHOOL_PSYCHOLOGIST) {
+		WriteForbiddenJWT(w)
+		return
+	}
+
+	startDate := r.FormValue("start_date")
+	endDate := r.FormValue("end_date")
+	start, err := time.Parse("02-01-2006", startDate)
+	if err != nil {
+		WriteBadRequest(w)
+		return
+	}
+	end, err := time.Parse("02-01-2006", endDate)
+	if err != nil || end.Before(start) {
+		WriteBadRequest(w)
+		return
+	}
+	startHour, err := optionalIntFormValue(r, "start_hour")
+	if err != nil {
+		WriteBadRequest(w)
+		return
+	}
+	endHour, err := optionalIntFormValue(r, "end_hour")
+	if err != nil {
+		WriteBadRequest(w)
+		return
+	}
+	if start.Equal(end) && startHour != nil && endHour != nil && *endHour < *startHour {
+		WriteBadRequest(w)
+		return
+	}
+	reasonType, err := strconv.Atoi(r.FormValue("reason_type"))
+	if err != nil || reasonType < sql.PLANNED_ABSENCE_COMPETITION || reasonType > sql.PLANNED_ABSENCE_OTHER {
+		WriteBadRequest(w)
+		return
+	}
+	reason := strings.TrimSpace(r.FormValue("reason"))
+
+	absence := sql.PlannedAbsence{
+		StartDate:  startDate,
+		EndDate:    endDate,
+		StartHour:  startHour,
+		EndHour:    endHour,
+		ReasonType: reasonType,
+		Reason:     reason,
+		CreatedBy:  user.ID,
+	}
+	absences := make([]sql.PlannedAbsence, 0)
+	classId := r.FormValue("class_id")
+	if classId != "" {
+		_, err := server.db.GetClass(classId)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Could not fetch class", Error: err.Error(), Success: false}, http.StatusNotFound)
+			return
+		}
+		absence.ClassID = &classId
+		absences = append(absences, absence)
+	} else {
+		var userIds []string
+		err = json.Unmarshal([]byte(r.FormValue("user_ids")), &userIds)
+		if err != nil || len(userIds) == 0 {
+			WriteBadRequest(w)
+			return
+		}
+		for i := 0; i < len(userIds); i++ {
+			student, err := server.db.GetUser(userIds[i])
+			if err != nil || student.Role != STUDENT {
+				WriteBadRequest(w)
+				return
+			}
+			studentAbsence := absence
+			studentAbsence.UserID = &userIds[i]
+			absences = append(absences, studentAbsence)
+		}
+	}
+
+	err = server.db.InsertPlannedAbsences(absences)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while inserting planned absences", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusCreated)
+}
+
+func (server *httpImpl) DeletePlannedAbsence(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == TEACHER || user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT || user.Role == SCHOOL_PSYCHOLOGIST) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	absence, err := server.db.GetPlannedAbsence(mux.Vars(r)["planned_absence_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the planned absence", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	if (user.Role == TEACHER || user.Role == SCHOOL_PSYCHOLOGIST) && absence.CreatedBy != user.ID {
+		WriteForbiddenJWT(w)
+		return
+	}
+	err = server.db.DeletePlannedAbsence(absence.ID)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while deleting the planned absence", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusOK)
+}
@@ -13,6 +13,7 @@ import (
 	"math/rand"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 func (server *httpImpl) ManageTeacherAbsences(w http.ResponseWriter, r *http.Request) {
@@ -30,7 +31,7 @@ func (server *httpImpl) ManageTeacherAbsences(w http.ResponseWriter, r *http.Req
 		WriteBadRequest(w)
 		return
 	}
-	absences, err := server.proton.ManageAbsences(meetingId)
+	absences, err := server.proton.ManageAbsences(meetingId, server.config)
 	if err != nil {
 		WriteJSON(w, Response{Data: "Proton failed to optimize timetable", Error: err.Error(), Success: false}, http.StatusInternalServerError)
 		return
@@ -176,7 +177,7 @@ func (server *httpImpl) NewProtonRule(w http.ResponseWriter, r *http.Request) {
 			})
 		}
 	}
-	err = server.proton.NewProtonRule(protonRule)
+	err = server.proton.NewProtonRule(protonRule, user.ID)
 	if err != nil {
 		WriteJSON(w, Response{Data: "Failed to add a new rule", Error: err.Error(), Success: false}, http.StatusInternalServerError)
 		return
@@ -195,19 +196,54 @@ func (server *httpImpl) GetProtonRules(w http.ResponseWriter, r *http.Request) {
 		WriteForbiddenJWT(w)
 		return
 	}
-	protonConfig := server.proton.GetProtonConfig()
-	for i := 0; i < len(protonConfig.Rules); i++ {
-		if protonConfig.Rules[i].ID == "" {
-			UUID, err := uuid.NewUUID()
-			if err != nil {
-				continue
-			}
-			protonConfig.Rules[i].ID = UUID.String()
-		}
+	err = server.proton.ReloadConfig()
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed to load Proton rules", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSON(w, Response{Data: server.proton.GetProtonConfig(), Success: true}, http.StatusOK)
+}
+
+func (server *httpImpl) GetProtonConfigVersions(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	versions, err := server.proton.GetConfigVersions()
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed to retrieve Proton rule set versions", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
 	}
-	server.proton.SaveConfig(protonConfig)
+	WriteJSON(w, Response{Data: versions, Success: true}, http.StatusOK)
+}
 
-	WriteJSON(w, Response{Data: server.proton.GetProtonConfig(), Success: false}, http.StatusOK)
+func (server *httpImpl) RestoreProtonConfigVersion(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	version, err := strconv.Atoi(mux.Vars(r)["version"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed at converting version to integer", Error: err.Error(), Success: false}, http.StatusBadRequest)
+		return
+	}
+	err = server.proton.RestoreConfigVersion(version, user.ID)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed to restore Proton rule set", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: server.proton.GetProtonConfig(), Success: true}, http.StatusOK)
 }
 
 func GenerateRandomHourForBeforeAfterSubjects() int {
@@ -239,16 +275,106 @@ func (server *httpImpl) AssembleTimetable(w http.ResponseWriter, r *http.Request
 		WriteForbiddenJWT(w)
 		return
 	}
+	err = server.proton.ReloadConfig()
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed to load Proton rules", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	stableTimetable, ok := server.assembleTimetable(w, make([]proton.ProtonMeeting, 0))
+	if !ok {
+		return
+	}
+
+	WriteJSON(w, Response{Data: stableTimetable, Success: true}, http.StatusOK)
+}
+
+type PartialTimetable struct {
+	Timetable []proton.ProtonMeeting `json:"timetable"`
+	Diff      proton.TimetableDiff   `json:"diff"`
+}
+
+// AssemblePartialTimetable ponovno sestavi samo del urnika. Srečanja izbranih razredov (pinClasses), učiteljev (pinTeachers)
+// in posamezna srečanja (pinMeetings) iz sprejetega urnika v tednu datuma start ostanejo na svojem mestu,
+// preostali predmeti pa se sestavijo na novo. Vrne nov urnik in razlike glede na trenutno sprejet urnik.
+func (server *httpImpl) AssemblePartialTimetable(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	start, err := time.Parse("02-01-2006", r.FormValue("start"))
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed at converting start to Time", Error: err.Error(), Success: false}, http.StatusBadRequest)
+		return
+	}
+	// Ponedeljek v tednu začetnega datuma
+	start = start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+
+	var pinClasses, pinTeachers, pinMeetings []string
+	for field, pins := range map[string]*[]string{"pinClasses": &pinClasses, "pinTeachers": &pinTeachers, "pinMeetings": &pinMeetings} {
+		value := r.FormValue(field)
+		if value == "" {
+			continue
+		}
+		err = json.Unmarshal([]byte(value), pins)
+		if err != nil {
+			WriteJSON(w, Response{Data: fmt.Sprintf("Failed while unmarshalling %s", field), Error: err.Error(), Success: false}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	err = server.proton.ReloadConfig()
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed to load Proton rules", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	meetings, err := server.getAcceptedMeetings(start, start.AddDate(0, 0, 13))
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving meetings", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	current, err := server.proton.ProtonMeetingsFromMeetings(meetings, start)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while converting meetings", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	pinned := server.proton.PinMeetings(current, pinClasses, pinTeachers, pinMeetings)
+	ok, err := server.proton.CheckIfProtonConfigIsOk(pinned)
+	if !ok {
+		WriteJSON(w, Response{Data: pinned, Error: err.Error(), Success: false}, http.StatusConflict)
+		return
+	}
+
+	timetable, ok := server.assembleTimetable(w, pinned)
+	if !ok {
+		return
+	}
+
+	WriteJSON(w, Response{Data: PartialTimetable{Timetable: timetable, Diff: proton.DiffTimetables(current, timetable)}, Success: true}, http.StatusOK)
+}
+
+// assembleTimetable sestavi urnik, ki se začne z zaklenjenimi srečanji (pinned). Pri polni sestavi je ta seznam prazen.
+// Ob napaki funkcija sama zapiše odgovor in vrne false.
+func (server *httpImpl) assembleTimetable(w http.ResponseWriter, pinned []proton.ProtonMeeting) ([]proton.ProtonMeeting, bool) {
+	ruleSetVersion := server.proton.GetProtonConfig().RuleSetVersion
+
 	subjects, err := server.db.GetAllSubjects()
 	if err != nil {
 		WriteJSON(w, Response{Data: "Failed to retrieve subjects", Error: err.Error(), Success: false}, http.StatusInternalServerError)
-		return
+		return nil, false
 	}
 
 	classes, err := server.db.GetClasses()
 	if err != nil {
 		WriteJSON(w, Response{Data: "Failed to retrieve classes", Error: err.Error(), Success: false}, http.StatusInternalServerError)
-		return
+		return nil, false
 	}
 
 	// Before & After class subjects will be treated differently
@@ -264,6 +390,7 @@ func (server *httpImpl) AssembleTimetable(w http.ResponseWriter, r *http.Request
 	subjectGroups := server.proton.GetSubjectGroups()
 
 	stableTimetable := make([]proton.ProtonMeeting, 0)
+	stableTimetable = append(stableTimetable, pinned...)
 
 	depth := 0
 
@@ -273,7 +400,7 @@ func (server *httpImpl) AssembleTimetable(w http.ResponseWriter, r *http.Request
 	for {
 		if failResetCount >= proton.PROTON_ALLOWED_FAIL_RESET_RATE {
 			WriteJSON(w, Response{Data: "Fail reset rate was exceeded. Aborted.", Success: false}, http.StatusInternalServerError)
-			return
+			return nil, false
 		}
 
 		if failRate >= proton.PROTON_ALLOWED_FAIL_RATE {
@@ -281,7 +408,7 @@ func (server *httpImpl) AssembleTimetable(w http.ResponseWriter, r *http.Request
 			_, err = crypto_rand.Read(b[:])
 			if err != nil {
 				WriteJSON(w, Response{Data: "cannot seed math/rand package with cryptographically secure random number generator", Error: err.Error(), Success: false}, http.StatusInternalServerError)
-				return
+				return nil, false
 			}
 			rand.Seed(int64(binary.LittleEndian.Uint64(b[:])))
 
@@ -290,6 +417,7 @@ func (server *httpImpl) AssembleTimetable(w http.ResponseWriter, r *http.Request
 			failRate = 0
 
 			stableTimetable = make([]proton.ProtonMeeting, 0)
+			stableTimetable = append(stableTimetable, pinned...)
 
 			//server.logger.Debug(stableTimetable)
 
@@ -298,7 +426,7 @@ func (server *httpImpl) AssembleTimetable(w http.ResponseWriter, r *http.Request
 
 		if depth >= proton.PROTON_ALLOWED_WHILE_DEPTH {
 			WriteJSON(w, Response{Error: "Failed to make a timetable", Data: stableTimetable, Success: false}, http.StatusInternalServerError)
-			return
+			return nil, false
 		}
 
 		subjectNum := rand.Intn(len(subjects))
@@ -389,11 +517,12 @@ func (server *httpImpl) AssembleTimetable(w http.ResponseWriter, r *http.Request
 				currentSubjectSelectedHours = currentSubject.SelectedHours
 			} else if currentSubjectSelectedHours != currentSubject.SelectedHours {
 				WriteJSON(w, Response{Data: fmt.Sprintf("Nekompatibilna sestava Proton konfiguracije. Predmet %s je nekompatibilen v številu ur z ostalimi v skupini. Ne morem ustvariti urnika.", fmt.Sprint(subjectId)), Success: false}, http.StatusConflict)
-				return
+				return nil, false
 			}
 			UUID, err2 := uuid.NewUUID()
 			if err2 != nil {
-				return
+				WriteJSON(w, Response{Data: "Failed to generate meeting ID", Error: err2.Error(), Success: false}, http.StatusInternalServerError)
+				return nil, false
 			}
 
 			var generateOnlyOneHour = false
@@ -422,27 +551,10 @@ func (server *httpImpl) AssembleTimetable(w http.ResponseWriter, r *http.Request
 				}
 			}
 
-			var classId = make([]string, 0)
-			if currentSubject.InheritsClass {
-				classId = append(classId, *currentSubject.ClassID)
-			} else {
-				var students []string
-				err := json.Unmarshal([]byte(currentSubject.Students), &students)
-				if err != nil {
-					return
-				}
-				for i := 0; i < len(classes); i++ {
-					var classStudents []string
-					err := json.Unmarshal([]byte(classes[i].Students), &classStudents)
-					if err != nil {
-						return
-					}
-					for n := 0; n < len(students); n++ {
-						if helpers.Contains(classStudents, students[n]) && !helpers.Contains(classId, classes[i].ID) {
-							classId = append(classId, classes[i].ID)
-						}
-					}
-				}
+			classId, err := server.proton.GetClassesOfSubject(currentSubject, classes)
+			if err != nil {
+				WriteJSON(w, Response{Data: "Failed to retrieve classes of the subject", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+				return nil, false
 			}
 
 			m := proton.ProtonMeeting{
@@ -464,7 +576,8 @@ func (server *httpImpl) AssembleTimetable(w http.ResponseWriter, r *http.Request
 
 			UUID, err2 = uuid.NewUUID()
 			if err2 != nil {
-				return
+				WriteJSON(w, Response{Data: "Failed to generate meeting ID", Error: err2.Error(), Success: false}, http.StatusInternalServerError)
+				return nil, false
 			}
 
 			m = proton.ProtonMeeting{
@@ -484,7 +597,8 @@ func (server *httpImpl) AssembleTimetable(w http.ResponseWriter, r *http.Request
 			if server.proton.SubjectHasDoubleHours(subjectId) {
 				UUID, err2 := uuid.NewUUID()
 				if err2 != nil {
-					return
+					WriteJSON(w, Response{Data: "Failed to generate meeting ID", Error: err2.Error(), Success: false}, http.StatusInternalServerError)
+					return nil, false
 				}
 
 				hour++
@@ -539,10 +653,11 @@ func (server *httpImpl) AssembleTimetable(w http.ResponseWriter, r *http.Request
 	stableTimetable, err = server.PostProcessTimetable(classes, stableTimetable, false)
 	if err != nil {
 		WriteJSON(w, Response{Data: "Fail while post-processing the timetable", Error: err.Error(), Success: false}, http.StatusInternalServerError)
-		return
+		return nil, false
 	}
+	proton.StampRuleSetVersion(stableTimetable, ruleSetVersion)
 
-	WriteJSON(w, Response{Data: stableTimetable, Success: true}, http.StatusOK)
+	return stableTimetable, true
 }
 
 func (server *httpImpl) ManualPostProcessRepeat(w http.ResponseWriter, r *http.Request) {
@@ -555,6 +670,11 @@ func (server *httpImpl) ManualPostProcessRepeat(w http.ResponseWriter, r *http.R
 		WriteForbiddenJWT(w)
 		return
 	}
+	err = server.proton.ReloadConfig()
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed to load Proton rules", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
 
 	var stableTimetable []proton.ProtonMeeting
 	err = json.Unmarshal([]byte(r.FormValue("timetable")), &stableTimetable)
@@ -575,11 +695,13 @@ func (server *httpImpl) ManualPostProcessRepeat(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	ruleSetVersion := server.proton.GetProtonConfig().RuleSetVersion
 	stableTimetable, err = server.PostProcessTimetable(classes, stableTimetable, cancelPostProcessingBeforeDone)
 	if err != nil {
 		WriteJSON(w, Response{Data: "Fail while post-processing the timetable", Error: err.Error(), Success: false}, http.StatusInternalServerError)
 		return
 	}
+	proton.StampRuleSetVersion(stableTimetable, ruleSetVersion)
 
 	WriteJSON(w, Response{Data: stableTimetable, Success: true}, http.StatusOK)
 }
@@ -594,6 +716,11 @@ func (server *httpImpl) AcceptAssembledTimetable(w http.ResponseWriter, r *http.
 		WriteForbiddenJWT(w)
 		return
 	}
+	err = server.proton.ReloadConfig()
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed to load Proton rules", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
 	timetableString := r.FormValue("timetable")
 	var protonMeetings []proton.ProtonMeeting
 	err = json.Unmarshal([]byte(timetableString), &protonMeetings)
@@ -602,22 +729,40 @@ func (server *httpImpl) AcceptAssembledTimetable(w http.ResponseWriter, r *http.
 		return
 	}
 
-	meetings, err := server.proton.AssembleMeetingsFromProtonMeetings(protonMeetings, server.config)
+	timetableVersion, err := server.AcceptTimetable(protonMeetings, r.FormValue("name"), user.ID)
 	if err != nil {
-		WriteJSON(w, Response{Data: "Failed while assembling meetings from proton meetings", Success: false, Error: err.Error()}, http.StatusInternalServerError)
+		WriteJSON(w, Response{Data: "Failed while accepting the timetable", Success: false, Error: err.Error()}, http.StatusInternalServerError)
 		return
 	}
 
-	for i := 0; i < len(meetings); i++ {
-		meeting := meetings[i]
-		err := server.db.InsertMeeting(meeting)
-		if err != nil {
-			WriteJSON(w, Response{Data: "Failed while inserting new meeting", Error: err.Error(), Success: false}, http.StatusInternalServerError)
-			return
-		}
+	WriteJSON(w, Response{Data: timetableVersion, Error: "OK", Success: true}, http.StatusCreated)
+}
+
+// AcceptTimetable razširi Proton urnik v dejanska (beta) srečanja in jih v eni transakciji shrani kot nov osnutek različice urnika.
+// Uporablja se tako za urnike, ustvarjene s Protonom, kot tudi za uvožene urnike.
+func (server *httpImpl) AcceptTimetable(protonMeetings []proton.ProtonMeeting, name string, createdBy string) (sql.TimetableVersion, error) {
+	meetings, err := server.proton.AssembleMeetingsFromProtonMeetings(protonMeetings, server.config)
+	if err != nil {
+		return sql.TimetableVersion{}, err
+	}
+
+	if name == "" {
+		name = fmt.Sprintf("Urnik %s", time.Now().Format("02-01-2006 15:04"))
+	}
+	timetableVersion := sql.TimetableVersion{
+		Name:      name,
+		CreatedBy: &createdBy,
+	}
+	if len(meetings) != 0 {
+		timetableVersion.ProtonConfigVersion = meetings[0].ProtonConfigVersion
+	}
+
+	version, err := server.db.InsertTimetableVersion(timetableVersion, meetings)
+	if err != nil {
+		return sql.TimetableVersion{}, err
 	}
 
-	WriteJSON(w, Response{Data: meetings, Error: "OK", Success: true}, http.StatusCreated)
+	return server.db.GetTimetableVersion(version)
 }
 
 func (server *httpImpl) DeleteProtonRule(w http.ResponseWriter, r *http.Request) {
@@ -630,6 +775,10 @@ func (server *httpImpl) DeleteProtonRule(w http.ResponseWriter, r *http.Request)
 		WriteForbiddenJWT(w)
 		return
 	}
-	server.proton.DeleteRule(r.FormValue("ruleId"))
+	err = server.proton.DeleteRule(r.FormValue("ruleId"), user.ID)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed to delete the rule", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
 	WriteJSON(w, Response{Data: server.proton.GetProtonConfig(), Success: true}, http.StatusOK)
 }
@@ -0,0 +1,347 @@
+package httphandlers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"github.com/MeetPlan/MeetPlanBackend/sql"
+	"github.com/dchest/uniuri"
+	"github.com/signintech/gopdf"
+	"net/http"
+	"sort"
+	"time"
+)
+
+type SubstitutionBoardEntry struct {
+	MeetingID             string
+	MeetingName           string
+	SubjectName           string
+	OriginalTeacherID     string
+	OriginalTeacherName   string
+	SubstituteTeacherID   *string
+	SubstituteTeacherName string
+	Location              string
+	IsCancelled           bool
+}
+
+type SubstitutionBoardClass struct {
+	ClassID   *string
+	ClassName string
+	Entries   []SubstitutionBoardEntry
+}
+
+type SubstitutionBoardHour struct {
+	Hour    int
+	Classes []SubstitutionBoardClass
+}
+
+type SubstitutionBoard struct {
+	Date  string
+	Hours []SubstitutionBoardHour
+}
+
+// canViewSubstitutionBoard preveri, ali je zahteva prijavljenega uporabnika ali pa vsebuje veljaven žeton za
+// zaslon na hodniku (kiosk). Žeton omogoča samo branje seznama nadomeščanj.
+func (server *httpImpl) canViewSubstitutionBoard(r *http.Request) bool {
+	kiosk := r.URL.Query().Get("kiosk")
+	if kiosk != "" {
+		return server.config.KioskToken != "" && subtle.ConstantTimeCompare([]byte(kiosk), []byte(server.config.KioskToken)) == 1
+	}
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		return false
+	}
+	return user.Role != UNVERIFIED
+}
+
+// getSubstitutionBoard zbere vsa nadomeščana in odpadla srečanja na podani dan.
+// Srečanje odpade, če je njegov učitelj odsoten in srečanje nima nadomeščanja.
+func (server *httpImpl) getSubstitutionBoard(date string) (SubstitutionBoard, error) {
+	board := SubstitutionBoard{Date: date, Hours: make([]SubstitutionBoardHour, 0)}
+
+	meetings, err := server.db.GetMeetingsOnSpecificDate(date, false)
+	if err != nil {
+		return board, err
+	}
+	absences, err := server.db.GetTeacherAbsencesBetween(date, date)
+	if err != nil {
+		return board, err
+	}
+	absentTeachers := make(map[string]bool)
+	for i := 0; i < len(absences); i++ {
+		absentTeachers[absences[i].TeacherID] = true
+	}
+	substitutions, err := server.db.GetSubstitutionsBetween(date, date)
+	if err != nil {
+		return board, err
+	}
+	originalTeachers := make(map[string]string)
+	for i := 0; i < len(substitutions); i++ {
+		originalTeachers[substitutions[i].MeetingID] = substitutions[i].OriginalTeacherID
+	}
+
+	teacherNames := make(map[string]string)
+	teacherName := func(id string) (string, error) {
+		if name, ok := teacherNames[id]; ok {
+			return name, nil
+		}
+		teacher, err := server.db.GetUser(id)
+		if err != nil {
+			return "", err
+		}
+		teacherNames[id] = teacher.Name
+		return teacher.Name, nil
+	}
+	classNames := make(map[string]string)
+
+	hours := make(map[int]map[string]*SubstitutionBoardClass)
+	for i := 0; i < len(meetings); i++ {
+		meeting := meetings[i]
+		if !meeting.IsSubstitution && !absentTeachers[meeting.TeacherID] {
+			continue
+		}
+		subject, err := server.db.GetSubject(meeting.SubjectID)
+		if err != nil {
+			return board, err
+		}
+
+		entry := SubstitutionBoardEntry{
+			MeetingID:   meeting.ID,
+			MeetingName: meeting.MeetingName,
+			SubjectName: subject.LongName,
+			Location:    meeting.Location,
+			IsCancelled: !meeting.IsSubstitution,
+		}
+		if entry.Location == "" {
+			entry.Location = subject.Location
+		}
+		if meeting.IsSubstitution {
+			entry.OriginalTeacherID = subject.TeacherID
+			if originalTeacher, ok := originalTeachers[meeting.ID]; ok {
+				entry.OriginalTeacherID = originalTeacher
+			}
+			substituteTeacherId := meeting.TeacherID
+			entry.SubstituteTeacherID = &substituteTeacherId
+			entry.SubstituteTeacherName, err = teacherName(substituteTeacherId)
+			if err != nil {
+				return board, err
+			}
+		} else {
+			entry.OriginalTeacherID = meeting.TeacherID
+		}
+		entry.OriginalTeacherName, err = teacherName(entry.OriginalTeacherID)
+		if err != nil {
+			return board, err
+		}
+
+		// Predmeti, ki ne dedujejo razreda (npr. izbirni predmeti), so na tabli navedeni pod imenom predmeta.
+		var classKey = subject.ID
+		var className = subject.Name
+		if subject.InheritsClass && subject.ClassID != nil {
+			classKey = *subject.ClassID
+			name, ok := classNames[classKey]
+			if !ok {
+				class, err := server.db.GetClass(classKey)
+				if err != nil {
+					return board, err
+				}
+				name = class.Name
+				classNames[classKey] = name
+			}
+			className = name
+		}
+
+		if hours[meeting.Hour] == nil {
+			hours[meeting.Hour] = make(map[string]*SubstitutionBoardClass)
+		}
+		class, ok := hours[meeting.Hour][classKey]
+		if !ok {
+			class = &SubstitutionBoardClass{ClassName: className, Entries: make([]SubstitutionBoardEntry, 0)}
+			if subject.InheritsClass {
+				class.ClassID = subject.ClassID
+			}
+			hours[meeting.Hour][classKey] = class
+		}
+		class.Entries = append(class.Entries, entry)
+	}
+
+	for hour, classes := range hours {
+		boardHour := SubstitutionBoardHour{Hour: hour, Classes: make([]SubstitutionBoardClass, 0)}
+		for _, class := range classes {
+			boardHour.Classes = append(boardHour.Classes, *class)
+		}
+		sort.Slice(boardHour.Classes, func(i, j int) bool {
+			return boardHour.Classes[i].ClassName < boardHour.Classes[j].ClassName
+		})
+		board.Hours = append(board.Hours, boardHour)
+	}
+	sort.Slice(board.Hours, func(i, j int) bool {
+		return board.Hours[i].Hour < board.Hours[j].Hour
+	})
+	return board, nil
+}
+
+func getSubstitutionBoardDate(r *http.Request) (string, bool) {
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		return time.Now().Format("02-01-2006"), true
+	}
+	_, err := time.Parse("02-01-2006", date)
+	return date, err == nil
+}
+
+func (server *httpImpl) GetSubstitutionBoard(w http.ResponseWriter, r *http.Request) {
+	if !server.canViewSubstitutionBoard(r) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	date, ok := getSubstitutionBoardDate(r)
+	if !ok {
+		WriteBadRequest(w)
+		return
+	}
+	board, err := server.getSubstitutionBoard(date)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving substitutions", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: board, Success: true}, http.StatusOK)
+}
+
+// GetSubstitutionBoardPDF vrne seznam nadomeščanj za tisk. Dokument ni uraden, zato ni podpisan.
+func (server *httpImpl) GetSubstitutionBoardPDF(w http.ResponseWriter, r *http.Request) {
+	if !server.canViewSubstitutionBoard(r) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	date, ok := getSubstitutionBoardDate(r)
+	if !ok {
+		WriteBadRequest(w)
+		return
+	}
+	board, err := server.getSubstitutionBoard(date)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving substitutions", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	pdf := gopdf.GoPdf{}
+	pdf.Start(gopdf.Config{PageSize: *gopdf.PageSizeA4})
+	pdf.AddPage()
+
+	err = pdf.AddTTFFont("opensans", "fonts/opensans.ttf")
+	if err != nil {
+		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	err = pdf.Image("icons/school_banner.png", 30, 30, &gopdf.Rect{H: 70, W: 70})
+	if err != nil {
+		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	const borderBase = 30
+	const pageBottom = 800
+
+	_ = pdf.SetFont("opensans", "", 22)
+	pdf.SetX(120)
+	pdf.SetY(60)
+	pdf.Text("Nadomeščanja")
+	_ = pdf.SetFont("opensans", "", 13)
+	pdf.SetX(120)
+	pdf.SetY(82)
+	pdf.Text(fmt.Sprintf("%s, %s", server.config.SchoolName, board.Date))
+
+	pdf.Line(20, 115, 575, 115)
+
+	var y float64 = 140
+	newLine := func(height float64) {
+		y += height
+		if y > pageBottom {
+			pdf.AddPage()
+			y = 50
+		}
+	}
+
+	if len(board.Hours) == 0 {
+		pdf.SetX(borderBase)
+		pdf.SetY(y)
+		pdf.Text("Na ta dan ni nadomeščanj.")
+	}
+
+	for i := 0; i < len(board.Hours); i++ {
+		hour := board.Hours[i]
+		_ = pdf.SetFont("opensans", "", 15)
+		pdf.SetX(borderBase)
+		pdf.SetY(y)
+		pdf.Text(fmt.Sprintf("%d. ura", hour.Hour))
+		newLine(20)
+
+		_ = pdf.SetFont("opensans", "", 10)
+		for n := 0; n < len(hour.Classes); n++ {
+			class := hour.Classes[n]
+			for x := 0; x < len(class.Entries); x++ {
+				entry := class.Entries[x]
+				pdf.SetX(borderBase + 10)
+				pdf.SetY(y)
+				pdf.Text(class.ClassName)
+				pdf.SetX(110)
+				pdf.Text(entry.SubjectName)
+				pdf.SetX(250)
+				pdf.Text(entry.OriginalTeacherName)
+				pdf.SetX(380)
+				if entry.IsCancelled {
+					pdf.Text("odpade")
+				} else {
+					pdf.Text(entry.SubstituteTeacherName)
+				}
+				pdf.SetX(510)
+				pdf.Text(entry.Location)
+				newLine(15)
+			}
+		}
+		newLine(10)
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Write(pdf.GetBytesPdf())
+}
+
+// NewKioskToken ustvari nov žeton za zaslon na hodniku. Prejšnji žeton s tem preneha veljati.
+func (server *httpImpl) NewKioskToken(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	server.config.KioskToken = uniuri.NewLen(32)
+	err = sql.SaveConfig(server.config)
+	if err != nil {
+		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: server.config.KioskToken, Success: true}, http.StatusOK)
+}
+
+func (server *httpImpl) DeleteKioskToken(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	server.config.KioskToken = ""
+	err = sql.SaveConfig(server.config)
+	if err != nil {
+		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusOK)
+}
@@ -0,0 +1,557 @@
+package httphandlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/MeetPlan/MeetPlanBackend/helpers"
+	"github.com/MeetPlan/MeetPlanBackend/proton"
+	"github.com/MeetPlan/MeetPlanBackend/sql"
+	"github.com/gorilla/mux"
+	"github.com/johnfercher/maroto/pkg/consts"
+	"github.com/johnfercher/maroto/pkg/pdf"
+	"github.com/johnfercher/maroto/pkg/props"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (server *httpImpl) GetTeacherAbsences(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	absences, err := server.db.GetTeacherAbsences()
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving teacher absences", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: absences, Success: true}, http.StatusOK)
+}
+
+func (server *httpImpl) NewTeacherAbsence(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	teacher, err := server.db.GetUser(r.FormValue("teacher_id"))
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the teacher", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	if !(teacher.Role == TEACHER || teacher.Role == PRINCIPAL || teacher.Role == PRINCIPAL_ASSISTANT || teacher.Role == SCHOOL_PSYCHOLOGIST) {
+		WriteJSON(w, Response{Data: "User is not a teacher", Success: false}, http.StatusBadRequest)
+		return
+	}
+	start, err := time.Parse("02-01-2006", r.FormValue("start_date"))
+	if err != nil {
+		WriteBadRequest(w)
+		return
+	}
+	end, err := time.Parse("02-01-2006", r.FormValue("end_date"))
+	if err != nil || end.Before(start) {
+		WriteBadRequest(w)
+		return
+	}
+	id, err := server.db.InsertTeacherAbsence(sql.TeacherAbsence{
+		TeacherID: teacher.ID,
+		StartDate: start.Format("02-01-2006"),
+		EndDate:   end.Format("02-01-2006"),
+		Reason:    r.FormValue("reason"),
+		CreatedBy: &user.ID,
+	})
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while inserting the teacher absence", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: id, Success: true}, http.StatusCreated)
+}
+
+func (server *httpImpl) DeleteTeacherAbsence(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	absenceId := mux.Vars(r)["absence_id"]
+	// Pred izbrisom odsotnosti srečanjem vrnemo prvotnega učitelja.
+	err = server.db.RevertSubstitutions(absenceId, "")
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while reverting substitutions", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	err = server.db.DeleteTeacherAbsence(absenceId)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while deleting the teacher absence", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusOK)
+}
+
+// PlanTeacherAbsenceSubstitutions vrne predlog nadomeščanj za vsa srečanja, ki jih zadeva odsotnost. Ničesar ne spremeni.
+func (server *httpImpl) PlanTeacherAbsenceSubstitutions(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	absence, err := server.db.GetTeacherAbsence(mux.Vars(r)["absence_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the teacher absence", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	plan, err := server.proton.PlanSubstitutions(absence, server.config)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Proton failed to plan substitutions", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: plan, Success: true}, http.StatusOK)
+}
+
+// ApplyTeacherAbsenceSubstitutions uveljavi nadomeščanja. Če je podan plan (npr. popravljen predogled), se uporabi ta,
+// drugače se nadomeščanja izračunajo na novo. Nadomestni učitelji dobijo obvestilo.
+func (server *httpImpl) ApplyTeacherAbsenceSubstitutions(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	absence, err := server.db.GetTeacherAbsence(mux.Vars(r)["absence_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the teacher absence", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+
+	var plan []proton.SubstitutionAssignment
+	if r.FormValue("plan") != "" {
+		err = json.Unmarshal([]byte(r.FormValue("plan")), &plan)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while unmarshalling the substitution plan", Error: err.Error(), Success: false}, http.StatusBadRequest)
+			return
+		}
+		// Od predogleda se je stanje lahko spremenilo, plan pa je lahko tudi ročno popravljen.
+		err = server.proton.ValidateSubstitutions(absence, plan, server.config)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Invalid substitution plan", Error: err.Error(), Success: false}, http.StatusConflict)
+			return
+		}
+	} else {
+		plan, err = server.proton.PlanSubstitutions(absence, server.config)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Proton failed to plan substitutions", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Nadomeščamo lahko samo srečanja odsotnega učitelja v obdobju odsotnosti.
+	meetings, err := server.db.GetMeetingsForTeacherBetween(absence.TeacherID, absence.StartDate, absence.EndDate)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving meetings", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	affected := make(map[string]sql.Meeting)
+	for i := 0; i < len(meetings); i++ {
+		affected[meetings[i].ID] = meetings[i]
+	}
+
+	substitutions := make([]sql.Substitution, 0)
+	notifications := make(map[string][]string)
+	for i := 0; i < len(plan); i++ {
+		assignment := plan[i]
+		if assignment.SubstituteTeacherID == nil {
+			continue
+		}
+		meeting, ok := affected[assignment.MeetingID]
+		if !ok {
+			WriteJSON(w, Response{Data: fmt.Sprintf("Meeting %s is not affected by this absence", assignment.MeetingID), Success: false}, http.StatusBadRequest)
+			return
+		}
+		substitutions = append(substitutions, sql.Substitution{
+			AbsenceID:           &absence.ID,
+			MeetingID:           assignment.MeetingID,
+			OriginalTeacherID:   absence.TeacherID,
+			SubstituteTeacherID: *assignment.SubstituteTeacherID,
+			IsPaid:              true,
+		})
+		notifications[*assignment.SubstituteTeacherID] = append(
+			notifications[*assignment.SubstituteTeacherID],
+			fmt.Sprintf("%s, %d. ura - %s", meeting.Date, meeting.Hour, meeting.MeetingName),
+		)
+	}
+
+	err = server.db.ApplySubstitutions(substitutions)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while applying substitutions", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	for teacherId, lessons := range notifications {
+		err = server.notifyUsers(user.ID, []string{teacherId}, "Nadomeščanje", fmt.Sprintf("Dodeljena so vam nadomeščanja:\n%s", strings.Join(lessons, "\n")))
+		if err != nil {
+			server.logger.Errorw("failed to notify the substitute teacher", "teacher", teacherId, "error", err.Error())
+		}
+	}
+
+	WriteJSON(w, Response{Data: substitutions, Success: true}, http.StatusOK)
+}
+
+// RevertTeacherAbsenceSubstitutions razveljavi nadomeščanja, ko se učitelj vrne. Če je podan datum from,
+// se razveljavijo samo nadomeščanja od tega dne naprej, odsotnost pa se ustrezno skrajša.
+func (server *httpImpl) RevertTeacherAbsenceSubstitutions(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	absence, err := server.db.GetTeacherAbsence(mux.Vars(r)["absence_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the teacher absence", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+
+	from := r.FormValue("from")
+	if from != "" {
+		fromDate, err := time.Parse("02-01-2006", from)
+		if err != nil {
+			WriteBadRequest(w)
+			return
+		}
+		start, err := time.Parse("02-01-2006", absence.StartDate)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Invalid teacher absence", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		end, err := time.Parse("02-01-2006", absence.EndDate)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Invalid teacher absence", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		// Odsotnost se lahko le skrajša, from po koncu odsotnosti bi jo podaljšal.
+		if fromDate.After(end) {
+			WriteJSON(w, Response{Data: "from is after the end of the teacher absence", Success: false}, http.StatusBadRequest)
+			return
+		}
+		if fromDate.After(start) {
+			absence.EndDate = fromDate.AddDate(0, 0, -1).Format("02-01-2006")
+			err = server.db.UpdateTeacherAbsence(absence)
+			if err != nil {
+				WriteJSON(w, Response{Data: "Failed while updating the teacher absence", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	err = server.db.RevertSubstitutions(absence.ID, from)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while reverting substitutions", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusOK)
+}
+
+type SubstitutionSummary struct {
+	sql.SubstitutionStats
+	Name string
+}
+
+type SubstitutionReport struct {
+	Month         string
+	Teachers      []SubstitutionSummary
+	Substitutions []sql.SubstitutionEntry
+}
+
+func (server *httpImpl) getSubstitutionSummaries(start string, end string) ([]SubstitutionSummary, error) {
+	stats, err := server.db.GetSubstitutionStats(start, end)
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]SubstitutionSummary, 0)
+	for i := 0; i < len(stats); i++ {
+		teacher, err := server.db.GetUser(stats[i].TeacherID)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, SubstitutionSummary{SubstitutionStats: stats[i], Name: teacher.Name})
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Name < summaries[j].Name
+	})
+	return summaries, nil
+}
+
+// GetSubstitutionStats vrne zgodovino nadomeščanj po učiteljih med start in end.
+// Brez parametrov vrne zgodovino za tekoče šolsko leto.
+func (server *httpImpl) GetSubstitutionStats(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	start := r.URL.Query().Get("start")
+	end := r.URL.Query().Get("end")
+	if start == "" {
+		start = proton.FirstMondayOfSchoolYear(time.Now()).Format("02-01-2006")
+	}
+	if end == "" {
+		end = time.Now().Format("02-01-2006")
+	}
+	_, err = time.Parse("02-01-2006", start)
+	if err != nil {
+		WriteBadRequest(w)
+		return
+	}
+	_, err = time.Parse("02-01-2006", end)
+	if err != nil {
+		WriteBadRequest(w)
+		return
+	}
+	summaries, err := server.getSubstitutionSummaries(start, end)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving substitution statistics", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: summaries, Success: true}, http.StatusOK)
+}
+
+// PatchSubstitutionPaid označi nadomeščanje kot plačano ali neplačano (npr. ko učitelj nadomešča v okviru svoje obveze).
+func (server *httpImpl) PatchSubstitutionPaid(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	isPaid, err := strconv.ParseBool(r.FormValue("is_paid"))
+	if err != nil {
+		WriteBadRequest(w)
+		return
+	}
+	err = server.db.SetSubstitutionPaid(mux.Vars(r)["substitution_id"], isPaid)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while updating the substitution", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusOK)
+}
+
+// GetSubstitutionReport vrne mesečno poročilo o nadomeščanjih za obračun plač.
+// Mesec je podan kot MM-YYYY, format pa je json (privzeto), csv ali pdf.
+func (server *httpImpl) GetSubstitutionReport(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	month, err := time.Parse("01-2006", r.URL.Query().Get("month"))
+	if err != nil {
+		WriteBadRequest(w)
+		return
+	}
+	start := month.Format("02-01-2006")
+	end := month.AddDate(0, 1, -1).Format("02-01-2006")
+
+	summaries, err := server.getSubstitutionSummaries(start, end)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving substitution statistics", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" || format == "json" {
+		substitutions, err := server.db.GetSubstitutionsBetween(start, end)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while retrieving substitutions", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		WriteJSON(w, Response{Data: SubstitutionReport{
+			Month:         month.Format("01-2006"),
+			Teachers:      summaries,
+			Substitutions: substitutions,
+		}, Success: true}, http.StatusOK)
+		return
+	}
+
+	if format == "csv" {
+		var output bytes.Buffer
+		writer := csv.NewWriter(&output)
+		_ = writer.Write([]string{"teacher_id", "teacher", "days", "hours", "paid_hours", "unpaid_hours"})
+		for i := 0; i < len(summaries); i++ {
+			summary := summaries[i]
+			_ = writer.Write([]string{
+				summary.TeacherID,
+				summary.Name,
+				fmt.Sprint(summary.Days),
+				fmt.Sprint(summary.Hours),
+				fmt.Sprint(summary.PaidHours),
+				fmt.Sprint(summary.UnpaidHours),
+			})
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			WriteJSON(w, Response{Data: "Failed while exporting the report", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"substitutions-%s.csv\"", month.Format("01-2006")))
+		w.Write(output.Bytes())
+		return
+	}
+
+	if format != "pdf" {
+		WriteJSON(w, Response{Data: "Unsupported format", Success: false}, http.StatusBadRequest)
+		return
+	}
+
+	m := pdf.NewMaroto(consts.Portrait, consts.A4)
+
+	m.AddUTF8Font("OpenSans", consts.Normal, "fonts/opensans.ttf")
+	m.SetDefaultFontFamily("OpenSans")
+
+	m.Row(40, func() {
+		m.Col(3, func() {
+			_ = m.FileImage("icons/school_logo.png", props.Rect{
+				Center:  true,
+				Percent: 80,
+			})
+		})
+		m.ColSpace(1)
+		m.Col(8, func() {
+			m.Text("Poročilo o nadomeščanjih", props.Text{
+				Top:         12,
+				Size:        20,
+				Extrapolate: true,
+			})
+			m.Text(fmt.Sprintf("%s, obdobje %s - %s", server.config.SchoolName, start, end), props.Text{
+				Top:         23,
+				Size:        11,
+				Extrapolate: true,
+			})
+		})
+	})
+
+	m.Line(10)
+
+	contents := make([][]string, 0)
+	var hours, paidHours, unpaidHours = 0, 0, 0
+	for i := 0; i < len(summaries); i++ {
+		summary := summaries[i]
+		contents = append(contents, []string{
+			summary.Name,
+			fmt.Sprint(summary.Days),
+			fmt.Sprint(summary.Hours),
+			fmt.Sprint(summary.PaidHours),
+			fmt.Sprint(summary.UnpaidHours),
+		})
+		hours += summary.Hours
+		paidHours += summary.PaidHours
+		unpaidHours += summary.UnpaidHours
+	}
+	contents = append(contents, []string{"Skupaj", "", fmt.Sprint(hours), fmt.Sprint(paidHours), fmt.Sprint(unpaidHours)})
+
+	m.TableList([]string{"Učitelj", "Dnevi", "Ure", "Plačane ure", "Neplačane ure"}, contents, props.TableList{
+		HeaderProp: props.TableListContent{
+			Family:    "OpenSans",
+			Size:      10,
+			GridSizes: []uint{4, 2, 2, 2, 2},
+		},
+		ContentProp: props.TableListContent{
+			Family:    "OpenSans",
+			Size:      10,
+			GridSizes: []uint{4, 2, 2, 2, 2},
+		},
+		Line: true,
+	})
+
+	m.Line(10)
+
+	UUID := newDocumentID()
+
+	m.Row(30, func() {
+		m.Col(9, func() {
+			m.Text(fmt.Sprintf("Enolični identifikator dokumenta: %s", UUID), props.Text{
+				Top:  8,
+				Size: 10,
+			})
+		})
+		m.Col(3, func() {
+			m.QrCode(server.documentVerificationURL(UUID), props.Rect{
+				Center:  true,
+				Percent: 100,
+			})
+		})
+	})
+
+	output, err := m.Output()
+	if err != nil {
+		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("documents/%s.pdf", UUID)
+
+	err = helpers.Sign(output.Bytes(), filename, "cacerts/key-pair.p12", "")
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while signing", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	err = server.insertSignedDocument(sql.Document{
+		ID:           UUID,
+		ExportedBy:   user.ID,
+		DocumentType: POROCILO_O_NADOMESCANJIH,
+		IsSigned:     true,
+	})
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while inserting document into the database", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	file, err := os.ReadFile(filename)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while reading signed document", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(file)
+}
@@ -7,11 +7,7 @@ import (
 	"fmt"
 	"github.com/MeetPlan/MeetPlanBackend/helpers"
 	"github.com/MeetPlan/MeetPlanBackend/sql"
-	"github.com/google/uuid"
 	"github.com/gorilla/mux"
-	"github.com/johnfercher/maroto/pkg/consts"
-	"github.com/johnfercher/maroto/pkg/pdf"
-	"github.com/johnfercher/maroto/pkg/props"
 	"net/http"
 	"os"
 	"strings"
@@ -176,170 +172,30 @@ func (server *httpImpl) GetPDFSelfTestingReportStudent(w http.ResponseWriter, r
 		return
 	}
 
-	//jwt, err, expiration := sql.GetJWTForTestingResult(test.UserID, test.Result, test.ID, test.Date)
-	//if err != nil {
-	//	return
-	//}
-
-	m := pdf.NewMaroto(consts.Portrait, consts.A4)
-
-	m.AddUTF8Font("OpenSans", consts.Normal, "fonts/opensans.ttf")
-	m.SetDefaultFontFamily("OpenSans")
-
-	m.Row(40, func() {
-		m.Col(3, func() {
-			_ = m.Base64Image(MeetPlanLogoBase64, consts.Png, props.Rect{
-				Center:  true,
-				Percent: 80,
-			})
-		})
-
-		m.ColSpace(1)
-
-		m.Col(5, func() {
-			m.Text("MeetPlan", props.Text{
-				Top:         12,
-				Size:        30,
-				Extrapolate: true,
-			})
-			m.Text("Rezultati samotestiranja", props.Text{
-				Size: 20,
-				Top:  22,
-			})
-		})
-		m.ColSpace(4)
-	})
-
-	m.Line(10)
-
-	m.Row(40, func() {
-		m.Col(7, func() {
-			m.Text("Rezultat testiranja: ", props.Text{
-				Size: 15,
-				Top:  12,
-			})
-			m.Text(test.Result, props.Text{Size: 20, Top: 20})
-			if test.Result == "POZITIVEN" {
-				m.Text(
-					"Vaš test je bil pozitiven. Samoizolirajte se v čim manjšem možnem času. To potrdilo vam lahko s podpisom osebe, ki je izvajala testiranje, tudi služi kot dokaz za PCR testiranje.",
-					props.Text{Top: 30},
-				)
-			} else if test.Result == "NEVELJAVEN" {
-				m.Text(
-					"Vaš test je bil neveljaven. Ponovite testiranje.",
-					props.Text{Top: 30},
-				)
-			}
-		})
-		m.ColSpace(1)
-		//m.Col(4, func() {
-		//	m.QrCode(jwt, props.Rect{
-		//		Center:  true,
-		//		Percent: 100,
-		//	})
-		//})
-	})
-
-	m.Line(10)
-
-	m.SetBorder(true)
-
-	m.Row(60, func() {
-		m.Col(6, func() {
-			m.Text(fmt.Sprintf(" Enolični identifikator testiranja: %s", fmt.Sprint(test.ID)), props.Text{
-				Size: 15,
-				Top:  5,
-			})
-			m.Text(fmt.Sprintf(" Datum izvedbe testiranja: %s", test.Date), props.Text{
-				Size: 15,
-				Top:  15,
-			})
-			//m.Text(fmt.Sprintf(" Datum veljavnosti testa: %s", expiration), props.Text{
-			//	Size: 15,
-			//	Top:  25,
-			//})
-			m.Text(fmt.Sprintf(" Oseba: %s", student.Name), props.Text{
-				Size: 15,
-				Top:  35,
-			})
-			m.Text(fmt.Sprintf(" Enolični identifikator osebe: %s", fmt.Sprint(student.ID)), props.Text{
-				Size: 15,
-				Top:  45,
-			})
-		})
-		m.Col(6, func() {
-			m.Text("Izdal MeetPlan Certificate Authority", props.Text{
-				Top:   25,
-				Size:  15,
-				Align: consts.Center,
-			})
-		})
-	})
-
-	m.SetBorder(false)
-
-	m.Row(40, func() {})
-
-	m.Row(40, func() {
-		m.ColSpace(1)
-		m.Col(6, func() {
-			m.Text("_________________________", props.Text{
-				Top:  14,
-				Size: 15,
-			})
-			m.Text(teacher.Name, props.Text{
-				Top:  14,
-				Size: 15,
-			})
-			m.Text("digitalni podpis izvajalca testiranja", props.Text{Top: 20, Size: 9})
-		})
-		m.Col(3, func() {
-			m.Text("_________________________", props.Text{
-				Top:  14,
-				Size: 15,
-			})
-			m.Text("podpis izvajalca testiranja", props.Text{Top: 20, Size: 9})
-		})
-	})
-
-	m.Row(5, func() {
-		m.Col(12, func() {
-			m.Text("S podpisom tega dokumenta, potrjujem, da se je oseba, navedena zgoraj samotestirala in to sem to tudi potrdil(a).", props.Text{
-				Top:  14,
-				Size: 15,
-			})
-		})
-	})
-
-	UUID := uuid.New().String()
-
-	m.Row(15, func() {
-		m.Col(12, func() {
-			m.Text(fmt.Sprintf("Edinstveni identifikator dokumenta: %s", UUID), props.Text{
-				Top:  40,
-				Size: 10,
-			})
-		})
-	})
+	supersedes, ok := server.supersededDocument(w, r, user, POTRDILO_O_SAMOTESTIRANJU, student.ID)
+	if !ok {
+		return
+	}
 
-	m.Row(5, func() {
-		m.Col(12, func() {
-			m.Text(fmt.Sprintf("Izvozil/a: %s", user.Name), props.Text{
-				Top:  12,
-				Size: 10,
-			})
-		})
-	})
+	UUID := newDocumentID()
 
-	filename := fmt.Sprintf("documents/%s.pdf", UUID)
+	values := server.documentTemplateValues(UUID)
+	addUserTemplateValues(values, "student", student)
+	addUserTemplateValues(values, "teacher", teacher)
+	addUserTemplateValues(values, "exporter", user)
+	values["testing.id"] = test.ID
+	values["testing.date"] = test.Date
+	values["testing.result"] = test.Result
 
-	output, err := m.Output()
+	output, err := server.renderDocument(TEMPLATE_POTRDILO_O_SAMOTESTIRANJU, TemplateData{Values: values}, false)
 	if err != nil {
-		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		WriteJSON(w, Response{Data: "Failed while rendering the document", Error: err.Error(), Success: false}, http.StatusInternalServerError)
 		return
 	}
 
-	err = helpers.Sign(output.Bytes(), filename, "cacerts/key-pair.p12", "")
+	filename := fmt.Sprintf("documents/%s.pdf", UUID)
+
+	err = helpers.Sign(output, filename, "cacerts/key-pair.p12", "")
 	if err != nil {
 		WriteJSON(w, Response{Data: "Failed while signing", Error: err.Error(), Success: false}, http.StatusInternalServerError)
 		return
@@ -350,9 +206,11 @@ func (server *httpImpl) GetPDFSelfTestingReportStudent(w http.ResponseWriter, r
 		ExportedBy:   user.ID,
 		DocumentType: POTRDILO_O_SAMOTESTIRANJU,
 		IsSigned:     true,
+		Supersedes:   supersedes,
+		IssuedFor:    &student.ID,
 	}
 
-	err = server.db.InsertDocument(document)
+	err = server.insertSignedDocument(document)
 	if err != nil {
 		WriteJSON(w, Response{Data: "Failed while inserting document into the database", Error: err.Error(), Success: false}, http.StatusInternalServerError)
 		return
@@ -0,0 +1,391 @@
+package httphandlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/MeetPlan/MeetPlanBackend/proton"
+	"github.com/MeetPlan/MeetPlanBackend/sql"
+	"github.com/gorilla/mux"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const TIMETABLE_FORMAT_CSV = "csv"
+const TIMETABLE_FORMAT_FET = "fet"
+const TIMETABLE_FORMAT_ICS = "ics"
+
+func (server *httpImpl) ImportTimetable(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while reading the uploaded file", Error: err.Error(), Success: false}, http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while reading the uploaded file", Error: err.Error(), Success: false}, http.StatusBadRequest)
+		return
+	}
+
+	var rows []proton.TimetableRow
+	format := r.FormValue("format")
+	if format == TIMETABLE_FORMAT_CSV {
+		rows, err = proton.ParseTimetableCSV(data)
+	} else if format == TIMETABLE_FORMAT_FET {
+		rows, err = proton.ParseFET(data)
+	} else {
+		WriteJSON(w, Response{Data: "Unsupported format", Success: false}, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while parsing the timetable", Error: err.Error(), Success: false}, http.StatusBadRequest)
+		return
+	}
+
+	err = server.proton.ReloadConfig()
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed to load Proton rules", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	protonMeetings, importErrors, err := server.proton.ResolveTimetableRows(rows)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while validating the timetable", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	if len(importErrors) != 0 {
+		WriteJSON(w, Response{Data: importErrors, Error: "Timetable contains unknown subjects, teachers or classes", Success: false}, http.StatusBadRequest)
+		return
+	}
+
+	ok, err := server.proton.CheckIfProtonConfigIsOk(protonMeetings)
+	if !ok {
+		WriteJSON(w, Response{Data: protonMeetings, Error: err.Error(), Success: false}, http.StatusConflict)
+		return
+	}
+
+	accept, err := strconv.ParseBool(r.FormValue("accept"))
+	if err != nil || !accept {
+		// Predogled. Urnik se sprejme s /proton/accept/timetable ali s ponovnim uvozom z accept=true.
+		WriteJSON(w, Response{Data: protonMeetings, Success: true}, http.StatusOK)
+		return
+	}
+
+	timetableVersion, err := server.AcceptTimetable(protonMeetings, r.FormValue("name"), user.ID)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while accepting the timetable", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: timetableVersion, Error: "OK", Success: true}, http.StatusCreated)
+}
+
+func (server *httpImpl) writeTimetableExport(w http.ResponseWriter, timetable []proton.ProtonMeeting, format string) {
+	rows, err := server.proton.DescribeTimetable(timetable)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while preparing the timetable for export", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	var export []byte
+	if format == TIMETABLE_FORMAT_CSV {
+		export, err = proton.ExportTimetableCSV(rows)
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", "attachment; filename=\"timetable.csv\"")
+	} else if format == TIMETABLE_FORMAT_FET {
+		export, err = proton.ExportTimetableFET(rows, server.config.SchoolName)
+		w.Header().Set("Content-Type", "application/xml")
+		w.Header().Set("Content-Disposition", "attachment; filename=\"timetable.fet\"")
+	} else {
+		WriteJSON(w, Response{Data: "Unsupported format", Success: false}, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		w.Header().Del("Content-Disposition")
+		WriteJSON(w, Response{Data: "Failed while exporting the timetable", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	w.Write(export)
+}
+
+func (server *httpImpl) ExportAssembledTimetable(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	var protonMeetings []proton.ProtonMeeting
+	err = json.Unmarshal([]byte(r.FormValue("timetable")), &protonMeetings)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while unmarshalling proton meetings", Error: err.Error(), Success: false}, http.StatusBadRequest)
+		return
+	}
+	server.writeTimetableExport(w, protonMeetings, r.FormValue("format"))
+}
+
+// getAcceptedMeetings vrne vsa sprejeta (ne-beta) srečanja med start in end, vključno z obema datumoma.
+func (server *httpImpl) getAcceptedMeetings(start time.Time, end time.Time) ([]sql.Meeting, error) {
+	meetings := make([]sql.Meeting, 0)
+	for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
+		dayMeetings, err := server.db.GetMeetingsOnSpecificDate(date.Format("02-01-2006"), false)
+		if err != nil {
+			return nil, err
+		}
+		meetings = append(meetings, dayMeetings...)
+	}
+	return meetings, nil
+}
+
+func escapeICS(text string) string {
+	text = strings.ReplaceAll(text, "\\", "\\\\")
+	text = strings.ReplaceAll(text, ";", "\\;")
+	text = strings.ReplaceAll(text, ",", "\\,")
+	return strings.ReplaceAll(text, "\n", "\\n")
+}
+
+// ExportCurrentTimetable izvozi sprejet (ne-beta) urnik. CSV in FET vsebujeta dvotedenski urnik,
+// ki se začne s tednom podanega datuma, iCalendar pa vsa srečanja med start in end.
+func (server *httpImpl) ExportCurrentTimetable(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	start, err := time.Parse("02-01-2006", r.URL.Query().Get("start"))
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed at converting start to Time", Error: err.Error(), Success: false}, http.StatusBadRequest)
+		return
+	}
+	format := r.URL.Query().Get("format")
+
+	var end time.Time
+	if format == TIMETABLE_FORMAT_ICS {
+		end, err = time.Parse("02-01-2006", r.URL.Query().Get("end"))
+		if err != nil || end.Before(start) {
+			WriteBadRequest(w)
+			return
+		}
+	} else {
+		// Ponedeljek v tednu začetnega datuma
+		start = start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+		end = start.AddDate(0, 0, 13)
+	}
+
+	meetings, err := server.getAcceptedMeetings(start, end)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving meetings", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	if format != TIMETABLE_FORMAT_ICS {
+		timetable, err := server.proton.ProtonMeetingsFromMeetings(meetings, start)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while converting meetings", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		server.writeTimetableExport(w, timetable, format)
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//MeetPlan//MeetPlanBackend//SL\r\n")
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	for i := 0; i < len(meetings); i++ {
+		meeting := meetings[i]
+		date, err := time.Parse("02-01-2006", meeting.Date)
+		if err != nil {
+			continue
+		}
+		// Srečanja nimajo ure začetka, zato jih zapišemo kot celodnevne dogodke z zaporedno uro v naslovu.
+		b.WriteString("BEGIN:VEVENT\r\n")
+		b.WriteString(fmt.Sprintf("UID:%s@meetplan\r\n", meeting.ID))
+		b.WriteString(fmt.Sprintf("DTSTAMP:%s\r\n", stamp))
+		b.WriteString(fmt.Sprintf("DTSTART;VALUE=DATE:%s\r\n", date.Format("20060102")))
+		b.WriteString(fmt.Sprintf("SUMMARY:%s\r\n", escapeICS(fmt.Sprintf("%d. ura - %s", meeting.Hour, meeting.MeetingName))))
+		b.WriteString(fmt.Sprintf("LOCATION:%s\r\n", escapeICS(meeting.Location)))
+		b.WriteString("END:VEVENT\r\n")
+	}
+	b.WriteString("END:VCALENDAR\r\n")
+
+	w.Header().Set("Content-Type", "text/calendar")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"timetable.ics\"")
+	w.Write([]byte(b.String()))
+}
+
+func (server *httpImpl) GetTimetableVersions(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	versions, err := server.db.GetTimetableVersions()
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving timetable versions", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: versions, Success: true}, http.StatusOK)
+}
+
+func (server *httpImpl) getTimetableVersionFromRequest(w http.ResponseWriter, r *http.Request) (sql.TimetableVersion, bool) {
+	version, err := strconv.Atoi(mux.Vars(r)["version"])
+	if err != nil {
+		WriteBadRequest(w)
+		return sql.TimetableVersion{}, false
+	}
+	timetableVersion, err := server.db.GetTimetableVersion(version)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the timetable version", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return sql.TimetableVersion{}, false
+	}
+	return timetableVersion, true
+}
+
+func (server *httpImpl) PublishTimetableVersion(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	timetableVersion, ok := server.getTimetableVersionFromRequest(w, r)
+	if !ok {
+		return
+	}
+	if timetableVersion.Status != sql.TIMETABLE_VERSION_DRAFT {
+		WriteJSON(w, Response{Data: "Only draft timetable versions can be published", Success: false}, http.StatusConflict)
+		return
+	}
+	err = server.db.PublishTimetableVersion(timetableVersion.Version)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while publishing the timetable version", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusOK)
+}
+
+// RollbackTimetableVersion prekliče aktivno različico urnika in ponovno objavi prejšnjo.
+// Srečanja, na katera so že vezane ocene, izostanki ali domače naloge, ostanejo nespremenjena.
+func (server *httpImpl) RollbackTimetableVersion(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	timetableVersion, ok := server.getTimetableVersionFromRequest(w, r)
+	if !ok {
+		return
+	}
+	if timetableVersion.Status != sql.TIMETABLE_VERSION_ACTIVE {
+		WriteJSON(w, Response{Data: "Only the active timetable version can be rolled back", Success: false}, http.StatusConflict)
+		return
+	}
+	err = server.db.RollbackTimetableVersion(timetableVersion)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while rolling back the timetable version", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusOK)
+}
+
+func (server *httpImpl) DeleteTimetableVersion(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	timetableVersion, ok := server.getTimetableVersionFromRequest(w, r)
+	if !ok {
+		return
+	}
+	if timetableVersion.Status != sql.TIMETABLE_VERSION_DRAFT {
+		WriteJSON(w, Response{Data: "Only draft timetable versions can be deleted", Success: false}, http.StatusConflict)
+		return
+	}
+	err = server.db.DeleteDraftTimetableVersion(timetableVersion.Version)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while deleting the timetable version", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, Response{Data: "OK", Success: true}, http.StatusOK)
+}
+
+// DiffTimetableVersions primerja dvotedenska urnika dveh različic (from in to), ki se začneta s tednom datuma start,
+// ločeno po razredih in učiteljih.
+func (server *httpImpl) DiffTimetableVersions(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	if !(user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		WriteForbiddenJWT(w)
+		return
+	}
+	from, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil {
+		WriteBadRequest(w)
+		return
+	}
+	to, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil {
+		WriteBadRequest(w)
+		return
+	}
+	start, err := time.Parse("02-01-2006", r.URL.Query().Get("start"))
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed at converting start to Time", Error: err.Error(), Success: false}, http.StatusBadRequest)
+		return
+	}
+	// Ponedeljek v tednu začetnega datuma
+	start = start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+
+	timetables := make([][]proton.ProtonMeeting, 0)
+	for _, version := range []int{from, to} {
+		meetings, err := server.db.GetMeetingsForTimetableVersion(version)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while retrieving meetings", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		timetable, err := server.proton.ProtonMeetingsFromMeetings(meetings, start)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while converting meetings", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		timetables = append(timetables, timetable)
+	}
+
+	WriteJSON(w, Response{Data: proton.DiffTimetablesByClassAndTeacher(timetables[0], timetables[1]), Success: true}, http.StatusOK)
+}
@@ -8,11 +8,7 @@ import (
 	"github.com/MeetPlan/MeetPlanBackend/helpers"
 	"github.com/MeetPlan/MeetPlanBackend/sql"
 	"github.com/dchest/uniuri"
-	"github.com/google/uuid"
 	"github.com/gorilla/mux"
-	"github.com/johnfercher/maroto/pkg/consts"
-	"github.com/johnfercher/maroto/pkg/pdf"
-	"github.com/johnfercher/maroto/pkg/props"
 	"github.com/signintech/gopdf"
 	"net/http"
 	"os"
@@ -467,7 +463,7 @@ func (server *httpImpl) GetAbsencesUser(w http.ResponseWriter, r *http.Request)
 			WriteJSON(w, Response{Data: "Could not fetch meeting", Error: err.Error(), Success: false}, http.StatusInternalServerError)
 			return
 		}
-		if absence.AbsenceType == "ABSENT" || absence.AbsenceType == "LATE" {
+		if !(absence.AbsenceType == sql.ABSENCE_UNMANAGED || absence.AbsenceType == sql.ABSENCE_PRESENT) {
 			absenceJson = append(absenceJson, Absence{
 				Absence:     absence,
 				TeacherName: teacher.Name,
@@ -609,6 +605,18 @@ func (server *httpImpl) HasBirthday(w http.ResponseWriter, r *http.Request) {
 	WriteJSON(w, Response{Data: tm-bm == 0 && td-bd == 0, Success: true}, http.StatusOK)
 }
 
+func (server *httpImpl) schoolingCertificateTemplateData(student sql.User, class sql.Class, UUID string) (TemplateData, error) {
+	principal, err := server.db.GetPrincipal()
+	if err != nil {
+		return TemplateData{}, err
+	}
+	values := server.documentTemplateValues(UUID)
+	addUserTemplateValues(values, "student", student)
+	addClassTemplateValues(values, class)
+	values["principal.name"] = principal.Name
+	return TemplateData{Values: values}, nil
+}
+
 func (server *httpImpl) CertificateOfSchooling(w http.ResponseWriter, r *http.Request) {
 	user, err := server.db.CheckToken(GetAuthorizationToken(r))
 	if err != nil {
@@ -675,112 +683,28 @@ func (server *httpImpl) CertificateOfSchooling(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	m := pdf.NewMaroto(consts.Portrait, consts.A4)
-
-	m.AddUTF8Font("OpenSans", consts.Normal, "fonts/opensans.ttf")
-	m.SetDefaultFontFamily("OpenSans")
-
-	m.Row(40, func() {
-
-		m.Col(3, func() {
-			_ = m.FileImage("icons/school_logo.png", props.Rect{
-				Center:  true,
-				Percent: 80,
-			})
-		})
-
-		m.ColSpace(1)
-
-		m.Col(4, func() {
-			m.Text("Potrdilo o šolanju", props.Text{
-				Top:         12,
-				Size:        25,
-				Extrapolate: true,
-			})
-			m.Text("MeetPlan sistem", props.Text{
-				Top:         23,
-				Size:        13,
-				Extrapolate: true,
-			})
-		})
-		m.ColSpace(1)
-
-		m.Col(3, func() {
-			_ = m.FileImage("icons/country_coat_of_arms_black.png", props.Rect{
-				Center:  true,
-				Percent: 80,
-			})
-		})
-	})
+	supersedes, ok := server.supersededDocument(w, r, user, POTRDILO_O_SOLANJU, student.ID)
+	if !ok {
+		return
+	}
 
-	m.Line(10)
-
-	m.Row(40, func() {
-		m.Text(fmt.Sprintf(
-			"Učenec %s, rojen %s, %s, %s, v šolskem letu %s",
-			student.Name, student.Birthday, student.CityOfBirth,
-			student.CountryOfBirth, class.ClassYear,
-		), props.Text{
-			Top:         12,
-			Size:        11,
-			Extrapolate: true,
-		})
-		m.Text(fmt.Sprintf("obiskuje %s razred šole %s.",
-			class.Name, server.config.SchoolName,
-		), props.Text{
-			Top:         16,
-			Size:        11,
-			Extrapolate: true,
-		})
-	})
+	UUID := newDocumentID()
 
-	principal, err := server.db.GetPrincipal()
+	data, err := server.schoolingCertificateTemplateData(student, class, UUID)
 	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while preparing the certificate", Error: err.Error(), Success: false}, http.StatusInternalServerError)
 		return
 	}
 
-	m.Row(40, func() {
-		m.ColSpace(1)
-		m.Col(6, func() {
-			m.Text("_________________________", props.Text{
-				Top:  14,
-				Size: 15,
-			})
-			m.Text(principal.Name, props.Text{
-				Top:  14,
-				Size: 15,
-			})
-			m.Text("digitalni podpis ravnatelja", props.Text{Top: 20, Size: 9})
-		})
-		m.Col(3, func() {
-			m.Text("_________________________", props.Text{
-				Top:  14,
-				Size: 15,
-			})
-			m.Text("podpis ravnatelja", props.Text{Top: 20, Size: 9})
-		})
-	})
-
-	m.Line(10)
-
-	UUID := uuid.New().String()
-
-	m.Row(40, func() {
-		m.Text(fmt.Sprintf("Enolični identifikator dokumenta: %s", UUID), props.Text{
-			Top:  14,
-			Size: 10,
-		})
-	})
-
-	output, err := m.Output()
+	output, err := server.renderDocument(TEMPLATE_POTRDILO_O_SOLANJU, data, false)
 	if err != nil {
-		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		WriteJSON(w, Response{Data: "Failed while rendering the document", Error: err.Error(), Success: false}, http.StatusInternalServerError)
 		return
 	}
 
 	filename := fmt.Sprintf("documents/%s.pdf", UUID)
 
-	err = helpers.Sign(output.Bytes(), filename, "cacerts/key-pair.p12", "")
+	err = helpers.Sign(output, filename, "cacerts/key-pair.p12", "")
 	if err != nil {
 		WriteJSON(w, Response{Data: "Failed while signing", Error: err.Error(), Success: false}, http.StatusInternalServerError)
 		return
@@ -791,9 +715,11 @@ func (server *httpImpl) CertificateOfSchooling(w http.ResponseWriter, r *http.Re
 		ExportedBy:   user.ID,
 		DocumentType: POTRDILO_O_SOLANJU,
 		IsSigned:     true,
+		Supersedes:   supersedes,
+		IssuedFor:    &student.ID,
 	}
 
-	err = server.db.InsertDocument(document)
+	err = server.insertSignedDocument(document)
 	if err != nil {
 		WriteJSON(w, Response{Data: "Failed while inserting document into the database", Error: err.Error(), Success: false}, http.StatusInternalServerError)
 		return
@@ -808,20 +734,13 @@ func (server *httpImpl) CertificateOfSchooling(w http.ResponseWriter, r *http.Re
 	w.Write(file)
 }
 
-func (server *httpImpl) GenerateNewUserCert(pdf *gopdf.GoPdf, userId string) (*gopdf.GoPdf, string, error) {
+// GenerateNewUserCert uporabniku nastavi novo naključno geslo in v pdf doda stran s pristopno izjavo.
+func (server *httpImpl) GenerateNewUserCert(pdf *gopdf.GoPdf, definition DocumentTemplateDefinition, userId string) (*gopdf.GoPdf, string, error) {
 	user, err := server.db.GetUser(userId)
 	if err != nil {
 		return pdf, "", err
 	}
 
-	pdf.AddPage()
-	rect := gopdf.Rect{H: 120, W: 120}
-
-	err = pdf.Image("icons/meetplan.png", 50, 50, &rect)
-	if err != nil {
-		return pdf, "", err
-	}
-
 	newPassword := uniuri.NewLen(10)
 	password, err := sql.HashPassword(newPassword)
 	if err != nil {
@@ -830,111 +749,16 @@ func (server *httpImpl) GenerateNewUserCert(pdf *gopdf.GoPdf, userId string) (*g
 
 	user.Password = password
 
-	const borderBase = 30
-
-	pdf.SetX(250)
-	pdf.SetY(120)
-	pdf.SetFontSize(30)
-	pdf.Text("MeetPlan")
-	pdf.SetFontSize(18)
-	pdf.SetX(250)
-	pdf.SetY(140)
-	pdf.Text("Pristopna izjava k MeetPlan sistemu")
-
-	pdf.Line(20, 200, 575, 200)
-
-	pdf.SetFontSize(13)
-	pdf.SetX(borderBase)
-	pdf.SetY(240)
-	pdf.Text("Verjetno ste bili že obveščeni, da je vaša šola to leto izbrala drug sistem.")
-	pdf.SetX(borderBase)
-	pdf.SetY(255)
-	pdf.Text("MeetPlan je popolnoma odprtokoden sistem, ki je popolnoma brezplačen za vse.")
-	pdf.SetX(borderBase)
-	pdf.SetY(270)
-	pdf.Text("Ta izjava vsebuje vaše osebne podatke za dostop do MeetPlan sistema.")
-	pdf.SetX(borderBase)
-	pdf.SetY(285)
-	pdf.Text("Priporočamo, da pri prvem vstopu v sistem to geslo tudi zamenjate.")
-	pdf.SetX(borderBase)
-	pdf.SetY(300)
-	pdf.Text("Poleg spodaj naštetih podatkov zbiramo samo še matično številko osebe.")
-
-	const differ = 200
-
-	pdf.SetX(borderBase)
-	pdf.SetY(350)
-	pdf.SetFontSize(10)
-	pdf.Text("uporabnik")
-	pdf.SetFontSize(20)
-	pdf.SetX(differ)
-	pdf.Text(user.Name)
-
-	pdf.SetX(borderBase)
-	pdf.SetY(380)
-	pdf.SetFontSize(10)
-	pdf.Text("elektronski naslov")
-	pdf.SetX(differ)
-	pdf.SetFontSize(20)
-	pdf.Text(user.Email)
-
-	pdf.SetX(borderBase)
-	pdf.SetY(410)
-	pdf.SetFontSize(10)
-	pdf.Text("geslo")
-	pdf.SetX(differ)
-	pdf.SetFontSize(20)
-	pdf.Text(newPassword)
-
-	pdf.SetX(borderBase)
-	pdf.SetY(440)
-	pdf.SetFontSize(10)
-	pdf.Text("naziv v sistemu")
-	pdf.SetX(differ)
-	pdf.SetFontSize(20)
-	pdf.Text(user.Role)
-
-	pdf.SetX(borderBase)
-	pdf.SetY(470)
-	pdf.SetFontSize(10)
-	pdf.Text("kraj rojstva")
-	pdf.SetX(differ)
-	pdf.SetFontSize(20)
-	pdf.Text(user.CityOfBirth)
-
-	pdf.SetX(borderBase)
-	pdf.SetY(500)
-	pdf.SetFontSize(10)
-	pdf.Text("država rojstva")
-	pdf.SetX(differ)
-	pdf.SetFontSize(20)
-	pdf.Text(user.CountryOfBirth)
-
-	pdf.SetX(borderBase)
-	pdf.SetY(530)
-	pdf.SetFontSize(10)
-	pdf.Text("datum rojstva")
-	pdf.SetX(differ)
-	pdf.SetFontSize(20)
-	pdf.Text(user.Birthday)
-
-	UUID := uuid.New().String()
-
-	pdf.SetX(borderBase)
-	pdf.SetY(560)
-	pdf.SetFontSize(10)
-	pdf.Text("enolični identifikator dokumenta")
-	pdf.SetFontSize(20)
-	pdf.SetX(differ)
-	pdf.Text(UUID)
-
-	pdf.SetX(borderBase)
-	pdf.SetY(590)
-	pdf.SetFontSize(10)
-	pdf.Text("enolični identifikator osebe")
-	pdf.SetFontSize(20)
-	pdf.SetX(differ)
-	pdf.Text(fmt.Sprint(user.ID))
+	UUID := newDocumentID()
+
+	values := server.documentTemplateValues(UUID)
+	addUserTemplateValues(values, "user", user)
+	values["user.password"] = newPassword
+
+	err = renderDocumentTemplate(pdf, definition, TemplateData{Values: values}, false)
+	if err != nil {
+		return pdf, "", err
+	}
 
 	err = server.db.UpdateUser(user)
 	return pdf, UUID, err
@@ -954,22 +778,19 @@ func (server *httpImpl) ResetPassword(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		p := &gopdf.GoPdf{}
-		p.Start(gopdf.Config{PageSize: *gopdf.PageSizeA4})
-
-		err = p.AddTTFFont("opensans", "fonts/opensans.ttf")
+		definition, _, err := server.loadDocumentTemplate(TEMPLATE_RESETIRANJE_GESLA)
 		if err != nil {
-			WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			WriteJSON(w, Response{Data: "Failed while loading the template", Error: err.Error(), Success: false}, http.StatusInternalServerError)
 			return
 		}
 
-		err = p.SetFont("opensans", "", 11)
+		p, err := newTemplatePdf(definition)
 		if err != nil {
 			WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
 			return
 		}
 
-		p, UUID, err := server.GenerateNewUserCert(p, id)
+		p, UUID, err := server.GenerateNewUserCert(p, definition, id)
 		if err != nil {
 			WriteJSON(w, Response{Data: "Failed at generating PDF", Error: err.Error(), Success: false}, http.StatusInternalServerError)
 			return
@@ -988,9 +809,10 @@ func (server *httpImpl) ResetPassword(w http.ResponseWriter, r *http.Request) {
 			ExportedBy:   user.ID,
 			DocumentType: RESETIRANJE_GESLA,
 			IsSigned:     true,
+			IssuedFor:    &id,
 		}
 
-		err = server.db.InsertDocument(document)
+		err = server.insertSignedDocument(document)
 		if err != nil {
 			WriteJSON(w, Response{Data: "Failed while inserting document into the database", Error: err.Error(), Success: false}, http.StatusInternalServerError)
 			return
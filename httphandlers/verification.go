@@ -0,0 +1,130 @@
+package httphandlers
+
+import (
+	"github.com/gorilla/mux"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Največje število preverjanj dokumentov z enega naslova IP v eni minuti
+const VERIFY_RATE_LIMIT = 20
+
+type DocumentVerification struct {
+	ID           string
+	DocumentType int
+	DocumentName string
+	IssuedAt     string
+	School       string
+	IsRevoked    bool
+	RevokedAt    *string
+	// Dokument, ki je nadomestil preklicanega (prazno, če ga ni)
+	SupersededBy string
+	SHA256       string
+}
+
+// rateLimiter omejuje število zahtev na ključ (naslov IP) v fiksnem časovnem oknu.
+type rateLimiter struct {
+	mutex    sync.Mutex
+	limit    int
+	window   time.Duration
+	start    time.Time
+	requests map[string]int
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{limit: limit, window: window, start: time.Now(), requests: make(map[string]int)}
+}
+
+func (limiter *rateLimiter) Allow(key string) bool {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	if time.Since(limiter.start) > limiter.window {
+		limiter.start = time.Now()
+		limiter.requests = make(map[string]int)
+	}
+	limiter.requests[key]++
+	return limiter.requests[key] <= limiter.limit
+}
+
+var verificationLimiter = newRateLimiter(VERIFY_RATE_LIMIT, time.Minute)
+
+// isTrustedProxy pove, ali je naslov med zaupanja vrednimi posredniki (naslovi IP ali omrežja CIDR).
+func isTrustedProxy(ip net.IP, proxies []string) bool {
+	if ip == nil {
+		return false
+	}
+	for i := 0; i < len(proxies); i++ {
+		if strings.Contains(proxies[i], "/") {
+			_, network, err := net.ParseCIDR(proxies[i])
+			if err == nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if ip.Equal(net.ParseIP(proxies[i])) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP vrne naslov odjemalca. Glavo RealIPHeader lahko odjemalec poljubno nastavi, zato se upošteva samo, če
+// zahteva pride od zaupanja vrednega posrednika. Pri verigi naslovov (X-Forwarded-For) se vzame zadnji naslov, ki ni
+// zaupanja vreden posrednik.
+func (server *httpImpl) clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if server.config.RealIPHeader == "" || !isTrustedProxy(net.ParseIP(ip), server.config.TrustedProxies) {
+		return ip
+	}
+	addresses := strings.Split(r.Header.Get(server.config.RealIPHeader), ",")
+	for i := len(addresses) - 1; i >= 0; i-- {
+		address := net.ParseIP(strings.TrimSpace(addresses[i]))
+		if address == nil {
+			break
+		}
+		if !isTrustedProxy(address, server.config.TrustedProxies) {
+			return address.String()
+		}
+	}
+	return ip
+}
+
+// VerifyDocument je javna točka, s katero lahko tretja oseba (npr. druga šola ali delodajalec) preveri, ali je
+// dokument s podanim identifikatorjem izdala ta šola. Vrne tudi SHA-256 podpisanega PDF-ja, da lahko imetnik
+// preveri, ali je njegova kopija enaka izdani.
+func (server *httpImpl) VerifyDocument(w http.ResponseWriter, r *http.Request) {
+	if !verificationLimiter.Allow(server.clientIP(r)) {
+		WriteJSON(w, Response{Data: "Too many requests", Success: false}, http.StatusTooManyRequests)
+		return
+	}
+
+	document, err := server.db.GetDocument(mux.Vars(r)["id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Document not found", Success: false}, http.StatusNotFound)
+		return
+	}
+	supersededBy := ""
+	if document.IsRevoked {
+		superseding, err := server.db.GetSupersedingDocument(document.ID)
+		if err == nil {
+			supersededBy = superseding.ID
+		}
+	}
+	WriteJSON(w, Response{Data: DocumentVerification{
+		ID:           document.ID,
+		DocumentType: document.DocumentType,
+		DocumentName: documentTypeNames[document.DocumentType],
+		IssuedAt:     document.Timestamp,
+		School:       server.config.SchoolName,
+		IsRevoked:    document.IsRevoked,
+		RevokedAt:    document.RevokedAt,
+		SupersededBy: supersededBy,
+		SHA256:       document.SHA256,
+	}, Success: true}, http.StatusOK)
+}
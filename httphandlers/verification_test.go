@@ -0,0 +1,47 @@
+package httphandlers
+
+import (
+	"github.com/MeetPlan/MeetPlanBackend/sql"
+	"net/http"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies := []string{"10.0.0.1", "192.168.0.0/24"}
+	tests := []struct {
+		name       string
+		header     string
+		proxies    []string
+		remoteAddr string
+		value      string
+		ip         string
+	}{
+		{"no proxy configured", "", nil, "203.0.113.5:5000", "", "203.0.113.5"},
+		{"header ignored without configuration", "", nil, "203.0.113.5:5000", "198.51.100.7", "203.0.113.5"},
+		{"header from untrusted address", "X-Real-IP", proxies, "203.0.113.5:5000", "198.51.100.7", "203.0.113.5"},
+		{"real ip from trusted proxy", "X-Real-IP", proxies, "10.0.0.1:5000", "198.51.100.7", "198.51.100.7"},
+		{"trusted proxy network", "X-Real-IP", proxies, "192.168.0.20:5000", "198.51.100.7", "198.51.100.7"},
+		{"spoofed forwarded chain", "X-Forwarded-For", proxies, "10.0.0.1:5000", "1.2.3.4, 198.51.100.7", "198.51.100.7"},
+		{"trusted proxies in chain", "X-Forwarded-For", proxies, "10.0.0.1:5000", "198.51.100.7, 192.168.0.3", "198.51.100.7"},
+		{"invalid header", "X-Real-IP", proxies, "10.0.0.1:5000", "unknown", "10.0.0.1"},
+		{"missing header", "X-Real-IP", proxies, "10.0.0.1:5000", "", "10.0.0.1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := &httpImpl{config: sql.Config{RealIPHeader: test.header, TrustedProxies: test.proxies}}
+			r, err := http.NewRequest("GET", "/verify/id", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.RemoteAddr = test.remoteAddr
+			if test.value != "" {
+				r.Header.Set("X-Real-IP", test.value)
+				r.Header.Set("X-Forwarded-For", test.value)
+			}
+			ip := server.clientIP(r)
+			if ip != test.ip {
+				t.Fatalf("got %s, expected %s", ip, test.ip)
+			}
+		})
+	}
+}
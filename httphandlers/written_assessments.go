@@ -0,0 +1,358 @@
+package httphandlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/MeetPlan/MeetPlanBackend/helpers"
+	"github.com/MeetPlan/MeetPlanBackend/sql"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const WRITTEN_ASSESSMENT_CONFLICT_DAY = "day"
+const WRITTEN_ASSESSMENT_CONFLICT_WEEK = "week"
+const WRITTEN_ASSESSMENT_CONFLICT_NOTICE = "notice"
+
+// WrittenAssessmentConflict opisuje kršitev omejitev pisnih ocenjevanj: preveč pisnih ocenjevanj razreda na dan
+// ali v tednu oziroma prepozno napovedano pisno ocenjevanje (takrat razred ni podan).
+type WrittenAssessmentConflict struct {
+	Type        string
+	ClassID     string
+	ClassName   string
+	Date        string
+	Assessments []sql.WrittenAssessment
+}
+
+type TestCalendarEntry struct {
+	sql.WrittenAssessment
+	SubjectName string
+	// Razlog, s katerim je vodstvo dovolilo ocenjevanje kljub omejitvam (viden samo zaposlenim)
+	OverrideReason string
+}
+
+// weekBounds vrne ponedeljek in nedeljo tedna, v katerem je podan dan.
+func weekBounds(date time.Time) (time.Time, time.Time) {
+	monday := date.AddDate(0, 0, -((int(date.Weekday()) + 6) % 7))
+	return monday, monday.AddDate(0, 0, 6)
+}
+
+// getClassesOfStudents vrne razrede, v katere je vpisan vsaj eden od podanih učencev.
+func (server *httpImpl) getClassesOfStudents(students []string) ([]sql.Class, error) {
+	classes, err := server.db.GetClasses()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]sql.Class, 0)
+	for i := 0; i < len(classes); i++ {
+		var classStudents []string
+		err := json.Unmarshal([]byte(classes[i].Students), &classStudents)
+		if err != nil {
+			return nil, err
+		}
+		for n := 0; n < len(classStudents); n++ {
+			if helpers.Contains(students, classStudents[n]) {
+				result = append(result, classes[i])
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// filterClassAssessments vrne ocenjevanja predmetov, ki jih obiskuje vsaj en učenec razreda.
+// subjectStudents hrani že prebrane učence predmetov.
+func (server *httpImpl) filterClassAssessments(assessments []sql.WrittenAssessment, classStudents []string, subjectStudents map[string][]string) ([]sql.WrittenAssessment, error) {
+	result := make([]sql.WrittenAssessment, 0)
+	for i := 0; i < len(assessments); i++ {
+		students, exists := subjectStudents[assessments[i].SubjectID]
+		if !exists {
+			subject, err := server.db.GetSubject(assessments[i].SubjectID)
+			if err != nil {
+				return nil, err
+			}
+			students, err = server.getSubjectStudents(subject)
+			if err != nil {
+				return nil, err
+			}
+			subjectStudents[subject.ID] = students
+		}
+		for n := 0; n < len(students); n++ {
+			if helpers.Contains(classStudents, students[n]) {
+				result = append(result, assessments[i])
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// countsAsWrittenAssessment pove, ali srečanje šteje v omejitve pisnih ocenjevanj. Štejejo tako pisna ocenjevanja
+// kot preverjanja znanja, enako kot v koledarju preizkusov.
+func countsAsWrittenAssessment(isWrittenAssessment bool, isTest bool) bool {
+	return isWrittenAssessment || isTest
+}
+
+// findWrittenAssessmentConflicts preveri, ali nova pisna ocenjevanja predmeta na podane dneve kršijo omejitve iz
+// konfiguracije. Omejitve na dan in teden veljajo za vsak razred, katerega učenci obiskujejo predmet.
+// Ocenjevanja z ID-ji iz ignore se ne štejejo (npr. srečanja, ki jih urejamo in so med podanimi dnevi).
+func (server *httpImpl) findWrittenAssessmentConflicts(subject sql.Subject, dates []time.Time, ignore []string) ([]WrittenAssessmentConflict, error) {
+	conflicts := make([]WrittenAssessmentConflict, 0)
+
+	if server.config.WrittenAssessmentNoticeDays > 0 {
+		now := time.Now()
+		earliest := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, server.config.WrittenAssessmentNoticeDays)
+		for i := 0; i < len(dates); i++ {
+			if dates[i].Before(earliest) {
+				conflicts = append(conflicts, WrittenAssessmentConflict{
+					Type:        WRITTEN_ASSESSMENT_CONFLICT_NOTICE,
+					Date:        dates[i].Format("02-01-2006"),
+					Assessments: make([]sql.WrittenAssessment, 0),
+				})
+			}
+		}
+	}
+
+	maxDay := server.config.MaxWrittenAssessmentsPerDay
+	maxWeek := server.config.MaxWrittenAssessmentsPerWeek
+	if maxDay == 0 && maxWeek == 0 {
+		return conflicts, nil
+	}
+
+	students, err := server.getSubjectStudents(subject)
+	if err != nil {
+		return nil, err
+	}
+	classes, err := server.getClassesOfStudents(students)
+	if err != nil {
+		return nil, err
+	}
+
+	weeks := make(map[string][]sql.WrittenAssessment)
+	subjectStudents := make(map[string][]string)
+	for i := 0; i < len(classes); i++ {
+		var classStudents []string
+		err := json.Unmarshal([]byte(classes[i].Students), &classStudents)
+		if err != nil {
+			return nil, err
+		}
+		reported := make(map[string]bool)
+		for n := 0; n < len(dates); n++ {
+			date := dates[n].Format("02-01-2006")
+			monday, sunday := weekBounds(dates[n])
+			weekKey := monday.Format("02-01-2006")
+
+			assessments, exists := weeks[weekKey]
+			if !exists {
+				assessments, err = server.db.GetWrittenAssessmentsBetween(weekKey, sunday.Format("02-01-2006"))
+				if err != nil {
+					return nil, err
+				}
+				weeks[weekKey] = assessments
+			}
+			classAssessments, err := server.filterClassAssessments(assessments, classStudents, subjectStudents)
+			if err != nil {
+				return nil, err
+			}
+
+			week := make([]sql.WrittenAssessment, 0)
+			day := make([]sql.WrittenAssessment, 0)
+			for x := 0; x < len(classAssessments); x++ {
+				if !countsAsWrittenAssessment(classAssessments[x].IsWrittenAssessment, classAssessments[x].IsTest) {
+					continue
+				}
+				if helpers.Contains(ignore, classAssessments[x].ID) {
+					continue
+				}
+				week = append(week, classAssessments[x])
+				if classAssessments[x].Date == date {
+					day = append(day, classAssessments[x])
+				}
+			}
+			// upoštevamo tudi ostala nova ocenjevanja iz iste zahteve (npr. ponavljajoča se srečanja)
+			var newDay, newWeek = 0, 0
+			for x := 0; x < len(dates); x++ {
+				if dates[x].Equal(dates[n]) {
+					newDay++
+				}
+				if !dates[x].Before(monday) && !dates[x].After(sunday) {
+					newWeek++
+				}
+			}
+
+			if maxDay > 0 && len(day)+newDay > maxDay && !reported["day"+date] {
+				reported["day"+date] = true
+				conflicts = append(conflicts, WrittenAssessmentConflict{
+					Type:        WRITTEN_ASSESSMENT_CONFLICT_DAY,
+					ClassID:     classes[i].ID,
+					ClassName:   classes[i].Name,
+					Date:        date,
+					Assessments: day,
+				})
+			}
+			if maxWeek > 0 && len(week)+newWeek > maxWeek && !reported["week"+weekKey] {
+				reported["week"+weekKey] = true
+				conflicts = append(conflicts, WrittenAssessmentConflict{
+					Type:        WRITTEN_ASSESSMENT_CONFLICT_WEEK,
+					ClassID:     classes[i].ID,
+					ClassName:   classes[i].Name,
+					Date:        weekKey,
+					Assessments: week,
+				})
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+// checkWrittenAssessmentLimits preveri omejitve pisnih ocenjevanj. Vodstvo šole lahko omejitve preglasi z razlogom
+// (override_reason), ki ga funkcija vrne, da ga klicatelj shrani. Če ocenjevanja ni mogoče dodati, zapiše odgovor
+// s seznamom kršitev in vrne false.
+func (server *httpImpl) checkWrittenAssessmentLimits(w http.ResponseWriter, r *http.Request, user sql.User, subject sql.Subject, dates []time.Time, ignore []string) (string, bool) {
+	conflicts, err := server.findWrittenAssessmentConflicts(subject, dates, ignore)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while checking written assessment limits", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return "", false
+	}
+	if len(conflicts) == 0 {
+		return "", true
+	}
+	reason := strings.TrimSpace(r.FormValue("override_reason"))
+	if reason != "" && (user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT) {
+		return reason, true
+	}
+	WriteJSON(w, Response{Data: conflicts, Error: "The written assessment exceeds the allowed limits", Success: false}, http.StatusConflict)
+	return "", false
+}
+
+func (server *httpImpl) recordWrittenAssessmentOverrides(user sql.User, subject sql.Subject, dates []time.Time, hour int, reason string) error {
+	for i := 0; i < len(dates); i++ {
+		err := server.db.InsertWrittenAssessmentOverride(sql.WrittenAssessmentOverride{
+			SubjectID: subject.ID,
+			Date:      dates[i].Format("02-01-2006"),
+			Hour:      hour,
+			Reason:    reason,
+			CreatedBy: user.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTestCalendar vrne napovedana pisna ocenjevanja in preverjanja znanja razreda med start in end.
+// Privzeto vrne ocenjevanja od danes do konca zadnjega ocenjevalnega obdobja.
+func (server *httpImpl) GetTestCalendar(w http.ResponseWriter, r *http.Request) {
+	user, err := server.db.CheckToken(GetAuthorizationToken(r))
+	if err != nil {
+		WriteForbiddenJWT(w)
+		return
+	}
+	class, err := server.db.GetClass(mux.Vars(r)["class_id"])
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving the class", Error: err.Error(), Success: false}, http.StatusNotFound)
+		return
+	}
+	var classStudents []string
+	err = json.Unmarshal([]byte(class.Students), &classStudents)
+	if err != nil {
+		WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+
+	isStaff := user.Role == ADMIN || user.Role == PRINCIPAL || user.Role == PRINCIPAL_ASSISTANT || user.Role == TEACHER || user.Role == SCHOOL_PSYCHOLOGIST
+	if user.Role == STUDENT && !helpers.Contains(classStudents, user.ID) {
+		WriteForbiddenJWT(w)
+		return
+	} else if user.Role == PARENT {
+		var children []string
+		err = json.Unmarshal([]byte(user.Users), &children)
+		if err != nil {
+			WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		var isParent = false
+		for i := 0; i < len(children); i++ {
+			if helpers.Contains(classStudents, children[i]) {
+				isParent = true
+				break
+			}
+		}
+		if !isParent {
+			WriteForbiddenJWT(w)
+			return
+		}
+	} else if !isStaff && user.Role != STUDENT {
+		WriteForbiddenJWT(w)
+		return
+	}
+
+	start := r.URL.Query().Get("start")
+	end := r.URL.Query().Get("end")
+	if start == "" {
+		start = time.Now().Format("02-01-2006")
+	}
+	if end == "" {
+		periods, err := server.getGradingPeriods(time.Now())
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while retrieving grading periods", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		end = periods[len(periods)-1].EndDate
+	}
+	_, err = time.Parse("02-01-2006", start)
+	if err != nil {
+		WriteBadRequest(w)
+		return
+	}
+	_, err = time.Parse("02-01-2006", end)
+	if err != nil {
+		WriteBadRequest(w)
+		return
+	}
+
+	assessments, err := server.db.GetWrittenAssessmentsBetween(start, end)
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving written assessments", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	assessments, err = server.filterClassAssessments(assessments, classStudents, make(map[string][]string))
+	if err != nil {
+		WriteJSON(w, Response{Data: "Failed while retrieving written assessments", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+		return
+	}
+	reasons := make(map[string]string)
+	if isStaff {
+		overrides, err := server.db.GetWrittenAssessmentOverridesBetween(start, end)
+		if err != nil {
+			WriteJSON(w, Response{Data: "Failed while retrieving overrides", Error: err.Error(), Success: false}, http.StatusInternalServerError)
+			return
+		}
+		for i := 0; i < len(overrides); i++ {
+			reasons[fmt.Sprintf("%s/%s/%d", overrides[i].SubjectID, overrides[i].Date, overrides[i].Hour)] = overrides[i].Reason
+		}
+	}
+
+	subjectNames := make(map[string]string)
+	calendar := make([]TestCalendarEntry, 0)
+	for i := 0; i < len(assessments); i++ {
+		name, exists := subjectNames[assessments[i].SubjectID]
+		if !exists {
+			subject, err := server.db.GetSubject(assessments[i].SubjectID)
+			if err != nil {
+				WriteJSON(w, Response{Error: err.Error(), Success: false}, http.StatusInternalServerError)
+				return
+			}
+			name = subject.Name
+			subjectNames[subject.ID] = name
+		}
+		calendar = append(calendar, TestCalendarEntry{
+			WrittenAssessment: assessments[i],
+			SubjectName:       name,
+			OverrideReason:    reasons[fmt.Sprintf("%s/%s/%d", assessments[i].SubjectID, assessments[i].Date, assessments[i].Hour)],
+		})
+	}
+	WriteJSON(w, Response{Data: calendar, Success: true}, http.StatusOK)
+}
@@ -50,6 +50,22 @@ func main() {
 		os.Mkdir("documents", os.ModePerm)
 	}
 
+	if _, err := os.Stat("documents/batches"); os.IsNotExist(err) {
+		os.Mkdir("documents/batches", os.ModePerm)
+	}
+
+	if _, err := os.Stat("documents/archive"); os.IsNotExist(err) {
+		os.Mkdir("documents/archive", os.ModePerm)
+	}
+
+	if _, err := os.Stat("excuses"); os.IsNotExist(err) {
+		os.Mkdir("excuses", os.ModePerm)
+	}
+
+	if _, err := os.Stat("templates"); os.IsNotExist(err) {
+		os.Mkdir("templates", os.ModePerm)
+	}
+
 	db, err := sql.NewSQL(config.DatabaseName, config.DatabaseConfig, sugared)
 	db.Init()
 
@@ -159,6 +175,9 @@ func main() {
 
 	sugared.Info("Database created successfully")
 
+	httphandler.FailInterruptedDocumentBatches()
+	go httphandler.RunDocumentRetention()
+
 	r := mux.NewRouter()
 	r.HandleFunc("/user/new", httphandler.NewUser).Methods("POST")
 	r.HandleFunc("/user/login", httphandler.Login).Methods("POST")
@@ -177,6 +196,22 @@ func main() {
 	r.HandleFunc("/user/get/unread_messages", httphandler.GetUnreadMessages).Methods("GET")
 
 	r.HandleFunc("/user/get/absences/{student_id}/excuse/{absence_id}", httphandler.ExcuseAbsence).Methods("PATCH")
+	r.HandleFunc("/user/get/absences/{student_id}/stats", httphandler.GetAbsenceStatsForStudent).Methods("GET")
+	r.HandleFunc("/user/get/absences/{student_id}/alerts", httphandler.GetAbsenceAlertsForStudent).Methods("GET")
+	r.HandleFunc("/class/get/{class_id}/absences/stats", httphandler.GetAbsenceStatsForClass).Methods("GET")
+	r.HandleFunc("/absences/planned", httphandler.GetPlannedAbsences).Methods("GET")
+	r.HandleFunc("/absences/planned/new", httphandler.NewPlannedAbsence).Methods("POST")
+	r.HandleFunc("/absences/planned/{planned_absence_id}", httphandler.DeletePlannedAbsence).Methods("DELETE")
+
+	r.HandleFunc("/lesson_logs/missing", httphandler.GetMissingLessonLogs).Methods("GET")
+	r.HandleFunc("/excuses/pending", httphandler.GetPendingExcuses).Methods("GET")
+	r.HandleFunc("/excuses/{student_id}", httphandler.GetExcusesForStudent).Methods("GET")
+	r.HandleFunc("/excuse/new", httphandler.NewExcuse).Methods("POST")
+	r.HandleFunc("/excuse/{excuse_id}", httphandler.GetExcuse).Methods("GET")
+	r.HandleFunc("/excuse/{excuse_id}/document", httphandler.GetExcuseDocument).Methods("GET")
+	r.HandleFunc("/excuse/{excuse_id}/approve", httphandler.ApproveExcuse).Methods("PATCH")
+	r.HandleFunc("/excuse/{excuse_id}/reject", httphandler.RejectExcuse).Methods("PATCH")
+	r.HandleFunc("/excuse/{excuse_id}/withdraw", httphandler.WithdrawExcuse).Methods("PATCH")
 
 	r.HandleFunc("/class/get/{class_id}/self_testing", httphandler.GetSelfTestingTeacher).Methods("GET")
 	r.HandleFunc("/user/self_testing/patch/{class_id}/{student_id}", httphandler.PatchSelfTesting).Methods("PATCH")
@@ -191,6 +226,7 @@ func main() {
 	r.HandleFunc("/classes/get", httphandler.GetClasses).Methods("GET")
 	r.HandleFunc("/class/get/{class_id}/add_user/{user_id}", httphandler.AssignUserToClass).Methods("PATCH")
 	r.HandleFunc("/class/get/{class_id}/remove_user/{user_id}", httphandler.RemoveUserFromClass).Methods("DELETE")
+	r.HandleFunc("/class/get/{class_id}/grading_scale", httphandler.PatchClassGradingScale).Methods("PATCH")
 
 	r.HandleFunc("/users/get", httphandler.GetAllUsers).Methods("GET")
 	r.HandleFunc("/meals/get", httphandler.GetMeals).Methods("GET")
@@ -237,6 +273,11 @@ func main() {
 	r.HandleFunc("/meeting/get/{meeting_id}/gradings", httphandler.GetGradingsTeacher).Methods("GET")
 	r.HandleFunc("/meeting/get/{meeting_id}/gradings", httphandler.NewGrading).Methods("POST")
 	r.HandleFunc("/meeting/get/{meeting_id}/absences", httphandler.GetAbsencesTeacher).Methods("GET")
+	r.HandleFunc("/meeting/get/{meeting_id}/absences", httphandler.PutAbsencesForMeeting).Methods("PUT")
+	r.HandleFunc("/meeting/get/{meeting_id}/log", httphandler.GetLessonLog).Methods("GET")
+	r.HandleFunc("/meeting/get/{meeting_id}/log", httphandler.PostLessonLog).Methods("POST")
+	r.HandleFunc("/meeting/get/{meeting_id}/log/sign", httphandler.SignLessonLog).Methods("PATCH")
+	r.HandleFunc("/meeting/get/{meeting_id}/log/sign", httphandler.UnsignLessonLog).Methods("DELETE")
 	r.HandleFunc("/meeting/get/{meeting_id}/users", httphandler.GetUsersForMeeting).Methods("GET")
 	r.HandleFunc("/meeting/get/{meeting_id}/grades", httphandler.GetGradesForMeeting).Methods("GET")
 	r.HandleFunc("/meeting/get/{meeting_id}/homework/{homework_id}/{student_id}", httphandler.PatchHomeworkForStudent).Methods("PATCH")
@@ -251,6 +292,9 @@ func main() {
 
 	r.HandleFunc("/grading_term/{grading_term_id}", httphandler.PatchGradingTerm).Methods("PATCH")
 	r.HandleFunc("/grading_term/{grading_term_id}", httphandler.DeleteGradingTerm).Methods("DELETE")
+	r.HandleFunc("/grading_term/{grading_term_id}/stats", httphandler.GetGradingTermStats).Methods("GET")
+	r.HandleFunc("/grades/stats", httphandler.GetSubjectComparison).Methods("GET")
+	r.HandleFunc("/grades/at_risk", httphandler.GetStudentsAtRisk).Methods("GET")
 
 	r.HandleFunc("/meeting/absence/{absence_id}", httphandler.PatchAbsence).Methods("PATCH")
 
@@ -267,6 +311,23 @@ func main() {
 	r.HandleFunc("/subject/get/{subject_id}", httphandler.PatchSubjectName).Methods("PATCH")
 	r.HandleFunc("/subject/get/{subject_id}/add_user/{user_id}", httphandler.AssignUserToSubject).Methods("PATCH")
 	r.HandleFunc("/subject/get/{subject_id}/remove_user/{user_id}", httphandler.RemoveUserFromSubject).Methods("DELETE")
+	r.HandleFunc("/subject/get/{subject_id}/register", httphandler.GetSubjectRegister).Methods("GET")
+	r.HandleFunc("/subject/get/{subject_id}/grading_scale", httphandler.PatchSubjectGradingScale).Methods("PATCH")
+	r.HandleFunc("/subject/get/{subject_id}/grading_policy", httphandler.GetGradingPolicy).Methods("GET")
+	r.HandleFunc("/subject/get/{subject_id}/grading_policy", httphandler.PatchGradingPolicy).Methods("PATCH")
+	r.HandleFunc("/grading_periods", httphandler.GetGradingPeriods).Methods("GET")
+	r.HandleFunc("/subject/get/{subject_id}/final_grades", httphandler.GetFinalGradeProposals).Methods("GET")
+	r.HandleFunc("/subject/get/{subject_id}/final_grades/{student_id}", httphandler.PatchFinalGradeProposal).Methods("PATCH")
+	r.HandleFunc("/subject/get/{subject_id}/final_grades/{student_id}", httphandler.DeleteFinalGradeProposal).Methods("DELETE")
+	r.HandleFunc("/class/get/{class_id}/conference", httphandler.GetClassConference).Methods("GET")
+	r.HandleFunc("/class/get/{class_id}/conference", httphandler.ConfirmClassConference).Methods("POST")
+	r.HandleFunc("/class/get/{class_id}/conference/minutes", httphandler.GetClassConferenceMinutes).Methods("GET")
+	r.HandleFunc("/class/get/{class_id}/test_calendar", httphandler.GetTestCalendar).Methods("GET")
+
+	r.HandleFunc("/grading_scales", httphandler.GetGradingScales).Methods("GET")
+	r.HandleFunc("/grading_scales/new", httphandler.NewGradingScale).Methods("POST")
+	r.HandleFunc("/grading_scale/{grading_scale_id}", httphandler.PatchGradingScale).Methods("PATCH")
+	r.HandleFunc("/grading_scale/{grading_scale_id}", httphandler.DeleteGradingScale).Methods("DELETE")
 
 	r.HandleFunc("/admin/config/get", httphandler.GetConfig).Methods("GET")
 	r.HandleFunc("/admin/config/get", httphandler.UpdateConfiguration).Methods("PATCH")
@@ -278,13 +339,52 @@ func main() {
 	r.HandleFunc("/proton/rule/new", httphandler.NewProtonRule).Methods("POST")
 	r.HandleFunc("/proton/rules/get", httphandler.GetProtonRules).Methods("GET")
 	r.HandleFunc("/proton/rule/get", httphandler.DeleteProtonRule).Methods("DELETE")
+	r.HandleFunc("/proton/rules/versions", httphandler.GetProtonConfigVersions).Methods("GET")
+	r.HandleFunc("/proton/rules/versions/{version}/restore", httphandler.RestoreProtonConfigVersion).Methods("POST")
 
 	r.HandleFunc("/proton/assemble/timetable", httphandler.AssembleTimetable).Methods("GET")
+	r.HandleFunc("/proton/assemble/timetable/partial", httphandler.AssemblePartialTimetable).Methods("POST")
 	r.HandleFunc("/proton/accept/timetable", httphandler.AcceptAssembledTimetable).Methods("POST")
 	r.HandleFunc("/proton/timetable/manual_postprocessing", httphandler.ManualPostProcessRepeat).Methods("POST")
+	r.HandleFunc("/proton/import/timetable", httphandler.ImportTimetable).Methods("POST")
+	r.HandleFunc("/proton/export/timetable", httphandler.ExportAssembledTimetable).Methods("POST")
+	r.HandleFunc("/timetable/export", httphandler.ExportCurrentTimetable).Methods("GET")
+	r.HandleFunc("/timetable/versions", httphandler.GetTimetableVersions).Methods("GET")
+	r.HandleFunc("/timetable/versions/diff", httphandler.DiffTimetableVersions).Methods("GET")
+	r.HandleFunc("/timetable/versions/{version}/publish", httphandler.PublishTimetableVersion).Methods("POST")
+	r.HandleFunc("/timetable/versions/{version}/rollback", httphandler.RollbackTimetableVersion).Methods("POST")
+	r.HandleFunc("/timetable/versions/{version}", httphandler.DeleteTimetableVersion).Methods("DELETE")
+
+	r.HandleFunc("/calendar", httphandler.GetCalendar).Methods("GET")
+	r.HandleFunc("/calendar/event/new", httphandler.NewCalendarEvent).Methods("POST")
+	r.HandleFunc("/calendar/event/{event_id}", httphandler.DeleteCalendarEvent).Methods("DELETE")
+	r.HandleFunc("/calendar/import", httphandler.ImportCalendar).Methods("POST")
+
+	r.HandleFunc("/teacher/absences", httphandler.GetTeacherAbsences).Methods("GET")
+	r.HandleFunc("/teacher/absence/new", httphandler.NewTeacherAbsence).Methods("POST")
+	r.HandleFunc("/teacher/absence/{absence_id}", httphandler.DeleteTeacherAbsence).Methods("DELETE")
+	r.HandleFunc("/teacher/absence/{absence_id}/substitutions/plan", httphandler.PlanTeacherAbsenceSubstitutions).Methods("GET")
+	r.HandleFunc("/teacher/absence/{absence_id}/substitutions/apply", httphandler.ApplyTeacherAbsenceSubstitutions).Methods("POST")
+	r.HandleFunc("/teacher/absence/{absence_id}/substitutions/revert", httphandler.RevertTeacherAbsenceSubstitutions).Methods("POST")
+	r.HandleFunc("/substitutions/stats", httphandler.GetSubstitutionStats).Methods("GET")
+	r.HandleFunc("/substitutions/report", httphandler.GetSubstitutionReport).Methods("GET")
+	r.HandleFunc("/substitution/{substitution_id}", httphandler.PatchSubstitutionPaid).Methods("PATCH")
+	r.HandleFunc("/substitutions", httphandler.GetSubstitutionBoard).Methods("GET")
+	r.HandleFunc("/substitutions/pdf", httphandler.GetSubstitutionBoardPDF).Methods("GET")
+	r.HandleFunc("/substitutions/kiosk/token", httphandler.NewKioskToken).Methods("POST")
+	r.HandleFunc("/substitutions/kiosk/token", httphandler.DeleteKioskToken).Methods("DELETE")
 
 	r.HandleFunc("/documents/get", httphandler.FetchAllDocuments).Methods("GET")
-	r.HandleFunc("/documents/get", httphandler.DeleteDocument).Methods("DELETE")
+	r.HandleFunc("/documents/{document_id}/revoke", httphandler.RevokeDocument).Methods("PATCH")
+	r.HandleFunc("/documents/{document_id}/legal_hold", httphandler.SetDocumentLegalHold).Methods("PATCH")
+	r.HandleFunc("/verify/{id}", httphandler.VerifyDocument).Methods("GET")
+	r.HandleFunc("/class/get/{class_id}/documents/batch", httphandler.NewDocumentBatch).Methods("POST")
+	r.HandleFunc("/documents/batch/{batch_id}", httphandler.GetDocumentBatch).Methods("GET")
+	r.HandleFunc("/documents/batch/{batch_id}/{format}", httphandler.GetDocumentBatchFile).Methods("GET")
+	r.HandleFunc("/document_templates", httphandler.GetDocumentTemplates).Methods("GET")
+	r.HandleFunc("/document_templates/{name}", httphandler.GetDocumentTemplate).Methods("GET")
+	r.HandleFunc("/document_templates/{name}", httphandler.NewDocumentTemplateVersion).Methods("POST")
+	r.HandleFunc("/document_templates/{name}/active", httphandler.ActivateDocumentTemplate).Methods("PATCH")
 
 	o := cors.Options{
 		AllowedMethods:   []string{"POST", "GET", "DELETE", "PATCH", "PUT"},
@@ -0,0 +1,2 @@
+ALTER TABLE meetings ADD COLUMN IF NOT EXISTS attendance_taken_at TIMESTAMP;
+UPDATE meetings SET attendance_taken_at=updated_at WHERE attendance_taken_at IS NULL AND EXISTS (SELECT 1 FROM absence WHERE absence.meeting_id=meetings.id);
@@ -0,0 +1 @@
+ALTER TABLE calendar_events ADD COLUMN IF NOT EXISTS closing_date VARCHAR(20) DEFAULT null;
@@ -0,0 +1,7 @@
+ALTER TABLE documents ADD COLUMN revoked_at TIMESTAMP;
+ALTER TABLE documents ADD COLUMN revoked_by UUID;
+ALTER TABLE documents ADD COLUMN revocation_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE documents ADD COLUMN supersedes VARCHAR(50);
+ALTER TABLE documents ADD COLUMN legal_hold BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE documents ADD CONSTRAINT FK_DocumentsRevokedBy FOREIGN KEY (revoked_by) REFERENCES users(id);
+ALTER TABLE documents ADD CONSTRAINT FK_DocumentsSupersedes FOREIGN KEY (supersedes) REFERENCES documents(id) ON DELETE SET NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE documents ADD COLUMN sha256 VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE documents ADD COLUMN is_revoked BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1 @@
+ALTER TABLE absence ADD COLUMN excuse_id UUID;
@@ -0,0 +1,4 @@
+ALTER TABLE gradings ADD COLUMN weight FLOAT NOT NULL DEFAULT 1;
+ALTER TABLE grades ADD COLUMN category INTEGER NOT NULL DEFAULT 0;
+UPDATE grades SET category=1 WHERE is_written=true;
+UPDATE grades SET category=gradings.grading_type FROM grading_terms, gradings WHERE grades.term_id=grading_terms.id AND grading_terms.grading_id=gradings.id;
@@ -0,0 +1,2 @@
+ALTER TABLE subject ADD COLUMN grading_scale_id UUID DEFAULT null;
+ALTER TABLE classes ADD COLUMN grading_scale_id UUID DEFAULT null;
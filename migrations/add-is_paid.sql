@@ -0,0 +1,2 @@
+ALTER TABLE substitutions ADD COLUMN IF NOT EXISTS is_paid BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE substitutions ALTER COLUMN absence_id DROP NOT NULL;
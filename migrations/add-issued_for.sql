@@ -0,0 +1,3 @@
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS issued_for UUID;
+ALTER TABLE documents DROP CONSTRAINT IF EXISTS FK_DocumentsIssuedFor;
+ALTER TABLE documents ADD CONSTRAINT FK_DocumentsIssuedFor FOREIGN KEY (issued_for) REFERENCES users(id) ON DELETE SET NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE grades ADD COLUMN points FLOAT DEFAULT null;
+ALTER TABLE grades ADD COLUMN assessment VARCHAR(1000) NOT NULL DEFAULT '';
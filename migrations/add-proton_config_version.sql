@@ -0,0 +1 @@
+ALTER TABLE meetings ADD COLUMN proton_config_version INTEGER DEFAULT null;
@@ -0,0 +1 @@
+ALTER TABLE meetings ADD COLUMN series_id UUID DEFAULT null;
@@ -0,0 +1 @@
+ALTER TABLE meetings ADD COLUMN timetable_version INTEGER DEFAULT null;
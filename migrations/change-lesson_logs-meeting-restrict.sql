@@ -0,0 +1,2 @@
+ALTER TABLE lesson_logs DROP CONSTRAINT IF EXISTS FK_LessonLogsMeeting;
+ALTER TABLE lesson_logs ADD CONSTRAINT FK_LessonLogsMeeting FOREIGN KEY (meeting_id) REFERENCES meetings(id) ON DELETE RESTRICT;
@@ -0,0 +1,2 @@
+-- Prisotnost se ne shranjuje več, zato izbrišemo vrstice, ki jih je ustvarilo odpiranje seznama učencev.
+DELETE FROM absence WHERE absence_type='UNMANAGED' OR absence_type='PRESENT' OR absence_type IS NULL;
@@ -0,0 +1,309 @@
+/// This file is a part of MeetPlan Proton, which is a part of MeetPlanBackend (https://github.com/MeetPlan/MeetPlanBackend).
+///
+/// Copyright (c) 2022, Mitja Ševerkar <mytja@protonmail.com> and The MeetPlan Team.
+/// All rights reserved.
+/// Use of this source code is governed by the GNU AGPLv3 license, that can be found in the LICENSE file.
+
+package proton
+
+import (
+	"errors"
+	"fmt"
+	"github.com/MeetPlan/MeetPlanBackend/helpers"
+	"github.com/MeetPlan/MeetPlanBackend/sql"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Šolski koledar. Proton ga uporablja pri razširjanju dvotedenskega urnika v dejanska srečanja,
+// GetTimetable pa pri prikazu urnika.
+
+type calendarEvent struct {
+	sql.CalendarEvent
+	start time.Time
+	end   time.Time
+}
+
+type SchoolCalendar struct {
+	events      []calendarEvent
+	freeDays    []string
+	firstMonday time.Time
+}
+
+func mondayOf(date time.Time) time.Time {
+	return date.AddDate(0, 0, -((int(date.Weekday()) + 6) % 7))
+}
+
+func weeksBetween(from time.Time, to time.Time) int {
+	return int(math.Round(mondayOf(to).Sub(mondayOf(from)).Hours()/24)) / 7
+}
+
+// FirstMondayOfSchoolYear vrne ponedeljek v tednu 1. septembra šolskega leta, v katerem je podan datum.
+func FirstMondayOfSchoolYear(date time.Time) time.Time {
+	year := date.Year()
+	if date.Month() < time.September {
+		year--
+	}
+	firstSchoolDay := time.Date(year, time.September, 1, 0, 0, 0, 0, date.Location())
+	if firstSchoolDay.Weekday() == time.Saturday {
+		firstSchoolDay = firstSchoolDay.AddDate(0, 0, 2)
+	} else if firstSchoolDay.Weekday() == time.Sunday {
+		firstSchoolDay = firstSchoolDay.AddDate(0, 0, 1)
+	}
+	return mondayOf(firstSchoolDay)
+}
+
+// NewSchoolCalendar ustvari koledar iz dogodkov v podatkovni bazi in starega seznama prostih dni iz konfiguracije (oblika 2006-01-02).
+// firstMonday je ponedeljek prvega (A) tedna, ki se uporabi, dokler koledar ne vsebuje drugačne določitve A/B tednov.
+func NewSchoolCalendar(events []sql.CalendarEvent, schoolFreeDays []string, firstMonday time.Time) (*SchoolCalendar, error) {
+	calendar := SchoolCalendar{
+		events:      make([]calendarEvent, 0),
+		freeDays:    schoolFreeDays,
+		firstMonday: mondayOf(firstMonday),
+	}
+	for i := 0; i < len(events); i++ {
+		event := events[i]
+		start, err := time.Parse("02-01-2006", event.StartDate)
+		if err != nil {
+			return nil, err
+		}
+		end, err := time.Parse("02-01-2006", event.EndDate)
+		if err != nil {
+			return nil, err
+		}
+		if end.Before(start) {
+			return nil, errors.New(fmt.Sprintf("calendar event %s ends before it starts", event.ID))
+		}
+		calendar.events = append(calendar.events, calendarEvent{CalendarEvent: event, start: start, end: end})
+	}
+	return &calendar, nil
+}
+
+func sameDay(a time.Time, b time.Time) bool {
+	return a.Year() == b.Year() && a.Month() == b.Month() && a.Day() == b.Day()
+}
+
+func (event calendarEvent) contains(date time.Time) bool {
+	return (sameDay(event.start, date) || event.start.Before(date)) && (sameDay(event.end, date) || event.end.After(date))
+}
+
+// EventsOn vrne vse dogodke, ki veljajo na podan dan.
+func (calendar *SchoolCalendar) EventsOn(date time.Time) []sql.CalendarEvent {
+	events := make([]sql.CalendarEvent, 0)
+	for i := 0; i < len(calendar.events); i++ {
+		if calendar.events[i].contains(date) {
+			events = append(events, calendar.events[i].CalendarEvent)
+		}
+	}
+	return events
+}
+
+// IsSchoolFreeDay preveri, ali je dan praznik, del počitnic ali prost dan iz konfiguracije.
+func (calendar *SchoolCalendar) IsSchoolFreeDay(date time.Time) bool {
+	if helpers.Contains(calendar.freeDays, date.Format("2006-01-02")) {
+		return true
+	}
+	for i := 0; i < len(calendar.events); i++ {
+		event := calendar.events[i]
+		if (event.EventType == sql.CALENDAR_HOLIDAY || event.EventType == sql.CALENDAR_BREAK) && event.contains(date) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsClassFreeDay preveri, ali ima razred na podan dan izjemo (ekskurzija, športni dan ...) in nima rednega pouka.
+func (calendar *SchoolCalendar) IsClassFreeDay(date time.Time, classId string) bool {
+	for i := 0; i < len(calendar.events); i++ {
+		event := calendar.events[i]
+		if event.EventType == sql.CALENDAR_CLASS_EXCEPTION && event.ClassID != nil && *event.ClassID == classId && event.contains(date) {
+			return true
+		}
+	}
+	return false
+}
+
+// TimetableDay vrne, po urniku katerega dne v tednu (0 - ponedeljek, 4 - petek) poteka pouk na podan dan.
+// Drugi rezultat je false, če na ta dan ni pouka.
+func (calendar *SchoolCalendar) TimetableDay(date time.Time) (int, bool) {
+	for i := 0; i < len(calendar.events); i++ {
+		event := calendar.events[i]
+		if event.EventType == sql.CALENDAR_MAKEUP_DAY && event.RunsAs != nil && event.contains(date) {
+			return *event.RunsAs, true
+		}
+	}
+	if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+		return 0, false
+	}
+	if calendar.IsSchoolFreeDay(date) {
+		return 0, false
+	}
+	return int(date.Weekday()) - 1, true
+}
+
+// WeekOf vrne teden dvotedenskega urnika (0 - A teden, 1 - B teden), v katerem je podan dan.
+// Zadnja določitev A/B tedna pred tem dnem se upošteva kot nova izhodiščna točka, od katere se tedni izmenjujejo.
+func (calendar *SchoolCalendar) WeekOf(date time.Time) int {
+	anchor := calendar.firstMonday
+	anchorWeek := 0
+	var found = false
+	for i := 0; i < len(calendar.events); i++ {
+		event := calendar.events[i]
+		if event.EventType != sql.CALENDAR_WEEK || event.Week == nil {
+			continue
+		}
+		monday := mondayOf(event.start)
+		if monday.After(date) {
+			continue
+		}
+		if !found || monday.After(anchor) {
+			anchor = monday
+			anchorWeek = *event.Week
+			found = true
+		}
+	}
+	week := (anchorWeek + weeksBetween(anchor, date)) % 2
+	if week < 0 {
+		week += 2
+	}
+	return week
+}
+
+// TermOf vrne ocenjevalno obdobje, v katerem je podan dan.
+func (calendar *SchoolCalendar) TermOf(date time.Time) (int, bool) {
+	for i := 0; i < len(calendar.events); i++ {
+		event := calendar.events[i]
+		if event.EventType == sql.CALENDAR_TERM && event.Term != nil && event.contains(date) {
+			return *event.Term, true
+		}
+	}
+	return 0, false
+}
+
+// GradingPeriods vrne ocenjevalna obdobja šolskega leta (od 1. septembra do 31. avgusta), v katerem je podan dan,
+// urejena po številki obdobja.
+func (calendar *SchoolCalendar) GradingPeriods(date time.Time) []sql.CalendarEvent {
+	year := date.Year()
+	if date.Month() < time.September {
+		year--
+	}
+	yearStart := time.Date(year, time.September, 1, 0, 0, 0, 0, date.Location())
+	yearEnd := yearStart.AddDate(1, 0, -1)
+	periods := make([]sql.CalendarEvent, 0)
+	for i := 0; i < len(calendar.events); i++ {
+		event := calendar.events[i]
+		if event.EventType != sql.CALENDAR_TERM || event.Term == nil || event.start.After(yearEnd) || event.end.Before(yearStart) {
+			continue
+		}
+		periods = append(periods, event.CalendarEvent)
+	}
+	sort.Slice(periods, func(i, j int) bool {
+		return *periods[i].Term < *periods[j].Term
+	})
+	return periods
+}
+
+// SchoolYearBounds vrne prvi in zadnji dan pouka glede na ocenjevalna obdobja v koledarju.
+func (calendar *SchoolCalendar) SchoolYearBounds() (time.Time, time.Time, bool) {
+	var first, last time.Time
+	var ok = false
+	for i := 0; i < len(calendar.events); i++ {
+		event := calendar.events[i]
+		if event.EventType != sql.CALENDAR_TERM {
+			continue
+		}
+		if !ok || event.start.Before(first) {
+			first = event.start
+		}
+		if !ok || event.end.After(last) {
+			last = event.end
+		}
+		ok = true
+	}
+	return first, last, ok
+}
+
+func unescapeICS(text string) string {
+	text = strings.ReplaceAll(text, "\\n", "\n")
+	text = strings.ReplaceAll(text, "\\N", "\n")
+	text = strings.ReplaceAll(text, "\\,", ",")
+	text = strings.ReplaceAll(text, "\\;", ";")
+	return strings.ReplaceAll(text, "\\\\", "\\")
+}
+
+func parseICSDate(value string) (time.Time, bool, error) {
+	if len(value) < 8 {
+		return time.Time{}, false, errors.New(fmt.Sprintf("invalid date %s", value))
+	}
+	date, err := time.Parse("20060102", value[:8])
+	return date, len(value) == 8, err
+}
+
+// ParseCalendarICS prebere dogodke iz iCalendar datoteke. Vsi dogodki dobijo podano vrsto in razred.
+// Celodnevni dogodki imajo v iCalendar formatu izključen končni datum, zato ga zmanjšamo za en dan.
+func ParseCalendarICS(data []byte, eventType int, classId *string) ([]sql.CalendarEvent, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\n ", "")
+	text = strings.ReplaceAll(text, "\n\t", "")
+	lines := strings.Split(text, "\n")
+
+	events := make([]sql.CalendarEvent, 0)
+	var inEvent = false
+	var summary, start, end string
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "BEGIN:VEVENT" {
+			inEvent = true
+			summary, start, end = "", "", ""
+			continue
+		}
+		if !inEvent {
+			continue
+		}
+		if line == "END:VEVENT" {
+			inEvent = false
+			if start == "" {
+				return nil, errors.New(fmt.Sprintf("event %s has no DTSTART", summary))
+			}
+			startDate, _, err := parseICSDate(start)
+			if err != nil {
+				return nil, err
+			}
+			endDate := startDate
+			if end != "" {
+				var allDay bool
+				endDate, allDay, err = parseICSDate(end)
+				if err != nil {
+					return nil, err
+				}
+				if allDay && endDate.After(startDate) {
+					endDate = endDate.AddDate(0, 0, -1)
+				}
+			}
+			events = append(events, sql.CalendarEvent{
+				Name:      summary,
+				EventType: eventType,
+				StartDate: startDate.Format("02-01-2006"),
+				EndDate:   endDate.Format("02-01-2006"),
+				ClassID:   classId,
+			})
+			continue
+		}
+		colon := strings.Index(line, ":")
+		if colon == -1 {
+			continue
+		}
+		key := strings.ToUpper(strings.Split(line[:colon], ";")[0])
+		value := line[colon+1:]
+		if key == "SUMMARY" {
+			summary = unescapeICS(value)
+		} else if key == "DTSTART" {
+			start = value
+		} else if key == "DTEND" {
+			end = value
+		}
+	}
+	return events, nil
+}
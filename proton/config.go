@@ -7,7 +7,10 @@
 package proton
 
 import (
+	sql2 "database/sql"
 	"encoding/json"
+	"errors"
+	"github.com/MeetPlan/MeetPlanBackend/sql"
 	"os"
 )
 
@@ -18,6 +21,9 @@ const SubjectObjectType = "Subject"
 
 const ProtonConfigVersion = "1.0"
 
+// Stara konfiguracijska datoteka, ki jo ob zagonu preselimo v podatkovno bazo.
+const legacyConfigFile = "protonConfig.json"
+
 type ProtonObject struct {
 	ObjectID string `json:"object_id"`
 	Type     string `json:"type"`
@@ -31,53 +37,206 @@ type ProtonRule struct {
 }
 
 type ProtonConfig struct {
-	Version string       `json:"version"`
-	Rules   []ProtonRule `json:"rules"`
+	Version        string       `json:"version"`
+	RuleSetVersion int          `json:"rule_set_version"`
+	Rules          []ProtonRule `json:"rules"`
+}
+
+// ProtonConfigSnapshot je ena shranjena različica nabora pravil iz zgodovine sprememb.
+type ProtonConfigSnapshot struct {
+	Version      int          `json:"version"`
+	Rules        []ProtonRule `json:"rules"`
+	CreatedBy    *string      `json:"created_by"`
+	RestoredFrom *int         `json:"restored_from"`
+	CreatedAt    string       `json:"created_at"`
 }
 
-func LoadConfig() (config ProtonConfig, err error) {
-	file, err := os.ReadFile("protonConfig.json")
+func ruleFromSQL(rule sql.ProtonRule) (ProtonRule, error) {
+	var objects []ProtonObject
+	err := json.Unmarshal([]byte(rule.Objects), &objects)
 	if err != nil {
-		marshal, err := json.Marshal(ProtonConfig{
-			Version: ProtonConfigVersion,
-			Rules:   make([]ProtonRule, 0),
-		})
-		if err != nil {
-			return config, err
-		}
-		err = os.WriteFile("protonConfig.json", marshal, 0600)
+		return ProtonRule{}, err
+	}
+	return ProtonRule{
+		Objects:  objects,
+		RuleName: rule.RuleName,
+		RuleType: rule.RuleType,
+		ID:       rule.ID,
+	}, nil
+}
+
+func ruleToSQL(rule ProtonRule) (sql.ProtonRule, error) {
+	if rule.Objects == nil {
+		rule.Objects = make([]ProtonObject, 0)
+	}
+	marshal, err := json.Marshal(rule.Objects)
+	if err != nil {
+		return sql.ProtonRule{}, err
+	}
+	return sql.ProtonRule{
+		ID:       rule.ID,
+		RuleName: rule.RuleName,
+		RuleType: rule.RuleType,
+		Objects:  string(marshal),
+	}, nil
+}
+
+func snapshotFromSQL(version sql.ProtonConfigVersion) (ProtonConfigSnapshot, error) {
+	var rules []ProtonRule
+	err := json.Unmarshal([]byte(version.Rules), &rules)
+	if err != nil {
+		return ProtonConfigSnapshot{}, err
+	}
+	return ProtonConfigSnapshot{
+		Version:      version.Version,
+		Rules:        rules,
+		CreatedBy:    version.CreatedBy,
+		RestoredFrom: version.RestoredFrom,
+		CreatedAt:    version.CreatedAt,
+	}, nil
+}
+
+// LoadConfig prebere trenutna pravila in zadnjo različico nabora pravil iz podatkovne baze.
+func LoadConfig(db sql.SQL) (config ProtonConfig, err error) {
+	config = ProtonConfig{
+		Version: ProtonConfigVersion,
+		Rules:   make([]ProtonRule, 0),
+	}
+	rules, err := db.GetProtonRules()
+	if err != nil {
+		return config, err
+	}
+	for i := 0; i < len(rules); i++ {
+		rule, err := ruleFromSQL(rules[i])
 		if err != nil {
 			return config, err
 		}
-		file, err = os.ReadFile("protonConfig.json")
-		if err != nil {
-			return config, err
+		config.Rules = append(config.Rules, rule)
+	}
+	latest, err := db.GetLatestProtonConfigVersion()
+	if err != nil {
+		if errors.Is(err, sql2.ErrNoRows) {
+			return config, nil
 		}
+		return config, err
+	}
+	config.RuleSetVersion = latest.Version
+	return config, nil
+}
+
+// SaveSnapshot shrani trenutno stanje pravil v podatkovni bazi kot novo različico nabora pravil.
+func SaveSnapshot(db sql.SQL, createdBy *string, restoredFrom *int) (int, error) {
+	config, err := LoadConfig(db)
+	if err != nil {
+		return 0, err
+	}
+	marshal, err := json.Marshal(config.Rules)
+	if err != nil {
+		return 0, err
+	}
+	return db.InsertProtonConfigVersion(sql.ProtonConfigVersion{
+		Rules:        string(marshal),
+		CreatedBy:    createdBy,
+		RestoredFrom: restoredFrom,
+	})
+}
+
+func AddNewRule(db sql.SQL, rule ProtonRule, createdBy string) (ProtonConfig, error) {
+	sqlRule, err := ruleToSQL(rule)
+	if err != nil {
+		return ProtonConfig{}, err
+	}
+	_, err = db.InsertProtonRule(sqlRule)
+	if err != nil {
+		return ProtonConfig{}, err
+	}
+	_, err = SaveSnapshot(db, &createdBy, nil)
+	if err != nil {
+		return ProtonConfig{}, err
+	}
+	return LoadConfig(db)
+}
+
+func RemoveRule(db sql.SQL, ruleId string, createdBy string) (ProtonConfig, error) {
+	err := db.DeleteProtonRule(ruleId)
+	if err != nil {
+		return ProtonConfig{}, err
+	}
+	_, err = SaveSnapshot(db, &createdBy, nil)
+	if err != nil {
+		return ProtonConfig{}, err
 	}
-	err = json.Unmarshal(file, &config)
-	return config, err
+	return LoadConfig(db)
 }
 
-func SaveConfig(protonConfig ProtonConfig) error {
-	marshal, err := json.Marshal(&protonConfig)
+// RestoreConfig zamenja trenutna pravila s pravili iz podane različice. Obnova se shrani kot nova različica,
+// tako da zgodovina nikoli ne izgubi vmesnih stanj.
+func RestoreConfig(db sql.SQL, version int, createdBy string) (ProtonConfig, error) {
+	configVersion, err := db.GetProtonConfigVersion(version)
 	if err != nil {
-		return err
+		return ProtonConfig{}, err
 	}
-	f, err := os.Create("protonConfig.json")
+	snapshot, err := snapshotFromSQL(configVersion)
 	if err != nil {
-		return err
+		return ProtonConfig{}, err
 	}
-	if err := f.Close(); err != nil {
-		return err
+	rules := make([]sql.ProtonRule, 0)
+	for i := 0; i < len(snapshot.Rules); i++ {
+		rule, err := ruleToSQL(snapshot.Rules[i])
+		if err != nil {
+			return ProtonConfig{}, err
+		}
+		rules = append(rules, rule)
+	}
+	err = db.ReplaceProtonRules(rules)
+	if err != nil {
+		return ProtonConfig{}, err
 	}
-	err = os.WriteFile("protonConfig.json", marshal, 0600)
+	_, err = SaveSnapshot(db, &createdBy, &version)
 	if err != nil {
-		return err
+		return ProtonConfig{}, err
 	}
-	return nil
+	return LoadConfig(db)
 }
 
-func AddNewRule(protonConfig ProtonConfig, rule ProtonRule) (ProtonConfig, error) {
-	protonConfig.Rules = append(protonConfig.Rules, rule)
-	return protonConfig, SaveConfig(protonConfig)
+// MigrateLegacyConfig preseli pravila iz stare protonConfig.json datoteke v podatkovno bazo.
+// Datoteko po uspešni selitvi preimenuje, da se selitev ne izvede ponovno.
+// Pravila se uvozijo samo, če podatkovna baza še ne vsebuje nobenih pravil.
+func MigrateLegacyConfig(db sql.SQL) (bool, error) {
+	file, err := os.ReadFile(legacyConfigFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	existing, err := db.GetProtonRules()
+	if err != nil {
+		return false, err
+	}
+	if len(existing) == 0 {
+		var config ProtonConfig
+		err = json.Unmarshal(file, &config)
+		if err != nil {
+			return false, err
+		}
+		for i := 0; i < len(config.Rules); i++ {
+			// ID-je generira podatkovna baza, saj so jih stare datoteke pogosto imele prazne
+			rule := config.Rules[i]
+			rule.ID = ""
+			sqlRule, err := ruleToSQL(rule)
+			if err != nil {
+				return false, err
+			}
+			_, err = db.InsertProtonRule(sqlRule)
+			if err != nil {
+				return false, err
+			}
+		}
+		_, err = SaveSnapshot(db, nil, nil)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, os.Rename(legacyConfigFile, legacyConfigFile+".migrated")
 }
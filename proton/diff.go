@@ -0,0 +1,122 @@
+/// This file is a part of MeetPlan Proton, which is a part of MeetPlanBackend (https://github.com/MeetPlan/MeetPlanBackend).
+///
+/// Copyright (c) 2022, Mitja Ševerkar <mytja@protonmail.com> and The MeetPlan Team.
+/// All rights reserved.
+/// Use of this source code is governed by the GNU AGPLv3 license, that can be found in the LICENSE file.
+
+package proton
+
+type MovedLesson struct {
+	From ProtonMeeting `json:"from"`
+	To   ProtonMeeting `json:"to"`
+}
+
+type TimetableDiff struct {
+	Added   []ProtonMeeting `json:"added"`
+	Moved   []MovedLesson   `json:"moved"`
+	Removed []ProtonMeeting `json:"removed"`
+}
+
+func sameSlot(a ProtonMeeting, b ProtonMeeting) bool {
+	return a.SubjectID == b.SubjectID && a.Week == b.Week && a.DayOfTheWeek == b.DayOfTheWeek && a.Hour == b.Hour
+}
+
+// DiffTimetables primerja dva dvotedenska urnika po predmetih.
+// Ure, ki so v obeh urnikih na istem mestu, so nespremenjene. Preostale ure istega predmeta v istem tednu
+// se združijo v premaknjene ure, kar ostane, pa je dodano ali odstranjeno.
+func DiffTimetables(current []ProtonMeeting, next []ProtonMeeting) TimetableDiff {
+	diff := TimetableDiff{
+		Added:   make([]ProtonMeeting, 0),
+		Moved:   make([]MovedLesson, 0),
+		Removed: make([]ProtonMeeting, 0),
+	}
+
+	matchedCurrent := make([]bool, len(current))
+	unmatchedNext := make([]ProtonMeeting, 0)
+
+	for i := 0; i < len(next); i++ {
+		var found = false
+		for n := 0; n < len(current); n++ {
+			if !matchedCurrent[n] && sameSlot(current[n], next[i]) {
+				matchedCurrent[n] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			unmatchedNext = append(unmatchedNext, next[i])
+		}
+	}
+
+	for i := 0; i < len(unmatchedNext); i++ {
+		meeting := unmatchedNext[i]
+		var moved = false
+		for n := 0; n < len(current); n++ {
+			if matchedCurrent[n] || current[n].SubjectID != meeting.SubjectID || current[n].Week != meeting.Week {
+				continue
+			}
+			matchedCurrent[n] = true
+			diff.Moved = append(diff.Moved, MovedLesson{From: current[n], To: meeting})
+			moved = true
+			break
+		}
+		if !moved {
+			diff.Added = append(diff.Added, meeting)
+		}
+	}
+
+	for n := 0; n < len(current); n++ {
+		if !matchedCurrent[n] {
+			diff.Removed = append(diff.Removed, current[n])
+		}
+	}
+
+	return diff
+}
+
+type TimetableVersionDiff struct {
+	Classes  map[string]TimetableDiff `json:"classes"`
+	Teachers map[string]TimetableDiff `json:"teachers"`
+}
+
+func (diff TimetableDiff) isEmpty() bool {
+	return len(diff.Added) == 0 && len(diff.Moved) == 0 && len(diff.Removed) == 0
+}
+
+// DiffTimetablesByClassAndTeacher primerja urnika ločeno za vsak razred in vsakega učitelja.
+// Razredi in učitelji brez sprememb niso vključeni.
+func DiffTimetablesByClassAndTeacher(current []ProtonMeeting, next []ProtonMeeting) TimetableVersionDiff {
+	classes := make(map[string][2][]ProtonMeeting)
+	teachers := make(map[string][2][]ProtonMeeting)
+	for i, timetable := range [][]ProtonMeeting{current, next} {
+		for n := 0; n < len(timetable); n++ {
+			meeting := timetable[n]
+			teacher := teachers[meeting.TeacherID]
+			teacher[i] = append(teacher[i], meeting)
+			teachers[meeting.TeacherID] = teacher
+			for x := 0; x < len(meeting.ClassID); x++ {
+				class := classes[meeting.ClassID[x]]
+				class[i] = append(class[i], meeting)
+				classes[meeting.ClassID[x]] = class
+			}
+		}
+	}
+
+	diff := TimetableVersionDiff{
+		Classes:  make(map[string]TimetableDiff),
+		Teachers: make(map[string]TimetableDiff),
+	}
+	for classId, timetables := range classes {
+		classDiff := DiffTimetables(timetables[0], timetables[1])
+		if !classDiff.isEmpty() {
+			diff.Classes[classId] = classDiff
+		}
+	}
+	for teacherId, timetables := range teachers {
+		teacherDiff := DiffTimetables(timetables[0], timetables[1])
+		if !teacherDiff.isEmpty() {
+			diff.Teachers[teacherId] = teacherDiff
+		}
+	}
+	return diff
+}
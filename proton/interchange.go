@@ -0,0 +1,628 @@
+/// This file is a part of MeetPlan Proton, which is a part of MeetPlanBackend (https://github.com/MeetPlan/MeetPlanBackend).
+///
+/// Copyright (c) 2022, Mitja Ševerkar <mytja@protonmail.com> and The MeetPlan Team.
+/// All rights reserved.
+/// Use of this source code is governed by the GNU AGPLv3 license, that can be found in the LICENSE file.
+
+package proton
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/MeetPlan/MeetPlanBackend/helpers"
+	"github.com/MeetPlan/MeetPlanBackend/sql"
+	"github.com/google/uuid"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Uvoz in izvoz urnikov v standardnih formatih (FET XML in preprost CSV).
+// Oba formata se najprej pretvorita v TimetableRow, ki vsebuje samo imena (razred, predmet, učitelj),
+// nato pa se ta imena razrešijo v predmete in učitelje iz podatkovne baze.
+
+// BothWeeks označuje vrstico, ki velja za oba tedna dvotedenskega Proton urnika.
+const BothWeeks = -1
+
+var dayNames = [][]string{
+	{"monday", "mon", "ponedeljek", "pon"},
+	{"tuesday", "tue", "torek", "tor"},
+	{"wednesday", "wed", "sreda", "sre"},
+	{"thursday", "thu", "četrtek", "cet", "čet"},
+	{"friday", "fri", "petek", "pet"},
+}
+
+var fetDayNames = []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday"}
+
+type TimetableRow struct {
+	Class   string `json:"class"`
+	Subject string `json:"subject"`
+	Teacher string `json:"teacher"`
+	Day     int    `json:"day"`
+	Hour    int    `json:"hour"`
+	Week    int    `json:"week"`
+	Line    int    `json:"line"`
+}
+
+type TimetableImportError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+func ParseDay(day string) (int, error) {
+	day = strings.ToLower(strings.TrimSpace(day))
+	if d, err := strconv.Atoi(day); err == nil {
+		if d < 0 || d >= len(dayNames) {
+			return 0, fmt.Errorf("day %d is out of range", d)
+		}
+		return d, nil
+	}
+	for i := 0; i < len(dayNames); i++ {
+		if helpers.Contains(dayNames[i], day) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown day %s", day)
+}
+
+func parseWeek(week string) (int, error) {
+	switch strings.ToUpper(strings.TrimSpace(week)) {
+	case "":
+		return BothWeeks, nil
+	case "0", "A":
+		return 0, nil
+	case "1", "B":
+		return 1, nil
+	}
+	return 0, fmt.Errorf("unknown week %s", week)
+}
+
+// ParseTimetableCSV prebere CSV datoteko s stolpci class, subject, teacher, day, hour, week.
+// Glava je neobvezna, ločilo je lahko vejica ali podpičje.
+func ParseTimetableCSV(data []byte) ([]TimetableRow, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	firstLine := strings.SplitN(string(data), "\n", 2)[0]
+	if strings.Contains(firstLine, ";") && !strings.Contains(firstLine, ",") {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]TimetableRow, 0)
+	for i := 0; i < len(records); i++ {
+		record := records[i]
+		if i == 0 && len(record) > 0 && strings.ToLower(strings.TrimSpace(record[0])) == "class" {
+			continue
+		}
+		if len(record) < 5 {
+			return nil, fmt.Errorf("line %d: expected at least 5 columns, got %d", i+1, len(record))
+		}
+		day, err := ParseDay(record[3])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", i+1, err.Error())
+		}
+		hour, err := strconv.Atoi(strings.TrimSpace(record[4]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", i+1, err.Error())
+		}
+		week := BothWeeks
+		if len(record) > 5 {
+			week, err = parseWeek(record[5])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", i+1, err.Error())
+			}
+		}
+		rows = append(rows, TimetableRow{
+			Class:   strings.TrimSpace(record[0]),
+			Subject: strings.TrimSpace(record[1]),
+			Teacher: strings.TrimSpace(record[2]),
+			Day:     day,
+			Hour:    hour,
+			Week:    week,
+			Line:    i + 1,
+		})
+	}
+	return rows, nil
+}
+
+func ExportTimetableCSV(rows []TimetableRow) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	err := writer.Write([]string{"class", "subject", "teacher", "day", "hour", "week"})
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(rows); i++ {
+		row := rows[i]
+		week := ""
+		if row.Week != BothWeeks {
+			week = fmt.Sprint(row.Week)
+		}
+		err := writer.Write([]string{row.Class, row.Subject, row.Teacher, fmt.Sprint(row.Day), fmt.Sprint(row.Hour), week})
+		if err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	return buffer.Bytes(), writer.Error()
+}
+
+type fetNamed struct {
+	Name string `xml:"Name"`
+}
+
+type fetYear struct {
+	Name             string `xml:"Name"`
+	NumberOfStudents int    `xml:"Number_of_Students"`
+}
+
+type fetActivity struct {
+	Teachers        []string `xml:"Teacher"`
+	Subject         string   `xml:"Subject"`
+	Students        []string `xml:"Students"`
+	Duration        int      `xml:"Duration"`
+	TotalDuration   int      `xml:"Total_Duration"`
+	ID              int      `xml:"Id"`
+	ActivityGroupID int      `xml:"Activity_Group_Id"`
+	Active          bool     `xml:"Active"`
+	Comments        string   `xml:"Comments"`
+}
+
+type fetBasicCompulsoryTime struct {
+	WeightPercentage int  `xml:"Weight_Percentage"`
+	Active           bool `xml:"Active"`
+}
+
+type fetPreferredStartingTime struct {
+	WeightPercentage  int    `xml:"Weight_Percentage"`
+	ActivityID        int    `xml:"Activity_Id"`
+	PreferredDay      string `xml:"Preferred_Day"`
+	PreferredHour     string `xml:"Preferred_Hour"`
+	PermanentlyLocked bool   `xml:"Permanently_Locked"`
+	Active            bool   `xml:"Active"`
+}
+
+type fetFile struct {
+	XMLName         xml.Name                   `xml:"fet"`
+	Version         string                     `xml:"version,attr"`
+	InstitutionName string                     `xml:"Institution_Name"`
+	Comments        string                     `xml:"Comments"`
+	NumberOfHours   int                        `xml:"Hours_List>Number_of_Hours"`
+	Hours           []fetNamed                 `xml:"Hours_List>Hour"`
+	NumberOfDays    int                        `xml:"Days_List>Number_of_Days"`
+	Days            []fetNamed                 `xml:"Days_List>Day"`
+	Students        []fetYear                  `xml:"Students_List>Year"`
+	Teachers        []fetNamed                 `xml:"Teachers_List>Teacher"`
+	Subjects        []fetNamed                 `xml:"Subjects_List>Subject"`
+	Activities      []fetActivity              `xml:"Activities_List>Activity"`
+	BasicTime       fetBasicCompulsoryTime     `xml:"Time_Constraints_List>ConstraintBasicCompulsoryTime"`
+	StartingTimes   []fetPreferredStartingTime `xml:"Time_Constraints_List>ConstraintActivityPreferredStartingTime"`
+}
+
+// FET ne pozna dvotedenskih urnikov, zato teden zapišemo v komentar aktivnosti.
+func fetWeekComment(week int) string {
+	if week == BothWeeks {
+		return ""
+	}
+	return fmt.Sprintf("week=%d", week)
+}
+
+func parseFETWeekComment(comment string) int {
+	comment = strings.TrimSpace(comment)
+	if strings.HasPrefix(comment, "week=") {
+		week, err := parseWeek(strings.TrimPrefix(comment, "week="))
+		if err == nil {
+			return week
+		}
+	}
+	return BothWeeks
+}
+
+// ParseFET prebere .fet datoteko. Upoštevajo se samo aktivnosti, ki imajo določen začetni čas
+// (ConstraintActivityPreferredStartingTime), saj le te predstavljajo že sestavljen urnik.
+func ParseFET(data []byte) ([]TimetableRow, error) {
+	var fet fetFile
+	err := xml.Unmarshal(data, &fet)
+	if err != nil {
+		return nil, err
+	}
+	days := make(map[string]int)
+	for i := 0; i < len(fet.Days); i++ {
+		days[fet.Days[i].Name] = i
+	}
+	hours := make(map[string]int)
+	for i := 0; i < len(fet.Hours); i++ {
+		hour, err := strconv.Atoi(strings.TrimSpace(fet.Hours[i].Name))
+		if err != nil {
+			// Ure niso oštevilčene, posledično jih štejemo od prve redne ure naprej
+			hour = i + PROTON_MIN_NORMAL_HOUR
+		}
+		hours[fet.Hours[i].Name] = hour
+	}
+	activities := make(map[int]fetActivity)
+	for i := 0; i < len(fet.Activities); i++ {
+		activities[fet.Activities[i].ID] = fet.Activities[i]
+	}
+	rows := make([]TimetableRow, 0)
+	for i := 0; i < len(fet.StartingTimes); i++ {
+		startingTime := fet.StartingTimes[i]
+		activity, ok := activities[startingTime.ActivityID]
+		if !ok || !activity.Active || !startingTime.Active {
+			continue
+		}
+		day, ok := days[startingTime.PreferredDay]
+		if !ok || day >= len(dayNames) {
+			return nil, fmt.Errorf("activity %d: unsupported day %s", activity.ID, startingTime.PreferredDay)
+		}
+		hour, ok := hours[startingTime.PreferredHour]
+		if !ok {
+			return nil, fmt.Errorf("activity %d: unknown hour %s", activity.ID, startingTime.PreferredHour)
+		}
+		if len(activity.Teachers) == 0 {
+			return nil, fmt.Errorf("activity %d: activity has no teacher", activity.ID)
+		}
+		duration := activity.Duration
+		if duration <= 0 {
+			duration = 1
+		}
+		week := parseFETWeekComment(activity.Comments)
+		for s := 0; s < len(activity.Students); s++ {
+			for d := 0; d < duration; d++ {
+				rows = append(rows, TimetableRow{
+					Class:   activity.Students[s],
+					Subject: activity.Subject,
+					Teacher: activity.Teachers[0],
+					Day:     day,
+					Hour:    hour + d,
+					Week:    week,
+					Line:    activity.ID,
+				})
+			}
+		}
+	}
+	return rows, nil
+}
+
+type fetActivityKey struct {
+	Subject string
+	Teacher string
+	Day     int
+	Hour    int
+	Week    int
+}
+
+func ExportTimetableFET(rows []TimetableRow, institutionName string) ([]byte, error) {
+	fet := fetFile{
+		Version:         "6.0.0",
+		InstitutionName: institutionName,
+		Comments:        "Exported from MeetPlan",
+		BasicTime:       fetBasicCompulsoryTime{WeightPercentage: 100, Active: true},
+	}
+	for hour := 0; hour <= PROTON_MAX_AFTER_CLASS_HOUR; hour++ {
+		fet.Hours = append(fet.Hours, fetNamed{Name: fmt.Sprint(hour)})
+	}
+	fet.NumberOfHours = len(fet.Hours)
+	for i := 0; i < len(fetDayNames); i++ {
+		fet.Days = append(fet.Days, fetNamed{Name: fetDayNames[i]})
+	}
+	fet.NumberOfDays = len(fet.Days)
+
+	var classes, teachers, subjects []string
+	activities := make(map[fetActivityKey]*fetActivity)
+	keys := make([]fetActivityKey, 0)
+	for i := 0; i < len(rows); i++ {
+		row := rows[i]
+		if !helpers.Contains(classes, row.Class) {
+			classes = append(classes, row.Class)
+		}
+		if !helpers.Contains(teachers, row.Teacher) {
+			teachers = append(teachers, row.Teacher)
+		}
+		if !helpers.Contains(subjects, row.Subject) {
+			subjects = append(subjects, row.Subject)
+		}
+		key := fetActivityKey{Subject: row.Subject, Teacher: row.Teacher, Day: row.Day, Hour: row.Hour, Week: row.Week}
+		activity, ok := activities[key]
+		if !ok {
+			activity = &fetActivity{
+				Teachers:      []string{row.Teacher},
+				Subject:       row.Subject,
+				Duration:      1,
+				TotalDuration: 1,
+				ID:            len(keys) + 1,
+				Active:        true,
+				Comments:      fetWeekComment(row.Week),
+			}
+			activities[key] = activity
+			keys = append(keys, key)
+		}
+		if !helpers.Contains(activity.Students, row.Class) {
+			activity.Students = append(activity.Students, row.Class)
+		}
+	}
+	for i := 0; i < len(classes); i++ {
+		fet.Students = append(fet.Students, fetYear{Name: classes[i]})
+	}
+	for i := 0; i < len(teachers); i++ {
+		fet.Teachers = append(fet.Teachers, fetNamed{Name: teachers[i]})
+	}
+	for i := 0; i < len(subjects); i++ {
+		fet.Subjects = append(fet.Subjects, fetNamed{Name: subjects[i]})
+	}
+	for i := 0; i < len(keys); i++ {
+		key := keys[i]
+		activity := activities[key]
+		fet.Activities = append(fet.Activities, *activity)
+		fet.StartingTimes = append(fet.StartingTimes, fetPreferredStartingTime{
+			WeightPercentage:  100,
+			ActivityID:        activity.ID,
+			PreferredDay:      fetDayNames[key.Day],
+			PreferredHour:     fmt.Sprint(key.Hour),
+			PermanentlyLocked: true,
+			Active:            true,
+		})
+	}
+	marshal, err := xml.MarshalIndent(fet, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), marshal...), nil
+}
+
+func teacherMatches(teacher sql.User, name string) bool {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return name == strings.ToLower(teacher.Name) ||
+		name == strings.ToLower(strings.TrimSpace(teacher.Name+" "+teacher.Surname)) ||
+		name == strings.ToLower(teacher.Email)
+}
+
+// GetClassesOfSubject vrne ID-je vseh razredov, katerih učenci obiskujejo podan predmet.
+func (p *protonImpl) GetClassesOfSubject(subject sql.Subject, classes []sql.Class) ([]string, error) {
+	var classId = make([]string, 0)
+	if subject.InheritsClass {
+		classId = append(classId, *subject.ClassID)
+		return classId, nil
+	}
+	var students []string
+	err := json.Unmarshal([]byte(subject.Students), &students)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(classes); i++ {
+		var classStudents []string
+		err := json.Unmarshal([]byte(classes[i].Students), &classStudents)
+		if err != nil {
+			return nil, err
+		}
+		for n := 0; n < len(students); n++ {
+			if helpers.Contains(classStudents, students[n]) && !helpers.Contains(classId, classes[i].ID) {
+				classId = append(classId, classes[i].ID)
+			}
+		}
+	}
+	return classId, nil
+}
+
+// ResolveTimetableRows razreši imena iz uvoženih vrstic v obstoječe predmete in učitelje.
+// Napake validacije se zbirajo in vrnejo skupaj, da jih lahko uporabnik popravi naenkrat.
+func (p *protonImpl) ResolveTimetableRows(rows []TimetableRow) ([]ProtonMeeting, []TimetableImportError, error) {
+	subjects, err := p.db.GetAllSubjects()
+	if err != nil {
+		return nil, nil, err
+	}
+	teachers, err := p.db.GetTeachers()
+	if err != nil {
+		return nil, nil, err
+	}
+	classes, err := p.db.GetClasses()
+	if err != nil {
+		return nil, nil, err
+	}
+	classNames := make(map[string]string)
+	for i := 0; i < len(classes); i++ {
+		classNames[classes[i].ID] = classes[i].Name
+	}
+	subjectClasses := make(map[string][]string)
+	for i := 0; i < len(subjects); i++ {
+		classIds, err := p.GetClassesOfSubject(subjects[i], classes)
+		if err != nil {
+			return nil, nil, err
+		}
+		subjectClasses[subjects[i].ID] = classIds
+	}
+
+	importErrors := make([]TimetableImportError, 0)
+	timetable := make([]ProtonMeeting, 0)
+	// Predmet, ki ga obiskuje več razredov, se v uvozu pojavi v več vrsticah, a je v urniku samo eno srečanje.
+	seen := make(map[string]bool)
+
+	for i := 0; i < len(rows); i++ {
+		row := rows[i]
+		if row.Hour < 0 || row.Hour > PROTON_MAX_AFTER_CLASS_HOUR {
+			importErrors = append(importErrors, TimetableImportError{Line: row.Line, Message: fmt.Sprintf("hour %d is out of range", row.Hour)})
+			continue
+		}
+		var teacher *sql.User
+		for n := 0; n < len(teachers); n++ {
+			if teacherMatches(teachers[n], row.Teacher) {
+				teacher = &teachers[n]
+				break
+			}
+		}
+		if teacher == nil {
+			importErrors = append(importErrors, TimetableImportError{Line: row.Line, Message: fmt.Sprintf("unknown teacher %s", row.Teacher)})
+			continue
+		}
+		candidates := make([]sql.Subject, 0)
+		for n := 0; n < len(subjects); n++ {
+			subject := subjects[n]
+			if subject.TeacherID != teacher.ID {
+				continue
+			}
+			if !strings.EqualFold(subject.Name, row.Subject) && !strings.EqualFold(subject.LongName, row.Subject) {
+				continue
+			}
+			classIds := subjectClasses[subject.ID]
+			for c := 0; c < len(classIds); c++ {
+				if strings.EqualFold(classNames[classIds[c]], row.Class) {
+					candidates = append(candidates, subject)
+					break
+				}
+			}
+		}
+		if len(candidates) == 0 {
+			importErrors = append(importErrors, TimetableImportError{Line: row.Line, Message: fmt.Sprintf("no subject %s taught by %s to class %s", row.Subject, row.Teacher, row.Class)})
+			continue
+		}
+		if len(candidates) > 1 {
+			importErrors = append(importErrors, TimetableImportError{Line: row.Line, Message: fmt.Sprintf("subject %s for class %s is ambiguous", row.Subject, row.Class)})
+			continue
+		}
+		subject := candidates[0]
+		weeks := []int{row.Week}
+		if row.Week == BothWeeks {
+			weeks = []int{0, 1}
+		}
+		for n := 0; n < len(weeks); n++ {
+			key := fmt.Sprintf("%s/%d/%d/%d", subject.ID, row.Day, row.Hour, weeks[n])
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			timetable = append(timetable, ProtonMeeting{
+				Hour:         row.Hour,
+				DayOfTheWeek: row.Day,
+				SubjectName:  subject.Name,
+				SubjectID:    subject.ID,
+				ID:           uuid.New().String(),
+				TeacherID:    subject.TeacherID,
+				Week:         weeks[n],
+				ClassID:      subjectClasses[subject.ID],
+				IsHalfHour:   row.Week != BothWeeks,
+			})
+		}
+	}
+	return timetable, importErrors, nil
+}
+
+// DescribeTimetable pretvori Proton urnik v vrstice z imeni, primerne za izvoz.
+// Srečanja, ki se ponovijo v obeh tednih, se združijo v eno vrstico.
+func (p *protonImpl) DescribeTimetable(timetable []ProtonMeeting) ([]TimetableRow, error) {
+	classNames := make(map[string]string)
+	classes, err := p.db.GetClasses()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(classes); i++ {
+		classNames[classes[i].ID] = classes[i].Name
+	}
+	teacherNames := make(map[string]string)
+	weeks := make(map[string][]int)
+	keys := make([]string, 0)
+	meetings := make(map[string]ProtonMeeting)
+	for i := 0; i < len(timetable); i++ {
+		meeting := timetable[i]
+		if _, ok := teacherNames[meeting.TeacherID]; !ok {
+			teacher, err := p.db.GetUser(meeting.TeacherID)
+			if err != nil {
+				return nil, err
+			}
+			teacherNames[meeting.TeacherID] = strings.TrimSpace(teacher.Name + " " + teacher.Surname)
+		}
+		key := fmt.Sprintf("%s/%d/%d", meeting.SubjectID, meeting.DayOfTheWeek, meeting.Hour)
+		if _, ok := weeks[key]; !ok {
+			keys = append(keys, key)
+			meetings[key] = meeting
+		}
+		if !helpers.Contains(weeks[key], meeting.Week) {
+			weeks[key] = append(weeks[key], meeting.Week)
+		}
+	}
+	rows := make([]TimetableRow, 0)
+	for i := 0; i < len(keys); i++ {
+		meeting := meetings[keys[i]]
+		rowWeeks := weeks[keys[i]]
+		if len(rowWeeks) > 1 {
+			rowWeeks = []int{BothWeeks}
+		}
+		for c := 0; c < len(meeting.ClassID); c++ {
+			className, ok := classNames[meeting.ClassID[c]]
+			if !ok {
+				return nil, errors.New("unknown class " + meeting.ClassID[c])
+			}
+			for w := 0; w < len(rowWeeks); w++ {
+				rows = append(rows, TimetableRow{
+					Class:   className,
+					Subject: meeting.SubjectName,
+					Teacher: teacherNames[meeting.TeacherID],
+					Day:     meeting.DayOfTheWeek,
+					Hour:    meeting.Hour,
+					Week:    rowWeeks[w],
+				})
+			}
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Class != rows[j].Class {
+			return rows[i].Class < rows[j].Class
+		}
+		if rows[i].Day != rows[j].Day {
+			return rows[i].Day < rows[j].Day
+		}
+		return rows[i].Hour < rows[j].Hour
+	})
+	return rows, nil
+}
+
+// ProtonMeetingsFromMeetings pretvori dejanska srečanja iz dveh zaporednih tednov (od firstMonday naprej)
+// nazaj v dvotedenski Proton urnik.
+func (p *protonImpl) ProtonMeetingsFromMeetings(meetings []sql.Meeting, firstMonday time.Time) ([]ProtonMeeting, error) {
+	classes, err := p.db.GetClasses()
+	if err != nil {
+		return nil, err
+	}
+	subjectClasses := make(map[string][]string)
+	subjectNames := make(map[string]string)
+	timetable := make([]ProtonMeeting, 0)
+	for i := 0; i < len(meetings); i++ {
+		meeting := meetings[i]
+		date, err := time.Parse("02-01-2006", meeting.Date)
+		if err != nil {
+			return nil, err
+		}
+		days := int(date.Sub(firstMonday).Hours() / 24)
+		if days < 0 || days >= 14 || days%7 >= len(dayNames) {
+			continue
+		}
+		if _, ok := subjectClasses[meeting.SubjectID]; !ok {
+			subject, err := p.db.GetSubject(meeting.SubjectID)
+			if err != nil {
+				return nil, err
+			}
+			subjectClasses[meeting.SubjectID], err = p.GetClassesOfSubject(subject, classes)
+			if err != nil {
+				return nil, err
+			}
+			subjectNames[meeting.SubjectID] = subject.Name
+		}
+		timetable = append(timetable, ProtonMeeting{
+			Hour:         meeting.Hour,
+			DayOfTheWeek: days % 7,
+			SubjectName:  subjectNames[meeting.SubjectID],
+			SubjectID:    meeting.SubjectID,
+			ID:           meeting.ID,
+			TeacherID:    meeting.TeacherID,
+			Week:         days / 7,
+			ClassID:      subjectClasses[meeting.SubjectID],
+		})
+	}
+	return timetable, nil
+}
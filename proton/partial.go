@@ -0,0 +1,89 @@
+/// This file is a part of MeetPlan Proton, which is a part of MeetPlanBackend (https://github.com/MeetPlan/MeetPlanBackend).
+///
+/// Copyright (c) 2022, Mitja Ševerkar <mytja@protonmail.com> and The MeetPlan Team.
+/// All rights reserved.
+/// Use of this source code is governed by the GNU AGPLv3 license, that can be found in the LICENSE file.
+
+package proton
+
+import "github.com/MeetPlan/MeetPlanBackend/helpers"
+
+func (p *protonImpl) subjectsInGroupWith(subjectId string) []string {
+	subjects := []string{subjectId}
+	subjectGroups := p.GetSubjectGroups()
+	for i := 0; i < len(subjectGroups); i++ {
+		group := subjectGroups[i]
+		var ok = false
+		for n := 0; n < len(group.Objects); n++ {
+			if group.Objects[n].Type == "subject" && group.Objects[n].ObjectID == subjectId {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		for n := 0; n < len(group.Objects); n++ {
+			object := group.Objects[n]
+			if object.Type == "subject" && !helpers.Contains(subjects, object.ObjectID) {
+				subjects = append(subjects, object.ObjectID)
+			}
+		}
+	}
+	return subjects
+}
+
+// PinMeetings iz trenutnega urnika izbere srečanja, ki morajo pri delni regeneraciji ostati na svojem mestu.
+// Srečanje je zaklenjeno, če pripada enemu izmed podanih razredov ali učiteljev ali če je njegov ID med podanimi srečanji.
+//
+// Posamezno zaklenjeno srečanje zaklene tudi isto uro predmeta v drugem tednu ter srečanja predmetov iz iste skupine
+// predmetov na istem mestu, saj jih generator vedno postavlja skupaj.
+func (p *protonImpl) PinMeetings(timetable []ProtonMeeting, classIds []string, teacherIds []string, meetingIds []string) []ProtonMeeting {
+	pinned := make([]bool, len(timetable))
+	for i := 0; i < len(timetable); i++ {
+		meeting := timetable[i]
+		if helpers.Contains(teacherIds, meeting.TeacherID) || helpers.Contains(meetingIds, meeting.ID) {
+			pinned[i] = true
+			continue
+		}
+		for n := 0; n < len(meeting.ClassID); n++ {
+			if helpers.Contains(classIds, meeting.ClassID[n]) {
+				pinned[i] = true
+				break
+			}
+		}
+	}
+
+	for i := 0; i < len(timetable); i++ {
+		if !pinned[i] {
+			continue
+		}
+		meeting := timetable[i]
+		group := p.subjectsInGroupWith(meeting.SubjectID)
+		for n := 0; n < len(timetable); n++ {
+			other := timetable[n]
+			if pinned[n] || other.DayOfTheWeek != meeting.DayOfTheWeek || other.Hour != meeting.Hour {
+				continue
+			}
+			if other.SubjectID == meeting.SubjectID || (other.Week == meeting.Week && helpers.Contains(group, other.SubjectID)) {
+				pinned[n] = true
+				if n < i {
+					// Na novo zaklenjeno srečanje lahko zaklene še druga, zato gremo čez urnik še enkrat.
+					i = -1
+					break
+				}
+			}
+		}
+	}
+
+	meetings := make([]ProtonMeeting, 0)
+	for i := 0; i < len(timetable); i++ {
+		if !pinned[i] {
+			continue
+		}
+		meeting := timetable[i]
+		meeting.PinnedAt = &ProtonSlot{Hour: meeting.Hour, DayOfTheWeek: meeting.DayOfTheWeek}
+		meetings = append(meetings, meeting)
+	}
+	return meetings
+}
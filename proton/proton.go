@@ -18,7 +18,9 @@ import (
 	"github.com/MeetPlan/MeetPlanBackend/helpers"
 	"github.com/MeetPlan/MeetPlanBackend/sql"
 	"go.uber.org/zap"
+	"math"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -34,16 +36,23 @@ const PROTON_REPEAT_POST_PROCESSING = 3 // Večja je številka, večja je možno
 //const PROTON_ALLOWED_HOLE_PATCHING_REPEAT_RATE = 200
 
 type protonImpl struct {
-	db     sql.SQL
+	db sql.SQL
+	// Pravila se ob vsaki zahtevi ponovno naložijo, zato je dostop do njih zaklenjen.
+	mutex  sync.RWMutex
 	config ProtonConfig
 	logger *zap.SugaredLogger
 }
 
 type Proton interface {
-	ManageAbsences(meetingId string) ([]TeacherTier, error)
+	ManageAbsences(meetingId string, systemConfig sql.Config) ([]TeacherTier, error)
+	PlanSubstitutions(absence sql.TeacherAbsence, systemConfig sql.Config) ([]SubstitutionAssignment, error)
+	ValidateSubstitutions(absence sql.TeacherAbsence, plan []SubstitutionAssignment, systemConfig sql.Config) error
 
-	NewProtonRule(rule ProtonRule) error
+	NewProtonRule(rule ProtonRule, userId string) error
 	GetProtonConfig() ProtonConfig
+	ReloadConfig() error
+	GetConfigVersions() ([]ProtonConfigSnapshot, error)
+	RestoreConfigVersion(version int, userId string) error
 
 	// Suita funkcij, ki skrbijo za pravila
 
@@ -71,14 +80,42 @@ type Proton interface {
 
 	AssembleMeetingsFromProtonMeetings(timetable []ProtonMeeting, systemConfig sql.Config) ([]sql.Meeting, error)
 
+	// Uvoz in izvoz urnikov
+
+	GetClassesOfSubject(subject sql.Subject, classes []sql.Class) ([]string, error)
+	ResolveTimetableRows(rows []TimetableRow) ([]ProtonMeeting, []TimetableImportError, error)
+	DescribeTimetable(timetable []ProtonMeeting) ([]TimetableRow, error)
+	ProtonMeetingsFromMeetings(meetings []sql.Meeting, firstMonday time.Time) ([]ProtonMeeting, error)
+
+	// Delna regeneracija urnika
+
+	PinMeetings(timetable []ProtonMeeting, classIds []string, teacherIds []string, meetingIds []string) []ProtonMeeting
+
 	FindIfHolesExist(timetable []ProtonMeeting) bool
 
-	SaveConfig(config ProtonConfig)
-	DeleteRule(ruleId string)
+	DeleteRule(ruleId string, userId string) error
 }
 
 func NewProton(db sql.SQL, logger *zap.SugaredLogger) (Proton, error) {
-	protonConfig, err := LoadConfig()
+	migrated, err := MigrateLegacyConfig(db)
+	if err != nil {
+		return nil, err
+	}
+	if migrated {
+		logger.Info("migrated legacy Proton configuration file to the database")
+	}
+	protonConfig, err := LoadConfig(db)
+	if err != nil {
+		return nil, err
+	}
+	// Vsak sprejet urnik mora imeti različico pravil, posledično ustvarimo začetni posnetek, če ta še ne obstaja.
+	if protonConfig.RuleSetVersion == 0 {
+		_, err = SaveSnapshot(db, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		protonConfig, err = LoadConfig(db)
+	}
 	return &protonImpl{db: db, config: protonConfig, logger: logger}, err
 }
 
@@ -89,6 +126,7 @@ type TierGradingList struct {
 	HasMeeting2HBefore bool
 	HasMeeting2HLater  bool
 	TeachesSameSubject bool
+	SubstitutionHours  int
 	Name               string
 }
 
@@ -99,7 +137,7 @@ type TeacherTier struct {
 	GradingList TierGradingList
 }
 
-func (p *protonImpl) ManageAbsences(meetingId string) ([]TeacherTier, error) {
+func (p *protonImpl) ManageAbsences(meetingId string, systemConfig sql.Config) ([]TeacherTier, error) {
 	teachers, err := p.db.GetTeachers()
 	if err != nil {
 		return make([]TeacherTier, 0), err
@@ -108,6 +146,34 @@ func (p *protonImpl) ManageAbsences(meetingId string) ([]TeacherTier, error) {
 	if err != nil {
 		return make([]TeacherTier, 0), err
 	}
+	absences, err := p.db.GetTeacherAbsencesBetween(originalMeeting.Date, originalMeeting.Date)
+	if err != nil {
+		return make([]TeacherTier, 0), err
+	}
+	absentTeachers := make([]string, 0)
+	for i := 0; i < len(absences); i++ {
+		absentTeachers = append(absentTeachers, absences[i].TeacherID)
+	}
+	return p.rankSubstitutes(originalMeeting, teachers, absentTeachers, systemConfig)
+}
+
+// substitutionHoursBetween vrne število ur nadomeščanj vsakega učitelja med start in end.
+func (p *protonImpl) substitutionHoursBetween(start time.Time, end time.Time) (map[string]int, error) {
+	stats, err := p.db.GetSubstitutionStats(start.Format("02-01-2006"), end.Format("02-01-2006"))
+	if err != nil {
+		return nil, err
+	}
+	hours := make(map[string]int)
+	for i := 0; i < len(stats); i++ {
+		hours[stats[i].TeacherID] = stats[i].Hours
+	}
+	return hours, nil
+}
+
+// rankSubstitutes razvrsti učitelje, ki lahko nadomeščajo srečanje. Učitelji iz excluded (npr. odsotni) niso upoštevani.
+// Učitelji, ki so v tem šolskem letu že veliko nadomeščali, dobijo nižjo oceno, tisti, ki so dosegli tedensko omejitev
+// nadomeščanj, pa niso upoštevani.
+func (p *protonImpl) rankSubstitutes(originalMeeting sql.Meeting, teachers []sql.User, excluded []string, systemConfig sql.Config) ([]TeacherTier, error) {
 	subject, err := p.db.GetSubject(originalMeeting.SubjectID)
 	if err != nil {
 		return make([]TeacherTier, 0), err
@@ -123,9 +189,28 @@ func (p *protonImpl) ManageAbsences(meetingId string) ([]TeacherTier, error) {
 			preferredTeachers = append(preferredTeachers, subject.TeacherID)
 		}
 	}
+	date, err := time.Parse("02-01-2006", originalMeeting.Date)
+	if err != nil {
+		return make([]TeacherTier, 0), err
+	}
+	yearHours, err := p.substitutionHoursBetween(FirstMondayOfSchoolYear(date), date)
+	if err != nil {
+		return make([]TeacherTier, 0), err
+	}
+	monday := mondayOf(date)
+	weekHours, err := p.substitutionHoursBetween(monday, monday.AddDate(0, 0, 6))
+	if err != nil {
+		return make([]TeacherTier, 0), err
+	}
 	var teacherTiers = make([]TierGradingList, 0)
 	for i := 0; i < len(teachers); i++ {
 		teacher := teachers[i]
+		if helpers.Contains(excluded, teacher.ID) {
+			continue
+		}
+		if systemConfig.MaxSubstitutionsPerWeek != 0 && weekHours[teacher.ID] >= systemConfig.MaxSubstitutionsPerWeek {
+			continue
+		}
 		teacherMeetings, err := p.db.GetMeetingsForTeacherOnSpecificDate(teacher.ID, originalMeeting.Date)
 		if err != nil {
 			return make([]TeacherTier, 0), err
@@ -135,6 +220,7 @@ func (p *protonImpl) ManageAbsences(meetingId string) ([]TeacherTier, error) {
 			HasMeetingBefore:   false,
 			HasMeetingLater:    false,
 			TeachesSameSubject: false,
+			SubstitutionHours:  yearHours[teacher.ID],
 			Name:               teacher.Name,
 		}
 		var hasSameHour = false
@@ -182,6 +268,7 @@ func (p *protonImpl) ManageAbsences(meetingId string) ([]TeacherTier, error) {
 		if teacherTier.HasMeetingBefore {
 			tierGrade += 3
 		}
+		tierGrade -= int(math.Round(float64(systemConfig.SubstitutionFairnessWeight) * float64(teacherTier.SubstitutionHours)))
 
 		var skip = true
 
@@ -214,8 +301,9 @@ func (p *protonImpl) ManageAbsences(meetingId string) ([]TeacherTier, error) {
 func (p *protonImpl) GetAllRulesForTeacher(teacherId string) []ProtonRule {
 	protonRules := make([]ProtonRule, 0)
 
-	for i := 0; i < len(p.config.Rules); i++ {
-		rule := p.config.Rules[i]
+	rules := p.GetProtonConfig().Rules
+	for i := 0; i < len(rules); i++ {
+		rule := rules[i]
 		for n := 0; n < len(rule.Objects); n++ {
 			object := rule.Objects[n]
 			if object.Type == "teacher" && object.ObjectID == teacherId {
@@ -230,8 +318,9 @@ func (p *protonImpl) GetAllRulesForTeacher(teacherId string) []ProtonRule {
 
 func (p *protonImpl) GetSubjectGroups() []ProtonRule {
 	protonRules := make([]ProtonRule, 0)
-	for i := 0; i < len(p.config.Rules); i++ {
-		protonRule := p.config.Rules[i]
+	rules := p.GetProtonConfig().Rules
+	for i := 0; i < len(rules); i++ {
+		protonRule := rules[i]
 		if protonRule.RuleType == 2 {
 			protonRules = append(protonRules, protonRule)
 		}
@@ -241,8 +330,9 @@ func (p *protonImpl) GetSubjectGroups() []ProtonRule {
 
 // SubjectHasDoubleHours preverja, če ima predmet blok ure.
 func (p *protonImpl) SubjectHasDoubleHours(subjectId string) bool {
-	for i := 0; i < len(p.config.Rules); i++ {
-		rule := p.config.Rules[i]
+	rules := p.GetProtonConfig().Rules
+	for i := 0; i < len(rules); i++ {
+		rule := rules[i]
 		if rule.RuleType == 4 {
 			for n := 0; n < len(rule.Objects); n++ {
 				object := rule.Objects[n]
@@ -294,7 +384,7 @@ func (p *protonImpl) GetSubjectsOfClass(timetable []ProtonMeeting, classStudents
 // GetSubjectsBeforeOrAfterClass retrieves all subjects, that are before or after the class (according to rule #3)
 func (p *protonImpl) GetSubjectsBeforeOrAfterClass() []string {
 	var subjects = make([]string, 0)
-	rules := p.config.Rules
+	rules := p.GetProtonConfig().Rules
 	for i := 0; i < len(rules); i++ {
 		rule := rules[i]
 		if rule.RuleType == 3 {
@@ -312,7 +402,7 @@ func (p *protonImpl) GetSubjectsBeforeOrAfterClass() []string {
 // GetSubjectsWithStackedHours retrieves all subjects, that have stacked hours (according to rule #4)
 func (p *protonImpl) GetSubjectsWithStackedHours() []string {
 	var subjects = make([]string, 0)
-	rules := p.config.Rules
+	rules := p.GetProtonConfig().Rules
 	for i := 0; i < len(rules); i++ {
 		rule := rules[i]
 		if rule.RuleType == 4 {
@@ -1350,6 +1440,15 @@ type ProtonMeeting struct {
 	Week         int
 	ClassID      []string
 	IsHalfHour   bool
+	// Zaklenjena srečanja (pri delni regeneraciji urnika) se ne smejo premakniti s svojega mesta.
+	PinnedAt *ProtonSlot `json:",omitempty"`
+	// Različica nabora pravil, s katero je bil urnik sestavljen
+	RuleSetVersion int `json:",omitempty"`
+}
+
+type ProtonSlot struct {
+	Hour         int
+	DayOfTheWeek int
 }
 
 // CheckIfProtonConfigIsOk preverja, če je trenuten timetable v redu sestavljen (v skladu z vsemi pravili).
@@ -1358,6 +1457,18 @@ func (p *protonImpl) CheckIfProtonConfigIsOk(timetable []ProtonMeeting) (bool, e
 	// Predpriprava
 	subjectGroups := p.GetSubjectGroups()
 
+	// 0. korak
+	// Vsa post-procesirna premikanja gredo skozi to funkcijo, posledično tukaj preprečimo premik zaklenjenih srečanj.
+	for t := 0; t < len(timetable); t++ {
+		meeting := timetable[t]
+		if meeting.PinnedAt == nil {
+			continue
+		}
+		if meeting.Hour != meeting.PinnedAt.Hour || meeting.DayOfTheWeek != meeting.PinnedAt.DayOfTheWeek {
+			return false, errors.New(fmt.Sprintf("pinned meeting %s was moved", meeting.ID))
+		}
+	}
+
 	// 1. korak
 	// Pojdimo čez vse učitelje in preverimo, da se nič ne prekriva in je urnik skladen z učiteljevimi urami.
 	teachers, err := p.db.GetTeachers()
@@ -1945,8 +2056,6 @@ func (p *protonImpl) AssembleMeetingsFromProtonMeetings(timetable []ProtonMeetin
 
 	p.logger.Info("last school date", lastSchoolDate)
 
-	weeks := OrderMeetingsByWeek(timetable)
-
 	newTimetable := make([]sql.Meeting, 0)
 
 	currentTime := time.Now()
@@ -1963,114 +2072,181 @@ func (p *protonImpl) AssembleMeetingsFromProtonMeetings(timetable []ProtonMeetin
 	}
 	firstMonday := firstSchoolDay.AddDate(0, 0, (-int(firstSchoolDay.Weekday()))+1)
 
+	events, err := p.db.GetCalendarEvents()
+	if err != nil {
+		return nil, err
+	}
+	calendar, err := NewSchoolCalendar(events, systemConfig.SchoolFreeDays, firstMonday)
+	if err != nil {
+		return nil, err
+	}
+
+	// Če koledar vsebuje ocenjevalna obdobja, se pouk začne in konča z njimi.
+	termsStart, termsEnd, ok := calendar.SchoolYearBounds()
+	if ok {
+		if termsStart.After(firstSchoolDay) {
+			firstSchoolDay = termsStart
+		}
+		if termsEnd.Before(lastSchoolDate) {
+			lastSchoolDate = termsEnd
+		}
+	}
+
 	p.logger.Debugw(
 		"calculated first school day",
 		"schoolDay", firstSchoolDay,
 		"currentTime", currentTime,
 		"firstMonday", firstMonday,
 		"firstSchoolWeekday", int(firstSchoolDay.Weekday()),
+		"lastSchoolDate", lastSchoolDate,
 	)
 
-	firstWeek := true
-	weekCount := 0
-
-	for {
-		triggeredEndOfSchool := false
-
-		for w := 0; w < len(weeks); w++ {
-			week := weeks[w]
+	subjects := make(map[string]sql.Subject)
 
-			for i := 0; i < len(week); i++ {
-				meeting := week[i]
+	for date := firstSchoolDay; !date.After(lastSchoolDate); date = date.AddDate(0, 0, 1) {
+		day, ok := calendar.TimetableDay(date)
+		if !ok {
+			p.logger.Debugw("skipped day without lessons", "date", date.Format("2006-01-02"))
+			continue
+		}
+		week := calendar.WeekOf(date)
+		nowDate := date.Format("02-01-2006")
 
-				if firstWeek && meeting.DayOfTheWeek < (int(firstSchoolDay.Weekday())-1) {
-					continue
-				}
+		for i := 0; i < len(timetable); i++ {
+			meeting := timetable[i]
+			if meeting.Week != week || meeting.DayOfTheWeek != day {
+				continue
+			}
 
-				date := firstMonday.AddDate(0, 0, weekCount*7+meeting.DayOfTheWeek)
-				p.logger.Debug(date)
+			if m[meeting.SubjectID] != nil && date.After(*m[meeting.SubjectID]) && !sameDay(date, *m[meeting.SubjectID]) {
+				continue
+			}
 
-				if (date.Day() == lastSchoolDate.Day() && date.Month() == lastSchoolDate.Month() && date.Year() == lastSchoolDate.Year()) || date.After(lastSchoolDate) {
-					p.logger.Info("triggered last school date")
-					triggeredEndOfSchool = true
+			// Srečanje odpade le, če imajo izjemo vsi razredi, ki jih zadeva.
+			if len(meeting.ClassID) != 0 {
+				var allClassesFree = true
+				for n := 0; n < len(meeting.ClassID); n++ {
+					if !calendar.IsClassFreeDay(date, meeting.ClassID[n]) {
+						allClassesFree = false
+						break
+					}
 				}
-
-				nowDate := date.Format("02-01-2006")
-
-				vacationDate := date.Format("2006-01-02")
-
-				if helpers.Contains(systemConfig.SchoolFreeDays, vacationDate) {
-					p.logger.Debugw("skipped meeting due to vacation", "date", vacationDate, "meeting", helpers.FmtSanitize(meeting))
+				if allClassesFree {
+					p.logger.Debugw("skipped meeting due to class exception", "date", nowDate, "meeting", helpers.FmtSanitize(meeting))
 					continue
 				}
+			}
 
-				subject, err := p.db.GetSubject(meeting.SubjectID)
+			subject, exists := subjects[meeting.SubjectID]
+			if !exists {
+				subject, err = p.db.GetSubject(meeting.SubjectID)
 				if err != nil {
 					return nil, err
 				}
-
-				newTimetable = append(newTimetable, sql.Meeting{
-					MeetingName:         meeting.SubjectName,
-					TeacherID:           meeting.TeacherID,
-					SubjectID:           meeting.SubjectID,
-					Hour:                meeting.Hour,
-					Location:            subject.Location,
-					Date:                nowDate,
-					IsMandatory:         true,
-					URL:                 "",
-					Details:             "",
-					IsSubstitution:      false,
-					IsGrading:           false,
-					IsWrittenAssessment: false,
-					IsTest:              false,
-					IsCorrectionTest:    false,
-					IsBeta:              true,
-				})
+				subjects[meeting.SubjectID] = subject
 			}
-			firstWeek = false
-			weekCount++
 
-			if triggeredEndOfSchool {
-				break
-			}
-		}
-
-		if triggeredEndOfSchool {
-			p.logger.Debug("triggered end of school")
-			break
+			newTimetable = append(newTimetable, sql.Meeting{
+				MeetingName:         meeting.SubjectName,
+				TeacherID:           meeting.TeacherID,
+				SubjectID:           meeting.SubjectID,
+				Hour:                meeting.Hour,
+				Location:            subject.Location,
+				Date:                nowDate,
+				IsMandatory:         true,
+				URL:                 "",
+				Details:             "",
+				IsSubstitution:      false,
+				IsGrading:           false,
+				IsWrittenAssessment: false,
+				IsTest:              false,
+				IsCorrectionTest:    false,
+				IsBeta:              true,
+				ProtonConfigVersion: meetingRuleSetVersion(meeting),
+			})
 		}
 	}
 
 	return newTimetable, nil
 }
 
-func (p *protonImpl) NewProtonRule(rule ProtonRule) error {
-	config, err := AddNewRule(p.config, rule)
+func (p *protonImpl) NewProtonRule(rule ProtonRule, userId string) error {
+	config, err := AddNewRule(p.db, rule, userId)
 	if err != nil {
 		return err
 	}
-	p.config = config
+	p.setConfig(config)
 	return nil
 }
 
-func (p *protonImpl) SaveConfig(config ProtonConfig) {
-	err := SaveConfig(config)
+func (p *protonImpl) DeleteRule(ruleId string, userId string) error {
+	config, err := RemoveRule(p.db, ruleId, userId)
 	if err != nil {
-		return
+		return err
 	}
-	p.config = config
+	p.setConfig(config)
+	return nil
+}
+
+func (p *protonImpl) RestoreConfigVersion(version int, userId string) error {
+	config, err := RestoreConfig(p.db, version, userId)
+	if err != nil {
+		return err
+	}
+	p.setConfig(config)
+	return nil
+}
+
+// ReloadConfig ponovno prebere pravila iz podatkovne baze, saj jih je lahko spremenila druga instanca strežnika.
+func (p *protonImpl) ReloadConfig() error {
+	config, err := LoadConfig(p.db)
+	if err != nil {
+		return err
+	}
+	p.setConfig(config)
+	return nil
 }
 
-func (p *protonImpl) DeleteRule(ruleId string) {
-	for i := 0; i < len(p.config.Rules); i++ {
-		if p.config.Rules[i].ID == ruleId {
-			p.config.Rules = helpers.Remove(p.config.Rules, i)
-			SaveConfig(p.config)
-			return
+func (p *protonImpl) GetConfigVersions() ([]ProtonConfigSnapshot, error) {
+	versions, err := p.db.GetProtonConfigVersions()
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make([]ProtonConfigSnapshot, 0)
+	for i := 0; i < len(versions); i++ {
+		snapshot, err := snapshotFromSQL(versions[i])
+		if err != nil {
+			return nil, err
 		}
+		snapshots = append(snapshots, snapshot)
 	}
+	return snapshots, nil
 }
 
 func (p *protonImpl) GetProtonConfig() ProtonConfig {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
 	return p.config
 }
+
+func (p *protonImpl) setConfig(config ProtonConfig) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.config = config
+}
+
+// meetingRuleSetVersion vrne različico pravil, s katero je bilo srečanje ustvarjeno, oziroma nil za uvožene urnike.
+func meetingRuleSetVersion(meeting ProtonMeeting) *int {
+	if meeting.RuleSetVersion == 0 {
+		return nil
+	}
+	version := meeting.RuleSetVersion
+	return &version
+}
+
+// StampRuleSetVersion vsem srečanjem urnika zapiše različico pravil, s katero je bil urnik sestavljen.
+func StampRuleSetVersion(timetable []ProtonMeeting, version int) {
+	for i := 0; i < len(timetable); i++ {
+		timetable[i].RuleSetVersion = version
+	}
+}
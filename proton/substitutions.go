@@ -0,0 +1,369 @@
+/// This file is a part of MeetPlan Proton, which is a part of MeetPlanBackend (https://github.com/MeetPlan/MeetPlanBackend).
+///
+/// Copyright (c) 2022, Mitja Ševerkar <mytja@protonmail.com> and The MeetPlan Team.
+/// All rights reserved.
+/// Use of this source code is governed by the GNU AGPLv3 license, that can be found in the LICENSE file.
+
+package proton
+
+import (
+	"fmt"
+	"github.com/MeetPlan/MeetPlanBackend/helpers"
+	"github.com/MeetPlan/MeetPlanBackend/sql"
+	"math"
+	"time"
+)
+
+// Najvišja možna ocena (tier) učitelja iz rankSubstitutes
+const PROTON_MAX_SUBSTITUTE_TIER = 13
+
+// Cena vsakega dodatnega nadomeščanja istega učitelja. Večja je številka, bolj enakomerno se nadomeščanja porazdelijo,
+// tudi če to pomeni, da srečanje nadomešča učitelj z nižjo oceno.
+const PROTON_SUBSTITUTION_LOAD_WEIGHT = 4
+
+type SubstitutionAssignment struct {
+	MeetingID           string
+	MeetingName         string
+	Date                string
+	Hour                int
+	OriginalTeacherID   string
+	SubstituteTeacherID *string
+	SubstituteName      string
+	Tier                int
+}
+
+// Preprost pretok z minimalno ceno (successive shortest paths z Bellman-Fordom).
+// Grafi pri nadomeščanjih so majhni (nekaj deset srečanj in učiteljev), zato je to povsem dovolj hitro.
+
+type flowEdge struct {
+	to   int
+	rev  int
+	cap  int
+	cost int
+}
+
+type minCostFlow struct {
+	graph [][]flowEdge
+}
+
+func newMinCostFlow(nodes int) *minCostFlow {
+	return &minCostFlow{graph: make([][]flowEdge, nodes)}
+}
+
+func (f *minCostFlow) addNode() int {
+	f.graph = append(f.graph, make([]flowEdge, 0))
+	return len(f.graph) - 1
+}
+
+func (f *minCostFlow) addEdge(from int, to int, cap int, cost int) int {
+	f.graph[from] = append(f.graph[from], flowEdge{to: to, rev: len(f.graph[to]), cap: cap, cost: cost})
+	f.graph[to] = append(f.graph[to], flowEdge{to: from, rev: len(f.graph[from]) - 1, cap: 0, cost: -cost})
+	return len(f.graph[from]) - 1
+}
+
+// Oznaka za vozlišče, ki še ni bilo doseženo. Povratne povezave imajo negativno ceno, zato -1 ni primerna oznaka.
+const flowUnreachable = math.MaxInt32
+
+// run poveča pretok od source do sink, kolikor je mogoče, in vrne pretok ter njegovo skupno ceno.
+func (f *minCostFlow) run(source int, sink int) (int, int) {
+	flow := 0
+	cost := 0
+	for {
+		dist := make([]int, len(f.graph))
+		prevNode := make([]int, len(f.graph))
+		prevEdge := make([]int, len(f.graph))
+		for i := 0; i < len(dist); i++ {
+			dist[i] = flowUnreachable
+		}
+		dist[source] = 0
+		inQueue := make([]bool, len(f.graph))
+		queue := []int{source}
+		inQueue[source] = true
+		for len(queue) != 0 {
+			node := queue[0]
+			queue = queue[1:]
+			inQueue[node] = false
+			for i := 0; i < len(f.graph[node]); i++ {
+				edge := f.graph[node][i]
+				if edge.cap <= 0 {
+					continue
+				}
+				if dist[node]+edge.cost < dist[edge.to] {
+					dist[edge.to] = dist[node] + edge.cost
+					prevNode[edge.to] = node
+					prevEdge[edge.to] = i
+					if !inQueue[edge.to] {
+						queue = append(queue, edge.to)
+						inQueue[edge.to] = true
+					}
+				}
+			}
+		}
+		if dist[sink] == flowUnreachable {
+			return flow, cost
+		}
+		// Vse kapacitete so 1, zato vsaka pot poveča pretok za 1.
+		for node := sink; node != source; node = prevNode[node] {
+			edge := &f.graph[prevNode[node]][prevEdge[node]]
+			edge.cap--
+			f.graph[node][edge.rev].cap++
+		}
+		flow++
+		cost += dist[sink]
+	}
+}
+
+// dateInRange preveri, ali je datum med start in end, vključno z obema (vsi v obliki 02-01-2006).
+func dateInRange(date string, start string, end string) (bool, error) {
+	d, err := time.Parse("02-01-2006", date)
+	if err != nil {
+		return false, err
+	}
+	s, err := time.Parse("02-01-2006", start)
+	if err != nil {
+		return false, err
+	}
+	e, err := time.Parse("02-01-2006", end)
+	if err != nil {
+		return false, err
+	}
+	return !d.Before(s) && !d.After(e), nil
+}
+
+// absentTeachersOn vrne učitelje, ki so na podan datum odsotni, vključno z učiteljem iz absence.
+func absentTeachersOn(date string, absence sql.TeacherAbsence, absences []sql.TeacherAbsence) ([]string, error) {
+	absentTeachers := []string{absence.TeacherID}
+	for n := 0; n < len(absences); n++ {
+		otherAbsence := absences[n]
+		absent, err := dateInRange(date, otherAbsence.StartDate, otherAbsence.EndDate)
+		if err != nil {
+			return nil, err
+		}
+		if absent && !helpers.Contains(absentTeachers, otherAbsence.TeacherID) {
+			absentTeachers = append(absentTeachers, otherAbsence.TeacherID)
+		}
+	}
+	return absentTeachers, nil
+}
+
+type substituteCandidate struct {
+	meeting int
+	edge    int
+	tier    TeacherTier
+}
+
+// PlanSubstitutions poišče vsa srečanja, ki jih zadeva odsotnost učitelja, in jim dodeli nadomestne učitelje.
+//
+// Namesto da bi vsakemu srečanju dodelili najbolje ocenjenega učitelja, rešimo problem kot pretok z minimalno ceno:
+// cena dodelitve je razlika do najvišje ocene, vsako dodatno nadomeščanje istega učitelja pa stane več od prejšnjega.
+// Tako dobimo optimalno razporeditev, pri kateri nihče ne nadomešča dveh srečanj hkrati in so nadomeščanja enakomerno porazdeljena.
+// Srečanja brez možnega nadomestnega učitelja ostanejo brez dodelitve (SubstituteTeacherID je nil).
+func (p *protonImpl) PlanSubstitutions(absence sql.TeacherAbsence, systemConfig sql.Config) ([]SubstitutionAssignment, error) {
+	meetings, err := p.db.GetMeetingsForTeacherBetween(absence.TeacherID, absence.StartDate, absence.EndDate)
+	if err != nil {
+		return nil, err
+	}
+	teachers, err := p.db.GetTeachers()
+	if err != nil {
+		return nil, err
+	}
+	absences, err := p.db.GetTeacherAbsencesBetween(absence.StartDate, absence.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	flow := newMinCostFlow(2)
+	source := 0
+	sink := 1
+
+	slots := make(map[string]int)
+	teacherNodes := make(map[string]int)
+	teacherWeekNodes := make(map[string]int)
+	weekHours := make(map[string]map[string]int)
+	candidates := make([]substituteCandidate, 0)
+	meetingNodes := make([]int, len(meetings))
+
+	for i := 0; i < len(meetings); i++ {
+		meeting := meetings[i]
+		meetingNodes[i] = flow.addNode()
+		flow.addEdge(source, meetingNodes[i], 1, 0)
+
+		absentTeachers, err := absentTeachersOn(meeting.Date, absence, absences)
+		if err != nil {
+			return nil, err
+		}
+
+		tiers, err := p.rankSubstitutes(meeting, teachers, absentTeachers, systemConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		date, err := time.Parse("02-01-2006", meeting.Date)
+		if err != nil {
+			return nil, err
+		}
+		monday := mondayOf(date)
+		week := monday.Format("02-01-2006")
+		if _, ok := weekHours[week]; !ok && systemConfig.MaxSubstitutionsPerWeek != 0 {
+			weekHours[week], err = p.substitutionHoursBetween(monday, monday.AddDate(0, 0, 6))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for n := 0; n < len(tiers); n++ {
+			tier := tiers[n]
+			slotKey := fmt.Sprintf("%s|%s|%d", tier.TeacherID, meeting.Date, meeting.Hour)
+			slot, ok := slots[slotKey]
+			if !ok {
+				slot = flow.addNode()
+				slots[slotKey] = slot
+
+				teacherNode, ok := teacherNodes[tier.TeacherID]
+				if !ok {
+					teacherNode = flow.addNode()
+					teacherNodes[tier.TeacherID] = teacherNode
+
+					// Nadomeščanja, ki jih učitelj v tem obdobju že ima, štejejo v njegovo obremenitev.
+					teacherMeetings, err := p.db.GetMeetingsForTeacherBetween(tier.TeacherID, absence.StartDate, absence.EndDate)
+					if err != nil {
+						return nil, err
+					}
+					load := 0
+					for x := 0; x < len(teacherMeetings); x++ {
+						if teacherMeetings[x].IsSubstitution {
+							load++
+						}
+					}
+					for x := 0; x < len(meetings); x++ {
+						flow.addEdge(teacherNode, sink, 1, (load+x)*PROTON_SUBSTITUTION_LOAD_WEIGHT)
+					}
+				}
+				if systemConfig.MaxSubstitutionsPerWeek == 0 {
+					flow.addEdge(slot, teacherNode, 1, 0)
+				} else {
+					// Tedenska omejitev velja tudi za nadomeščanja, ki jih dodelimo v tem načrtu.
+					teacherWeekKey := fmt.Sprintf("%s|%s", tier.TeacherID, week)
+					teacherWeekNode, ok := teacherWeekNodes[teacherWeekKey]
+					if !ok {
+						teacherWeekNode = flow.addNode()
+						teacherWeekNodes[teacherWeekKey] = teacherWeekNode
+						flow.addEdge(teacherWeekNode, teacherNode, systemConfig.MaxSubstitutionsPerWeek-weekHours[week][tier.TeacherID], 0)
+					}
+					flow.addEdge(slot, teacherWeekNode, 1, 0)
+				}
+			}
+			edge := flow.addEdge(meetingNodes[i], slot, 1, PROTON_MAX_SUBSTITUTE_TIER-tier.Tier)
+			candidates = append(candidates, substituteCandidate{meeting: i, edge: edge, tier: tier})
+		}
+	}
+
+	flow.run(source, sink)
+
+	assignments := make([]SubstitutionAssignment, len(meetings))
+	for i := 0; i < len(meetings); i++ {
+		assignments[i] = SubstitutionAssignment{
+			MeetingID:         meetings[i].ID,
+			MeetingName:       meetings[i].MeetingName,
+			Date:              meetings[i].Date,
+			Hour:              meetings[i].Hour,
+			OriginalTeacherID: meetings[i].TeacherID,
+		}
+	}
+	for i := 0; i < len(candidates); i++ {
+		candidate := candidates[i]
+		if flow.graph[meetingNodes[candidate.meeting]][candidate.edge].cap != 0 {
+			continue
+		}
+		teacherId := candidate.tier.TeacherID
+		assignments[candidate.meeting].SubstituteTeacherID = &teacherId
+		assignments[candidate.meeting].SubstituteName = candidate.tier.Name
+		assignments[candidate.meeting].Tier = candidate.tier.Tier
+	}
+	return assignments, nil
+}
+
+// ValidateSubstitutions preveri načrt nadomeščanj (npr. ročno popravljen predogled), preden se uveljavi.
+// Vsako nadomeščanje mora zadevati srečanje odsotnega učitelja, nadomestni učitelj pa mora biti na voljo po enakih
+// merilih kot pri PlanSubstitutions (ni odsoten, nima srečanja ob isti uri, ni presegel tedenske omejitve).
+// Načrt tudi ne sme istemu učitelju dodeliti dveh srečanj ob isti uri.
+func (p *protonImpl) ValidateSubstitutions(absence sql.TeacherAbsence, plan []SubstitutionAssignment, systemConfig sql.Config) error {
+	meetings, err := p.db.GetMeetingsForTeacherBetween(absence.TeacherID, absence.StartDate, absence.EndDate)
+	if err != nil {
+		return err
+	}
+	affected := make(map[string]sql.Meeting)
+	for i := 0; i < len(meetings); i++ {
+		affected[meetings[i].ID] = meetings[i]
+	}
+	teachers, err := p.db.GetTeachers()
+	if err != nil {
+		return err
+	}
+	absences, err := p.db.GetTeacherAbsencesBetween(absence.StartDate, absence.EndDate)
+	if err != nil {
+		return err
+	}
+
+	slots := make(map[string]bool)
+	planned := make(map[string]int)
+	weekHours := make(map[string]map[string]int)
+	for i := 0; i < len(plan); i++ {
+		assignment := plan[i]
+		if assignment.SubstituteTeacherID == nil {
+			continue
+		}
+		teacherId := *assignment.SubstituteTeacherID
+		meeting, ok := affected[assignment.MeetingID]
+		if !ok {
+			return fmt.Errorf("meeting %s is not affected by this absence", assignment.MeetingID)
+		}
+
+		slotKey := fmt.Sprintf("%s|%s|%d", teacherId, meeting.Date, meeting.Hour)
+		if slots[slotKey] {
+			return fmt.Errorf("teacher %s is assigned to two meetings on %s, hour %d", teacherId, meeting.Date, meeting.Hour)
+		}
+		slots[slotKey] = true
+
+		absentTeachers, err := absentTeachersOn(meeting.Date, absence, absences)
+		if err != nil {
+			return err
+		}
+		tiers, err := p.rankSubstitutes(meeting, teachers, absentTeachers, systemConfig)
+		if err != nil {
+			return err
+		}
+		var available = false
+		for n := 0; n < len(tiers); n++ {
+			if tiers[n].TeacherID == teacherId {
+				available = true
+				break
+			}
+		}
+		if !available {
+			return fmt.Errorf("teacher %s can't substitute meeting %s on %s, hour %d", teacherId, meeting.ID, meeting.Date, meeting.Hour)
+		}
+
+		if systemConfig.MaxSubstitutionsPerWeek == 0 {
+			continue
+		}
+		date, err := time.Parse("02-01-2006", meeting.Date)
+		if err != nil {
+			return err
+		}
+		monday := mondayOf(date)
+		week := monday.Format("02-01-2006")
+		if _, ok := weekHours[week]; !ok {
+			weekHours[week], err = p.substitutionHoursBetween(monday, monday.AddDate(0, 0, 6))
+			if err != nil {
+				return err
+			}
+		}
+		teacherWeekKey := fmt.Sprintf("%s|%s", teacherId, week)
+		planned[teacherWeekKey]++
+		if weekHours[week][teacherId]+planned[teacherWeekKey] > systemConfig.MaxSubstitutionsPerWeek {
+			return fmt.Errorf("teacher %s would exceed the weekly substitution limit in the week of %s", teacherId, week)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package proton
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// assignmentFlow zgradi dvodelni graf srečanj in učiteljev, kot ga gradi PlanSubstitutions. costs[m][t] je cena
+// dodelitve učitelja t srečanju m (-1 pomeni, da učitelj srečanja ne more nadomeščati), load pa cena vsakega dodatnega
+// nadomeščanja istega učitelja.
+func assignmentFlow(costs [][]int, teachers int, load int) (int, int) {
+	flow := newMinCostFlow(2)
+	teacherNodes := make([]int, teachers)
+	for t := 0; t < teachers; t++ {
+		teacherNodes[t] = flow.addNode()
+		for x := 0; x < len(costs); x++ {
+			flow.addEdge(teacherNodes[t], 1, 1, x*load)
+		}
+	}
+	for m := 0; m < len(costs); m++ {
+		meeting := flow.addNode()
+		flow.addEdge(0, meeting, 1, 0)
+		for t := 0; t < teachers; t++ {
+			if costs[m][t] >= 0 {
+				flow.addEdge(meeting, teacherNodes[t], 1, costs[m][t])
+			}
+		}
+	}
+	return flow.run(0, 1)
+}
+
+// bruteForceAssignment preizkusi vse dodelitve in vrne največje število dodeljenih srečanj in najnižjo ceno zanje.
+func bruteForceAssignment(costs [][]int, teachers int, load int) (int, int) {
+	bestFlow, bestCost := 0, 0
+	counts := make([]int, teachers)
+	var search func(m int, flow int, cost int)
+	search = func(m int, flow int, cost int) {
+		if m == len(costs) {
+			if flow > bestFlow || (flow == bestFlow && cost < bestCost) {
+				bestFlow, bestCost = flow, cost
+			}
+			return
+		}
+		search(m+1, flow, cost)
+		for t := 0; t < teachers; t++ {
+			if costs[m][t] < 0 {
+				continue
+			}
+			extra := costs[m][t] + counts[t]*load
+			counts[t]++
+			search(m+1, flow+1, cost+extra)
+			counts[t]--
+		}
+	}
+	search(0, 0, 0)
+	return bestFlow, bestCost
+}
+
+func TestMinCostFlowAssignment(t *testing.T) {
+	tests := []struct {
+		name     string
+		costs    [][]int
+		teachers int
+		load     int
+		flow     int
+		cost     int
+	}{
+		{"single", [][]int{{3}}, 1, 0, 1, 3},
+		{"no candidate", [][]int{{-1}, {2}}, 1, 0, 1, 2},
+		{"greedy is not optimal", [][]int{{1, 2}, {1, 5}}, 2, 10, 2, 3},
+		{"reroute through reverse edge", [][]int{{0, 10, -1}, {1, -1, 1}, {-1, 0, 0}}, 3, 0, 3, 1},
+		{"load spreads substitutions", [][]int{{0, 1}, {0, 1}, {0, 1}}, 2, 4, 3, 5},
+		{"one teacher for everything", [][]int{{2}, {2}, {2}}, 1, 4, 3, 18},
+		{"more meetings than teachers", [][]int{{5, -1}, {-1, 3}, {1, 1}}, 2, 100, 3, 109},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			flow, cost := assignmentFlow(test.costs, test.teachers, test.load)
+			if flow != test.flow || cost != test.cost {
+				t.Fatalf("got flow %d with cost %d, expected flow %d with cost %d", flow, cost, test.flow, test.cost)
+			}
+		})
+	}
+}
+
+func TestMinCostFlowMatchesBruteForce(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 300; i++ {
+		meetings := 1 + random.Intn(5)
+		teachers := 1 + random.Intn(4)
+		load := random.Intn(5)
+		costs := make([][]int, meetings)
+		for m := 0; m < meetings; m++ {
+			costs[m] = make([]int, teachers)
+			for n := 0; n < teachers; n++ {
+				costs[m][n] = random.Intn(PROTON_MAX_SUBSTITUTE_TIER+2) - 1
+			}
+		}
+		flow, cost := assignmentFlow(costs, teachers, load)
+		expectedFlow, expectedCost := bruteForceAssignment(costs, teachers, load)
+		if flow != expectedFlow || cost != expectedCost {
+			t.Fatalf("costs %v, load %d: got flow %d with cost %d, expected flow %d with cost %d", costs, load, flow, cost, expectedFlow, expectedCost)
+		}
+	}
+}
+
+// Pot s skupno ceno -1 mora biti najdena, čeprav je -1 nekoč služila kot oznaka za nedosegljivo vozlišče.
+func TestMinCostFlowNegativeDistance(t *testing.T) {
+	flow := newMinCostFlow(3)
+	flow.addEdge(0, 2, 1, 0)
+	flow.addEdge(2, 1, 1, -1)
+	flow.addEdge(0, 1, 1, 5)
+	total, cost := flow.run(0, 1)
+	if total != 2 || cost != 4 {
+		t.Fatalf("got flow %d with cost %d, expected flow 2 with cost 4", total, cost)
+	}
+}
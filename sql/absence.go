@@ -1,5 +1,27 @@
 package sql
 
+// Vrste odsotnosti. Prisotnost (PRESENT) se ne shranjuje, učenec brez vrstice v tabeli absence je prisoten.
+// UNMANAGED so uporabljale starejše različice za še nevpisano prisotnost (glej migrations/delete-unmanaged-absences.sql).
+const ABSENCE_UNMANAGED = "UNMANAGED"
+const ABSENCE_PRESENT = "PRESENT"
+const ABSENCE_ABSENT = "ABSENT"
+const ABSENCE_LATE = "LATE"
+const ABSENCE_EXCUSED_EARLY_LEAVE = "EXCUSED_EARLY_LEAVE"
+const ABSENCE_SCHOOL_ACTIVITY = "SCHOOL_ACTIVITY"
+
+var AbsenceTypes = []string{
+	ABSENCE_PRESENT,
+	ABSENCE_ABSENT,
+	ABSENCE_LATE,
+	ABSENCE_EXCUSED_EARLY_LEAVE,
+	ABSENCE_SCHOOL_ACTIVITY,
+}
+
+// IsExcusableAbsenceType vrne true za vrste odsotnosti, ki jih je mogoče opravičiti.
+func IsExcusableAbsenceType(absenceType string) bool {
+	return absenceType == ABSENCE_ABSENT || absenceType == ABSENCE_LATE || absenceType == ABSENCE_EXCUSED_EARLY_LEAVE
+}
+
 type Absence struct {
 	ID          string
 	UserID      string `db:"user_id"`
@@ -7,6 +29,8 @@ type Absence struct {
 	MeetingID   string `db:"meeting_id"`
 	AbsenceType string `db:"absence_type"`
 	IsExcused   bool   `db:"is_excused"`
+	// Opravičilo, s katerim je bila odsotnost opravičena
+	ExcuseID *string `db:"excuse_id"`
 
 	CreatedAt string `db:"created_at"`
 	UpdatedAt string `db:"updated_at"`
@@ -22,6 +46,14 @@ func (db *sqlImpl) GetAbsenceForUserMeeting(meeting_id string, user_id string) (
 	return absence, err
 }
 
+func (db *sqlImpl) GetAbsencesForMeeting(meetingId string) (absences []Absence, err error) {
+	err = db.db.Select(&absences, "SELECT * FROM absence WHERE meeting_id=$1", meetingId)
+	if absences == nil {
+		absences = make([]Absence, 0)
+	}
+	return absences, err
+}
+
 func (db *sqlImpl) GetAbsencesForUser(user_id string) (absence []Absence, err error) {
 	err = db.db.Select(&absence, "SELECT * FROM absence WHERE user_id=$1 ORDER BY id ASC", user_id)
 	return absence, err
@@ -34,18 +66,72 @@ func (db *sqlImpl) GetAllAbsences(id string) (absences []Absence, err error) {
 
 func (db *sqlImpl) InsertAbsence(absence Absence) error {
 	_, err := db.db.NamedExec(
-		"INSERT INTO absence (user_id, teacher_id, meeting_id, absence_type, is_excused) VALUES (:user_id, :teacher_id, :meeting_id, :absence_type, :is_excused)",
+		"INSERT INTO absence (user_id, teacher_id, meeting_id, absence_type, is_excused, excuse_id) VALUES (:user_id, :teacher_id, :meeting_id, :absence_type, :is_excused, :excuse_id)",
 		absence)
 	return err
 }
 
 func (db *sqlImpl) UpdateAbsence(absence Absence) error {
 	_, err := db.db.NamedExec(
-		"UPDATE absence SET user_id=:user_id, teacher_id=:teacher_id, meeting_id=:meeting_id, absence_type=:absence_type, is_excused=:is_excused WHERE id=:id",
+		"UPDATE absence SET user_id=:user_id, teacher_id=:teacher_id, meeting_id=:meeting_id, absence_type=:absence_type, is_excused=:is_excused, excuse_id=:excuse_id WHERE id=:id",
 		absence)
 	return err
 }
 
+// SetAbsencesForMeeting v eni transakciji zapiše prisotnost učencev na srečanju.
+// Prisotnim učencem se vrstica izbriše, ostalim pa se posodobi ali ustvari.
+func (db *sqlImpl) SetAbsencesForMeeting(meetingId string, absences []Absence) error {
+	tx, err := db.db.Beginx()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE meetings SET attendance_taken_at=COALESCE(attendance_taken_at, now()) WHERE id=$1", meetingId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	for i := 0; i < len(absences); i++ {
+		absence := absences[i]
+		absence.MeetingID = meetingId
+		if absence.AbsenceType == ABSENCE_PRESENT {
+			_, err = tx.Exec("DELETE FROM absence WHERE meeting_id=$1 AND user_id=$2", meetingId, absence.UserID)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+			continue
+		}
+		result, err := tx.NamedExec(
+			"UPDATE absence SET teacher_id=:teacher_id, absence_type=:absence_type, is_excused=:is_excused, excuse_id=:excuse_id WHERE meeting_id=:meeting_id AND user_id=:user_id",
+			absence)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if rows != 0 {
+			continue
+		}
+		_, err = tx.NamedExec(
+			"INSERT INTO absence (user_id, teacher_id, meeting_id, absence_type, is_excused, excuse_id) VALUES (:user_id, :teacher_id, :meeting_id, :absence_type, :is_excused, :excuse_id)",
+			absence)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (db *sqlImpl) DeleteAbsence(id string) error {
+	_, err := db.db.Exec("DELETE FROM absence WHERE id=$1", id)
+	return err
+}
+
 func (db *sqlImpl) DeleteAbsencesForTeacher(userId string) {
 	db.db.Exec("DELETE FROM absence WHERE teacher_id=$1", userId)
 }
@@ -0,0 +1,95 @@
+package sql
+
+const ABSENCE_ALERT_UNEXCUSED = 0
+const ABSENCE_ALERT_TOTAL = 1
+const ABSENCE_ALERT_ATTENDANCE = 2
+
+// SubjectAbsenceStats povzema odsotnosti enega učenca pri enem predmetu.
+type SubjectAbsenceStats struct {
+	SubjectID      string `db:"subject_id"`
+	Excused        int    `db:"excused"`
+	Unexcused      int    `db:"unexcused"`
+	Late           int    `db:"late"`
+	SchoolActivity int    `db:"school_activity"`
+}
+
+type SubjectMeetingCount struct {
+	SubjectID string `db:"subject_id"`
+	Held      int    `db:"held"`
+}
+
+// AbsenceAlert beleži, da je učenec presegel prag odsotnosti, da o tem obvestimo samo enkrat.
+type AbsenceAlert struct {
+	ID        string
+	UserID    string  `db:"user_id"`
+	AlertType int     `db:"alert_type"`
+	SubjectID *string `db:"subject_id"`
+	// Začetek obdobja (šolskega leta), v katerem je bil prag presežen
+	PeriodStart string `db:"period_start"`
+
+	CreatedAt string `db:"created_at"`
+	UpdatedAt string `db:"updated_at"`
+}
+
+// GetAbsenceStatsForUser vrne odsotnosti učenca po predmetih med start in end, vključno z obema datumoma.
+// Zgodnji odhod je vedno opravičen.
+func (db *sqlImpl) GetAbsenceStatsForUser(userId string, start string, end string) (stats []SubjectAbsenceStats, err error) {
+	err = db.db.Select(
+		&stats,
+		`SELECT meetings.subject_id,
+			COUNT(*) FILTER (WHERE absence.absence_type='`+ABSENCE_EXCUSED_EARLY_LEAVE+`' OR (absence.absence_type='`+ABSENCE_ABSENT+`' AND absence.is_excused)) AS excused,
+			COUNT(*) FILTER (WHERE absence.absence_type='`+ABSENCE_ABSENT+`' AND NOT COALESCE(absence.is_excused, false)) AS unexcused,
+			COUNT(*) FILTER (WHERE absence.absence_type='`+ABSENCE_LATE+`') AS late,
+			COUNT(*) FILTER (WHERE absence.absence_type='`+ABSENCE_SCHOOL_ACTIVITY+`') AS school_activity
+		FROM absence JOIN meetings ON meetings.id=absence.meeting_id
+		WHERE absence.user_id=$1 AND meetings.is_beta=false
+			AND to_date(meetings.date, 'DD-MM-YYYY') BETWEEN to_date($2, 'DD-MM-YYYY') AND to_date($3, 'DD-MM-YYYY')
+		GROUP BY meetings.subject_id`,
+		userId, start, end,
+	)
+	if stats == nil {
+		stats = make([]SubjectAbsenceStats, 0)
+	}
+	return stats, err
+}
+
+// GetHeldMeetingCounts vrne število izvedenih srečanj po predmetih med start in end. Srečanje je izvedeno, ko je
+// zanj vpisana prisotnost (attendance_taken_at), zato se prihodnje ure in ure brez prisotnosti ne štejejo.
+func (db *sqlImpl) GetHeldMeetingCounts(start string, end string) (counts []SubjectMeetingCount, err error) {
+	err = db.db.Select(
+		&counts,
+		`SELECT subject_id, COUNT(*) AS held FROM meetings
+		WHERE is_beta=false AND attendance_taken_at IS NOT NULL AND to_date(date, 'DD-MM-YYYY') BETWEEN to_date($1, 'DD-MM-YYYY') AND to_date($2, 'DD-MM-YYYY')
+		GROUP BY subject_id`,
+		start, end,
+	)
+	if counts == nil {
+		counts = make([]SubjectMeetingCount, 0)
+	}
+	return counts, err
+}
+
+// InsertAbsenceAlert zabeleži opozorilo. Vrne false, če je bilo enako opozorilo v tem obdobju že zabeleženo.
+func (db *sqlImpl) InsertAbsenceAlert(alert AbsenceAlert) (bool, error) {
+	result, err := db.db.NamedExec(
+		`INSERT INTO absence_alerts (user_id, alert_type, subject_id, period_start)
+			SELECT :user_id, :alert_type, :subject_id, :period_start
+			WHERE NOT EXISTS (
+				SELECT 1 FROM absence_alerts WHERE user_id=:user_id AND alert_type=:alert_type
+					AND subject_id IS NOT DISTINCT FROM :subject_id AND period_start=:period_start
+			)`,
+		alert)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows != 0, err
+}
+
+func (db *sqlImpl) GetAbsenceAlertsForUser(userId string) (alerts []AbsenceAlert, err error) {
+	err = db.db.Select(&alerts, "SELECT * FROM absence_alerts WHERE user_id=$1 ORDER BY created_at DESC", userId)
+	if alerts == nil {
+		alerts = make([]AbsenceAlert, 0)
+	}
+	return alerts, err
+}
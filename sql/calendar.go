@@ -0,0 +1,79 @@
+package sql
+
+// Vrste dogodkov v šolskem koledarju.
+const (
+	// Praznik ali drug dela prost dan (lahko tudi več zaporednih dni)
+	CALENDAR_HOLIDAY = 0
+	// Počitnice
+	CALENDAR_BREAK = 1
+	// Nadomestni delovni dan, npr. sobota, ki poteka po ponedeljkovem urniku (RunsAs)
+	CALENDAR_MAKEUP_DAY = 2
+	// Določitev A/B tedna (Week) od tega tedna naprej
+	CALENDAR_WEEK = 3
+	// Ocenjevalno obdobje (Term)
+	CALENDAR_TERM = 4
+	// Izjema za posamezen razred (ekskurzija, športni dan ...), ko razred nima rednega pouka
+	CALENDAR_CLASS_EXCEPTION = 5
+)
+
+type CalendarEvent struct {
+	ID        string
+	Name      string
+	EventType int     `db:"event_type"`
+	StartDate string  `db:"start_date"`
+	EndDate   string  `db:"end_date"`
+	RunsAs    *int    `db:"runs_as"`
+	Week      *int    `db:"week"`
+	Term      *int    `db:"term"`
+	ClassID   *string `db:"class_id"`
+	// Zaključni datum ocenjevalnega obdobja, po katerem so ocene obdobja zaklenjene
+	ClosingDate *string `db:"closing_date"`
+
+	CreatedAt string `db:"created_at"`
+	UpdatedAt string `db:"updated_at"`
+}
+
+func (db *sqlImpl) GetCalendarEvents() (events []CalendarEvent, err error) {
+	err = db.db.Select(&events, "SELECT * FROM calendar_events ORDER BY to_date(start_date, 'DD-MM-YYYY') ASC")
+	if events == nil {
+		events = make([]CalendarEvent, 0)
+	}
+	return events, err
+}
+
+func (db *sqlImpl) GetCalendarEvent(id string) (event CalendarEvent, err error) {
+	err = db.db.Get(&event, "SELECT * FROM calendar_events WHERE id=$1", id)
+	return event, err
+}
+
+func (db *sqlImpl) InsertCalendarEvent(event CalendarEvent) (err error) {
+	_, err = db.db.NamedExec(
+		`INSERT INTO calendar_events (name, event_type, start_date, end_date, runs_as, week, term, class_id, closing_date)
+			VALUES (:name, :event_type, :start_date, :end_date, :runs_as, :week, :term, :class_id, :closing_date)`,
+		event)
+	return err
+}
+
+// InsertCalendarEvents vstavi vse dogodke v eni transakciji (uporabljeno pri uvozu koledarja).
+func (db *sqlImpl) InsertCalendarEvents(events []CalendarEvent) error {
+	tx, err := db.db.Beginx()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(events); i++ {
+		_, err = tx.NamedExec(
+			`INSERT INTO calendar_events (name, event_type, start_date, end_date, runs_as, week, term, class_id, closing_date)
+				VALUES (:name, :event_type, :start_date, :end_date, :runs_as, :week, :term, :class_id, :closing_date)`,
+			events[i])
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (db *sqlImpl) DeleteCalendarEvent(id string) error {
+	_, err := db.db.Exec("DELETE FROM calendar_events WHERE id=$1", id)
+	return err
+}
@@ -14,6 +14,8 @@ type Class struct {
 	SOK            int
 	EOK            int
 	LastSchoolDate int `db:"last_school_date"`
+	// Lestvica ocenjevanja za predmete razreda, ki nimajo svoje lestvice
+	GradingScaleID *string `db:"grading_scale_id"`
 
 	CreatedAt string `db:"created_at"`
 	UpdatedAt string `db:"updated_at"`
@@ -27,6 +27,34 @@ func (db *sqlImpl) InsertCommunication(communication Communication) (err error)
 	return err
 }
 
+// InsertCommunicationWithMessage v eni transakciji ustvari nov pogovor in vanj zapiše prvo sporočilo.
+// Uporablja se za sistemska obvestila uporabnikom.
+func (db *sqlImpl) InsertCommunicationWithMessage(communication Communication, message Message) error {
+	tx, err := db.db.Beginx()
+	if err != nil {
+		return err
+	}
+	var id string
+	err = tx.Get(
+		&id,
+		"INSERT INTO communication (people, title, date_created) VALUES ($1, $2, $3) RETURNING id",
+		communication.People, communication.Title, communication.DateCreated,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	message.CommunicationID = id
+	_, err = tx.NamedExec(
+		"INSERT INTO message (communication_id, body, seen, date_created, user_id) VALUES (:communication_id, :body, :seen, :date_created, :user_id)",
+		message)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
 func (db *sqlImpl) UpdateCommunication(communication Communication) error {
 	_, err := db.db.NamedExec(
 		"UPDATE communication SET people=:people, title=:title WHERE id=:id",
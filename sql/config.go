@@ -26,6 +26,31 @@ type Config struct {
 	BlockRegistrations bool     `json:"block_registrations"`
 	BlockMeals         bool     `json:"block_meals"`
 	SchoolFreeDays     []string `json:"school_free_days"`
+	// Za koliko se zniža ocena nadomestnega učitelja za vsako uro nadomeščanja v tem šolskem letu
+	SubstitutionFairnessWeight float32 `json:"substitution_fairness_weight"`
+	// Največje število nadomeščanj na učitelja na teden (0 - brez omejitve)
+	MaxSubstitutionsPerWeek int `json:"max_substitutions_per_week"`
+	// Žeton za prikaz nadomeščanj na zaslonu na hodniku (prazen - onemogočeno)
+	KioskToken string `json:"kiosk_token"`
+	// Pragovi za opozorila o odsotnosti (0 - brez opozorila). Ure so seštete čez vse predmete v šolskem letu,
+	// prisotnost pa je v odstotkih pri posameznem predmetu.
+	AbsenceAlertUnexcusedHours int     `json:"absence_alert_unexcused_hours"`
+	AbsenceAlertTotalHours     int     `json:"absence_alert_total_hours"`
+	MinAttendancePercent       float32 `json:"min_attendance_percent"`
+	// Omejitve pisnih ocenjevanj na razred (0 - brez omejitve) in koliko dni vnaprej mora biti pisno ocenjevanje napovedano
+	MaxWrittenAssessmentsPerDay  int `json:"max_written_assessments_per_day"`
+	MaxWrittenAssessmentsPerWeek int `json:"max_written_assessments_per_week"`
+	WrittenAssessmentNoticeDays  int `json:"written_assessment_notice_days"`
+	// Javni naslov za preverjanje dokumentov (npr. https://meetplan.sola.si/verify), ki se zapiše v QR kodo na dokumentih.
+	// Če ni nastavljen, QR koda vsebuje samo identifikator dokumenta.
+	VerificationURL string `json:"verification_url"`
+	// Rok hrambe dokumentov v dneh glede na vrsto dokumenta (document_type). Dokumenti vrst, ki niso navedene
+	// ali imajo rok 0, se hranijo trajno.
+	DocumentRetentionDays map[int]int `json:"document_retention_days"`
+	// Obratni posredniki (npr. nginx) pred strežnikom kot naslovi IP ali omrežja CIDR in glava, v katero zapišejo
+	// naslov odjemalca (npr. X-Real-IP ali X-Forwarded-For). Glavi se zaupa samo pri zahtevah teh posrednikov.
+	TrustedProxies []string `json:"trusted_proxies"`
+	RealIPHeader   string   `json:"real_ip_header"`
 }
 
 func GetConfig() (Config, error) {
@@ -0,0 +1,60 @@
+package sql
+
+// Stanja paketne izdaje dokumentov.
+const (
+	DOCUMENT_BATCH_RUNNING = "running"
+	DOCUMENT_BATCH_DONE    = "done"
+	DOCUMENT_BATCH_FAILED  = "failed"
+)
+
+// DocumentBatch je paketna izdaja dokumentov iste vrste za vse učence razreda, ki teče v ozadju.
+type DocumentBatch struct {
+	ID           string
+	ClassID      string `db:"class_id"`
+	DocumentType int    `db:"document_type"`
+	Status       string
+	Total        int
+	Completed    int
+	Error        string
+	CreatedBy    string `db:"created_by"`
+
+	CreatedAt string `db:"created_at"`
+	UpdatedAt string `db:"updated_at"`
+}
+
+func (db *sqlImpl) GetDocumentBatch(id string) (batch DocumentBatch, err error) {
+	err = db.db.Get(&batch, "SELECT * FROM document_batches WHERE id=$1", id)
+	return batch, err
+}
+
+func (db *sqlImpl) InsertDocumentBatch(batch DocumentBatch) (id string, err error) {
+	err = db.db.Get(
+		&id,
+		"INSERT INTO document_batches (class_id, document_type, status, total, created_by) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		batch.ClassID, batch.DocumentType, batch.Status, batch.Total, batch.CreatedBy,
+	)
+	return id, err
+}
+
+func (db *sqlImpl) UpdateDocumentBatch(batch DocumentBatch) error {
+	_, err := db.db.NamedExec(
+		"UPDATE document_batches SET status=:status, completed=:completed, error=:error WHERE id=:id",
+		batch)
+	return err
+}
+
+func (db *sqlImpl) IncrementDocumentBatchProgress(id string) error {
+	_, err := db.db.Exec("UPDATE document_batches SET completed=completed+1 WHERE id=$1", id)
+	return err
+}
+
+// FailInterruptedDocumentBatches označi paketne izdaje, ki jih je prekinil ponovni zagon strežnika, kot neuspešne,
+// in jih vrne.
+func (db *sqlImpl) FailInterruptedDocumentBatches() (batches []DocumentBatch, err error) {
+	err = db.db.Select(
+		&batches,
+		"UPDATE document_batches SET status=$1, error='interrupted by a server restart' WHERE status=$2 RETURNING *",
+		DOCUMENT_BATCH_FAILED, DOCUMENT_BATCH_RUNNING,
+	)
+	return batches, err
+}
@@ -0,0 +1,52 @@
+package sql
+
+// DocumentTemplate je naložena različica predloge uradnega dokumenta. Definition vsebuje JSON definicijo predloge
+// (podlaga, pisave in postavitev polj). Za vsako ime predloge je aktivna največ ena različica, sicer se uporabi
+// vgrajena predloga.
+type DocumentTemplate struct {
+	ID         string
+	Name       string
+	Version    int
+	Definition string
+	Background *string
+	IsActive   bool   `db:"is_active"`
+	CreatedBy  string `db:"created_by"`
+
+	CreatedAt string `db:"created_at"`
+	UpdatedAt string `db:"updated_at"`
+}
+
+func (db *sqlImpl) GetDocumentTemplates(name string) (templates []DocumentTemplate, err error) {
+	err = db.db.Select(&templates, "SELECT * FROM document_templates WHERE name=$1 ORDER BY version DESC", name)
+	if templates == nil {
+		templates = make([]DocumentTemplate, 0)
+	}
+	return templates, err
+}
+
+func (db *sqlImpl) GetDocumentTemplate(name string, version int) (template DocumentTemplate, err error) {
+	err = db.db.Get(&template, "SELECT * FROM document_templates WHERE name=$1 AND version=$2", name, version)
+	return template, err
+}
+
+func (db *sqlImpl) GetActiveDocumentTemplate(name string) (template DocumentTemplate, err error) {
+	err = db.db.Get(&template, "SELECT * FROM document_templates WHERE name=$1 AND is_active=true", name)
+	return template, err
+}
+
+func (db *sqlImpl) InsertDocumentTemplate(template DocumentTemplate) (version int, err error) {
+	err = db.db.Get(
+		&version,
+		`INSERT INTO document_templates (name, version, definition, background, created_by)
+			VALUES ($1, (SELECT COALESCE(MAX(version), 0) + 1 FROM document_templates WHERE name=$1), $2, $3, $4) RETURNING version`,
+		template.Name, template.Definition, template.Background, template.CreatedBy,
+	)
+	return version, err
+}
+
+// ActivateDocumentTemplate označi podano različico predloge kot aktivno. Različica 0 izklopi vse naložene različice,
+// tako da se znova uporablja vgrajena predloga.
+func (db *sqlImpl) ActivateDocumentTemplate(name string, version int) error {
+	_, err := db.db.Exec("UPDATE document_templates SET is_active=(version=$2) WHERE name=$1", name, version)
+	return err
+}
@@ -7,6 +7,21 @@ type Document struct {
 	Timestamp    string `db:"created_at"`
 	IsSigned     bool   `db:"is_signed"`
 	UpdatedAt    string `db:"updated_at"`
+	// Paketna izdaja, v kateri je bil dokument ustvarjen
+	BatchID *string `db:"batch_id"`
+	// SHA-256 podpisanega PDF-ja, s katerim lahko tretja oseba preveri svojo kopijo
+	SHA256    string `db:"sha256"`
+	IsRevoked bool   `db:"is_revoked"`
+	// Preklic dokumenta. Zapis ostane, PDF pa se premakne v arhiv.
+	RevokedAt        *string `db:"revoked_at"`
+	RevokedBy        *string `db:"revoked_by"`
+	RevocationReason string  `db:"revocation_reason"`
+	// Dokument, ki ga ta dokument nadomešča (popravljena ponovna izdaja)
+	Supersedes *string `db:"supersedes"`
+	// Dokumenta pod pravnim zadržanjem ni mogoče izbrisati, tudi če mu je potekel rok hrambe
+	LegalHold bool `db:"legal_hold"`
+	// Uporabnik (npr. učenec), za katerega je bil dokument izdan. Prazno pri dokumentih, ki niso vezani na uporabnika.
+	IssuedFor *string `db:"issued_for"`
 }
 
 func (db *sqlImpl) GetDocument(id string) (document Document, err error) {
@@ -21,11 +36,49 @@ func (db *sqlImpl) GetAllDocuments() (documents []Document, err error) {
 
 func (db *sqlImpl) InsertDocument(document Document) error {
 	_, err := db.db.NamedExec(
-		"INSERT INTO documents (id, exported_by, document_type, is_signed) VALUES (:id, :exported_by, :document_type, :is_signed)",
+		"INSERT INTO documents (id, exported_by, document_type, is_signed, batch_id, sha256, supersedes, issued_for) VALUES (:id, :exported_by, :document_type, :is_signed, :batch_id, :sha256, :supersedes, :issued_for)",
 		document)
 	return err
 }
 
-func (db *sqlImpl) DeleteDocument(id string) {
-	db.db.Exec("DELETE FROM documents WHERE id=$1", id)
+func (db *sqlImpl) GetDocumentsForBatch(batchId string) (documents []Document, err error) {
+	err = db.db.Select(&documents, "SELECT * FROM documents WHERE batch_id=$1 ORDER BY created_at ASC", batchId)
+	if documents == nil {
+		documents = make([]Document, 0)
+	}
+	return documents, err
+}
+
+func (db *sqlImpl) RevokeDocument(id string, revokedBy string, reason string) error {
+	_, err := db.db.Exec(
+		"UPDATE documents SET is_revoked=true, revoked_at=now(), revoked_by=$2, revocation_reason=$3 WHERE id=$1",
+		id, revokedBy, reason,
+	)
+	return err
+}
+
+func (db *sqlImpl) SetDocumentLegalHold(id string, legalHold bool) error {
+	_, err := db.db.Exec("UPDATE documents SET legal_hold=$2 WHERE id=$1", id, legalHold)
+	return err
+}
+
+// GetSupersedingDocument vrne dokument, ki je nadomestil dokument z identifikatorjem id.
+func (db *sqlImpl) GetSupersedingDocument(id string) (document Document, err error) {
+	err = db.db.Get(&document, "SELECT * FROM documents WHERE supersedes=$1 ORDER BY created_at DESC LIMIT 1", id)
+	return document, err
+}
+
+// GetExpiredDocuments vrne dokumente vrste documentType, starejše od retentionDays dni, ki niso pod pravnim zadržanjem.
+func (db *sqlImpl) GetExpiredDocuments(documentType int, retentionDays int) (documents []Document, err error) {
+	err = db.db.Select(
+		&documents,
+		"SELECT * FROM documents WHERE document_type=$1 AND legal_hold=false AND created_at < now() - make_interval(days => $2)",
+		documentType, retentionDays,
+	)
+	return documents, err
+}
+
+func (db *sqlImpl) DeleteDocument(id string) error {
+	_, err := db.db.Exec("DELETE FROM documents WHERE id=$1", id)
+	return err
 }
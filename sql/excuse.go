@@ -0,0 +1,147 @@
+package sql
+
+const EXCUSE_PENDING = 0
+const EXCUSE_APPROVED = 1
+const EXCUSE_REJECTED = 2
+const EXCUSE_WITHDRAWN = 3
+
+// Vrste odsotnosti, ki jih opravičilo opraviči
+const excusableAbsenceTypes = "('" + ABSENCE_ABSENT + "', '" + ABSENCE_LATE + "', '" + ABSENCE_EXCUSED_EARLY_LEAVE + "')"
+
+type Excuse struct {
+	ID          string
+	StudentID   string `db:"student_id"`
+	SubmittedBy string `db:"submitted_by"`
+	StartDate   string `db:"start_date"`
+	EndDate     string `db:"end_date"`
+	Reason      string
+	// Pot do naloženega zdravniškega opravičila (prazno, če ga ni)
+	DocumentPath string  `db:"document_path"`
+	DocumentName string  `db:"document_name"`
+	Status       int     `db:"status"`
+	ReviewedBy   *string `db:"reviewed_by"`
+
+	CreatedAt string `db:"created_at"`
+	UpdatedAt string `db:"updated_at"`
+}
+
+// ExcuseHistory je en zapis v zgodovini opravičila (oddaja, odobritev, zavrnitev, preklic).
+type ExcuseHistory struct {
+	ID       string
+	ExcuseID string `db:"excuse_id"`
+	UserID   string `db:"user_id"`
+	Status   int    `db:"status"`
+	Comment  string `db:"comment"`
+
+	CreatedAt string `db:"created_at"`
+	UpdatedAt string `db:"updated_at"`
+}
+
+func (db *sqlImpl) GetExcuse(id string) (excuse Excuse, err error) {
+	err = db.db.Get(&excuse, "SELECT * FROM excuses WHERE id=$1", id)
+	return excuse, err
+}
+
+func (db *sqlImpl) GetExcusesForStudent(studentId string) (excuses []Excuse, err error) {
+	err = db.db.Select(&excuses, "SELECT * FROM excuses WHERE student_id=$1 ORDER BY created_at DESC", studentId)
+	if excuses == nil {
+		excuses = make([]Excuse, 0)
+	}
+	return excuses, err
+}
+
+func (db *sqlImpl) GetExcusesWithStatus(status int) (excuses []Excuse, err error) {
+	err = db.db.Select(&excuses, "SELECT * FROM excuses WHERE status=$1 ORDER BY created_at ASC", status)
+	if excuses == nil {
+		excuses = make([]Excuse, 0)
+	}
+	return excuses, err
+}
+
+// GetApprovedExcuseForStudentOnDate vrne odobreno opravičilo, ki pokriva podani datum.
+func (db *sqlImpl) GetApprovedExcuseForStudentOnDate(studentId string, date string) (excuse Excuse, err error) {
+	err = db.db.Get(
+		&excuse,
+		`SELECT * FROM excuses WHERE student_id=$1 AND status=$2
+			AND to_date($3, 'DD-MM-YYYY') BETWEEN to_date(start_date, 'DD-MM-YYYY') AND to_date(end_date, 'DD-MM-YYYY')
+			ORDER BY created_at DESC LIMIT 1`,
+		studentId, EXCUSE_APPROVED, date,
+	)
+	return excuse, err
+}
+
+func (db *sqlImpl) GetExcuseHistory(excuseId string) (history []ExcuseHistory, err error) {
+	err = db.db.Select(&history, "SELECT * FROM excuse_history WHERE excuse_id=$1 ORDER BY created_at ASC", excuseId)
+	if history == nil {
+		history = make([]ExcuseHistory, 0)
+	}
+	return history, err
+}
+
+// InsertExcuse shrani novo opravičilo skupaj s prvim zapisom v zgodovini.
+func (db *sqlImpl) InsertExcuse(excuse Excuse) (id string, err error) {
+	tx, err := db.db.Beginx()
+	if err != nil {
+		return "", err
+	}
+	err = tx.Get(
+		&id,
+		`INSERT INTO excuses (student_id, submitted_by, start_date, end_date, reason, document_path, document_name, status)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		excuse.StudentID, excuse.SubmittedBy, excuse.StartDate, excuse.EndDate, excuse.Reason, excuse.DocumentPath, excuse.DocumentName, EXCUSE_PENDING,
+	)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+	_, err = tx.Exec(
+		"INSERT INTO excuse_history (excuse_id, user_id, status, comment) VALUES ($1, $2, $3, $4)",
+		id, excuse.SubmittedBy, EXCUSE_PENDING, excuse.Reason,
+	)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+	return id, tx.Commit()
+}
+
+// SetExcuseStatus spremeni stanje opravičila in to zabeleži v zgodovino.
+// Ob odobritvi se opravičijo vse odsotnosti učenca v obdobju opravičila, ob zavrnitvi ali preklicu že odobrenega
+// opravičila pa se opravičenost teh odsotnosti razveljavi.
+func (db *sqlImpl) SetExcuseStatus(excuse Excuse, status int, userId string, comment string) error {
+	tx, err := db.db.Beginx()
+	if err != nil {
+		return err
+	}
+	var reviewedBy = excuse.ReviewedBy
+	if status == EXCUSE_APPROVED || status == EXCUSE_REJECTED {
+		reviewedBy = &userId
+	}
+	_, err = tx.Exec("UPDATE excuses SET status=$1, reviewed_by=$2 WHERE id=$3", status, reviewedBy, excuse.ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec(
+		"INSERT INTO excuse_history (excuse_id, user_id, status, comment) VALUES ($1, $2, $3, $4)",
+		excuse.ID, userId, status, comment,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if status == EXCUSE_APPROVED {
+		_, err = tx.Exec(
+			`UPDATE absence SET is_excused=true, excuse_id=$1 WHERE user_id=$2 AND absence_type IN `+excusableAbsenceTypes+`
+				AND meeting_id IN (SELECT id FROM meetings WHERE to_date(date, 'DD-MM-YYYY') BETWEEN to_date($3, 'DD-MM-YYYY') AND to_date($4, 'DD-MM-YYYY'))`,
+			excuse.ID, excuse.StudentID, excuse.StartDate, excuse.EndDate,
+		)
+	} else if excuse.Status == EXCUSE_APPROVED {
+		_, err = tx.Exec("UPDATE absence SET is_excused=false, excuse_id=NULL WHERE excuse_id=$1", excuse.ID)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,95 @@
+package sql
+
+// FinalGradeProposal je predlog zaključne ocene, ki ga učitelj predmeta shrani pred redovalno konferenco.
+// Če se predlagana ocena razlikuje od samodejnega predloga (AutoGrade), mora učitelj navesti utemeljitev.
+type FinalGradeProposal struct {
+	ID            string
+	UserID        string `db:"user_id"`
+	SubjectID     string `db:"subject_id"`
+	TeacherID     string `db:"teacher_id"`
+	SchoolYear    string `db:"school_year"`
+	Grade         int
+	Assessment    string
+	Average       float64
+	AutoGrade     *int `db:"auto_grade"`
+	Justification string
+
+	CreatedAt string `db:"created_at"`
+	UpdatedAt string `db:"updated_at"`
+}
+
+// ClassConference je zapis o redovalni konferenci razreda. Ko je konferenca potrjena, so zaključne ocene razreda
+// za to šolsko leto zaklenjene.
+type ClassConference struct {
+	ID          string
+	ClassID     string `db:"class_id"`
+	SchoolYear  string `db:"school_year"`
+	ConfirmedBy string `db:"confirmed_by"`
+	Notes       string
+
+	CreatedAt string `db:"created_at"`
+	UpdatedAt string `db:"updated_at"`
+}
+
+func (db *sqlImpl) GetFinalGradeProposalsForSubject(subjectId string, schoolYear string) (proposals []FinalGradeProposal, err error) {
+	err = db.db.Select(&proposals, "SELECT * FROM final_grade_proposals WHERE subject_id=$1 AND school_year=$2", subjectId, schoolYear)
+	if proposals == nil {
+		proposals = make([]FinalGradeProposal, 0)
+	}
+	return proposals, err
+}
+
+func (db *sqlImpl) SetFinalGradeProposal(proposal FinalGradeProposal) error {
+	_, err := db.db.NamedExec(
+		`INSERT INTO final_grade_proposals (user_id, subject_id, teacher_id, school_year, grade, assessment, average, auto_grade, justification)
+			VALUES (:user_id, :subject_id, :teacher_id, :school_year, :grade, :assessment, :average, :auto_grade, :justification)
+		ON CONFLICT (user_id, subject_id, school_year) DO UPDATE SET teacher_id=:teacher_id, grade=:grade, assessment=:assessment,
+			average=:average, auto_grade=:auto_grade, justification=:justification`,
+		proposal)
+	return err
+}
+
+func (db *sqlImpl) DeleteFinalGradeProposal(userId string, subjectId string, schoolYear string) error {
+	_, err := db.db.Exec("DELETE FROM final_grade_proposals WHERE user_id=$1 AND subject_id=$2 AND school_year=$3", userId, subjectId, schoolYear)
+	return err
+}
+
+func (db *sqlImpl) GetClassConference(classId string, schoolYear string) (conference ClassConference, err error) {
+	err = db.db.Get(&conference, "SELECT * FROM class_conferences WHERE class_id=$1 AND school_year=$2", classId, schoolYear)
+	return conference, err
+}
+
+// ConfirmClassConference v eni transakciji zapiše zaključne ocene, nastavi, ali učenci izdelajo razred,
+// in shrani zapis o konferenci.
+func (db *sqlImpl) ConfirmClassConference(conference ClassConference, grades []Grade, passing map[string]bool) error {
+	tx, err := db.db.Beginx()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(grades); i++ {
+		_, err = tx.NamedExec(
+			`INSERT INTO grades
+				(user_id, teacher_id, term_id, subject_id, date, is_written, grade, period, description, is_final, can_patch, points, assessment, category) VALUES
+				(:user_id, :teacher_id, :term_id, :subject_id, :date, :is_written, :grade, :period, :description, :is_final, :can_patch, :points, :assessment, :category)`,
+			grades[i])
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	for userId, isPassing := range passing {
+		_, err = tx.Exec("UPDATE users SET is_passing=$1 WHERE id=$2", isPassing, userId)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	_, err = tx.NamedExec(
+		"INSERT INTO class_conferences (class_id, school_year, confirmed_by, notes) VALUES (:class_id, :school_year, :confirmed_by, :notes)",
+		conference)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,151 @@
+package sql
+
+// gradeStatsSource so ocene (brez zaključnih in opisnih ocen, GRADING_SCALE_DESCRIPTIVE) skupaj s predmetom, vrsto
+// lestvice in tem, ali je ocena negativna. Lestvica se določi enako kot pri vpisu ocen: lestvica predmeta, sicer
+// lestvica razreda, sicer privzeta lestvica 1-5. Pri lestvici opravil/ni opravil je negativna ocena 0.
+const gradeStatsSource = `
+	SELECT grades.*, subject.long_name, subject.class_id, subject.teacher_id AS subject_teacher_id,
+		COALESCE(subject_scale.scale_type, class_scale.scale_type, 0) AS scale_type,
+		CASE WHEN COALESCE(subject_scale.scale_type, class_scale.scale_type, 0) = 1 THEN grades.grade = 0
+			ELSE grades.grade < COALESCE(subject_scale.passing_grade, class_scale.passing_grade, 2) END AS is_failed
+	FROM grades
+		JOIN subject ON subject.id=grades.subject_id
+		LEFT JOIN grading_scales subject_scale ON subject_scale.id=subject.grading_scale_id
+		LEFT JOIN classes ON classes.id=subject.class_id
+		LEFT JOIN grading_scales class_scale ON class_scale.id=classes.grading_scale_id
+	WHERE grades.is_final=false
+		AND COALESCE(subject_scale.scale_type, class_scale.scale_type, 0) <> 2
+`
+
+// averagedGradeStatsSource so ocene iz gradeStatsSource samo s številčnih in točkovnih lestvic. Uporablja se pri
+// primerjavah med predmeti, kjer bi ocene opravil/ni opravil (0 in 1) sicer pokvarile povprečje.
+const averagedGradeStatsSource = gradeStatsSource + `
+		AND COALESCE(subject_scale.scale_type, class_scale.scale_type, 0) IN (0, 3)
+`
+
+// gradeStatsColumns izračuna število ocen, povprečje, mediano in število negativnih ocen.
+const gradeStatsColumns = `
+	COUNT(*) AS count,
+	COALESCE(AVG(g.grade), 0)::float AS mean,
+	COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY g.grade), 0)::float AS median,
+	COUNT(*) FILTER (WHERE g.is_failed) AS failed
+`
+
+type GradeStats struct {
+	Count  int     `db:"count"`
+	Mean   float64 `db:"mean"`
+	Median float64 `db:"median"`
+	Failed int     `db:"failed"`
+}
+
+type GradeCount struct {
+	Grade int `db:"grade"`
+	Count int `db:"count"`
+}
+
+// SubjectGradeStats so statistike ocen enega predmeta (npr. matematike v 7.a pri določenem učitelju).
+type SubjectGradeStats struct {
+	GradeStats
+	SubjectID string  `db:"subject_id"`
+	ClassID   *string `db:"class_id"`
+	TeacherID string  `db:"teacher_id"`
+}
+
+type TeacherGradeStats struct {
+	GradeStats
+	TeacherID string `db:"teacher_id"`
+}
+
+type PeriodGradeStats struct {
+	GradeStats
+	Period int `db:"period"`
+}
+
+// StudentSubject je učenec s predmetom, pri katerem ima ocene.
+type StudentSubject struct {
+	UserID    string `db:"user_id"`
+	SubjectID string `db:"subject_id"`
+}
+
+func (db *sqlImpl) GetGradeStatsForTerm(termId string) (stats GradeStats, err error) {
+	err = db.db.Get(&stats, `SELECT `+gradeStatsColumns+` FROM (`+gradeStatsSource+`) g WHERE g.term_id=$1`, termId)
+	return stats, err
+}
+
+func (db *sqlImpl) GetGradeDistributionForTerm(termId string) (distribution []GradeCount, err error) {
+	err = db.db.Select(
+		&distribution,
+		`SELECT g.grade, COUNT(*) AS count FROM (`+gradeStatsSource+`) g WHERE g.term_id=$1 GROUP BY g.grade ORDER BY g.grade ASC`,
+		termId,
+	)
+	if distribution == nil {
+		distribution = make([]GradeCount, 0)
+	}
+	return distribution, err
+}
+
+// GetGradeStatsForLongName vrne statistike ocen vseh predmetov z istim dolgim imenom, vpisanih od since (DD-MM-YYYY) dalje.
+func (db *sqlImpl) GetGradeStatsForLongName(longName string, since string) (stats []SubjectGradeStats, err error) {
+	err = db.db.Select(
+		&stats,
+		`SELECT g.subject_id, g.class_id, g.subject_teacher_id AS teacher_id, `+gradeStatsColumns+`
+		FROM (`+averagedGradeStatsSource+`) g
+		WHERE g.long_name=$1 AND g.created_at >= to_date($2, 'DD-MM-YYYY')
+		GROUP BY g.subject_id, g.class_id, g.subject_teacher_id
+		ORDER BY mean DESC`,
+		longName, since,
+	)
+	if stats == nil {
+		stats = make([]SubjectGradeStats, 0)
+	}
+	return stats, err
+}
+
+func (db *sqlImpl) GetTeacherGradeStatsForLongName(longName string, since string) (stats []TeacherGradeStats, err error) {
+	err = db.db.Select(
+		&stats,
+		`SELECT g.subject_teacher_id AS teacher_id, `+gradeStatsColumns+`
+		FROM (`+averagedGradeStatsSource+`) g
+		WHERE g.long_name=$1 AND g.created_at >= to_date($2, 'DD-MM-YYYY')
+		GROUP BY g.subject_teacher_id
+		ORDER BY mean DESC`,
+		longName, since,
+	)
+	if stats == nil {
+		stats = make([]TeacherGradeStats, 0)
+	}
+	return stats, err
+}
+
+func (db *sqlImpl) GetPeriodGradeStatsForLongName(longName string, since string) (stats []PeriodGradeStats, err error) {
+	err = db.db.Select(
+		&stats,
+		`SELECT g.period, `+gradeStatsColumns+`
+		FROM (`+averagedGradeStatsSource+`) g
+		WHERE g.long_name=$1 AND g.created_at >= to_date($2, 'DD-MM-YYYY')
+		GROUP BY g.period
+		ORDER BY g.period ASC`,
+		longName, since,
+	)
+	if stats == nil {
+		stats = make([]PeriodGradeStats, 0)
+	}
+	return stats, err
+}
+
+// GetStudentSubjectsWithGrades vrne učence in predmete, pri katerih so bile od since dalje vpisane ocene.
+// Povprečja in negativne ocene se izračunajo v httphandlers, kjer se upoštevajo uteži in popravljene ocene.
+func (db *sqlImpl) GetStudentSubjectsWithGrades(since string) (students []StudentSubject, err error) {
+	err = db.db.Select(
+		&students,
+		`SELECT DISTINCT g.user_id, g.subject_id
+		FROM (`+gradeStatsSource+`) g
+		WHERE g.created_at >= to_date($1, 'DD-MM-YYYY')
+		ORDER BY g.user_id, g.subject_id`,
+		since,
+	)
+	if students == nil {
+		students = make([]StudentSubject, 0)
+	}
+	return students, err
+}
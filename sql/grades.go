@@ -1,5 +1,12 @@
 package sql
 
+// Kategorije ocen. Prve tri se ujemajo z vrstami ocenjevanj (Grading.GradingType).
+const GRADE_CATEGORY_ORAL = 0
+const GRADE_CATEGORY_WRITTEN = 1
+const GRADE_CATEGORY_OTHER = 2
+const GRADE_CATEGORY_PROJECT = 3
+const GRADE_CATEGORY_HOMEWORK = 4
+
 type Grade struct {
 	ID          string
 	UserID      string  `db:"user_id"`
@@ -13,6 +20,10 @@ type Grade struct {
 	Period      int
 	Description string
 	CanPatch    bool `db:"can_patch"`
+	// Dosežene točke (samo pri točkovni lestvici) in besedilo opisne ocene
+	Points     *float32
+	Assessment string
+	Category   int
 
 	CreatedAt string `db:"created_at"`
 	UpdatedAt string `db:"updated_at"`
@@ -48,11 +59,21 @@ func (db *sqlImpl) GetGradesForUserInSubject(userId string, subjectId string) (g
 	return grades, err
 }
 
+// GetGradesForUserInSubjectSince vrne ocene (brez zaključne) učenca pri predmetu, vpisane od since (DD-MM-YYYY) dalje.
+func (db *sqlImpl) GetGradesForUserInSubjectSince(userId string, subjectId string, since string) (grades []Grade, err error) {
+	err = db.db.Select(
+		&grades,
+		"SELECT * FROM grades WHERE user_id=$1 AND subject_id=$2 AND is_final=false AND created_at >= to_date($3, 'DD-MM-YYYY') ORDER BY id ASC",
+		userId, subjectId, since,
+	)
+	return grades, err
+}
+
 func (db *sqlImpl) InsertGrade(grade Grade) error {
 	i := `
 	INSERT INTO grades
-	    (user_id, teacher_id, term_id, subject_id, date, is_written, grade, period, description, is_final, can_patch) VALUES
-	    (:user_id, :teacher_id, :term_id, :subject_id, :date, :is_written, :grade, :period, :description, :is_final, :can_patch)
+	    (user_id, teacher_id, term_id, subject_id, date, is_written, grade, period, description, is_final, can_patch, points, assessment, category) VALUES
+	    (:user_id, :teacher_id, :term_id, :subject_id, :date, :is_written, :grade, :period, :description, :is_final, :can_patch, :points, :assessment, :category)
 	`
 	_, err := db.db.NamedExec(
 		i,
@@ -62,7 +83,7 @@ func (db *sqlImpl) InsertGrade(grade Grade) error {
 
 func (db *sqlImpl) UpdateGrade(grade Grade) error {
 	_, err := db.db.NamedExec(
-		"UPDATE grades SET user_id=:user_id, teacher_id=:teacher_id, term_id=:term_id, subject_id=:subject_id, date=:date, is_written=:is_written, grade=:grade, period=:period, description=:description, can_patch=:can_patch WHERE id=:id",
+		"UPDATE grades SET user_id=:user_id, teacher_id=:teacher_id, term_id=:term_id, subject_id=:subject_id, date=:date, is_written=:is_written, grade=:grade, period=:period, description=:description, can_patch=:can_patch, points=:points, assessment=:assessment, category=:category WHERE id=:id",
 		grade)
 	return err
 }
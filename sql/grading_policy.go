@@ -0,0 +1,66 @@
+package sql
+
+const AVERAGE_WEIGHTED_MEAN = 0
+const AVERAGE_MEDIAN = 1
+const AVERAGE_BEST_N = 2
+
+// GradingPolicy določa, kako se pri predmetu računa povprečje ocen.
+// Pri tehtanem povprečju ima vsaka ocena utež kategorije (ustno, pisno ...), pomnoženo z utežjo ocenjevanja.
+// Pri AVERAGE_BEST_N se upošteva navadno povprečje BestN najboljših ocen.
+type GradingPolicy struct {
+	ID             string
+	SubjectID      string  `db:"subject_id"`
+	AverageType    int     `db:"average_type"`
+	BestN          int     `db:"best_n"`
+	OralWeight     float32 `db:"oral_weight"`
+	WrittenWeight  float32 `db:"written_weight"`
+	OtherWeight    float32 `db:"other_weight"`
+	ProjectWeight  float32 `db:"project_weight"`
+	HomeworkWeight float32 `db:"homework_weight"`
+
+	CreatedAt string `db:"created_at"`
+	UpdatedAt string `db:"updated_at"`
+}
+
+// DefaultGradingPolicy je navadno aritmetično povprečje, ki velja za predmete brez nastavljenega pravila.
+func DefaultGradingPolicy(subjectId string) GradingPolicy {
+	return GradingPolicy{
+		SubjectID:      subjectId,
+		AverageType:    AVERAGE_WEIGHTED_MEAN,
+		OralWeight:     1,
+		WrittenWeight:  1,
+		OtherWeight:    1,
+		ProjectWeight:  1,
+		HomeworkWeight: 1,
+	}
+}
+
+// CategoryWeight vrne utež kategorije ocene.
+func (policy GradingPolicy) CategoryWeight(category int) float32 {
+	switch category {
+	case GRADE_CATEGORY_WRITTEN:
+		return policy.WrittenWeight
+	case GRADE_CATEGORY_OTHER:
+		return policy.OtherWeight
+	case GRADE_CATEGORY_PROJECT:
+		return policy.ProjectWeight
+	case GRADE_CATEGORY_HOMEWORK:
+		return policy.HomeworkWeight
+	}
+	return policy.OralWeight
+}
+
+func (db *sqlImpl) GetGradingPolicyForSubject(subjectId string) (policy GradingPolicy, err error) {
+	err = db.db.Get(&policy, "SELECT * FROM grading_policies WHERE subject_id=$1", subjectId)
+	return policy, err
+}
+
+func (db *sqlImpl) SetGradingPolicy(policy GradingPolicy) error {
+	_, err := db.db.NamedExec(
+		`INSERT INTO grading_policies (subject_id, average_type, best_n, oral_weight, written_weight, other_weight, project_weight, homework_weight)
+			VALUES (:subject_id, :average_type, :best_n, :oral_weight, :written_weight, :other_weight, :project_weight, :homework_weight)
+		ON CONFLICT (subject_id) DO UPDATE SET average_type=:average_type, best_n=:best_n, oral_weight=:oral_weight,
+			written_weight=:written_weight, other_weight=:other_weight, project_weight=:project_weight, homework_weight=:homework_weight`,
+		policy)
+	return err
+}
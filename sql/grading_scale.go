@@ -0,0 +1,254 @@
+package sql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const GRADING_SCALE_NUMERIC = 0
+const GRADING_SCALE_PASS_FAIL = 1
+const GRADING_SCALE_DESCRIPTIVE = 2
+const GRADING_SCALE_POINTS = 3
+
+// GradingScale določa, kako se ocenjuje pri predmetu ali v razredu.
+//
+// Številčna lestvica dovoljuje ocene med MinGrade in MaxGrade. Lestvica opravil/ni opravil shranjuje 1 (opravil)
+// in 0 (ni opravil). Pri opisni lestvici se shrani samo besedilo ocene, številčna ocena je vedno 0.
+// Pri točkovni lestvici učitelj vpiše točke (največ MaxPoints), ocena pa se določi po pragovih v PointsMapping.
+type GradingScale struct {
+	ID           string
+	Name         string
+	ScaleType    int `db:"scale_type"`
+	MinGrade     int `db:"min_grade"`
+	MaxGrade     int `db:"max_grade"`
+	PassingGrade int `db:"passing_grade"`
+	// JSON seznam GradingScaleLabel
+	Labels string
+	// JSON seznam PointsThreshold
+	PointsMapping string  `db:"points_mapping"`
+	MaxPoints     float32 `db:"max_points"`
+
+	CreatedAt string `db:"created_at"`
+	UpdatedAt string `db:"updated_at"`
+}
+
+// GradingScaleLabel je besedni opis ocene, npr. 5 - odlično.
+type GradingScaleLabel struct {
+	Grade int    `json:"grade"`
+	Label string `json:"label"`
+}
+
+// PointsThreshold določa, da učenec z vsaj MinPercent odstotki točk dobi oceno Grade.
+type PointsThreshold struct {
+	MinPercent float32 `json:"min_percent"`
+	Grade      int     `json:"grade"`
+}
+
+// DefaultGradingScale je lestvica od 1 do 5, ki velja za predmete brez dodeljene lestvice.
+func DefaultGradingScale() GradingScale {
+	labels, _ := json.Marshal([]GradingScaleLabel{
+		{Grade: 1, Label: "nezadostno"},
+		{Grade: 2, Label: "zadostno"},
+		{Grade: 3, Label: "dobro"},
+		{Grade: 4, Label: "prav dobro"},
+		{Grade: 5, Label: "odlično"},
+	})
+	return GradingScale{
+		Name:          "1-5",
+		ScaleType:     GRADING_SCALE_NUMERIC,
+		MinGrade:      1,
+		MaxGrade:      5,
+		PassingGrade:  2,
+		Labels:        string(labels),
+		PointsMapping: "[]",
+	}
+}
+
+func (scale GradingScale) GetLabels() ([]GradingScaleLabel, error) {
+	labels := make([]GradingScaleLabel, 0)
+	if scale.Labels == "" {
+		return labels, nil
+	}
+	err := json.Unmarshal([]byte(scale.Labels), &labels)
+	return labels, err
+}
+
+func (scale GradingScale) GetPointsMapping() ([]PointsThreshold, error) {
+	mapping := make([]PointsThreshold, 0)
+	if scale.PointsMapping == "" {
+		return mapping, nil
+	}
+	err := json.Unmarshal([]byte(scale.PointsMapping), &mapping)
+	return mapping, err
+}
+
+// Validate preveri, ali je definicija lestvice smiselna.
+func (scale GradingScale) Validate() error {
+	if scale.ScaleType < GRADING_SCALE_NUMERIC || scale.ScaleType > GRADING_SCALE_POINTS {
+		return errors.New("unknown grading scale type")
+	}
+	if scale.ScaleType != GRADING_SCALE_DESCRIPTIVE && scale.MinGrade > scale.MaxGrade {
+		return errors.New("min_grade is greater than max_grade")
+	}
+	if _, err := scale.GetLabels(); err != nil {
+		return err
+	}
+	mapping, err := scale.GetPointsMapping()
+	if err != nil {
+		return err
+	}
+	if scale.ScaleType == GRADING_SCALE_POINTS {
+		if scale.MaxPoints <= 0 || len(mapping) == 0 {
+			return errors.New("points scale requires max_points and points_mapping")
+		}
+		for i := 0; i < len(mapping); i++ {
+			if !scale.IsValidGrade(mapping[i].Grade) {
+				return errors.New(fmt.Sprintf("points mapping grade %d is out of range", mapping[i].Grade))
+			}
+		}
+	}
+	return nil
+}
+
+// IsAveraged pove, ali se iz ocen te lestvice računa povprečje.
+func (scale GradingScale) IsAveraged() bool {
+	return scale.ScaleType == GRADING_SCALE_NUMERIC || scale.ScaleType == GRADING_SCALE_POINTS
+}
+
+// IsValidGrade preveri, ali je številčna ocena veljavna v tej lestvici.
+func (scale GradingScale) IsValidGrade(grade int) bool {
+	if scale.ScaleType == GRADING_SCALE_DESCRIPTIVE {
+		return grade == 0
+	}
+	if scale.ScaleType == GRADING_SCALE_PASS_FAIL {
+		return grade == 0 || grade == 1
+	}
+	return grade >= scale.MinGrade && grade <= scale.MaxGrade
+}
+
+// IsPassing pove, ali je ocena pozitivna.
+func (scale GradingScale) IsPassing(grade Grade) bool {
+	if scale.ScaleType == GRADING_SCALE_DESCRIPTIVE {
+		return true
+	}
+	if scale.ScaleType == GRADING_SCALE_PASS_FAIL {
+		return grade.Grade == 1
+	}
+	return grade.Grade >= scale.PassingGrade
+}
+
+// GradeFromPoints določi oceno iz doseženih točk po pragovih lestvice. Upošteva se najvišji dosežen prag.
+func (scale GradingScale) GradeFromPoints(points float32) (int, error) {
+	if scale.ScaleType != GRADING_SCALE_POINTS {
+		return 0, errors.New("grading scale is not a points scale")
+	}
+	if points < 0 || points > scale.MaxPoints {
+		return 0, errors.New("points are out of range")
+	}
+	mapping, err := scale.GetPointsMapping()
+	if err != nil {
+		return 0, err
+	}
+	percent := points / scale.MaxPoints * 100
+	var grade = scale.MinGrade
+	var best float32 = -1
+	for i := 0; i < len(mapping); i++ {
+		if percent >= mapping[i].MinPercent && mapping[i].MinPercent > best {
+			best = mapping[i].MinPercent
+			grade = mapping[i].Grade
+		}
+	}
+	return grade, nil
+}
+
+// Format vrne oceno v obliki, primerni za izpis (npr. na spričevalu).
+func (scale GradingScale) Format(grade Grade) string {
+	if scale.ScaleType == GRADING_SCALE_DESCRIPTIVE {
+		return grade.Assessment
+	}
+	label := ""
+	labels, _ := scale.GetLabels()
+	for i := 0; i < len(labels); i++ {
+		if labels[i].Grade == grade.Grade {
+			label = labels[i].Label
+			break
+		}
+	}
+	if scale.ScaleType == GRADING_SCALE_PASS_FAIL {
+		if label != "" {
+			return label
+		}
+		if grade.Grade == 1 {
+			return "opravil"
+		}
+		return "ni opravil"
+	}
+	if label == "" {
+		return fmt.Sprint(grade.Grade)
+	}
+	return fmt.Sprintf("%s %d", label, grade.Grade)
+}
+
+func (db *sqlImpl) GetGradingScale(id string) (scale GradingScale, err error) {
+	err = db.db.Get(&scale, "SELECT * FROM grading_scales WHERE id=$1", id)
+	return scale, err
+}
+
+func (db *sqlImpl) GetGradingScales() (scales []GradingScale, err error) {
+	err = db.db.Select(&scales, "SELECT * FROM grading_scales ORDER BY name ASC")
+	if scales == nil {
+		scales = make([]GradingScale, 0)
+	}
+	return scales, err
+}
+
+func (db *sqlImpl) InsertGradingScale(scale GradingScale) error {
+	_, err := db.db.NamedExec(
+		`INSERT INTO grading_scales (name, scale_type, min_grade, max_grade, passing_grade, labels, points_mapping, max_points)
+			VALUES (:name, :scale_type, :min_grade, :max_grade, :passing_grade, :labels, :points_mapping, :max_points)`,
+		scale)
+	return err
+}
+
+func (db *sqlImpl) UpdateGradingScale(scale GradingScale) error {
+	_, err := db.db.NamedExec(
+		`UPDATE grading_scales SET name=:name, scale_type=:scale_type, min_grade=:min_grade, max_grade=:max_grade,
+			passing_grade=:passing_grade, labels=:labels, points_mapping=:points_mapping, max_points=:max_points WHERE id=:id`,
+		scale)
+	return err
+}
+
+// DeleteGradingScale izbriše lestvico. Predmeti in razredi, ki so jo uporabljali, se vrnejo na privzeto lestvico.
+func (db *sqlImpl) DeleteGradingScale(id string) error {
+	tx, err := db.db.Beginx()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE subject SET grading_scale_id=NULL WHERE grading_scale_id=$1", id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec("UPDATE classes SET grading_scale_id=NULL WHERE grading_scale_id=$1", id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM grading_scales WHERE id=$1", id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *sqlImpl) SetSubjectGradingScale(subjectId string, scaleId *string) error {
+	_, err := db.db.Exec("UPDATE subject SET grading_scale_id=$1 WHERE id=$2", scaleId, subjectId)
+	return err
+}
+
+func (db *sqlImpl) SetClassGradingScale(classId string, scaleId *string) error {
+	_, err := db.db.Exec("UPDATE classes SET grading_scale_id=$1 WHERE id=$2", scaleId, classId)
+	return err
+}
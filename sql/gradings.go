@@ -9,6 +9,8 @@ type Grading struct {
 	GradingType int    `db:"grading_type"`
 	SchoolYear  string `db:"school_year"`
 	Period      int
+	// Utež ocen tega ocenjevanja pri izračunu povprečja
+	Weight float32
 
 	CreatedAt string `db:"created_at"`
 	UpdatedAt string `db:"updated_at"`
@@ -27,8 +29,8 @@ func (db *sqlImpl) GetGradingsForSubject(subjectId string) (gradings []Grading,
 func (db *sqlImpl) InsertGrading(grading Grading) error {
 	i := `
 	INSERT INTO gradings
-	    (subject_id, teacher_id, name, description, grading_type, school_year, period) VALUES
-	    (:subject_id, :teacher_id, :name, :description, :grading_type, :school_year, :period)
+	    (subject_id, teacher_id, name, description, grading_type, school_year, period, weight) VALUES
+	    (:subject_id, :teacher_id, :name, :description, :grading_type, :school_year, :period, :weight)
 	`
 	_, err := db.db.NamedExec(
 		i,
@@ -38,7 +40,7 @@ func (db *sqlImpl) InsertGrading(grading Grading) error {
 
 func (db *sqlImpl) UpdateGrading(grading Grading) error {
 	_, err := db.db.NamedExec(
-		"UPDATE gradings SET teacher_id=:teacher_id, name=:name, description=:description, grading_type=:grading_type, school_year=:school_year, period=:period WHERE id=:id",
+		"UPDATE gradings SET teacher_id=:teacher_id, name=:name, description=:description, grading_type=:grading_type, school_year=:school_year, period=:period, weight=:weight WHERE id=:id",
 		grading)
 	return err
 }
@@ -0,0 +1,151 @@
+package sql
+
+import (
+	"errors"
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrDuplicateLessonNumber = errors.New("lesson number is already used for this subject")
+
+// LessonLog je vpis v dnevnik (tema in zaporedna številka ure predmeta), ki ga učitelj opravi po vsaki uri.
+type LessonLog struct {
+	ID           string
+	MeetingID    string `db:"meeting_id"`
+	SubjectID    string `db:"subject_id"`
+	TeacherID    string `db:"teacher_id"`
+	LessonNumber int    `db:"lesson_number"`
+	Topic        string
+	Notes        string
+	IsSigned     bool    `db:"is_signed"`
+	SignedAt     *string `db:"signed_at"`
+
+	CreatedAt string `db:"created_at"`
+	UpdatedAt string `db:"updated_at"`
+}
+
+// LessonLogEntry je vpis v dnevnik skupaj s podatki o srečanju.
+type LessonLogEntry struct {
+	LessonLog
+	Date        string `db:"date"`
+	Hour        int    `db:"hour"`
+	MeetingName string `db:"meeting_name"`
+}
+
+func (db *sqlImpl) GetLessonLogForMeeting(meetingId string) (log LessonLog, err error) {
+	err = db.db.Get(&log, "SELECT * FROM lesson_logs WHERE meeting_id=$1", meetingId)
+	return log, err
+}
+
+func (db *sqlImpl) GetLessonLogsForSubject(subjectId string) (logs []LessonLogEntry, err error) {
+	err = db.db.Select(
+		&logs,
+		`SELECT lesson_logs.*, meetings.date, meetings.hour, meetings.meeting_name
+		FROM lesson_logs JOIN meetings ON meetings.id=lesson_logs.meeting_id
+		WHERE lesson_logs.subject_id=$1 ORDER BY lesson_logs.lesson_number ASC`,
+		subjectId,
+	)
+	if logs == nil {
+		logs = make([]LessonLogEntry, 0)
+	}
+	return logs, err
+}
+
+// lockLessonNumber zaklene vrstico predmeta in preveri, da številke ure pri predmetu nima že drug vpis.
+func lockLessonNumber(tx *sqlx.Tx, log LessonLog) error {
+	_, err := tx.Exec("SELECT id FROM subject WHERE id=$1 FOR UPDATE", log.SubjectID)
+	if err != nil {
+		return err
+	}
+	if log.LessonNumber == 0 {
+		return nil
+	}
+	var exists bool
+	err = tx.Get(
+		&exists,
+		"SELECT EXISTS (SELECT 1 FROM lesson_logs WHERE subject_id=$1 AND lesson_number=$2 AND ($3='' OR id::text<>$3))",
+		log.SubjectID, log.LessonNumber, log.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrDuplicateLessonNumber
+	}
+	return nil
+}
+
+// InsertLessonLog shrani nov vpis v dnevnik. Če številka ure ni podana (0), dobi vpis naslednjo zaporedno številko
+// predmeta. Vrstica predmeta je med tem zaklenjena, da dva hkratna vpisa ne dobita iste številke.
+func (db *sqlImpl) InsertLessonLog(log LessonLog) (LessonLog, error) {
+	tx, err := db.db.Beginx()
+	if err != nil {
+		return log, err
+	}
+	err = lockLessonNumber(tx, log)
+	if err != nil {
+		tx.Rollback()
+		return log, err
+	}
+	if log.LessonNumber == 0 {
+		err = tx.Get(&log.LessonNumber, "SELECT COALESCE(MAX(lesson_number), 0)+1 FROM lesson_logs WHERE subject_id=$1", log.SubjectID)
+		if err != nil {
+			tx.Rollback()
+			return log, err
+		}
+	}
+	err = tx.Get(
+		&log.ID,
+		`INSERT INTO lesson_logs (meeting_id, subject_id, teacher_id, lesson_number, topic, notes)
+			VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		log.MeetingID, log.SubjectID, log.TeacherID, log.LessonNumber, log.Topic, log.Notes,
+	)
+	if err != nil {
+		tx.Rollback()
+		return log, err
+	}
+	return log, tx.Commit()
+}
+
+func (db *sqlImpl) UpdateLessonLog(log LessonLog) error {
+	tx, err := db.db.Beginx()
+	if err != nil {
+		return err
+	}
+	err = lockLessonNumber(tx, log)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.NamedExec(
+		"UPDATE lesson_logs SET teacher_id=:teacher_id, lesson_number=:lesson_number, topic=:topic, notes=:notes WHERE id=:id",
+		log)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *sqlImpl) SignLessonLog(id string, isSigned bool) error {
+	_, err := db.db.Exec(
+		"UPDATE lesson_logs SET is_signed=$1, signed_at=CASE WHEN $1 THEN now() ELSE NULL END WHERE id=$2",
+		isSigned, id)
+	return err
+}
+
+// GetMeetingsWithoutLessonLog vrne objavljena srečanja med start in end, ki nimajo vpisa v dnevnik.
+// Če teacherId ni prazen, vrne samo srečanja tega učitelja.
+func (db *sqlImpl) GetMeetingsWithoutLessonLog(start string, end string, teacherId string) (meetings []Meeting, err error) {
+	err = db.db.Select(
+		&meetings,
+		`SELECT meetings.* FROM meetings LEFT JOIN lesson_logs ON lesson_logs.meeting_id=meetings.id
+		WHERE lesson_logs.id IS NULL AND meetings.is_beta=false AND ($3='' OR meetings.teacher_id::text=$3)
+			AND to_date(meetings.date, 'DD-MM-YYYY') BETWEEN to_date($1, 'DD-MM-YYYY') AND to_date($2, 'DD-MM-YYYY')
+		ORDER BY to_date(meetings.date, 'DD-MM-YYYY') ASC, meetings.hour ASC`,
+		start, end, teacherId,
+	)
+	if meetings == nil {
+		meetings = make([]Meeting, 0)
+	}
+	return meetings, err
+}
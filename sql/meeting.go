@@ -23,6 +23,15 @@ type Meeting struct {
 	// Če učitelji ne bodo zadovoljni, se z enim klikom izbriše ta srečanja in se ustvari nov urnik s Proton layerjem, drugače pa se jih z enim klikom spremeni v normalna srečanja,
 	// vidna tudi učencem
 	IsBeta bool `db:"is_beta"`
+	// Različica nabora Proton pravil, s katero je bil ustvarjen sprejet urnik. Ročno ustvarjena srečanja je nimajo.
+	ProtonConfigVersion *int `db:"proton_config_version"`
+	// Različica urnika, s katero je bilo srečanje sprejeto. Ročno ustvarjena srečanja je nimajo.
+	TimetableVersion *int `db:"timetable_version"`
+	// Ponavljajoča se srečanja, ustvarjena z enim zahtevkom, si delijo ID serije.
+	SeriesID *string `db:"series_id"`
+	// Kdaj je bila za srečanje prvič oddana prisotnost. Prisotni učenci nimajo vrstice v absence, zato je to oznaka,
+	// da je bila ura izvedena.
+	AttendanceTakenAt *string `db:"attendance_taken_at"`
 
 	CreatedAt string `db:"created_at"`
 	UpdatedAt string `db:"updated_at"`
@@ -60,8 +69,8 @@ func (db *sqlImpl) GetMeetingsForSubject(subjectId string) (meetings []Meeting,
 
 func (db *sqlImpl) InsertMeeting(meeting Meeting) (err error) {
 	i := `
-	INSERT INTO meetings (meeting_name, teacher_id, subject_id, hour, date, is_mandatory, url, details, is_grading, is_written_assessment, is_test, is_substitution, is_beta, location, is_correction_test)
-		VALUES (:meeting_name, :teacher_id, :subject_id, :hour, :date, :is_mandatory, :url, :details, :is_grading, :is_written_assessment, :is_test, :is_substitution, :is_beta, :location, :is_correction_test)
+	INSERT INTO meetings (meeting_name, teacher_id, subject_id, hour, date, is_mandatory, url, details, is_grading, is_written_assessment, is_test, is_substitution, is_beta, location, is_correction_test, proton_config_version, timetable_version, series_id)
+		VALUES (:meeting_name, :teacher_id, :subject_id, :hour, :date, :is_mandatory, :url, :details, :is_grading, :is_written_assessment, :is_test, :is_substitution, :is_beta, :location, :is_correction_test, :proton_config_version, :timetable_version, :series_id)
 	`
 	_, err = db.db.NamedExec(
 		i,
@@ -84,13 +93,91 @@ func (db *sqlImpl) UpdateMeeting(meeting Meeting) error {
 	return err
 }
 
+// InsertMeetings v eni transakciji vstavi več srečanj (npr. celotno serijo). Če vstavljanje kateregakoli spodleti,
+// se ne shrani nobeno.
+func (db *sqlImpl) InsertMeetings(meetings []Meeting) error {
+	tx, err := db.db.Beginx()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(meetings); i++ {
+		_, err = tx.NamedExec(
+			`INSERT INTO meetings (meeting_name, teacher_id, subject_id, hour, date, is_mandatory, url, details, is_grading, is_written_assessment, is_test, is_substitution, is_beta, location, is_correction_test, proton_config_version, timetable_version, series_id)
+				VALUES (:meeting_name, :teacher_id, :subject_id, :hour, :date, :is_mandatory, :url, :details, :is_grading, :is_written_assessment, :is_test, :is_substitution, :is_beta, :location, :is_correction_test, :proton_config_version, :timetable_version, :series_id)`,
+			meetings[i])
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Srečanja serije, ki jih je še mogoče spreminjati ali izbrisati: današnja in prihodnja srečanja brez vezanih podatkov.
+// Izvedena srečanja ostanejo nespremenjena, saj so nanje vezani izostanki, vpisi v dnevnik ipd.
+const editableSeriesMeeting = `series_id=$1 AND ($2='' OR to_date(date, 'DD-MM-YYYY')>=to_date($2, 'DD-MM-YYYY'))
+	AND to_date(date, 'DD-MM-YYYY')>=CURRENT_DATE AND NOT ` + meetingHasAttachments
+
+// GetEditableMeetingsInSeries vrne srečanja serije, ki jih je še mogoče spreminjati. Če from ni prazen, vrne samo
+// srečanja na ta dan ali kasneje.
+func (db *sqlImpl) GetEditableMeetingsInSeries(seriesId string, from string) (meetings []Meeting, err error) {
+	err = db.db.Select(
+		&meetings,
+		"SELECT * FROM meetings WHERE "+editableSeriesMeeting+" ORDER BY to_date(date, 'DD-MM-YYYY') ASC",
+		seriesId, from,
+	)
+	return meetings, err
+}
+
+// MeetingHasAttachments preveri, ali so na srečanje že vezani podatki (oddana prisotnost, izostanki, vpis v dnevnik ...).
+func (db *sqlImpl) MeetingHasAttachments(id string) (hasAttachments bool, err error) {
+	err = db.db.Get(&hasAttachments, "SELECT "+meetingHasAttachments+" FROM meetings WHERE id=$1", id)
+	return hasAttachments, err
+}
+
+// UpdateMeetings v eni transakciji posodobi več srečanj (npr. celotno serijo).
+func (db *sqlImpl) UpdateMeetings(meetings []Meeting) error {
+	tx, err := db.db.Beginx()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(meetings); i++ {
+		_, err = tx.NamedExec(
+			`UPDATE meetings SET meeting_name=:meeting_name, teacher_id=:teacher_id, hour=:hour, date=:date,
+			                    is_mandatory=:is_mandatory, url=:url, details=:details,
+			                    is_grading=:is_grading, is_written_assessment=:is_written_assessment,
+			                    is_test=:is_test, is_substitution=:is_substitution,
+			                    location=:location, is_correction_test=:is_correction_test WHERE id=:id`,
+			meetings[i])
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteEditableMeetingsInSeries izbriše srečanja serije, ki jih je še mogoče spreminjati. Če from ni prazen, izbriše
+// samo srečanja na ta dan ali kasneje. Vrne število izbrisanih srečanj.
+func (db *sqlImpl) DeleteEditableMeetingsInSeries(seriesId string, from string) (int64, error) {
+	result, err := db.db.Exec("DELETE FROM meetings WHERE "+editableSeriesMeeting, seriesId, from)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// MigrateBetaMeetingsToNonBeta objavi beta srečanja, ki ne pripadajo nobeni različici urnika.
+// Srečanja različic urnika se objavljajo s PublishTimetableVersion.
 func (db *sqlImpl) MigrateBetaMeetingsToNonBeta() error {
-	_, err := db.db.Exec("UPDATE meetings SET is_beta=false WHERE is_beta=true")
+	_, err := db.db.Exec("UPDATE meetings SET is_beta=false WHERE is_beta=true AND timetable_version IS NULL")
 	return err
 }
 
+// DeleteBetaMeetings izbriše beta srečanja, ki ne pripadajo nobeni različici urnika.
+// Skrita srečanja prejšnjih različic ostanejo, saj jih potrebujemo za povrnitev urnika.
 func (db *sqlImpl) DeleteBetaMeetings() error {
-	_, err := db.db.Exec("DELETE FROM meetings WHERE is_beta=true")
+	_, err := db.db.Exec("DELETE FROM meetings WHERE is_beta=true AND timetable_version IS NULL")
 	return err
 }
 
@@ -0,0 +1,76 @@
+package sql
+
+const PLANNED_ABSENCE_COMPETITION = 0
+const PLANNED_ABSENCE_EXCURSION = 1
+const PLANNED_ABSENCE_MEDICAL = 2
+const PLANNED_ABSENCE_OTHER = 3
+
+// PlannedAbsence je vnaprej napovedana (in opravičena) odsotnost učenca ali celega razreda,
+// npr. zaradi tekmovanja ali ekskurzije. Obdobje traja od StartHour na StartDate do EndHour na EndDate.
+// Če ura ni podana, velja za cel dan.
+type PlannedAbsence struct {
+	ID         string
+	UserID     *string `db:"user_id"`
+	ClassID    *string `db:"class_id"`
+	StartDate  string  `db:"start_date"`
+	EndDate    string  `db:"end_date"`
+	StartHour  *int    `db:"start_hour"`
+	EndHour    *int    `db:"end_hour"`
+	ReasonType int     `db:"reason_type"`
+	Reason     string
+	CreatedBy  string `db:"created_by"`
+
+	CreatedAt string `db:"created_at"`
+	UpdatedAt string `db:"updated_at"`
+}
+
+// AbsenceType vrne vrsto odsotnosti, s katero se vpiše učenec na napovedani odsotnosti.
+func (absence PlannedAbsence) AbsenceType() string {
+	if absence.ReasonType == PLANNED_ABSENCE_COMPETITION || absence.ReasonType == PLANNED_ABSENCE_EXCURSION {
+		return ABSENCE_SCHOOL_ACTIVITY
+	}
+	return ABSENCE_ABSENT
+}
+
+func (db *sqlImpl) GetPlannedAbsence(id string) (absence PlannedAbsence, err error) {
+	err = db.db.Get(&absence, "SELECT * FROM planned_absences WHERE id=$1", id)
+	return absence, err
+}
+
+// GetPlannedAbsencesBetween vrne napovedane odsotnosti, ki se prekrivajo z obdobjem med start in end.
+func (db *sqlImpl) GetPlannedAbsencesBetween(start string, end string) (absences []PlannedAbsence, err error) {
+	err = db.db.Select(
+		&absences,
+		`SELECT * FROM planned_absences WHERE to_date(start_date, 'DD-MM-YYYY')<=to_date($2, 'DD-MM-YYYY') AND to_date(end_date, 'DD-MM-YYYY')>=to_date($1, 'DD-MM-YYYY')
+			ORDER BY to_date(start_date, 'DD-MM-YYYY') ASC`,
+		start, end,
+	)
+	if absences == nil {
+		absences = make([]PlannedAbsence, 0)
+	}
+	return absences, err
+}
+
+// InsertPlannedAbsences v eni transakciji shrani napovedane odsotnosti (npr. za več učencev hkrati).
+func (db *sqlImpl) InsertPlannedAbsences(absences []PlannedAbsence) error {
+	tx, err := db.db.Beginx()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(absences); i++ {
+		_, err = tx.NamedExec(
+			`INSERT INTO planned_absences (user_id, class_id, start_date, end_date, start_hour, end_hour, reason_type, reason, created_by)
+				VALUES (:user_id, :class_id, :start_date, :end_date, :start_hour, :end_hour, :reason_type, :reason, :created_by)`,
+			absences[i])
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (db *sqlImpl) DeletePlannedAbsence(id string) error {
+	_, err := db.db.Exec("DELETE FROM planned_absences WHERE id=$1", id)
+	return err
+}
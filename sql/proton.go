@@ -0,0 +1,111 @@
+package sql
+
+type ProtonRule struct {
+	ID       string
+	RuleName string `db:"rule_name"`
+	RuleType int    `db:"rule_type"`
+	Objects  string
+
+	CreatedAt string `db:"created_at"`
+	UpdatedAt string `db:"updated_at"`
+}
+
+// ProtonConfigVersion je posnetek celotnega nabora Proton pravil ob neki spremembi.
+// Rules vsebuje JSON serializirana pravila, tako da lahko kadarkoli obnovimo prejšnje stanje.
+type ProtonConfigVersion struct {
+	ID           string
+	Version      int
+	Rules        string
+	CreatedBy    *string `db:"created_by"`
+	RestoredFrom *int    `db:"restored_from"`
+
+	CreatedAt string `db:"created_at"`
+	UpdatedAt string `db:"updated_at"`
+}
+
+func (db *sqlImpl) GetProtonRules() (rules []ProtonRule, err error) {
+	err = db.db.Select(&rules, "SELECT * FROM proton_rules ORDER BY created_at ASC")
+	if rules == nil {
+		rules = make([]ProtonRule, 0)
+	}
+	return rules, err
+}
+
+func (db *sqlImpl) InsertProtonRule(rule ProtonRule) (id string, err error) {
+	err = db.db.Get(
+		&id,
+		"INSERT INTO proton_rules (rule_name, rule_type, objects) VALUES ($1, $2, $3) RETURNING id",
+		rule.RuleName, rule.RuleType, rule.Objects,
+	)
+	return id, err
+}
+
+func (db *sqlImpl) DeleteProtonRule(ID string) error {
+	_, err := db.db.Exec("DELETE FROM proton_rules WHERE id=$1", ID)
+	return err
+}
+
+// ReplaceProtonRules v eni transakciji zamenja vsa trenutna pravila s podanimi (uporabljeno pri obnovi prejšnje različice).
+func (db *sqlImpl) ReplaceProtonRules(rules []ProtonRule) error {
+	tx, err := db.db.Beginx()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM proton_rules")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	for i := 0; i < len(rules); i++ {
+		_, err = tx.NamedExec(
+			"INSERT INTO proton_rules (id, rule_name, rule_type, objects) VALUES (:id, :rule_name, :rule_type, :objects)",
+			rules[i])
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (db *sqlImpl) GetProtonConfigVersions() (versions []ProtonConfigVersion, err error) {
+	err = db.db.Select(&versions, "SELECT * FROM proton_config_versions ORDER BY version DESC")
+	if versions == nil {
+		versions = make([]ProtonConfigVersion, 0)
+	}
+	return versions, err
+}
+
+func (db *sqlImpl) GetProtonConfigVersion(version int) (configVersion ProtonConfigVersion, err error) {
+	err = db.db.Get(&configVersion, "SELECT * FROM proton_config_versions WHERE version=$1", version)
+	return configVersion, err
+}
+
+func (db *sqlImpl) GetLatestProtonConfigVersion() (configVersion ProtonConfigVersion, err error) {
+	err = db.db.Get(&configVersion, "SELECT * FROM proton_config_versions ORDER BY version DESC LIMIT 1")
+	return configVersion, err
+}
+
+func (db *sqlImpl) InsertProtonConfigVersion(configVersion ProtonConfigVersion) (version int, err error) {
+	tx, err := db.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	// Zaklenemo tabelo, da dve sočasni spremembi pravil ne dobita iste številke različice.
+	_, err = tx.Exec("LOCK TABLE proton_config_versions IN EXCLUSIVE MODE")
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	err = tx.Get(
+		&version,
+		`INSERT INTO proton_config_versions (version, rules, created_by, restored_from)
+			VALUES ((SELECT COALESCE(MAX(version), 0) + 1 FROM proton_config_versions), $1, $2, $3) RETURNING version`,
+		configVersion.Rules, configVersion.CreatedBy, configVersion.RestoredFrom,
+	)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return version, tx.Commit()
+}
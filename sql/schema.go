@@ -45,6 +45,7 @@ CREATE TABLE IF NOT EXISTS classes (
 	teacher                  UUID,
 	sok                      INTEGER,
 	eok                      INTEGER,
+	grading_scale_id         UUID,
 	
 	created_at               TIMESTAMP      NOT NULL DEFAULT now(),
 	updated_at               TIMESTAMP      NOT NULL DEFAULT now(),
@@ -64,6 +65,7 @@ CREATE TABLE IF NOT EXISTS subject (
 	selected_hours          FLOAT           DEFAULT(1.0),
 	color                   VARCHAR(10),
 	is_graded               BOOLEAN,
+	grading_scale_id        UUID,
 	
 	created_at              TIMESTAMP      NOT NULL DEFAULT now(),
 	updated_at              TIMESTAMP      NOT NULL DEFAULT now(),
@@ -103,6 +105,10 @@ CREATE TABLE IF NOT EXISTS meetings (
 	is_test                 BOOLEAN         NOT NULL,
 	is_substitution         BOOLEAN         NOT NULL,
 	is_beta                 BOOLEAN         NOT NULL,
+	proton_config_version   INTEGER,
+	timetable_version       INTEGER,
+	series_id               UUID,
+	attendance_taken_at     TIMESTAMP,
 	
 	created_at              TIMESTAMP      NOT NULL DEFAULT now(),
 	updated_at              TIMESTAMP      NOT NULL DEFAULT now(),
@@ -117,6 +123,7 @@ CREATE TABLE IF NOT EXISTS absence (
 	teacher_id              UUID,
 	absence_type            VARCHAR(200),
 	is_excused              BOOLEAN,
+	excuse_id               UUID,
 	
 	created_at              TIMESTAMP      NOT NULL DEFAULT now(),
 	updated_at              TIMESTAMP      NOT NULL DEFAULT now(),
@@ -134,6 +141,7 @@ CREATE TABLE IF NOT EXISTS gradings (
 	grading_type            INTEGER,
 	school_year             VARCHAR(11),
 	period                  INTEGER,
+	weight                  FLOAT          NOT NULL        DEFAULT 1,
 	
 	created_at              TIMESTAMP      NOT NULL DEFAULT now(),
 	updated_at              TIMESTAMP      NOT NULL DEFAULT now(),
@@ -171,6 +179,9 @@ CREATE TABLE IF NOT EXISTS grades (
 	is_final                BOOLEAN,
 	can_patch               BOOLEAN,
 	description             VARCHAR(200),
+	points                  FLOAT,
+	assessment              VARCHAR(1000)  NOT NULL        DEFAULT '',
+	category                INTEGER        NOT NULL        DEFAULT 0,
 	
 	created_at              TIMESTAMP      NOT NULL DEFAULT now(),
 	updated_at              TIMESTAMP      NOT NULL DEFAULT now(),
@@ -275,11 +286,298 @@ CREATE TABLE IF NOT EXISTS documents (
     exported_by             UUID            NOT NULL,
     document_type           INTEGER         NOT NULL,
     is_signed               BOOLEAN         NOT NULL,
+    batch_id                UUID,
+    sha256                  VARCHAR(64)     NOT NULL        DEFAULT '',
+    is_revoked              BOOLEAN         NOT NULL        DEFAULT false,
+    revoked_at              TIMESTAMP,
+    revoked_by              UUID,
+    revocation_reason       TEXT            NOT NULL        DEFAULT '',
+    supersedes              VARCHAR(50),
+    legal_hold              BOOLEAN         NOT NULL        DEFAULT false,
+    issued_for              UUID,
     
     created_at              TIMESTAMP      NOT NULL DEFAULT now(),
 	updated_at              TIMESTAMP      NOT NULL DEFAULT now(),
     
-    CONSTRAINT FK_DocumentsExporter FOREIGN KEY (exported_by) REFERENCES users(id)
+    CONSTRAINT FK_DocumentsExporter FOREIGN KEY (exported_by) REFERENCES users(id),
+    CONSTRAINT FK_DocumentsRevokedBy FOREIGN KEY (revoked_by) REFERENCES users(id),
+    CONSTRAINT FK_DocumentsSupersedes FOREIGN KEY (supersedes) REFERENCES documents(id) ON DELETE SET NULL,
+    CONSTRAINT FK_DocumentsIssuedFor FOREIGN KEY (issued_for) REFERENCES users(id) ON DELETE SET NULL
+);
+CREATE TABLE IF NOT EXISTS proton_rules (
+	id                      UUID           PRIMARY KEY     DEFAULT gen_random_uuid(),
+	rule_name               VARCHAR(200)   NOT NULL,
+	rule_type               INTEGER        NOT NULL,
+	objects                 JSON           DEFAULT('[]'),
+	
+	created_at              TIMESTAMP      NOT NULL DEFAULT now(),
+	updated_at              TIMESTAMP      NOT NULL DEFAULT now()
+);
+CREATE TABLE IF NOT EXISTS proton_config_versions (
+	id                      UUID           PRIMARY KEY     DEFAULT gen_random_uuid(),
+	version                 INTEGER        NOT NULL        UNIQUE,
+	rules                   JSON           DEFAULT('[]'),
+	created_by              UUID,
+	restored_from           INTEGER,
+	
+	created_at              TIMESTAMP      NOT NULL DEFAULT now(),
+	updated_at              TIMESTAMP      NOT NULL DEFAULT now(),
+
+	CONSTRAINT FK_ProtonConfigVersionsCreator FOREIGN KEY (created_by) REFERENCES users(id)
+);
+CREATE TABLE IF NOT EXISTS timetable_versions (
+	id                      UUID           PRIMARY KEY     DEFAULT gen_random_uuid(),
+	version                 INTEGER        NOT NULL        UNIQUE,
+	name                    VARCHAR(200)   NOT NULL,
+	status                  INTEGER        NOT NULL,
+	created_by              UUID,
+	proton_config_version   INTEGER,
+	previous_version        INTEGER,
+	published_at            TIMESTAMP,
+	
+	created_at              TIMESTAMP      NOT NULL DEFAULT now(),
+	updated_at              TIMESTAMP      NOT NULL DEFAULT now(),
+
+	CONSTRAINT FK_TimetableVersionsCreator FOREIGN KEY (created_by) REFERENCES users(id)
+);
+CREATE TABLE IF NOT EXISTS calendar_events (
+	id                      UUID           PRIMARY KEY     DEFAULT gen_random_uuid(),
+	name                    VARCHAR(200)   NOT NULL,
+	event_type              INTEGER        NOT NULL,
+	start_date              VARCHAR(20)    NOT NULL,
+	end_date                VARCHAR(20)    NOT NULL,
+	runs_as                 INTEGER,
+	week                    INTEGER,
+	term                    INTEGER,
+	class_id                UUID,
+	closing_date            VARCHAR(20),
+	
+	created_at              TIMESTAMP      NOT NULL DEFAULT now(),
+	updated_at              TIMESTAMP      NOT NULL DEFAULT now(),
+
+	CONSTRAINT FK_CalendarEventsClass FOREIGN KEY (class_id) REFERENCES classes(id)
+);
+CREATE TABLE IF NOT EXISTS excuses (
+	id                      UUID           PRIMARY KEY     DEFAULT gen_random_uuid(),
+	student_id              UUID           NOT NULL,
+	submitted_by            UUID           NOT NULL,
+	start_date              VARCHAR(20)    NOT NULL,
+	end_date                VARCHAR(20)    NOT NULL,
+	reason                  VARCHAR(1000)  NOT NULL,
+	document_path           VARCHAR(300)   NOT NULL        DEFAULT '',
+	document_name           VARCHAR(300)   NOT NULL        DEFAULT '',
+	status                  INTEGER        NOT NULL        DEFAULT 0,
+	reviewed_by             UUID,
+	
+	created_at              TIMESTAMP      NOT NULL DEFAULT now(),
+	updated_at              TIMESTAMP      NOT NULL DEFAULT now(),
+
+	CONSTRAINT FK_ExcusesStudent   FOREIGN KEY (student_id)   REFERENCES users(id),
+	CONSTRAINT FK_ExcusesSubmitter FOREIGN KEY (submitted_by) REFERENCES users(id),
+	CONSTRAINT FK_ExcusesReviewer  FOREIGN KEY (reviewed_by)  REFERENCES users(id)
+);
+CREATE TABLE IF NOT EXISTS excuse_history (
+	id                      UUID           PRIMARY KEY     DEFAULT gen_random_uuid(),
+	excuse_id               UUID           NOT NULL,
+	user_id                 UUID           NOT NULL,
+	status                  INTEGER        NOT NULL,
+	comment                 VARCHAR(1000)  NOT NULL        DEFAULT '',
+	
+	created_at              TIMESTAMP      NOT NULL DEFAULT now(),
+	updated_at              TIMESTAMP      NOT NULL DEFAULT now(),
+
+	CONSTRAINT FK_ExcuseHistoryExcuse FOREIGN KEY (excuse_id) REFERENCES excuses(id),
+	CONSTRAINT FK_ExcuseHistoryUser   FOREIGN KEY (user_id)   REFERENCES users(id)
+);
+CREATE TABLE IF NOT EXISTS absence_alerts (
+	id                      UUID           PRIMARY KEY     DEFAULT gen_random_uuid(),
+	user_id                 UUID           NOT NULL,
+	alert_type              INTEGER        NOT NULL,
+	subject_id              UUID,
+	period_start            VARCHAR(20)    NOT NULL,
+	
+	created_at              TIMESTAMP      NOT NULL DEFAULT now(),
+	updated_at              TIMESTAMP      NOT NULL DEFAULT now(),
+
+	CONSTRAINT FK_AbsenceAlertsUser    FOREIGN KEY (user_id)    REFERENCES users(id) ON DELETE CASCADE,
+	CONSTRAINT FK_AbsenceAlertsSubject FOREIGN KEY (subject_id) REFERENCES subject(id) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS planned_absences (
+	id                      UUID           PRIMARY KEY     DEFAULT gen_random_uuid(),
+	user_id                 UUID,
+	class_id                UUID,
+	start_date              VARCHAR(20)    NOT NULL,
+	end_date                VARCHAR(20)    NOT NULL,
+	start_hour              INTEGER,
+	end_hour                INTEGER,
+	reason_type             INTEGER        NOT NULL,
+	reason                  VARCHAR(1000)  NOT NULL,
+	created_by              UUID           NOT NULL,
+	
+	created_at              TIMESTAMP      NOT NULL DEFAULT now(),
+	updated_at              TIMESTAMP      NOT NULL DEFAULT now(),
+
+	CONSTRAINT FK_PlannedAbsencesUser    FOREIGN KEY (user_id)    REFERENCES users(id) ON DELETE CASCADE,
+	CONSTRAINT FK_PlannedAbsencesClass   FOREIGN KEY (class_id)   REFERENCES classes(id) ON DELETE CASCADE,
+	CONSTRAINT FK_PlannedAbsencesCreator FOREIGN KEY (created_by) REFERENCES users(id)
+);
+CREATE TABLE IF NOT EXISTS grading_scales (
+	id                      UUID           PRIMARY KEY     DEFAULT gen_random_uuid(),
+	name                    VARCHAR(200)   NOT NULL,
+	scale_type              INTEGER        NOT NULL,
+	min_grade               INTEGER        NOT NULL,
+	max_grade               INTEGER        NOT NULL,
+	passing_grade           INTEGER        NOT NULL,
+	labels                  JSON           NOT NULL        DEFAULT('[]'),
+	points_mapping          JSON           NOT NULL        DEFAULT('[]'),
+	max_points              FLOAT          NOT NULL        DEFAULT 0,
+	
+	created_at              TIMESTAMP      NOT NULL DEFAULT now(),
+	updated_at              TIMESTAMP      NOT NULL DEFAULT now()
+);
+CREATE TABLE IF NOT EXISTS grading_policies (
+	id                      UUID           PRIMARY KEY     DEFAULT gen_random_uuid(),
+	subject_id              UUID           NOT NULL        UNIQUE,
+	average_type            INTEGER        NOT NULL        DEFAULT 0,
+	best_n                  INTEGER        NOT NULL        DEFAULT 0,
+	oral_weight             FLOAT          NOT NULL        DEFAULT 1,
+	written_weight          FLOAT          NOT NULL        DEFAULT 1,
+	other_weight            FLOAT          NOT NULL        DEFAULT 1,
+	project_weight          FLOAT          NOT NULL        DEFAULT 1,
+	homework_weight         FLOAT          NOT NULL        DEFAULT 1,
+	
+	created_at              TIMESTAMP      NOT NULL DEFAULT now(),
+	updated_at              TIMESTAMP      NOT NULL DEFAULT now(),
+
+	CONSTRAINT FK_GradingPoliciesSubject FOREIGN KEY (subject_id) REFERENCES subject(id) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS lesson_logs (
+	id                      UUID           PRIMARY KEY     DEFAULT gen_random_uuid(),
+	meeting_id              UUID           NOT NULL        UNIQUE,
+	subject_id              UUID           NOT NULL,
+	teacher_id              UUID           NOT NULL,
+	lesson_number           INTEGER        NOT NULL,
+	topic                   VARCHAR(500)   NOT NULL,
+	notes                   TEXT           NOT NULL        DEFAULT '',
+	is_signed               BOOLEAN        NOT NULL        DEFAULT false,
+	signed_at               TIMESTAMP,
+	
+	created_at              TIMESTAMP      NOT NULL DEFAULT now(),
+	updated_at              TIMESTAMP      NOT NULL DEFAULT now(),
+
+	CONSTRAINT FK_LessonLogsMeeting FOREIGN KEY (meeting_id) REFERENCES meetings(id) ON DELETE RESTRICT,
+	CONSTRAINT FK_LessonLogsSubject FOREIGN KEY (subject_id) REFERENCES subject(id) ON DELETE CASCADE,
+	CONSTRAINT FK_LessonLogsTeacher FOREIGN KEY (teacher_id) REFERENCES users(id),
+	CONSTRAINT UQ_LessonLogsNumber  UNIQUE (subject_id, lesson_number)
+);
+CREATE TABLE IF NOT EXISTS teacher_absences (
+	id                      UUID           PRIMARY KEY     DEFAULT gen_random_uuid(),
+	teacher_id              UUID           NOT NULL,
+	start_date              VARCHAR(20)    NOT NULL,
+	end_date                VARCHAR(20)    NOT NULL,
+	reason                  VARCHAR(1000)  NOT NULL,
+	created_by              UUID,
+	
+	created_at              TIMESTAMP      NOT NULL DEFAULT now(),
+	updated_at              TIMESTAMP      NOT NULL DEFAULT now(),
+
+	CONSTRAINT FK_TeacherAbsencesTeacher FOREIGN KEY (teacher_id) REFERENCES users(id),
+	CONSTRAINT FK_TeacherAbsencesCreator FOREIGN KEY (created_by) REFERENCES users(id)
+);
+CREATE TABLE IF NOT EXISTS substitutions (
+	id                      UUID           PRIMARY KEY     DEFAULT gen_random_uuid(),
+	absence_id              UUID,
+	meeting_id              UUID           NOT NULL,
+	original_teacher_id     UUID           NOT NULL,
+	substitute_teacher_id   UUID           NOT NULL,
+	is_paid                 BOOLEAN        NOT NULL        DEFAULT true,
+	
+	created_at              TIMESTAMP      NOT NULL DEFAULT now(),
+	updated_at              TIMESTAMP      NOT NULL DEFAULT now(),
+
+	CONSTRAINT FK_SubstitutionsAbsence    FOREIGN KEY (absence_id)            REFERENCES teacher_absences(id) ON DELETE CASCADE,
+	CONSTRAINT FK_SubstitutionsMeeting    FOREIGN KEY (meeting_id)            REFERENCES meetings(id) ON DELETE CASCADE,
+	CONSTRAINT FK_SubstitutionsOriginal   FOREIGN KEY (original_teacher_id)   REFERENCES users(id),
+	CONSTRAINT FK_SubstitutionsSubstitute FOREIGN KEY (substitute_teacher_id) REFERENCES users(id)
+);
+CREATE TABLE IF NOT EXISTS final_grade_proposals (
+	id                      UUID           PRIMARY KEY     DEFAULT gen_random_uuid(),
+	user_id                 UUID           NOT NULL,
+	subject_id              UUID           NOT NULL,
+	teacher_id              UUID           NOT NULL,
+	school_year             VARCHAR(20)    NOT NULL,
+	grade                   INTEGER        NOT NULL,
+	assessment              TEXT           NOT NULL        DEFAULT '',
+	average                 FLOAT          NOT NULL        DEFAULT 0,
+	auto_grade              INTEGER,
+	justification           TEXT           NOT NULL        DEFAULT '',
+	
+	created_at              TIMESTAMP      NOT NULL DEFAULT now(),
+	updated_at              TIMESTAMP      NOT NULL DEFAULT now(),
+
+	UNIQUE (user_id, subject_id, school_year),
+	CONSTRAINT FK_FinalGradeProposalsUser    FOREIGN KEY (user_id)    REFERENCES users(id) ON DELETE CASCADE,
+	CONSTRAINT FK_FinalGradeProposalsSubject FOREIGN KEY (subject_id) REFERENCES subject(id) ON DELETE CASCADE,
+	CONSTRAINT FK_FinalGradeProposalsTeacher FOREIGN KEY (teacher_id) REFERENCES users(id)
+);
+CREATE TABLE IF NOT EXISTS class_conferences (
+	id                      UUID           PRIMARY KEY     DEFAULT gen_random_uuid(),
+	class_id                UUID           NOT NULL,
+	school_year             VARCHAR(20)    NOT NULL,
+	confirmed_by            UUID           NOT NULL,
+	notes                   TEXT           NOT NULL        DEFAULT '',
+	
+	created_at              TIMESTAMP      NOT NULL DEFAULT now(),
+	updated_at              TIMESTAMP      NOT NULL DEFAULT now(),
+
+	UNIQUE (class_id, school_year),
+	CONSTRAINT FK_ClassConferencesClass FOREIGN KEY (class_id)     REFERENCES classes(id) ON DELETE CASCADE,
+	CONSTRAINT FK_ClassConferencesUser  FOREIGN KEY (confirmed_by) REFERENCES users(id)
+);
+CREATE TABLE IF NOT EXISTS written_assessment_overrides (
+	id                      UUID           PRIMARY KEY     DEFAULT gen_random_uuid(),
+	subject_id              UUID           NOT NULL,
+	date                    VARCHAR(20)    NOT NULL,
+	hour                    INTEGER        NOT NULL,
+	reason                  TEXT           NOT NULL,
+	created_by              UUID           NOT NULL,
+	
+	created_at              TIMESTAMP      NOT NULL DEFAULT now(),
+	updated_at              TIMESTAMP      NOT NULL DEFAULT now(),
+
+	CONSTRAINT FK_WrittenAssessmentOverridesSubject FOREIGN KEY (subject_id) REFERENCES subject(id) ON DELETE CASCADE,
+	CONSTRAINT FK_WrittenAssessmentOverridesUser    FOREIGN KEY (created_by) REFERENCES users(id)
+);
+CREATE TABLE IF NOT EXISTS document_templates (
+	id                      UUID           PRIMARY KEY     DEFAULT gen_random_uuid(),
+	name                    VARCHAR(100)   NOT NULL,
+	version                 INTEGER        NOT NULL,
+	definition              TEXT           NOT NULL,
+	background              VARCHAR(300),
+	is_active               BOOLEAN        NOT NULL        DEFAULT false,
+	created_by              UUID           NOT NULL,
+	
+	created_at              TIMESTAMP      NOT NULL DEFAULT now(),
+	updated_at              TIMESTAMP      NOT NULL DEFAULT now(),
+
+	UNIQUE (name, version),
+	CONSTRAINT FK_DocumentTemplatesUser FOREIGN KEY (created_by) REFERENCES users(id)
+);
+CREATE TABLE IF NOT EXISTS document_batches (
+	id                      UUID           PRIMARY KEY     DEFAULT gen_random_uuid(),
+	class_id                UUID           NOT NULL,
+	document_type           INTEGER        NOT NULL,
+	status                  VARCHAR(20)    NOT NULL,
+	total                   INTEGER        NOT NULL        DEFAULT 0,
+	completed               INTEGER        NOT NULL        DEFAULT 0,
+	error                   TEXT           NOT NULL        DEFAULT '',
+	created_by              UUID           NOT NULL,
+	
+	created_at              TIMESTAMP      NOT NULL DEFAULT now(),
+	updated_at              TIMESTAMP      NOT NULL DEFAULT now(),
+
+	CONSTRAINT FK_DocumentBatchesClass FOREIGN KEY (class_id)   REFERENCES classes(id) ON DELETE CASCADE,
+	CONSTRAINT FK_DocumentBatchesUser  FOREIGN KEY (created_by) REFERENCES users(id)
 );
 
 CREATE OR REPLACE TRIGGER update_users_updated_at BEFORE UPDATE ON users FOR EACH ROW EXECUTE PROCEDURE update_changetimestamp_column();
@@ -299,5 +597,23 @@ CREATE OR REPLACE TRIGGER update_improvements_updated_at BEFORE UPDATE ON improv
 CREATE OR REPLACE TRIGGER update_documents_updated_at BEFORE UPDATE ON documents FOR EACH ROW EXECUTE PROCEDURE update_changetimestamp_column();
 CREATE OR REPLACE TRIGGER update_gradings_updated_at BEFORE UPDATE ON gradings FOR EACH ROW EXECUTE PROCEDURE update_changetimestamp_column();
 CREATE OR REPLACE TRIGGER update_grading_terms_updated_at BEFORE UPDATE ON grading_terms FOR EACH ROW EXECUTE PROCEDURE update_changetimestamp_column();
+CREATE OR REPLACE TRIGGER update_proton_rules_updated_at BEFORE UPDATE ON proton_rules FOR EACH ROW EXECUTE PROCEDURE update_changetimestamp_column();
+CREATE OR REPLACE TRIGGER update_proton_config_versions_updated_at BEFORE UPDATE ON proton_config_versions FOR EACH ROW EXECUTE PROCEDURE update_changetimestamp_column();
+CREATE OR REPLACE TRIGGER update_timetable_versions_updated_at BEFORE UPDATE ON timetable_versions FOR EACH ROW EXECUTE PROCEDURE update_changetimestamp_column();
+CREATE OR REPLACE TRIGGER update_calendar_events_updated_at BEFORE UPDATE ON calendar_events FOR EACH ROW EXECUTE PROCEDURE update_changetimestamp_column();
+CREATE OR REPLACE TRIGGER update_teacher_absences_updated_at BEFORE UPDATE ON teacher_absences FOR EACH ROW EXECUTE PROCEDURE update_changetimestamp_column();
+CREATE OR REPLACE TRIGGER update_substitutions_updated_at BEFORE UPDATE ON substitutions FOR EACH ROW EXECUTE PROCEDURE update_changetimestamp_column();
+CREATE OR REPLACE TRIGGER update_excuses_updated_at BEFORE UPDATE ON excuses FOR EACH ROW EXECUTE PROCEDURE update_changetimestamp_column();
+CREATE OR REPLACE TRIGGER update_excuse_history_updated_at BEFORE UPDATE ON excuse_history FOR EACH ROW EXECUTE PROCEDURE update_changetimestamp_column();
+CREATE OR REPLACE TRIGGER update_absence_alerts_updated_at BEFORE UPDATE ON absence_alerts FOR EACH ROW EXECUTE PROCEDURE update_changetimestamp_column();
+CREATE OR REPLACE TRIGGER update_planned_absences_updated_at BEFORE UPDATE ON planned_absences FOR EACH ROW EXECUTE PROCEDURE update_changetimestamp_column();
+CREATE OR REPLACE TRIGGER update_lesson_logs_updated_at BEFORE UPDATE ON lesson_logs FOR EACH ROW EXECUTE PROCEDURE update_changetimestamp_column();
+CREATE OR REPLACE TRIGGER update_grading_scales_updated_at BEFORE UPDATE ON grading_scales FOR EACH ROW EXECUTE PROCEDURE update_changetimestamp_column();
+CREATE OR REPLACE TRIGGER update_grading_policies_updated_at BEFORE UPDATE ON grading_policies FOR EACH ROW EXECUTE PROCEDURE update_changetimestamp_column();
+CREATE OR REPLACE TRIGGER update_final_grade_proposals_updated_at BEFORE UPDATE ON final_grade_proposals FOR EACH ROW EXECUTE PROCEDURE update_changetimestamp_column();
+CREATE OR REPLACE TRIGGER update_class_conferences_updated_at BEFORE UPDATE ON class_conferences FOR EACH ROW EXECUTE PROCEDURE update_changetimestamp_column();
+CREATE OR REPLACE TRIGGER update_written_assessment_overrides_updated_at BEFORE UPDATE ON written_assessment_overrides FOR EACH ROW EXECUTE PROCEDURE update_changetimestamp_column();
+CREATE OR REPLACE TRIGGER update_document_templates_updated_at BEFORE UPDATE ON document_templates FOR EACH ROW EXECUTE PROCEDURE update_changetimestamp_column();
+CREATE OR REPLACE TRIGGER update_document_batches_updated_at BEFORE UPDATE ON document_batches FOR EACH ROW EXECUTE PROCEDURE update_changetimestamp_column();
 
 `
@@ -60,6 +60,11 @@ type SQL interface {
 	GetMeetingsForTeacherOnSpecificDate(teacherId string, date string) (meetings []Meeting, err error)
 	InsertMeeting(meeting Meeting) (err error)
 	UpdateMeeting(meeting Meeting) error
+	InsertMeetings(meetings []Meeting) error
+	GetEditableMeetingsInSeries(seriesId string, from string) (meetings []Meeting, err error)
+	MeetingHasAttachments(id string) (hasAttachments bool, err error)
+	UpdateMeetings(meetings []Meeting) error
+	DeleteEditableMeetingsInSeries(seriesId string, from string) (int64, error)
 
 	GetMeetings() (meetings []Meeting, err error)
 	GetMeetingsForSubjectWithIDLower(createdAt string, subjectId string) (meetings []Meeting, err error)
@@ -70,15 +75,109 @@ type SQL interface {
 	MigrateBetaMeetingsToNonBeta() error
 	DeleteBetaMeetings() error
 
+	GetTimetableVersions() (versions []TimetableVersion, err error)
+	GetTimetableVersion(version int) (timetableVersion TimetableVersion, err error)
+	GetActiveTimetableVersion() (timetableVersion TimetableVersion, err error)
+	GetLatestDraftTimetableVersion() (timetableVersion TimetableVersion, err error)
+	GetMeetingsForTimetableVersion(version int) (meetings []Meeting, err error)
+	InsertTimetableVersion(timetableVersion TimetableVersion, meetings []Meeting) (int, error)
+	PublishTimetableVersion(version int) error
+	RollbackTimetableVersion(timetableVersion TimetableVersion) error
+	DeleteDraftTimetableVersion(version int) error
+
+	GetCalendarEvents() (events []CalendarEvent, err error)
+	GetCalendarEvent(id string) (event CalendarEvent, err error)
+	InsertCalendarEvent(event CalendarEvent) (err error)
+	InsertCalendarEvents(events []CalendarEvent) error
+	DeleteCalendarEvent(id string) error
+
+	GetTeacherAbsences() (absences []TeacherAbsence, err error)
+	GetTeacherAbsence(id string) (absence TeacherAbsence, err error)
+	GetTeacherAbsencesBetween(start string, end string) (absences []TeacherAbsence, err error)
+	InsertTeacherAbsence(absence TeacherAbsence) (id string, err error)
+	UpdateTeacherAbsence(absence TeacherAbsence) error
+	DeleteTeacherAbsence(id string) error
+	GetMeetingsForTeacherBetween(teacherId string, start string, end string) (meetings []Meeting, err error)
+	GetSubstitutionsForAbsence(absenceId string) (substitutions []Substitution, err error)
+	ApplySubstitutions(substitutions []Substitution) error
+	RevertSubstitutions(absenceId string, from string) error
+	UpsertSubstitutionForMeeting(substitution Substitution) error
+	DeleteSubstitutionForMeeting(meetingId string) error
+	SetSubstitutionPaid(id string, isPaid bool) error
+	GetSubstitutionStats(start string, end string) (stats []SubstitutionStats, err error)
+	GetSubstitutionsBetween(start string, end string) (substitutions []SubstitutionEntry, err error)
+
 	GetAbsence(id string) (absence Absence, err error)
 	GetAllAbsences(id string) (absences []Absence, err error)
 	InsertAbsence(absence Absence) error
 	UpdateAbsence(absence Absence) error
 	GetAbsenceForUserMeeting(meeting_id string, user_id string) (absence Absence, err error)
 	GetAbsencesForUser(user_id string) (absence []Absence, err error)
+	GetAbsencesForMeeting(meetingId string) (absences []Absence, err error)
+	SetAbsencesForMeeting(meetingId string, absences []Absence) error
+	DeleteAbsence(id string) error
 	DeleteAbsencesForTeacher(userId string)
 	DeleteAbsencesForUser(userId string)
 
+	GetExcuse(id string) (excuse Excuse, err error)
+	GetExcusesForStudent(studentId string) (excuses []Excuse, err error)
+	GetExcusesWithStatus(status int) (excuses []Excuse, err error)
+	GetApprovedExcuseForStudentOnDate(studentId string, date string) (excuse Excuse, err error)
+	GetExcuseHistory(excuseId string) (history []ExcuseHistory, err error)
+	InsertExcuse(excuse Excuse) (id string, err error)
+	SetExcuseStatus(excuse Excuse, status int, userId string, comment string) error
+
+	GetAbsenceStatsForUser(userId string, start string, end string) (stats []SubjectAbsenceStats, err error)
+	GetHeldMeetingCounts(start string, end string) (counts []SubjectMeetingCount, err error)
+	InsertAbsenceAlert(alert AbsenceAlert) (bool, error)
+	GetAbsenceAlertsForUser(userId string) (alerts []AbsenceAlert, err error)
+
+	GetPlannedAbsence(id string) (absence PlannedAbsence, err error)
+	GetPlannedAbsencesBetween(start string, end string) (absences []PlannedAbsence, err error)
+	InsertPlannedAbsences(absences []PlannedAbsence) error
+	DeletePlannedAbsence(id string) error
+
+	GetLessonLogForMeeting(meetingId string) (log LessonLog, err error)
+	GetLessonLogsForSubject(subjectId string) (logs []LessonLogEntry, err error)
+	InsertLessonLog(log LessonLog) (LessonLog, error)
+	UpdateLessonLog(log LessonLog) error
+	SignLessonLog(id string, isSigned bool) error
+	GetMeetingsWithoutLessonLog(start string, end string, teacherId string) (meetings []Meeting, err error)
+
+	GetGradingScale(id string) (scale GradingScale, err error)
+	GetGradingScales() (scales []GradingScale, err error)
+	InsertGradingScale(scale GradingScale) error
+	UpdateGradingScale(scale GradingScale) error
+	DeleteGradingScale(id string) error
+	SetSubjectGradingScale(subjectId string, scaleId *string) error
+	SetClassGradingScale(classId string, scaleId *string) error
+
+	GetGradingPolicyForSubject(subjectId string) (policy GradingPolicy, err error)
+	SetGradingPolicy(policy GradingPolicy) error
+
+	GetFinalGradeProposalsForSubject(subjectId string, schoolYear string) (proposals []FinalGradeProposal, err error)
+	SetFinalGradeProposal(proposal FinalGradeProposal) error
+	DeleteFinalGradeProposal(userId string, subjectId string, schoolYear string) error
+	GetClassConference(classId string, schoolYear string) (conference ClassConference, err error)
+	ConfirmClassConference(conference ClassConference, grades []Grade, passing map[string]bool) error
+
+	GetGradeStatsForTerm(termId string) (stats GradeStats, err error)
+	GetGradeDistributionForTerm(termId string) (distribution []GradeCount, err error)
+	GetGradeStatsForLongName(longName string, since string) (stats []SubjectGradeStats, err error)
+	GetTeacherGradeStatsForLongName(longName string, since string) (stats []TeacherGradeStats, err error)
+	GetPeriodGradeStatsForLongName(longName string, since string) (stats []PeriodGradeStats, err error)
+	GetStudentSubjectsWithGrades(since string) (students []StudentSubject, err error)
+
+	GetWrittenAssessmentsBetween(start string, end string) (assessments []WrittenAssessment, err error)
+	GetWrittenAssessmentOverridesBetween(start string, end string) (overrides []WrittenAssessmentOverride, err error)
+	InsertWrittenAssessmentOverride(override WrittenAssessmentOverride) error
+
+	GetDocumentTemplates(name string) (templates []DocumentTemplate, err error)
+	GetDocumentTemplate(name string, version int) (template DocumentTemplate, err error)
+	GetActiveDocumentTemplate(name string) (template DocumentTemplate, err error)
+	InsertDocumentTemplate(template DocumentTemplate) (version int, err error)
+	ActivateDocumentTemplate(name string, version int) error
+
 	GetSubject(id string) (subject Subject, err error)
 	GetAllSubjectsForTeacher(id string) (subject []Subject, err error)
 	GetAllSubjectsForUser(id string) (subject []Subject, err error)
@@ -87,6 +186,7 @@ type SQL interface {
 	UpdateSubject(subject Subject) error
 	GetAllSubjects() (subject []Subject, err error)
 	GetStudents() (message []User, err error)
+	GetParents() (parents []User, err error)
 	DeleteSubject(subject Subject) error
 	DeleteStudentSubject(userId string)
 
@@ -95,6 +195,7 @@ type SQL interface {
 	GetGradesForTerm(termId string) (grades []Grade, err error)
 	GetGradeForTermAndUser(termId string, userId string) (grade Grade, err error)
 	GetGradesForUserInSubject(userId string, subjectId string) (grades []Grade, err error)
+	GetGradesForUserInSubjectSince(userId string, subjectId string, since string) (grades []Grade, err error)
 	CheckIfFinal(userId string, subjectId string) (grade Grade, err error)
 	InsertGrade(grade Grade) error
 	UpdateGrade(grade Grade) error
@@ -139,6 +240,7 @@ type SQL interface {
 
 	GetCommunication(id string) (communication Communication, err error)
 	InsertCommunication(communication Communication) (err error)
+	InsertCommunicationWithMessage(communication Communication, message Message) error
 	UpdateCommunication(communication Communication) error
 
 	GetCommunications() (communication []Communication, err error)
@@ -177,7 +279,27 @@ type SQL interface {
 	GetDocument(id string) (document Document, err error)
 	GetAllDocuments() (documents []Document, err error)
 	InsertDocument(document Document) error
-	DeleteDocument(id string)
+	DeleteDocument(id string) error
+	RevokeDocument(id string, revokedBy string, reason string) error
+	SetDocumentLegalHold(id string, legalHold bool) error
+	GetSupersedingDocument(id string) (document Document, err error)
+	GetExpiredDocuments(documentType int, retentionDays int) (documents []Document, err error)
+	GetDocumentsForBatch(batchId string) (documents []Document, err error)
+
+	GetDocumentBatch(id string) (batch DocumentBatch, err error)
+	InsertDocumentBatch(batch DocumentBatch) (id string, err error)
+	UpdateDocumentBatch(batch DocumentBatch) error
+	IncrementDocumentBatchProgress(id string) error
+	FailInterruptedDocumentBatches() (batches []DocumentBatch, err error)
+
+	GetProtonRules() (rules []ProtonRule, err error)
+	InsertProtonRule(rule ProtonRule) (id string, err error)
+	DeleteProtonRule(ID string) error
+	ReplaceProtonRules(rules []ProtonRule) error
+	GetProtonConfigVersions() (versions []ProtonConfigVersion, err error)
+	GetProtonConfigVersion(version int) (configVersion ProtonConfigVersion, err error)
+	GetLatestProtonConfigVersion() (configVersion ProtonConfigVersion, err error)
+	InsertProtonConfigVersion(configVersion ProtonConfigVersion) (version int, err error)
 }
 
 func NewSQL(driver string, drivername string, logger *zap.SugaredLogger) (SQL, error) {
@@ -71,6 +71,16 @@ func (db *sqlImpl) GetStudentsHomeworkByHomeworkID(id string, meetingId string)
 		return make([]StudentHomeworkJSON, 0), err
 	}
 
+	// Prisotni učenci nimajo vrstice v absence, zato je status prazen le, če prisotnost za srečanje še ni bila oddana.
+	var attendanceTaken = false
+	if meetingId != "" {
+		meeting, err := db.GetMeeting(meetingId)
+		if err != nil && !errors.Is(err, sql2.ErrNoRows) {
+			return make([]StudentHomeworkJSON, 0), err
+		}
+		attendanceTaken = err == nil && meeting.AttendanceTakenAt != nil
+	}
+
 	homework = make([]StudentHomeworkJSON, 0)
 	for i := 0; i < len(students); i++ {
 		student, err := db.GetUser(students[i])
@@ -85,12 +95,12 @@ func (db *sqlImpl) GetStudentsHomeworkByHomeworkID(id string, meetingId string)
 				if err != nil {
 					if !errors.Is(err, sql2.ErrNoRows) {
 						return make([]StudentHomeworkJSON, 0), err
-					} else {
+					} else if !attendanceTaken {
 						status = ""
 					}
 				}
 				if status != "" {
-					if absence.AbsenceType == "ABSENT" {
+					if absence.AbsenceType == ABSENCE_ABSENT {
 						status = "ABSENT"
 					}
 				}
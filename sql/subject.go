@@ -18,6 +18,8 @@ type Subject struct {
 	Color         string
 	Location      string `db:"location"`
 	IsGraded      bool   `db:"is_graded"`
+	// Lestvica ocenjevanja. Če ni podana, velja lestvica razreda oziroma privzeta lestvica 1-5.
+	GradingScaleID *string `db:"grading_scale_id"`
 
 	CreatedAt string `db:"created_at"`
 	UpdatedAt string `db:"updated_at"`
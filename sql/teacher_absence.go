@@ -0,0 +1,228 @@
+package sql
+
+type TeacherAbsence struct {
+	ID        string
+	TeacherID string  `db:"teacher_id"`
+	StartDate string  `db:"start_date"`
+	EndDate   string  `db:"end_date"`
+	Reason    string  `db:"reason"`
+	CreatedBy *string `db:"created_by"`
+
+	CreatedAt string `db:"created_at"`
+	UpdatedAt string `db:"updated_at"`
+}
+
+// Substitution zapiše nadomeščanje, tako da ga lahko ob vrnitvi učitelja razveljavimo in da vodimo zgodovino nadomeščanj.
+// Ročna nadomeščanja (PatchMeeting) nimajo odsotnosti.
+type Substitution struct {
+	ID                  string
+	AbsenceID           *string `db:"absence_id"`
+	MeetingID           string  `db:"meeting_id"`
+	OriginalTeacherID   string  `db:"original_teacher_id"`
+	SubstituteTeacherID string  `db:"substitute_teacher_id"`
+	IsPaid              bool    `db:"is_paid"`
+
+	CreatedAt string `db:"created_at"`
+	UpdatedAt string `db:"updated_at"`
+}
+
+func (db *sqlImpl) GetTeacherAbsences() (absences []TeacherAbsence, err error) {
+	err = db.db.Select(&absences, "SELECT * FROM teacher_absences ORDER BY to_date(start_date, 'DD-MM-YYYY') DESC")
+	if absences == nil {
+		absences = make([]TeacherAbsence, 0)
+	}
+	return absences, err
+}
+
+func (db *sqlImpl) GetTeacherAbsence(id string) (absence TeacherAbsence, err error) {
+	err = db.db.Get(&absence, "SELECT * FROM teacher_absences WHERE id=$1", id)
+	return absence, err
+}
+
+// GetTeacherAbsencesBetween vrne odsotnosti, ki se prekrivajo z obdobjem med start in end (oblika 02-01-2006).
+func (db *sqlImpl) GetTeacherAbsencesBetween(start string, end string) (absences []TeacherAbsence, err error) {
+	err = db.db.Select(
+		&absences,
+		"SELECT * FROM teacher_absences WHERE to_date(start_date, 'DD-MM-YYYY')<=to_date($2, 'DD-MM-YYYY') AND to_date(end_date, 'DD-MM-YYYY')>=to_date($1, 'DD-MM-YYYY')",
+		start, end,
+	)
+	if absences == nil {
+		absences = make([]TeacherAbsence, 0)
+	}
+	return absences, err
+}
+
+func (db *sqlImpl) InsertTeacherAbsence(absence TeacherAbsence) (id string, err error) {
+	err = db.db.Get(
+		&id,
+		"INSERT INTO teacher_absences (teacher_id, start_date, end_date, reason, created_by) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		absence.TeacherID, absence.StartDate, absence.EndDate, absence.Reason, absence.CreatedBy,
+	)
+	return id, err
+}
+
+func (db *sqlImpl) UpdateTeacherAbsence(absence TeacherAbsence) error {
+	_, err := db.db.NamedExec(
+		"UPDATE teacher_absences SET start_date=:start_date, end_date=:end_date, reason=:reason WHERE id=:id",
+		absence)
+	return err
+}
+
+func (db *sqlImpl) DeleteTeacherAbsence(id string) error {
+	_, err := db.db.Exec("DELETE FROM teacher_absences WHERE id=$1", id)
+	return err
+}
+
+// GetMeetingsForTeacherBetween vrne objavljena srečanja učitelja med start in end, vključno z obema datumoma.
+func (db *sqlImpl) GetMeetingsForTeacherBetween(teacherId string, start string, end string) (meetings []Meeting, err error) {
+	err = db.db.Select(
+		&meetings,
+		"SELECT * FROM meetings WHERE teacher_id=$1 AND is_beta=false AND to_date(date, 'DD-MM-YYYY') BETWEEN to_date($2, 'DD-MM-YYYY') AND to_date($3, 'DD-MM-YYYY') ORDER BY to_date(date, 'DD-MM-YYYY') ASC, hour ASC",
+		teacherId, start, end,
+	)
+	if meetings == nil {
+		meetings = make([]Meeting, 0)
+	}
+	return meetings, err
+}
+
+func (db *sqlImpl) GetSubstitutionsForAbsence(absenceId string) (substitutions []Substitution, err error) {
+	err = db.db.Select(&substitutions, "SELECT * FROM substitutions WHERE absence_id=$1", absenceId)
+	if substitutions == nil {
+		substitutions = make([]Substitution, 0)
+	}
+	return substitutions, err
+}
+
+// ApplySubstitutions v eni transakciji zapiše nadomeščanja in srečanjem nastavi nadomestnega učitelja.
+func (db *sqlImpl) ApplySubstitutions(substitutions []Substitution) error {
+	tx, err := db.db.Beginx()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(substitutions); i++ {
+		substitution := substitutions[i]
+		_, err = tx.NamedExec(
+			`INSERT INTO substitutions (absence_id, meeting_id, original_teacher_id, substitute_teacher_id, is_paid)
+				VALUES (:absence_id, :meeting_id, :original_teacher_id, :substitute_teacher_id, :is_paid)`,
+			substitution)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		_, err = tx.Exec(
+			"UPDATE meetings SET teacher_id=$1, is_substitution=true WHERE id=$2",
+			substitution.SubstituteTeacherID, substitution.MeetingID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// RevertSubstitutions srečanjem vrne prvotnega učitelja in izbriše zapise o nadomeščanju.
+// Če from ni prazen, se razveljavijo samo nadomeščanja od tega datuma naprej (učitelj se je vrnil predčasno).
+func (db *sqlImpl) RevertSubstitutions(absenceId string, from string) error {
+	tx, err := db.db.Beginx()
+	if err != nil {
+		return err
+	}
+	condition := "absence_id=$1"
+	args := []interface{}{absenceId}
+	if from != "" {
+		condition += " AND meeting_id IN (SELECT id FROM meetings WHERE to_date(date, 'DD-MM-YYYY')>=to_date($2, 'DD-MM-YYYY'))"
+		args = append(args, from)
+	}
+	_, err = tx.Exec(
+		"UPDATE meetings SET teacher_id=substitutions.original_teacher_id, is_substitution=false FROM substitutions WHERE substitutions.meeting_id=meetings.id AND substitutions."+condition,
+		args...)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM substitutions WHERE "+condition, args...)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpsertSubstitutionForMeeting zapiše ročno nadomeščanje srečanja. Če srečanje že ima zapis o nadomeščanju, se mu le spremeni nadomestni učitelj.
+func (db *sqlImpl) UpsertSubstitutionForMeeting(substitution Substitution) error {
+	result, err := db.db.Exec("UPDATE substitutions SET substitute_teacher_id=$1 WHERE meeting_id=$2", substitution.SubstituteTeacherID, substitution.MeetingID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil || rows != 0 {
+		return err
+	}
+	_, err = db.db.NamedExec(
+		`INSERT INTO substitutions (absence_id, meeting_id, original_teacher_id, substitute_teacher_id, is_paid)
+			VALUES (:absence_id, :meeting_id, :original_teacher_id, :substitute_teacher_id, :is_paid)`,
+		substitution)
+	return err
+}
+
+func (db *sqlImpl) DeleteSubstitutionForMeeting(meetingId string) error {
+	_, err := db.db.Exec("DELETE FROM substitutions WHERE meeting_id=$1", meetingId)
+	return err
+}
+
+func (db *sqlImpl) SetSubstitutionPaid(id string, isPaid bool) error {
+	_, err := db.db.Exec("UPDATE substitutions SET is_paid=$1 WHERE id=$2", isPaid, id)
+	return err
+}
+
+// SubstitutionStats povzema nadomeščanja enega učitelja v nekem obdobju.
+type SubstitutionStats struct {
+	TeacherID   string `db:"teacher_id"`
+	Days        int    `db:"days"`
+	Hours       int    `db:"hours"`
+	PaidHours   int    `db:"paid_hours"`
+	UnpaidHours int    `db:"unpaid_hours"`
+}
+
+type SubstitutionEntry struct {
+	Substitution
+	Date        string `db:"date"`
+	Hour        int    `db:"hour"`
+	MeetingName string `db:"meeting_name"`
+}
+
+// GetSubstitutionStats vrne povzetek nadomeščanj po učiteljih med start in end, vključno z obema datumoma.
+func (db *sqlImpl) GetSubstitutionStats(start string, end string) (stats []SubstitutionStats, err error) {
+	err = db.db.Select(
+		&stats,
+		`SELECT substitutions.substitute_teacher_id AS teacher_id,
+			COUNT(DISTINCT meetings.date) AS days,
+			COUNT(*) AS hours,
+			COUNT(*) FILTER (WHERE substitutions.is_paid) AS paid_hours,
+			COUNT(*) FILTER (WHERE NOT substitutions.is_paid) AS unpaid_hours
+		FROM substitutions JOIN meetings ON meetings.id=substitutions.meeting_id
+		WHERE to_date(meetings.date, 'DD-MM-YYYY') BETWEEN to_date($1, 'DD-MM-YYYY') AND to_date($2, 'DD-MM-YYYY')
+		GROUP BY substitutions.substitute_teacher_id`,
+		start, end,
+	)
+	if stats == nil {
+		stats = make([]SubstitutionStats, 0)
+	}
+	return stats, err
+}
+
+func (db *sqlImpl) GetSubstitutionsBetween(start string, end string) (substitutions []SubstitutionEntry, err error) {
+	err = db.db.Select(
+		&substitutions,
+		`SELECT substitutions.*, meetings.date, meetings.hour, meetings.meeting_name
+		FROM substitutions JOIN meetings ON meetings.id=substitutions.meeting_id
+		WHERE to_date(meetings.date, 'DD-MM-YYYY') BETWEEN to_date($1, 'DD-MM-YYYY') AND to_date($2, 'DD-MM-YYYY')
+		ORDER BY to_date(meetings.date, 'DD-MM-YYYY') ASC, meetings.hour ASC`,
+		start, end,
+	)
+	if substitutions == nil {
+		substitutions = make([]SubstitutionEntry, 0)
+	}
+	return substitutions, err
+}
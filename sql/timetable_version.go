@@ -0,0 +1,205 @@
+package sql
+
+// Stanja različic urnika. Osnutek je sprejet, a še ni objavljen (srečanja so beta), aktivna različica je trenutno objavljen urnik.
+const (
+	TIMETABLE_VERSION_DRAFT       = 0
+	TIMETABLE_VERSION_ACTIVE      = 1
+	TIMETABLE_VERSION_SUPERSEDED  = 2
+	TIMETABLE_VERSION_ROLLED_BACK = 3
+)
+
+// meetingHasAttachments je pogoj, ki velja za izvedena srečanja (oddana prisotnost) in srečanja, na katera so že vezani
+// izostanki, opombe, vpisi v dnevnik, ocene ali domače naloge.
+// Ocene in domače naloge niso neposredno vezane na srečanje, zato jih povežemo prek predmeta in datuma.
+const meetingHasAttachments = `(
+	meetings.attendance_taken_at IS NOT NULL
+	OR EXISTS (SELECT 1 FROM absence WHERE absence.meeting_id=meetings.id)
+	OR EXISTS (SELECT 1 FROM improvements WHERE improvements.meeting_id=meetings.id)
+	OR EXISTS (SELECT 1 FROM lesson_logs WHERE lesson_logs.meeting_id=meetings.id)
+	OR EXISTS (SELECT 1 FROM grades WHERE grades.subject_id=meetings.subject_id AND LEFT(grades.date, 10)=to_char(to_date(meetings.date, 'DD-MM-YYYY'), 'YYYY-MM-DD'))
+	OR EXISTS (SELECT 1 FROM homework WHERE homework.subject_id=meetings.subject_id AND homework.from_date=to_char(to_date(meetings.date, 'DD-MM-YYYY'), 'YYYY-MM-DD'))
+)`
+
+type TimetableVersion struct {
+	ID                  string  `db:"id"`
+	Version             int     `db:"version"`
+	Name                string  `db:"name"`
+	Status              int     `db:"status"`
+	CreatedBy           *string `db:"created_by"`
+	ProtonConfigVersion *int    `db:"proton_config_version"`
+	PreviousVersion     *int    `db:"previous_version"`
+	PublishedAt         *string `db:"published_at"`
+
+	CreatedAt string `db:"created_at"`
+	UpdatedAt string `db:"updated_at"`
+}
+
+func (db *sqlImpl) GetTimetableVersions() (versions []TimetableVersion, err error) {
+	err = db.db.Select(&versions, "SELECT * FROM timetable_versions ORDER BY version DESC")
+	if versions == nil {
+		versions = make([]TimetableVersion, 0)
+	}
+	return versions, err
+}
+
+func (db *sqlImpl) GetTimetableVersion(version int) (timetableVersion TimetableVersion, err error) {
+	err = db.db.Get(&timetableVersion, "SELECT * FROM timetable_versions WHERE version=$1", version)
+	return timetableVersion, err
+}
+
+func (db *sqlImpl) GetActiveTimetableVersion() (timetableVersion TimetableVersion, err error) {
+	err = db.db.Get(&timetableVersion, "SELECT * FROM timetable_versions WHERE status=$1 ORDER BY version DESC LIMIT 1", TIMETABLE_VERSION_ACTIVE)
+	return timetableVersion, err
+}
+
+func (db *sqlImpl) GetLatestDraftTimetableVersion() (timetableVersion TimetableVersion, err error) {
+	err = db.db.Get(&timetableVersion, "SELECT * FROM timetable_versions WHERE status=$1 ORDER BY version DESC LIMIT 1", TIMETABLE_VERSION_DRAFT)
+	return timetableVersion, err
+}
+
+func (db *sqlImpl) GetMeetingsForTimetableVersion(version int) (meetings []Meeting, err error) {
+	err = db.db.Select(&meetings, "SELECT * FROM meetings WHERE timetable_version=$1 ORDER BY id ASC", version)
+	return meetings, err
+}
+
+// InsertTimetableVersion v eni transakciji ustvari nov osnutek urnika in vstavi vsa njegova (beta) srečanja.
+// Če vstavljanje kateregakoli srečanja spodleti, se ne shrani nič.
+func (db *sqlImpl) InsertTimetableVersion(timetableVersion TimetableVersion, meetings []Meeting) (int, error) {
+	tx, err := db.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	// Zaklenemo tabelo, da dve sočasni sprejemanji ne dobita iste številke različice.
+	_, err = tx.Exec("LOCK TABLE timetable_versions IN EXCLUSIVE MODE")
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	var version int
+	err = tx.Get(&version, "SELECT COALESCE(MAX(version), 0)+1 FROM timetable_versions")
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	timetableVersion.Version = version
+	timetableVersion.Status = TIMETABLE_VERSION_DRAFT
+	_, err = tx.NamedExec(
+		"INSERT INTO timetable_versions (version, name, status, created_by, proton_config_version) VALUES (:version, :name, :status, :created_by, :proton_config_version)",
+		timetableVersion)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	for i := 0; i < len(meetings); i++ {
+		meeting := meetings[i]
+		meeting.IsBeta = true
+		meeting.TimetableVersion = &version
+		_, err = tx.NamedExec(`
+		INSERT INTO meetings (meeting_name, teacher_id, subject_id, hour, date, is_mandatory, url, details, is_grading, is_written_assessment, is_test, is_substitution, is_beta, location, is_correction_test, proton_config_version, timetable_version)
+			VALUES (:meeting_name, :teacher_id, :subject_id, :hour, :date, :is_mandatory, :url, :details, :is_grading, :is_written_assessment, :is_test, :is_substitution, :is_beta, :location, :is_correction_test, :proton_config_version, :timetable_version)
+		`, meeting)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	return version, tx.Commit()
+}
+
+// PublishTimetableVersion objavi osnutek urnika. Nova različica velja od danes naprej: njena pretekla srečanja se izbrišejo,
+// prihodnja srečanja prejšnje aktivne različice pa se skrijejo (postanejo beta), razen tistih, na katera so že vezani podatki.
+func (db *sqlImpl) PublishTimetableVersion(version int) error {
+	tx, err := db.db.Beginx()
+	if err != nil {
+		return err
+	}
+	var previous []int
+	err = tx.Select(&previous, "SELECT version FROM timetable_versions WHERE status=$1", TIMETABLE_VERSION_ACTIVE)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM meetings WHERE timetable_version=$1 AND to_date(date, 'DD-MM-YYYY') < CURRENT_DATE", version)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec(
+		"UPDATE meetings SET is_beta=true WHERE timetable_version IN (SELECT version FROM timetable_versions WHERE status=$1) AND to_date(date, 'DD-MM-YYYY') >= CURRENT_DATE AND NOT "+meetingHasAttachments,
+		TIMETABLE_VERSION_ACTIVE)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec("UPDATE timetable_versions SET status=$1 WHERE status=$2", TIMETABLE_VERSION_SUPERSEDED, TIMETABLE_VERSION_ACTIVE)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec("UPDATE meetings SET is_beta=false WHERE timetable_version=$1", version)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	var previousVersion *int
+	if len(previous) != 0 {
+		previousVersion = &previous[len(previous)-1]
+	}
+	_, err = tx.Exec("UPDATE timetable_versions SET status=$1, previous_version=$2, published_at=now() WHERE version=$3", TIMETABLE_VERSION_ACTIVE, previousVersion, version)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// RollbackTimetableVersion prekliče objavljeno različico urnika in ponovno objavi prejšnjo.
+// Srečanja preklicane različice, na katera so že vezani izostanki, opombe, ocene ali domače naloge, ostanejo nespremenjena.
+func (db *sqlImpl) RollbackTimetableVersion(timetableVersion TimetableVersion) error {
+	tx, err := db.db.Beginx()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM meetings WHERE timetable_version=$1 AND NOT "+meetingHasAttachments, timetableVersion.Version)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec("UPDATE timetable_versions SET status=$1 WHERE version=$2", TIMETABLE_VERSION_ROLLED_BACK, timetableVersion.Version)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if timetableVersion.PreviousVersion != nil {
+		_, err = tx.Exec("UPDATE meetings SET is_beta=false WHERE timetable_version=$1", *timetableVersion.PreviousVersion)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		_, err = tx.Exec("UPDATE timetable_versions SET status=$1 WHERE version=$2", TIMETABLE_VERSION_ACTIVE, *timetableVersion.PreviousVersion)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteDraftTimetableVersion izbriše neobjavljen osnutek urnika skupaj z njegovimi srečanji.
+func (db *sqlImpl) DeleteDraftTimetableVersion(version int) error {
+	tx, err := db.db.Beginx()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM meetings WHERE timetable_version IN (SELECT version FROM timetable_versions WHERE version=$1 AND status=$2)", version, TIMETABLE_VERSION_DRAFT)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM timetable_versions WHERE version=$1 AND status=$2", version, TIMETABLE_VERSION_DRAFT)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
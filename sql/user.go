@@ -53,6 +53,11 @@ func (db *sqlImpl) GetStudents() (message []User, err error) {
 	return message, err
 }
 
+func (db *sqlImpl) GetParents() (parents []User, err error) {
+	err = db.db.Select(&parents, "SELECT * FROM users WHERE role='parent' ORDER BY id ASC")
+	return parents, err
+}
+
 func (db *sqlImpl) GetUserByEmail(email string) (user User, err error) {
 	err = db.db.Get(&user, "SELECT * FROM users WHERE email=$1", email)
 	return user, err
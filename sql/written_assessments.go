@@ -0,0 +1,79 @@
+package sql
+
+const WRITTEN_ASSESSMENT_MEETING = "meeting"
+const WRITTEN_ASSESSMENT_GRADING_TERM = "grading_term"
+
+// WrittenAssessment je napovedano pisno ocenjevanje ali preverjanje znanja. Izvira iz srečanja ali iz roka
+// pisnega ocenjevanja. Datum je vedno v obliki DD-MM-YYYY.
+type WrittenAssessment struct {
+	Source              string `db:"source"`
+	ID                  string `db:"id"`
+	SubjectID           string `db:"subject_id"`
+	Name                string `db:"name"`
+	Date                string `db:"date"`
+	Hour                int    `db:"hour"`
+	IsWrittenAssessment bool   `db:"is_written_assessment"`
+	IsTest              bool   `db:"is_test"`
+	IsCorrectionTest    bool   `db:"is_correction_test"`
+}
+
+// WrittenAssessmentOverride beleži, da je vodstvo šole dovolilo pisno ocenjevanje kljub preseženim omejitvam.
+type WrittenAssessmentOverride struct {
+	ID        string
+	SubjectID string `db:"subject_id"`
+	Date      string
+	Hour      int
+	Reason    string
+	CreatedBy string `db:"created_by"`
+
+	CreatedAt string `db:"created_at"`
+	UpdatedAt string `db:"updated_at"`
+}
+
+// GetWrittenAssessmentsBetween vrne pisna ocenjevanja in preverjanja znanja med start in end (DD-MM-YYYY), vključno z
+// obema datumoma. Roki ocenjevanja imajo datum shranjen kot YYYY-MM-DD, pisna so ocenjevanja vrste 1.
+func (db *sqlImpl) GetWrittenAssessmentsBetween(start string, end string) (assessments []WrittenAssessment, err error) {
+	err = db.db.Select(
+		&assessments,
+		`SELECT * FROM (
+			SELECT '`+WRITTEN_ASSESSMENT_MEETING+`' AS source, id, subject_id, meeting_name AS name, date, hour,
+				is_written_assessment, is_test, is_correction_test
+			FROM meetings
+			WHERE is_beta=false AND (is_written_assessment OR is_test)
+				AND to_date(date, 'DD-MM-YYYY') BETWEEN to_date($1, 'DD-MM-YYYY') AND to_date($2, 'DD-MM-YYYY')
+			UNION ALL
+			SELECT '`+WRITTEN_ASSESSMENT_GRADING_TERM+`' AS source, grading_terms.id, gradings.subject_id, grading_terms.name,
+				to_char(to_date(grading_terms.date, 'YYYY-MM-DD'), 'DD-MM-YYYY') AS date, grading_terms.hour,
+				true AS is_written_assessment, false AS is_test, grading_terms.term > 1 AS is_correction_test
+			FROM grading_terms JOIN gradings ON gradings.id=grading_terms.grading_id
+			WHERE gradings.grading_type=1
+				AND to_date(grading_terms.date, 'YYYY-MM-DD') BETWEEN to_date($1, 'DD-MM-YYYY') AND to_date($2, 'DD-MM-YYYY')
+		) assessments
+		ORDER BY to_date(assessments.date, 'DD-MM-YYYY') ASC, assessments.hour ASC`,
+		start, end,
+	)
+	if assessments == nil {
+		assessments = make([]WrittenAssessment, 0)
+	}
+	return assessments, err
+}
+
+func (db *sqlImpl) GetWrittenAssessmentOverridesBetween(start string, end string) (overrides []WrittenAssessmentOverride, err error) {
+	err = db.db.Select(
+		&overrides,
+		`SELECT * FROM written_assessment_overrides
+		WHERE to_date(date, 'DD-MM-YYYY') BETWEEN to_date($1, 'DD-MM-YYYY') AND to_date($2, 'DD-MM-YYYY')`,
+		start, end,
+	)
+	if overrides == nil {
+		overrides = make([]WrittenAssessmentOverride, 0)
+	}
+	return overrides, err
+}
+
+func (db *sqlImpl) InsertWrittenAssessmentOverride(override WrittenAssessmentOverride) error {
+	_, err := db.db.NamedExec(
+		"INSERT INTO written_assessment_overrides (subject_id, date, hour, reason, created_by) VALUES (:subject_id, :date, :hour, :reason, :created_by)",
+		override)
+	return err
+}